@@ -18,8 +18,16 @@ type Config struct {
 	Database            DatabaseConfig            `mapstructure:"database"`
 	DatabaseConnections DatabaseConnectionsConfig `mapstructure:"database_connections"`
 	Redis               RedisConfig               `mapstructure:"redis"`
+	TokenStore          TokenStoreConfig          `mapstructure:"token_store"`
 	JWT                 JWTConfig                 `mapstructure:"jwt"`
 	RateLimit           RateLimitConfig           `mapstructure:"ratelimit"`
+	LoginProtection     LoginProtectionConfig     `mapstructure:"login_protection"`
+	PasswordHashing     PasswordHashingConfig     `mapstructure:"password_hashing"`
+	Validation          ValidationConfig          `mapstructure:"validation"`
+	Verification        VerificationConfig        `mapstructure:"verification"`
+	AccountDeletion     AccountDeletionConfig     `mapstructure:"account_deletion"`
+	OIDC                OIDCConfig                `mapstructure:"oidc"`
+	Admin               AdminConfig               `mapstructure:"admin"`
 	Email               EmailConfig               `mapstructure:"email"`
 	Tracing             Tracing                   `mapstructure:"tracing"`
 }
@@ -30,6 +38,7 @@ type EnvironmentConfig struct {
 
 type ServerConfig struct {
 	Port         string `mapstructure:"port"`
+	PublicURL    string `mapstructure:"public_url"` // base of the links sent in emails
 	CookieSecure bool   `mapstructure:"cookiesecure"`
 	ReadTimeout  int    `mapstructure:"read_timeout"`
 	WriteTimeout int    `mapstructure:"write_timeout"`
@@ -59,7 +68,21 @@ type RedisConfig struct {
 }
 
 type JWTConfig struct {
-	SecretKey string `mapstructure:"secretkey"`
+	SecretKey       string        `mapstructure:"secretkey"`
+	AccessTokenTTL  time.Duration `mapstructure:"access_token_ttl"`
+	RefreshTokenTTL time.Duration `mapstructure:"refresh_token_ttl"`
+
+	// With no keys configured, tokens are signed with SecretKey (HS256).
+	SigningKeyID string         `mapstructure:"signing_key_id"`
+	Keys         []JWTKeyConfig `mapstructure:"keys"`
+	AllowHMAC    bool           `mapstructure:"allow_hmac"` // keep accepting HS256 tokens while migrating
+}
+
+type JWTKeyConfig struct {
+	ID             string `mapstructure:"id"`
+	Algorithm      string `mapstructure:"algorithm"` // RS256 or EdDSA
+	PrivateKeyFile string `mapstructure:"private_key_file"`
+	PublicKeyFile  string `mapstructure:"public_key_file"`
 }
 
 type RateLimitConfig struct {
@@ -67,12 +90,121 @@ type RateLimitConfig struct {
 	Window      time.Duration `mapstructure:"window"`
 }
 
+type LoginProtectionConfig struct {
+	FailureWindow   time.Duration `mapstructure:"failure_window"`
+	BackoffAfter    int           `mapstructure:"backoff_after"`
+	IPBackoffAfter  int           `mapstructure:"ip_backoff_after"`
+	BackoffBase     time.Duration `mapstructure:"backoff_base"`
+	BackoffMax      time.Duration `mapstructure:"backoff_max"`
+	LockoutAfter    int           `mapstructure:"lockout_after"`
+	LockoutDuration time.Duration `mapstructure:"lockout_duration"`
+}
+
+type VerificationConfig struct {
+	TokenTTL     time.Duration `mapstructure:"token_ttl"`
+	ResendLimit  int           `mapstructure:"resend_limit"` // per address and window
+	ResendWindow time.Duration `mapstructure:"resend_window"`
+}
+
+type TokenStoreConfig struct {
+	Driver        string        `mapstructure:"driver"`         // redis, postgres or memory (single instance only)
+	SweepInterval time.Duration `mapstructure:"sweep_interval"` // how often expired entries are dropped; not used by redis
+}
+
+type AccountDeletionConfig struct {
+	GracePeriod   time.Duration `mapstructure:"grace_period"`   // time to change one's mind before the account is anonymised
+	PurgeInterval time.Duration `mapstructure:"purge_interval"` // how often due accounts are looked for
+}
+
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig `mapstructure:"providers"`
+}
+
+type OIDCProviderConfig struct {
+	Name         string   `mapstructure:"name"` // used in the login and callback URLs
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	RedirectURL  string   `mapstructure:"redirect_url"` // must point at /api/auth/oidc/<name>/callback
+	Scopes       []string `mapstructure:"scopes"`       // defaults to openid, email, profile
+
+	// AutoProvision creates an account for an unknown subject with a verified email.
+	AutoProvision bool `mapstructure:"auto_provision"`
+	// LinkByEmail attaches an unknown subject to the verified account with the same verified email.
+	LinkByEmail bool `mapstructure:"link_by_email"`
+}
+
+type PasswordHashingConfig struct {
+	Algorithm  string       `mapstructure:"algorithm"` // argon2id or bcrypt
+	BcryptCost int          `mapstructure:"bcrypt_cost"`
+	Argon2     Argon2Config `mapstructure:"argon2"`
+}
+
+type Argon2Config struct {
+	Memory      uint32 `mapstructure:"memory"` // KiB
+	Iterations  uint32 `mapstructure:"iterations"`
+	Parallelism uint8  `mapstructure:"parallelism"`
+	SaltLength  uint32 `mapstructure:"salt_length"`
+	KeyLength   uint32 `mapstructure:"key_length"`
+}
+
+type ValidationConfig struct {
+	Username UsernamePolicyConfig `mapstructure:"username"`
+	Email    EmailPolicyConfig    `mapstructure:"email"`
+	Password PasswordPolicyConfig `mapstructure:"password"`
+}
+
+type UsernamePolicyConfig struct {
+	MinLength int      `mapstructure:"min_length"`
+	MaxLength int      `mapstructure:"max_length"`
+	Charset   string   `mapstructure:"charset"`  // allowed characters, written as the inside of a regexp [...] class
+	Reserved  []string `mapstructure:"reserved"` // compared case-insensitively
+}
+
+type EmailPolicyConfig struct {
+	MaxLength int `mapstructure:"max_length"`
+}
+
+type PasswordPolicyConfig struct {
+	MinLength     int  `mapstructure:"min_length"`
+	MaxLength     int  `mapstructure:"max_length"`
+	RequireUpper  bool `mapstructure:"require_upper"`
+	RequireLower  bool `mapstructure:"require_lower"`
+	RequireDigit  bool `mapstructure:"require_digit"`
+	RequireSymbol bool `mapstructure:"require_symbol"`
+	// BannedListFile adds one password per line to the built-in banned list.
+	BannedListFile string `mapstructure:"banned_list_file"`
+}
+
+type AdminConfig struct {
+	// Usernames are promoted to the admin role at startup.
+	Usernames []string `mapstructure:"usernames"`
+}
+
 type EmailConfig struct {
 	SMTHost  string `mapstructure:"smtHost"`
 	SMTPort  string `mapstructure:"smtPort"`
 	Username string `mapstructure:"username"`
 	Password string `mapstructure:"password"`
 	From     string `mapstructure:"from"`
+
+	// TemplatesDir overrides the built-in templates file by file, optionally
+	// per locale in <dir>/<locale>/.
+	TemplatesDir string `mapstructure:"templates_dir"`
+	Locale       string `mapstructure:"locale"`
+
+	Transport   string            `mapstructure:"transport"`    // smtp, file (Maildir, for development) or memory
+	MaildirPath string            `mapstructure:"maildir_path"` // used by the file transport
+	Outbox      EmailOutboxConfig `mapstructure:"outbox"`
+}
+
+type EmailOutboxConfig struct {
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	MaxAttempts  int           `mapstructure:"max_attempts"` // after that the email is marked dead
+	RetryBase    time.Duration `mapstructure:"retry_base"`   // doubled after every failed attempt
+	RetryMax     time.Duration `mapstructure:"retry_max"`
+	Retention    time.Duration `mapstructure:"retention"` // sent and dead emails are deleted after that
 }
 
 type Tracing struct {
@@ -96,13 +228,51 @@ func LoadConfig() (config Config, err error) {
 
 	viper.SetDefault("environment.current", "development")
 	viper.SetDefault("server.port", "8080")
+	viper.SetDefault("server.public_url", "http://localhost:8080")
 	viper.SetDefault("database.path", "./burgers.db")
+	viper.SetDefault("email.locale", "en")
+	viper.SetDefault("email.transport", "smtp")
+	viper.SetDefault("email.maildir_path", "maildir")
+	viper.SetDefault("email.outbox.poll_interval", 5*time.Second)
+	viper.SetDefault("email.outbox.batch_size", 20)
+	viper.SetDefault("email.outbox.max_attempts", 8)
+	viper.SetDefault("email.outbox.retry_base", 30*time.Second)
+	viper.SetDefault("email.outbox.retry_max", time.Hour)
 	viper.SetDefault("redis.addr", "localhost:6379")
 	viper.SetDefault("redis.password", "")
 	viper.SetDefault("redis.db", 0)
+	viper.SetDefault("token_store.driver", "redis")
+	viper.SetDefault("token_store.sweep_interval", 10*time.Minute)
 	viper.SetDefault("jwt.secretkey", "your_default_secret_change_in_production")
+	viper.SetDefault("jwt.access_token_ttl", time.Hour)
+	viper.SetDefault("jwt.refresh_token_ttl", 30*24*time.Hour)
 	viper.SetDefault("ratelimit.maxrequests", 100)
 	viper.SetDefault("ratelimit.window", time.Minute)
+	viper.SetDefault("verification.token_ttl", 24*time.Hour)
+	viper.SetDefault("verification.resend_limit", 3)
+	viper.SetDefault("verification.resend_window", time.Hour)
+	viper.SetDefault("account_deletion.grace_period", 7*24*time.Hour)
+	viper.SetDefault("account_deletion.purge_interval", time.Hour)
+	viper.SetDefault("password_hashing.algorithm", "argon2id")
+	viper.SetDefault("password_hashing.bcrypt_cost", 10)
+	viper.SetDefault("password_hashing.argon2.memory", 64*1024)
+	viper.SetDefault("password_hashing.argon2.iterations", 3)
+	viper.SetDefault("password_hashing.argon2.parallelism", 2)
+	viper.SetDefault("password_hashing.argon2.salt_length", 16)
+	viper.SetDefault("password_hashing.argon2.key_length", 32)
+	viper.SetDefault("validation.username.min_length", 3)
+	viper.SetDefault("validation.username.max_length", 32)
+	viper.SetDefault("validation.username.charset", "a-zA-Z0-9._-")
+	viper.SetDefault("validation.email.max_length", 254)
+	viper.SetDefault("validation.password.min_length", 8)
+	viper.SetDefault("validation.password.max_length", 128)
+	viper.SetDefault("login_protection.failure_window", 15*time.Minute)
+	viper.SetDefault("login_protection.backoff_after", 3)
+	viper.SetDefault("login_protection.ip_backoff_after", 10)
+	viper.SetDefault("login_protection.backoff_base", time.Second)
+	viper.SetDefault("login_protection.backoff_max", 5*time.Minute)
+	viper.SetDefault("login_protection.lockout_after", 10)
+	viper.SetDefault("login_protection.lockout_duration", 15*time.Minute)
 
 	err = viper.Unmarshal(&config)
 	if err != nil {
@@ -8,12 +8,18 @@ package main
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"massager/app/config"
 	"massager/internal/adapters"
 	"massager/internal/handlers"
+	"massager/internal/models"
+	"massager/internal/ports"
 	"massager/internal/repositories"
 	"massager/internal/services"
+	"massager/internal/services/jwtkeys"
+	"massager/internal/services/oidc"
+	"massager/internal/services/validation"
 	websocket "massager/internal/websocet"
 	"net/http"
 	"os"
@@ -36,6 +42,7 @@ import (
 
 type Container struct {
 	isShuttingDown bool
+	stopWorkers    context.CancelFunc
 
 	GinEngine   *gin.Engine
 	Config      *config.Config
@@ -56,6 +63,8 @@ type Container struct {
 	ChatService  *services.ChatService
 
 	AuthHandler      *handlers.AuthHandler
+	AdminHandler     *handlers.AdminHandler
+	UserHandler      *handlers.UserHandler
 	ChatHandler      *handlers.ChatHandler
 	WebSocketHandler *handlers.WebsocetHandler
 
@@ -85,7 +94,6 @@ func (c *Container) initCore() error {
 	c.Config = &cfg
 
 	c.Logger = c.initLogger()
-	c.Redis = c.initRedis()
 
 	if err = c.initTracing(); err != nil || c.Tracer == nil {
 		return errors.New("Tracer initialization failed")
@@ -97,19 +105,75 @@ func (c *Container) initCore() error {
 		return err
 	}
 
-	var emailService = services.NewEmailService(cfg.Email, c.Logger)
+	mailTransport, err := c.initMailTransport()
+	if err != nil {
+		c.Logger.Error("Mail transport initialize error", "error", err.Error())
+		return err
+	}
+
+	var emailService = services.NewEmailService(cfg.Email, cfg.Server.PublicURL, c.Repository.Outbox, mailTransport, c.Logger)
 	var chatService = services.NewChatService(c.Repository.Chat, c.Repository.Message, c.Repository.User, c.Logger, c.Tracer)
 
 	c.WsHub = websocket.NewHub(chatService, c.Logger)
 	go c.WsHub.Run()
 
 	chatService.SetWSHub(c.WsHub)
+	chatService.SetInvites(c.Repository.Invite)
 
 	c.RateLimiter = NewRateLimiter(cfg.RateLimit.MaxRequests, cfg.RateLimit.Window)
 
-	c.AuthService = services.NewAuthService(c.Repository.User, emailService, &services.BcryptHasher{}, adapters.NewRedisTokenRepository(c.Redis), []byte(cfg.JWT.SecretKey), c.Logger, c.Tracer)
+	var passwordHasher = services.NewPasswordHasher(cfg.PasswordHashing)
+
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	c.stopWorkers = stopWorkers
+	go emailService.RunOutboxWorker(workersCtx)
+
+	stores, err := c.initTokenStores(workersCtx)
+	if err != nil {
+		c.Logger.Error("Token store initialize error", "error", err.Error())
+		return err
+	}
+
+	c.AuthService = services.NewAuthService(c.Repository.User, emailService, passwordHasher, stores.tokens,
+		stores.refreshTokens, c.Repository.Session, []byte(cfg.JWT.SecretKey), c.Logger, c.Tracer)
+	c.AuthService.SetTokenTTL(cfg.JWT.AccessTokenTTL, cfg.JWT.RefreshTokenTTL)
+
+	validationPolicy, err := validation.New(cfg.Validation)
+	if err != nil {
+		c.Logger.Error("Validation policy initialize error", "error", err.Error())
+		return err
+	}
+	c.AuthService.SetValidationPolicy(validationPolicy)
+
+	jwtKeys, err := jwtkeys.Load(cfg.JWT)
+	if err != nil {
+		c.Logger.Error("JWT keys initialize error", "error", err.Error())
+		return err
+	}
+	c.AuthService.SetKeySet(jwtKeys)
+	c.AuthService.SetWSHub(c.WsHub)
+	c.AuthService.SetLoginGuard(services.NewLoginGuard(stores.attempts, cfg.LoginProtection, c.Logger))
+	c.AuthService.SetVerification(stores.attempts, cfg.Verification)
+	c.AuthService.BootstrapAdmins(context.Background(), cfg.Admin.Usernames)
+
+	var oidcProviders []*oidc.Provider
+	for _, providerConfig := range cfg.OIDC.Providers {
+		oidcProviders = append(oidcProviders, oidc.NewProvider(providerConfig, nil))
+	}
+	c.AuthService.SetOIDC(oidcProviders, stores.oidcStates)
+	c.AuthService.SetAPIKeys(c.Repository.APIKey)
+
+	var userService = services.NewUserService(c.Repository.User, c.Repository.Chat, c.Repository.Message, emailService, passwordHasher,
+		c.AuthService, c.Logger, c.Tracer)
+	userService.SetAccountDeletion(cfg.AccountDeletion)
+	userService.SetValidationPolicy(validationPolicy)
+	userService.SetChatService(chatService)
+
+	go userService.RunDeletionWorker(workersCtx, cfg.AccountDeletion.PurgeInterval)
 
 	c.AuthHandler = handlers.NewAuthHandler(c.AuthService, c.Logger, c.Tracer)
+	c.AdminHandler = handlers.NewAdminHandler(c.AuthService, c.Logger, c.Tracer)
+	c.UserHandler = handlers.NewUserHandler(userService, c.Logger, c.Tracer)
 	c.ChatHandler = handlers.NewChatHandler(chatService, c.Logger, c.Tracer)
 
 	c.WebSocketHandler = handlers.NewWebSocketHandler(c.WsHub, c.AuthService, c.Logger, c.Tracer)
@@ -199,15 +263,20 @@ func (c *Container) initHealthRoutes(eng *gin.Engine) {
 			return
 		}
 
-		if err := c.Redis.Ping().Err(); err != nil {
-			health["redis"] = "unhealthy"
-			health["status"] = "degraded"
-			ctx.JSON(503, health)
-			return
+		health["database"] = "healthy"
+
+		// Redis is only dialled for the redis token store; the postgres
+		// store is covered by the database check and memory cannot fail.
+		if c.Redis != nil {
+			if err := c.Redis.Ping().Err(); err != nil {
+				health["redis"] = "unhealthy"
+				health["status"] = "degraded"
+				ctx.JSON(503, health)
+				return
+			}
+			health["redis"] = "healthy"
 		}
 
-		health["database"] = "healthy"
-		health["redis"] = "healthy"
 		ctx.JSON(200, health)
 	})
 
@@ -232,6 +301,8 @@ func (c *Container) initGinEngine() *gin.Engine {
 	eng.Static("/static", "./static")
 	eng.LoadHTMLGlob("static/*.html")
 
+	eng.GET("/.well-known/jwks.json", c.AuthHandler.JWKS)
+
 	api := eng.Group("/api")
 
 	api.Use(RateLimitMiddleware(c.RateLimiter))
@@ -240,19 +311,93 @@ func (c *Container) initGinEngine() *gin.Engine {
 		{
 			authGroup.POST("/register", c.AuthHandler.Register)
 			authGroup.POST("/login", c.AuthHandler.Login)
-			authGroup.POST("/logout", c.AuthHandler.Logout)
+			authGroup.POST("/login/mfa", c.AuthHandler.LoginMFA)
+			authGroup.POST("/refresh", c.AuthHandler.Refresh)
+			authGroup.POST("/logout", c.AuthHandler.AuthMiddleware(), c.AuthHandler.Logout)
+			authGroup.POST("/logout-all", c.AuthHandler.AuthMiddleware(), c.AuthHandler.LogoutAll)
+			authGroup.GET("/sessions", c.AuthHandler.AuthMiddleware(), c.AuthHandler.GetSessions)
+			authGroup.DELETE("/sessions/:id", c.AuthHandler.AuthMiddleware(), c.AuthHandler.RevokeSession)
+			authGroup.POST("/mfa/enroll", c.AuthHandler.AuthMiddleware(), c.AuthHandler.EnrollMFA)
+			authGroup.POST("/mfa/confirm", c.AuthHandler.AuthMiddleware(), c.AuthHandler.ConfirmMFA)
+			authGroup.POST("/mfa/disable", c.AuthHandler.AuthMiddleware(), c.AuthHandler.DisableMFA)
+			authGroup.POST("/password/forgot", c.AuthHandler.ForgotPassword)
+			authGroup.POST("/password/reset", c.AuthHandler.ResetPassword)
 			authGroup.GET("/verify-email", c.AuthHandler.VerifyEmail)
-			authGroup.GET("/verification-token", c.AuthHandler.GetVerificationToken)
+			authGroup.POST("/verification/resend", c.AuthHandler.ResendVerification)
 			authGroup.GET("/verification-status", c.AuthHandler.GetVerificationStatus)
+			authGroup.GET("/oidc/providers", c.AuthHandler.OIDCProviders)
+			authGroup.GET("/oidc/:provider/login", c.AuthHandler.OIDCLogin)
+			authGroup.GET("/oidc/:provider/callback", c.AuthHandler.OIDCCallback)
 		}
 
 		chatsGroup := api.Group("/chats")
-		chatsGroup.Use(c.AuthHandler.AuthMiddleware())
 		{
-			chatsGroup.POST("", c.ChatHandler.CreateChat)
-			chatsGroup.GET("", c.ChatHandler.GetUserChats)
-			chatsGroup.GET("/:chatId/messages", c.ChatHandler.GetChatMessages)
-			chatsGroup.DELETE("/:chatId", c.ChatHandler.DeleteChat)
+			chatsGroup.POST("", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.CreateChat)
+			chatsGroup.GET("", c.AuthHandler.AuthMiddleware(models.ScopeChatsRead), c.ChatHandler.GetUserChats)
+			chatsGroup.GET("/:chatId/messages", c.AuthHandler.AuthMiddleware(models.ScopeMessagesRead), c.ChatHandler.GetChatMessages)
+			chatsGroup.PUT("/:chatId/messages/:messageId/pin", c.AuthHandler.AuthMiddleware(models.ScopeMessagesWrite), c.ChatHandler.PinMessage)
+			chatsGroup.DELETE("/:chatId/messages/:messageId/pin", c.AuthHandler.AuthMiddleware(models.ScopeMessagesWrite), c.ChatHandler.UnpinMessage)
+			chatsGroup.PATCH("/:chatId", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.UpdateChat)
+			chatsGroup.DELETE("/:chatId", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.DeleteChat)
+			chatsGroup.POST("/:chatId/members", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.AddMembers)
+			chatsGroup.DELETE("/:chatId/members/:username", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.RemoveMember)
+			chatsGroup.POST("/:chatId/leave", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.LeaveChat)
+			chatsGroup.PUT("/:chatId/members/:username/role", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.SetMemberRole)
+			chatsGroup.POST("/:chatId/transfer", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.TransferOwnership)
+			chatsGroup.POST("/:chatId/invites", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.CreateInvite)
+			chatsGroup.GET("/:chatId/invites", c.AuthHandler.AuthMiddleware(models.ScopeChatsRead), c.ChatHandler.GetInvites)
+			chatsGroup.DELETE("/:chatId/invites/:inviteId", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.RevokeInvite)
+		}
+
+		invitesGroup := api.Group("/invites")
+		{
+			invitesGroup.GET("/:token", c.AuthHandler.AuthMiddleware(models.ScopeChatsRead), c.ChatHandler.PreviewInvite)
+			invitesGroup.POST("/:token/join", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.JoinByInvite)
+		}
+
+		api.POST("/dms/:username", c.AuthHandler.AuthMiddleware(models.ScopeChatsWrite), c.ChatHandler.OpenDirectChat)
+
+		usersGroup := api.Group("/users")
+		{
+			usersGroup.GET("/email/confirm", c.UserHandler.ConfirmEmailChange)
+			usersGroup.GET("/me", c.AuthHandler.AuthMiddleware(models.ScopeUsersRead), c.UserHandler.GetMe)
+			usersGroup.PATCH("/me", c.AuthHandler.AuthMiddleware(), c.UserHandler.UpdateMe)
+			usersGroup.DELETE("/me", c.AuthHandler.AuthMiddleware(), c.UserHandler.DeleteMe)
+			usersGroup.POST("/me/deletion/cancel", c.AuthHandler.AuthMiddleware(), c.UserHandler.CancelDeletion)
+			usersGroup.GET("/me/export", c.AuthHandler.AuthMiddleware(), c.UserHandler.ExportMe)
+			usersGroup.POST("/me/password", c.AuthHandler.AuthMiddleware(), c.UserHandler.ChangePassword)
+			usersGroup.POST("/me/email", c.AuthHandler.AuthMiddleware(), c.UserHandler.ChangeEmail)
+			usersGroup.GET("/search", c.AuthHandler.AuthMiddleware(models.ScopeUsersRead), c.UserHandler.SearchUsers)
+			usersGroup.GET("/:username", c.AuthHandler.AuthMiddleware(models.ScopeUsersRead), c.UserHandler.GetUser)
+		}
+
+		botsGroup := api.Group("/bots")
+		botsGroup.Use(c.AuthHandler.AuthMiddleware())
+		{
+			botsGroup.POST("", c.AuthHandler.CreateBot)
+			botsGroup.GET("", c.AuthHandler.GetBots)
+		}
+
+		keysGroup := api.Group("/keys")
+		keysGroup.Use(c.AuthHandler.AuthMiddleware())
+		{
+			keysGroup.POST("", c.AuthHandler.CreateAPIKey)
+			keysGroup.GET("", c.AuthHandler.GetAPIKeys)
+			keysGroup.DELETE("/:id", c.AuthHandler.RevokeAPIKey)
+		}
+
+		adminGroup := api.Group("/admin")
+		adminGroup.Use(c.AuthHandler.AuthMiddleware(), c.AuthHandler.RequireRole(models.RoleModerator))
+		{
+			adminGroup.GET("/users", c.AdminHandler.ListUsers)
+			adminGroup.POST("/users/:username/unlock", c.AdminHandler.UnlockUser)
+			adminGroup.POST("/users/:username/ban", c.AdminHandler.BanUser)
+			adminGroup.POST("/users/:username/unban", c.AdminHandler.UnbanUser)
+
+			requireAdmin := c.AuthHandler.RequireRole(models.RoleAdmin)
+			adminGroup.PUT("/users/:username/role", requireAdmin, c.AdminHandler.SetUserRole)
+			adminGroup.POST("/users/:username/verify", requireAdmin, c.AdminHandler.VerifyEmail)
+			adminGroup.POST("/users/:username/reset-password", requireAdmin, c.AdminHandler.ResetPassword)
 		}
 
 		api.GET("/ws", c.WebSocketHandler.HandleWebSocket)
@@ -281,6 +426,72 @@ func (c *Container) initLogger() *slog.Logger {
 	return logger
 }
 
+// tokenStores hold the short-lived auth state: revoked access tokens,
+// refresh tokens, attempt counters and pending OpenID Connect logins.
+type tokenStores struct {
+	tokens        ports.TokenRepository
+	refreshTokens ports.RefreshTokenRepository
+	attempts      ports.AttemptRepository
+	oidcStates    ports.OIDCStateRepository
+}
+
+// initTokenStores picks where the short-lived auth state is kept. Redis is
+// only dialled for the redis driver; the postgres and memory stores drop
+// expired entries in the background.
+func (c *Container) initTokenStores(ctx context.Context) (*tokenStores, error) {
+	storeConfig := c.Config.TokenStore
+
+	switch storeConfig.Driver {
+	case "", "redis":
+		c.Redis = c.initRedis()
+		return &tokenStores{
+			tokens:        adapters.NewRedisTokenRepository(c.Redis),
+			refreshTokens: adapters.NewRedisRefreshTokenRepository(c.Redis),
+			attempts:      adapters.NewRedisAttemptRepository(c.Redis),
+			oidcStates:    adapters.NewRedisOIDCStateRepository(c.Redis),
+		}, nil
+	case "postgres":
+		go c.Repository.Token.RunSweeper(ctx, storeConfig.SweepInterval)
+		go c.Repository.RefreshToken.RunSweeper(ctx, storeConfig.SweepInterval)
+		go c.Repository.Attempt.RunSweeper(ctx, storeConfig.SweepInterval)
+		go c.Repository.OIDCState.RunSweeper(ctx, storeConfig.SweepInterval)
+		return &tokenStores{
+			tokens:        c.Repository.Token,
+			refreshTokens: c.Repository.RefreshToken,
+			attempts:      c.Repository.Attempt,
+			oidcStates:    c.Repository.OIDCState,
+		}, nil
+	case "memory":
+		tokens := adapters.NewMemoryTokenRepository()
+		refreshTokens := adapters.NewMemoryRefreshTokenRepository()
+		attempts := adapters.NewMemoryAttemptRepository()
+		oidcStates := adapters.NewMemoryOIDCStateRepository()
+		go tokens.RunSweeper(ctx, storeConfig.SweepInterval)
+		go refreshTokens.RunSweeper(ctx, storeConfig.SweepInterval)
+		go attempts.RunSweeper(ctx, storeConfig.SweepInterval)
+		go oidcStates.RunSweeper(ctx, storeConfig.SweepInterval)
+		return &tokenStores{tokens: tokens, refreshTokens: refreshTokens, attempts: attempts, oidcStates: oidcStates}, nil
+	default:
+		return nil, fmt.Errorf("unknown token store driver %q", storeConfig.Driver)
+	}
+}
+
+// initMailTransport picks how queued emails leave the server.
+func (c *Container) initMailTransport() (ports.MailTransport, error) {
+	emailConfig := c.Config.Email
+
+	switch emailConfig.Transport {
+	case "", "smtp":
+		return adapters.NewSMTPMailTransport(emailConfig), nil
+	case "file":
+		return adapters.NewFileMailTransport(emailConfig.From, emailConfig.MaildirPath)
+	case "memory":
+		return adapters.NewMemoryMailTransport(), nil
+	default:
+		return nil, fmt.Errorf("unknown email transport %q", emailConfig.Transport)
+	}
+}
+
 func (c *Container) initRedis() *redis.Client {
 	return redis.NewClient(&redis.Options{
 		Addr:     c.Config.Redis.Addr,
@@ -301,8 +512,14 @@ func (c *Container) initServer() *http.Server {
 func (c *Container) Close() error {
 	c.isShuttingDown = true
 
+	if c.stopWorkers != nil {
+		c.stopWorkers()
+	}
+
 	if c.Redis != nil {
-		return c.Redis.Close()
+		if err := c.Redis.Close(); err != nil {
+			c.Logger.Error("failed to close redis client", "error", err)
+		}
 	}
 
 	if c.Repository != nil {
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"massager/app/config"
+	"massager/internal/adapters"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainer_MemoryTokenStoreWithoutRedis(t *testing.T) {
+	c := &Container{
+		Config: &config.Config{TokenStore: config.TokenStoreConfig{Driver: "memory"}},
+		Logger: slog.Default(),
+	}
+	require.Empty(t, c.Config.Redis.Addr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stores, err := c.initTokenStores(ctx)
+	require.NoError(t, err)
+
+	assert.Nil(t, c.Redis)
+	assert.IsType(t, &adapters.MemoryTokenRepository{}, stores.tokens)
+	assert.IsType(t, &adapters.MemoryRefreshTokenRepository{}, stores.refreshTokens)
+	assert.IsType(t, &adapters.MemoryAttemptRepository{}, stores.attempts)
+	assert.IsType(t, &adapters.MemoryOIDCStateRepository{}, stores.oidcStates)
+
+	count, err := stores.attempts.Increment(ctx, "validuser", time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, c.Close())
+}
+
+func TestContainer_UnknownTokenStore(t *testing.T) {
+	c := &Container{Config: &config.Config{TokenStore: config.TokenStoreConfig{Driver: "etcd"}}}
+
+	_, err := c.initTokenStores(context.Background())
+	assert.Error(t, err)
+	assert.Nil(t, c.Redis)
+}
@@ -329,7 +329,7 @@ class ApiService {
     }
 
     async resendVerification(identifier) {
-        return this.request('/auth/resend-verification', {
+        return this.request('/auth/verification/resend', {
             method: 'POST',
             body: JSON.stringify(identifier)
         });
@@ -346,7 +346,7 @@ class ApiService {
     }
 
     async resendVerificationForUser(username) {
-        return this.request('/auth/resend-verification', {
+        return this.request('/auth/verification/resend', {
             method: 'POST',
             body: JSON.stringify({ username })
         });
@@ -519,7 +519,7 @@ class UIManager {
                 `Chat with ${chat.members?.filter(m => m !== currentUsername).join(', ') || 'others'}`;
             
             const lastMessageText = lastMessage ? 
-                `${lastMessage.sender}: ${this.truncateText(lastMessage.content, 30)}` : 
+                `${lastMessage.sender_display_name || lastMessage.sender}: ${this.truncateText(lastMessage.content, 30)}` : 
                 'No messages yet';
 
             const unreadCount = app.unreadMessages.get(chat.id) || 0;
@@ -582,7 +582,7 @@ class UIManager {
             return this.safeHTML`
                 <div class="message ${isOwn ? 'my-message' : 'other-message'}">
                     <div class="message-header">
-                        <span class="message-sender">${message.sender}</span>
+                        <span class="message-sender">${message.sender_display_name || message.sender}</span>
                         <span class="message-time">${timestamp}</span>
                     </div>
                     <div class="message-text">${message.content}</div>
@@ -601,7 +601,7 @@ class UIManager {
 
         const messageElem = contactElement.querySelector('p');
         const shortContent = this.truncateText(message.content, 30);
-        messageElem.textContent = `${message.sender}: ${shortContent}`;
+        messageElem.textContent = `${message.sender_display_name || message.sender}: ${shortContent}`;
     }
 
     // Modal management
@@ -1040,7 +1040,7 @@ class ChatManager {
             const messageHTML = this.app.uiManager.safeHTML`
                 <div class="message ${isOwn ? 'my-message' : 'other-message'}">
                     <div class="message-header">
-                        <span class="message-sender">${message.sender}</span>
+                        <span class="message-sender">${message.sender_display_name || message.sender}</span>
                         <span class="message-time">${currentTime}</span>
                     </div>
                     <div class="message-text">${message.content}</div>
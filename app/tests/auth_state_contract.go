@@ -0,0 +1,191 @@
+package tests
+
+import (
+	"context"
+	"massager/internal/models"
+	"massager/internal/ports"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// RefreshTokenRepositoryContract is the behaviour every
+// ports.RefreshTokenRepository has to show.
+func RefreshTokenRepositoryContract(t *testing.T, repository ports.RefreshTokenRepository) {
+	ctx := context.Background()
+
+	newToken := func(ttl time.Duration) models.RefreshToken {
+		now := time.Now()
+		return models.RefreshToken{
+			TokenHash: randomKey(),
+			FamilyID:  randomKey(),
+			Username:  "validuser",
+			IssuedAt:  now,
+			ExpiresAt: now.Add(ttl),
+		}
+	}
+
+	t.Run("Unknown token", func(t *testing.T) {
+		token, err := repository.GetRefreshToken(ctx, randomKey())
+		require.NoError(t, err)
+		assert.Nil(t, token)
+	})
+
+	t.Run("Saved token", func(t *testing.T) {
+		saved := newToken(time.Hour)
+		require.NoError(t, repository.SaveRefreshToken(ctx, saved))
+
+		token, err := repository.GetRefreshToken(ctx, saved.TokenHash)
+		require.NoError(t, err)
+		require.NotNil(t, token)
+		assert.Equal(t, saved.FamilyID, token.FamilyID)
+		assert.Equal(t, saved.Username, token.Username)
+		assert.False(t, token.Used)
+	})
+
+	t.Run("Token can be marked used once", func(t *testing.T) {
+		saved := newToken(time.Hour)
+		require.NoError(t, repository.SaveRefreshToken(ctx, saved))
+
+		first, err := repository.MarkRefreshTokenUsed(ctx, saved.TokenHash)
+		require.NoError(t, err)
+		assert.True(t, first)
+
+		second, err := repository.MarkRefreshTokenUsed(ctx, saved.TokenHash)
+		require.NoError(t, err)
+		assert.False(t, second)
+
+		token, err := repository.GetRefreshToken(ctx, saved.TokenHash)
+		require.NoError(t, err)
+		require.NotNil(t, token)
+		assert.True(t, token.Used)
+	})
+
+	t.Run("Token expires", func(t *testing.T) {
+		saved := newToken(100 * time.Millisecond)
+		require.NoError(t, repository.SaveRefreshToken(ctx, saved))
+
+		assert.Eventually(t, func() bool {
+			token, err := repository.GetRefreshToken(ctx, saved.TokenHash)
+			return err == nil && token == nil
+		}, 2*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Revoked family", func(t *testing.T) {
+		familyID := randomKey()
+
+		revoked, err := repository.IsFamilyRevoked(ctx, familyID)
+		require.NoError(t, err)
+		assert.False(t, revoked)
+
+		require.NoError(t, repository.RevokeFamily(ctx, familyID, time.Hour))
+
+		revoked, err = repository.IsFamilyRevoked(ctx, familyID)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+}
+
+// AttemptRepositoryContract is the behaviour every ports.AttemptRepository
+// has to show.
+func AttemptRepositoryContract(t *testing.T, repository ports.AttemptRepository) {
+	ctx := context.Background()
+
+	t.Run("Counter increments and resets", func(t *testing.T) {
+		key := randomKey()
+
+		for want := int64(1); want <= 3; want++ {
+			count, err := repository.Increment(ctx, key, time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, want, count)
+		}
+
+		require.NoError(t, repository.Reset(ctx, key))
+
+		count, err := repository.Increment(ctx, key, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Counter starts over after the window", func(t *testing.T) {
+		key := randomKey()
+		_, err := repository.Increment(ctx, key, 100*time.Millisecond)
+		require.NoError(t, err)
+		time.Sleep(200 * time.Millisecond)
+
+		count, err := repository.Increment(ctx, key, time.Hour)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+
+	t.Run("Not blocked", func(t *testing.T) {
+		until, err := repository.BlockedUntil(ctx, randomKey())
+		require.NoError(t, err)
+		assert.True(t, until.IsZero())
+	})
+
+	t.Run("Block and unblock", func(t *testing.T) {
+		key := randomKey()
+		blockedUntil := time.Now().Add(time.Hour)
+		require.NoError(t, repository.Block(ctx, key, blockedUntil))
+
+		until, err := repository.BlockedUntil(ctx, key)
+		require.NoError(t, err)
+		assert.Equal(t, blockedUntil.UnixMilli(), until.UnixMilli())
+
+		require.NoError(t, repository.Unblock(ctx, key))
+
+		until, err = repository.BlockedUntil(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, until.IsZero())
+	})
+
+	t.Run("Block expires", func(t *testing.T) {
+		key := randomKey()
+		require.NoError(t, repository.Block(ctx, key, time.Now().Add(100*time.Millisecond)))
+
+		assert.Eventually(t, func() bool {
+			until, err := repository.BlockedUntil(ctx, key)
+			return err == nil && until.IsZero()
+		}, 2*time.Second, 50*time.Millisecond)
+	})
+}
+
+// OIDCStateRepositoryContract is the behaviour every
+// ports.OIDCStateRepository has to show.
+func OIDCStateRepositoryContract(t *testing.T, repository ports.OIDCStateRepository) {
+	ctx := context.Background()
+	pending := models.OIDCState{Provider: "test", Nonce: "nonce", CodeVerifier: "verifier"}
+
+	t.Run("Unknown state", func(t *testing.T) {
+		state, err := repository.ConsumeOIDCState(ctx, randomKey())
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+
+	t.Run("State is consumed once", func(t *testing.T) {
+		stateHash := randomKey()
+		require.NoError(t, repository.SaveOIDCState(ctx, stateHash, pending, time.Hour))
+
+		state, err := repository.ConsumeOIDCState(ctx, stateHash)
+		require.NoError(t, err)
+		require.NotNil(t, state)
+		assert.Equal(t, pending, *state)
+
+		state, err = repository.ConsumeOIDCState(ctx, stateHash)
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+
+	t.Run("State expires", func(t *testing.T) {
+		stateHash := randomKey()
+		require.NoError(t, repository.SaveOIDCState(ctx, stateHash, pending, 100*time.Millisecond))
+		time.Sleep(200 * time.Millisecond)
+
+		state, err := repository.ConsumeOIDCState(ctx, stateHash)
+		require.NoError(t, err)
+		assert.Nil(t, state)
+	})
+}
@@ -9,6 +9,7 @@ import (
 	"massager/internal/models"
 	"net/http"
 	"net/http/httptest"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"go.opentelemetry.io/otel/trace"
@@ -27,6 +28,38 @@ type MockEmailService struct {
 	mock.Mock
 }
 
+type MockTokenRepository struct {
+	mock.Mock
+}
+
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+type MockAttemptRepository struct {
+	mock.Mock
+}
+
+type MockSessionRepository struct {
+	mock.Mock
+}
+
+type MockOIDCStateRepository struct {
+	mock.Mock
+}
+
+type MockAPIKeyRepository struct {
+	mock.Mock
+}
+
+type MockEmailOutboxRepository struct {
+	mock.Mock
+}
+
+type MockChatInviteRepository struct {
+	mock.Mock
+}
+
 func NoopTracer() trace.Tracer {
 	return noop.NewTracerProvider().Tracer("test-tracer")
 }
@@ -35,8 +68,8 @@ type MockChatRepository struct {
 	mock.Mock
 }
 
-func (m *MockChatRepository) CreateChat(ctx context.Context, name string, members []string) (int, error) {
-	args := m.Called(ctx, name, members)
+func (m *MockChatRepository) CreateChat(ctx context.Context, name, createdBy string, members []string) (int, error) {
+	args := m.Called(ctx, name, createdBy, members)
 	return args.Int(0), args.Error(1)
 }
 
@@ -50,11 +83,41 @@ func (m *MockChatRepository) GetChatByID(ctx context.Context, chatID int) (*mode
 	return args.Get(0).(*models.Chat), args.Error(1)
 }
 
+func (m *MockChatRepository) GetOrCreateDirectChat(ctx context.Context, username, other string) (int, bool, error) {
+	args := m.Called(ctx, username, other)
+	return args.Int(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockChatRepository) UpdateChat(ctx context.Context, chatID int, update models.ChatUpdate) error {
+	args := m.Called(ctx, chatID, update)
+	return args.Error(0)
+}
+
 func (m *MockChatRepository) DeleteChat(ctx context.Context, chatID int) error {
 	args := m.Called(ctx, chatID)
 	return args.Error(0)
 }
 
+func (m *MockChatRepository) AddMembers(ctx context.Context, chatID int, usernames []string) error {
+	args := m.Called(ctx, chatID, usernames)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) RemoveMember(ctx context.Context, chatID int, username string) (string, error) {
+	args := m.Called(ctx, chatID, username)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockChatRepository) SetMemberRole(ctx context.Context, chatID int, username string, role models.ChatRole) error {
+	args := m.Called(ctx, chatID, username, role)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) TransferOwnership(ctx context.Context, chatID int, newOwner string) error {
+	args := m.Called(ctx, chatID, newOwner)
+	return args.Error(0)
+}
+
 type MockMessageRepository struct {
 	mock.Mock
 }
@@ -69,6 +132,21 @@ func (m *MockMessageRepository) GetMessages(ctx context.Context, chatID, limit,
 	return args.Get(0).([]models.Message), args.Error(1)
 }
 
+func (m *MockMessageRepository) GetMessagesBySender(ctx context.Context, senderID string) ([]models.Message, error) {
+	args := m.Called(ctx, senderID)
+	return args.Get(0).([]models.Message), args.Error(1)
+}
+
+func (m *MockMessageRepository) CreateSystemMessage(ctx context.Context, actor, content string, chatID int) error {
+	args := m.Called(ctx, actor, content, chatID)
+	return args.Error(0)
+}
+
+func (m *MockMessageRepository) SetMessagePinned(ctx context.Context, chatID, messageID int, actor string, pinned bool) (bool, error) {
+	args := m.Called(ctx, chatID, messageID, actor, pinned)
+	return args.Bool(0), args.Error(1)
+}
+
 func (m *MockMessageRepository) DeleteMessagesByChatID(ctx context.Context, chatID int) error {
 	args := m.Called(ctx, chatID)
 	return args.Error(0)
@@ -93,6 +171,56 @@ func (m *MockEmailService) SendVerificationEmail(email, token string) error {
 	return args.Error(0)
 }
 
+func (m *MockEmailService) SendPasswordResetEmail(email, token string) error {
+	args := m.Called(email, token)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendAccountLockedEmail(email string, lockedUntil time.Time) error {
+	args := m.Called(email, lockedUntil)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendEmailChangeVerification(email, token string) error {
+	args := m.Called(email, token)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendEmailChangeNotice(oldEmail, newEmail string) error {
+	args := m.Called(oldEmail, newEmail)
+	return args.Error(0)
+}
+
+func (m *MockEmailService) SendAccountDeletionScheduled(email string, deleteAt time.Time) error {
+	args := m.Called(email, deleteAt)
+	return args.Error(0)
+}
+
+func (m *MockAttemptRepository) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	args := m.Called(ctx, key, window)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAttemptRepository) Reset(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *MockAttemptRepository) Block(ctx context.Context, key string, until time.Time) error {
+	args := m.Called(ctx, key, until)
+	return args.Error(0)
+}
+
+func (m *MockAttemptRepository) BlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	args := m.Called(ctx, key)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockAttemptRepository) Unblock(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
 func (m *MockHasher) GenerateFromPassword(password []byte, cost int) ([]byte, error) {
 	args := m.Called(password, cost)
 	return args.Get(0).([]byte), args.Error(1)
@@ -107,6 +235,10 @@ func (m *MockHasher) DefaultCost() int {
 	return m.Called().Int(0)
 }
 
+func (m *MockHasher) NeedsRehash(storedPassword []byte) bool {
+	return m.Called(storedPassword).Bool(0)
+}
+
 func (m *MockRepository) GetUserByName(ctx context.Context, name string) (*models.User, error) {
 	args := m.Called(ctx, name)
 	return args.Get(0).(*models.User), args.Error(1)
@@ -117,8 +249,18 @@ func (m *MockRepository) GetUserByVerifyToken(ctx context.Context, name string)
 	return args.Get(0).(*models.User), args.Error(1)
 }
 
-func (m *MockRepository) CreateUser(ctx context.Context, name, email, password, token string) error {
-	args := m.Called(ctx, name, email, password, token)
+func (m *MockRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	args := m.Called(ctx, email)
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockRepository) CreateUser(ctx context.Context, name, email, password, token string, tokenExpiresAt time.Time) error {
+	args := m.Called(ctx, name, email, password, token, tokenExpiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SetVerifyToken(ctx context.Context, username, token string, expiresAt time.Time) error {
+	args := m.Called(ctx, username, token, expiresAt)
 	return args.Error(0)
 }
 
@@ -127,6 +269,211 @@ func (m *MockRepository) MarkUserAsVerified(ctx context.Context, token string) e
 	return args.Error(0)
 }
 
+func (m *MockTokenRepository) IsRevoked(ctx context.Context, tokenHash string) (bool, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockTokenRepository) Revoke(ctx context.Context, tokenHash string, expiration time.Duration) error {
+	args := m.Called(ctx, tokenHash, expiration)
+	return args.Error(0)
+}
+
+func (m *MockTokenRepository) RevokeAllBefore(ctx context.Context, username string, cutoff time.Time, expiration time.Duration) error {
+	args := m.Called(ctx, username, cutoff, expiration)
+	return args.Error(0)
+}
+
+func (m *MockTokenRepository) GetRevokedBefore(ctx context.Context, username string) (time.Time, error) {
+	args := m.Called(ctx, username)
+	return args.Get(0).(time.Time), args.Error(1)
+}
+
+func (m *MockRepository) UpdatePassword(ctx context.Context, username, hashedPassword string) error {
+	args := m.Called(ctx, username, hashedPassword)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SearchUsers(ctx context.Context, query, excludeUsername string, limit, offset int) ([]models.Profile, error) {
+	args := m.Called(ctx, query, excludeUsername, limit, offset)
+	return args.Get(0).([]models.Profile), args.Error(1)
+}
+
+func (m *MockRepository) UpdateProfile(ctx context.Context, username string, update models.ProfileUpdate) error {
+	args := m.Called(ctx, username, update)
+	return args.Error(0)
+}
+
+func (m *MockRepository) SetPendingEmail(ctx context.Context, username, email, tokenHash string, expiresAt time.Time) error {
+	args := m.Called(ctx, username, email, tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ConfirmPendingEmail(ctx context.Context, tokenHash string) (string, string, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.String(0), args.String(1), args.Error(2)
+}
+
+func (m *MockRepository) CreatePasswordResetToken(ctx context.Context, username, tokenHash string, expiresAt time.Time) error {
+	args := m.Called(ctx, username, tokenHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetPasswordResetTokenUser(ctx context.Context, tokenHash string) (string, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepository) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepository) SetTOTPSecret(ctx context.Context, username, secret string) error {
+	args := m.Called(ctx, username, secret)
+	return args.Error(0)
+}
+
+func (m *MockRepository) EnableTOTP(ctx context.Context, username string, recoveryCodeHashes []string) error {
+	args := m.Called(ctx, username, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (m *MockRepository) DisableTOTP(ctx context.Context, username string) error {
+	args := m.Called(ctx, username)
+	return args.Error(0)
+}
+
+func (m *MockRepository) AcceptTOTPStep(ctx context.Context, username string, step int64) (bool, error) {
+	args := m.Called(ctx, username, step)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ConsumeRecoveryCode(ctx context.Context, username, codeHash string) (bool, error) {
+	args := m.Called(ctx, username, codeHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) ScheduleDeletion(ctx context.Context, username string, deleteAt time.Time) error {
+	args := m.Called(ctx, username, deleteAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CancelDeletion(ctx context.Context, username string) (bool, error) {
+	args := m.Called(ctx, username)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepository) GetUsersDueForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepository) AnonymizeUser(ctx context.Context, username string) error {
+	args := m.Called(ctx, username)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	args := m.Called(ctx, provider, subject)
+	return args.Get(0).(*models.User), args.Error(1)
+}
+
+func (m *MockRepository) LinkIdentity(ctx context.Context, username, provider, subject, email string) error {
+	args := m.Called(ctx, username, provider, subject, email)
+	return args.Error(0)
+}
+
+func (m *MockRepository) CreateUserWithIdentity(ctx context.Context, username, email, provider, subject string) error {
+	args := m.Called(ctx, username, email, provider, subject)
+	return args.Error(0)
+}
+
+func (m *MockRepository) GetBots(ctx context.Context, owner string) ([]models.Profile, error) {
+	args := m.Called(ctx, owner)
+	return args.Get(0).([]models.Profile), args.Error(1)
+}
+
+func (m *MockRepository) CreateBot(ctx context.Context, owner, username, displayName string) error {
+	args := m.Called(ctx, owner, username, displayName)
+	return args.Error(0)
+}
+
+func (m *MockRepository) ListUsers(ctx context.Context, query string, limit, offset int) ([]models.UserSummary, error) {
+	args := m.Called(ctx, query, limit, offset)
+	return args.Get(0).([]models.UserSummary), args.Error(1)
+}
+
+func (m *MockRepository) SetRole(ctx context.Context, username string, role models.Role) error {
+	args := m.Called(ctx, username, role)
+	return args.Error(0)
+}
+
+func (m *MockRepository) BanUser(ctx context.Context, username, reason string, bannedAt time.Time) error {
+	args := m.Called(ctx, username, reason, bannedAt)
+	return args.Error(0)
+}
+
+func (m *MockRepository) UnbanUser(ctx context.Context, username string) error {
+	args := m.Called(ctx, username)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(*models.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, tokenHash string) (bool, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, expiration time.Duration) error {
+	args := m.Called(ctx, familyID, expiration)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	args := m.Called(ctx, familyID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSessionRepository) CreateSession(ctx context.Context, session models.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	args := m.Called(ctx, sessionID)
+	return args.Get(0).(*models.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) GetUserSessions(ctx context.Context, username string) ([]models.Session, error) {
+	args := m.Called(ctx, username)
+	return args.Get(0).([]models.Session), args.Error(1)
+}
+
+func (m *MockSessionRepository) TouchSession(ctx context.Context, sessionID string, seenAt time.Time) error {
+	args := m.Called(ctx, sessionID, seenAt)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	args := m.Called(ctx, sessionID)
+	return args.Error(0)
+}
+
+func (m *MockSessionRepository) RevokeUserSessions(ctx context.Context, username string) error {
+	args := m.Called(ctx, username)
+	return args.Error(0)
+}
+
 func CreateTestRequest(url, method string, body interface{}) *http.Request {
 	var buffer bytes.Buffer
 	if body != nil {
@@ -144,3 +491,93 @@ func ExecuteHandler(handler http.Handler, req *http.Request) *httptest.ResponseR
 	handler.ServeHTTP(rr, req)
 	return rr
 }
+
+func (m *MockOIDCStateRepository) SaveOIDCState(ctx context.Context, stateHash string, state models.OIDCState, ttl time.Duration) error {
+	args := m.Called(ctx, stateHash, state, ttl)
+	return args.Error(0)
+}
+
+func (m *MockOIDCStateRepository) ConsumeOIDCState(ctx context.Context, stateHash string) (*models.OIDCState, error) {
+	args := m.Called(ctx, stateHash)
+	return args.Get(0).(*models.OIDCState), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) CreateAPIKey(ctx context.Context, key models.APIKey) (int, error) {
+	args := m.Called(ctx, key)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	args := m.Called(ctx, keyHash)
+	return args.Get(0).(*models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) GetOwnedAPIKeys(ctx context.Context, owner string) ([]models.APIKey, error) {
+	args := m.Called(ctx, owner)
+	return args.Get(0).([]models.APIKey), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) RevokeAPIKey(ctx context.Context, id int, owner string) (bool, error) {
+	args := m.Called(ctx, id, owner)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAPIKeyRepository) TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error {
+	args := m.Called(ctx, id, usedAt)
+	return args.Error(0)
+}
+
+func (m *MockEmailOutboxRepository) EnqueueEmail(ctx context.Context, email models.OutboundEmail) (int, error) {
+	args := m.Called(ctx, email)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockEmailOutboxRepository) ClaimDueEmails(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboundEmail, error) {
+	args := m.Called(ctx, now, leaseUntil, limit)
+	return args.Get(0).([]models.OutboundEmail), args.Error(1)
+}
+
+func (m *MockEmailOutboxRepository) MarkEmailSent(ctx context.Context, id int, sentAt time.Time) error {
+	args := m.Called(ctx, id, sentAt)
+	return args.Error(0)
+}
+
+func (m *MockEmailOutboxRepository) RetryEmail(ctx context.Context, id int, lastError string, retryAt time.Time) error {
+	args := m.Called(ctx, id, lastError, retryAt)
+	return args.Error(0)
+}
+
+func (m *MockEmailOutboxRepository) MarkEmailDead(ctx context.Context, id int, lastError string) error {
+	args := m.Called(ctx, id, lastError)
+	return args.Error(0)
+}
+
+func (m *MockEmailOutboxRepository) DeleteFinishedEmails(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockChatInviteRepository) CreateInvite(ctx context.Context, invite models.ChatInvite) (int, error) {
+	args := m.Called(ctx, invite)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockChatInviteRepository) GetInviteByHash(ctx context.Context, tokenHash string) (*models.ChatInvite, error) {
+	args := m.Called(ctx, tokenHash)
+	return args.Get(0).(*models.ChatInvite), args.Error(1)
+}
+
+func (m *MockChatInviteRepository) GetChatInvites(ctx context.Context, chatID int) ([]models.ChatInvite, error) {
+	args := m.Called(ctx, chatID)
+	return args.Get(0).([]models.ChatInvite), args.Error(1)
+}
+
+func (m *MockChatInviteRepository) RevokeInvite(ctx context.Context, chatID, id int) (bool, error) {
+	args := m.Called(ctx, chatID, id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockChatInviteRepository) UseInvite(ctx context.Context, id int, username string, now time.Time) (bool, error) {
+	args := m.Called(ctx, id, username, now)
+	return args.Bool(0), args.Error(1)
+}
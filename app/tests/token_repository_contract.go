@@ -0,0 +1,91 @@
+package tests
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"massager/internal/ports"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TokenRepositoryContract is the behaviour every ports.TokenRepository has to
+// show. Keys are random, so implementations backed by a shared store do not
+// need to be emptied between runs.
+func TokenRepositoryContract(t *testing.T, repository ports.TokenRepository) {
+	ctx := context.Background()
+
+	t.Run("Unknown token is not revoked", func(t *testing.T) {
+		revoked, err := repository.IsRevoked(ctx, randomKey())
+		require.NoError(t, err)
+		assert.False(t, revoked)
+	})
+
+	t.Run("Revoked token", func(t *testing.T) {
+		tokenHash := randomKey()
+		require.NoError(t, repository.Revoke(ctx, tokenHash, time.Hour))
+
+		revoked, err := repository.IsRevoked(ctx, tokenHash)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("Revocation expires", func(t *testing.T) {
+		tokenHash := randomKey()
+		require.NoError(t, repository.Revoke(ctx, tokenHash, 100*time.Millisecond))
+
+		assert.Eventually(t, func() bool {
+			revoked, err := repository.IsRevoked(ctx, tokenHash)
+			return err == nil && !revoked
+		}, 2*time.Second, 50*time.Millisecond)
+	})
+
+	t.Run("Revocation without expiration is kept", func(t *testing.T) {
+		tokenHash := randomKey()
+		require.NoError(t, repository.Revoke(ctx, tokenHash, 0))
+
+		time.Sleep(50 * time.Millisecond)
+		revoked, err := repository.IsRevoked(ctx, tokenHash)
+		require.NoError(t, err)
+		assert.True(t, revoked)
+	})
+
+	t.Run("No cutoff", func(t *testing.T) {
+		cutoff, err := repository.GetRevokedBefore(ctx, randomKey())
+		require.NoError(t, err)
+		assert.True(t, cutoff.IsZero())
+	})
+
+	t.Run("Latest cutoff wins", func(t *testing.T) {
+		username := randomKey()
+		first := time.Now().Add(-time.Hour)
+		second := time.Now()
+
+		require.NoError(t, repository.RevokeAllBefore(ctx, username, first, time.Hour))
+		require.NoError(t, repository.RevokeAllBefore(ctx, username, second, time.Hour))
+
+		cutoff, err := repository.GetRevokedBefore(ctx, username)
+		require.NoError(t, err)
+		// Tokens carry their issue time in milliseconds.
+		assert.Equal(t, second.UnixMilli(), cutoff.UnixMilli())
+	})
+
+	t.Run("Cutoff expires", func(t *testing.T) {
+		username := randomKey()
+		require.NoError(t, repository.RevokeAllBefore(ctx, username, time.Now(), 100*time.Millisecond))
+
+		assert.Eventually(t, func() bool {
+			cutoff, err := repository.GetRevokedBefore(ctx, username)
+			return err == nil && cutoff.IsZero()
+		}, 2*time.Second, 50*time.Millisecond)
+	})
+}
+
+func randomKey() string {
+	raw := make([]byte, 8)
+	rand.Read(raw)
+	return "contract-" + hex.EncodeToString(raw)
+}
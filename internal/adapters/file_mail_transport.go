@@ -0,0 +1,52 @@
+package adapters
+
+import (
+	"context"
+	"fmt"
+	"massager/internal/models"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailTransport writes every email into a Maildir instead of sending it,
+// for development. Any mail client that reads Maildir can open the result.
+type FileMailTransport struct {
+	from     string
+	dir      string
+	sequence atomic.Int64
+}
+
+func NewFileMailTransport(from, dir string) (*FileMailTransport, error) {
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &FileMailTransport{from: from, dir: dir}, nil
+}
+
+// Send writes the message to tmp/ and moves it to new/ once complete, as the
+// Maildir format requires.
+func (t *FileMailTransport) Send(ctx context.Context, email models.OutboundEmail) error {
+	name := fmt.Sprintf("%d.%d_%d_%d.horizon", time.Now().Unix(), os.Getpid(), email.ID, t.sequence.Add(1))
+	tmpPath := filepath.Join(t.dir, "tmp", name)
+
+	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := newMailMessage(t.from, email).WriteTo(file); err != nil {
+		file.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return os.Rename(tmpPath, filepath.Join(t.dir, "new", name))
+}
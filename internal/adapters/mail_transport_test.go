@@ -0,0 +1,51 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"massager/internal/models"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileMailTransport_WritesMaildir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "maildir")
+	transport, err := NewFileMailTransport("Horizon <noreply@example.com>", dir)
+	require.NoError(t, err)
+
+	email := models.OutboundEmail{ID: 1, Recipient: "user@example.com", Subject: "Hello", TextBody: "plain body", HTMLBody: "<p>html body</p>"}
+	require.NoError(t, transport.Send(context.Background(), email))
+	require.NoError(t, transport.Send(context.Background(), email))
+
+	delivered, err := os.ReadDir(filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	require.Len(t, delivered, 2)
+
+	pending, err := os.ReadDir(filepath.Join(dir, "tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	content, err := os.ReadFile(filepath.Join(dir, "new", delivered[0].Name()))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "To: user@example.com")
+	assert.Contains(t, string(content), "Subject: Hello")
+	assert.Contains(t, string(content), "plain body")
+	assert.Contains(t, string(content), "<p>html body</p>")
+}
+
+func TestMemoryMailTransport(t *testing.T) {
+	transport := NewMemoryMailTransport()
+	email := models.OutboundEmail{ID: 1, Recipient: "user@example.com"}
+
+	transport.SetError(errors.New("smtp down"))
+	assert.Error(t, transport.Send(context.Background(), email))
+	assert.Empty(t, transport.Sent())
+
+	transport.SetError(nil)
+	require.NoError(t, transport.Send(context.Background(), email))
+	assert.Equal(t, []models.OutboundEmail{email}, transport.Sent())
+}
@@ -0,0 +1,113 @@
+package adapters
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryCounter struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// MemoryAttemptRepository keeps attempt counters and blocks in process
+// memory. Counters are per instance, so it suits single instance deployments
+// only.
+type MemoryAttemptRepository struct {
+	mu       sync.Mutex
+	counters map[string]memoryCounter
+	blocks   map[string]time.Time
+}
+
+func NewMemoryAttemptRepository() *MemoryAttemptRepository {
+	return &MemoryAttemptRepository{
+		counters: make(map[string]memoryCounter),
+		blocks:   make(map[string]time.Time),
+	}
+}
+
+// Increment mirrors Redis INCR followed by EXPIRE: every attempt restarts the
+// window.
+func (r *MemoryAttemptRepository) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counter := r.counters[key]
+	if !now.Before(counter.expiresAt) {
+		counter.count = 0
+	}
+	counter.count++
+	counter.expiresAt = now.Add(window)
+	r.counters[key] = counter
+	return counter.count, nil
+}
+
+func (r *MemoryAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.counters, key)
+	return nil
+}
+
+func (r *MemoryAttemptRepository) Block(ctx context.Context, key string, until time.Time) error {
+	if !time.Now().Before(until) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.blocks[key] = until
+	return nil
+}
+
+func (r *MemoryAttemptRepository) BlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	until, found := r.blocks[key]
+	if !found || !time.Now().Before(until) {
+		return time.Time{}, nil
+	}
+	return until, nil
+}
+
+func (r *MemoryAttemptRepository) Unblock(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.blocks, key)
+	return nil
+}
+
+// Evict drops expired counters and blocks and returns how many were removed.
+func (r *MemoryAttemptRepository) Evict() int {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	evicted := 0
+	for key, counter := range r.counters {
+		if !now.Before(counter.expiresAt) {
+			delete(r.counters, key)
+			evicted++
+		}
+	}
+	for key, until := range r.blocks {
+		if !now.Before(until) {
+			delete(r.blocks, key)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// RunSweeper evicts expired entries every interval until ctx is cancelled.
+func (r *MemoryAttemptRepository) RunSweeper(ctx context.Context, interval time.Duration) {
+	runEvictions(ctx, interval, r.Evict)
+}
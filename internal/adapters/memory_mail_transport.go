@@ -0,0 +1,45 @@
+package adapters
+
+import (
+	"context"
+	"massager/internal/models"
+	"sync"
+)
+
+// MemoryMailTransport keeps sent emails in memory so tests can inspect them.
+type MemoryMailTransport struct {
+	mu   sync.Mutex
+	sent []models.OutboundEmail
+	err  error
+}
+
+func NewMemoryMailTransport() *MemoryMailTransport {
+	return &MemoryMailTransport{}
+}
+
+func (t *MemoryMailTransport) Send(ctx context.Context, email models.OutboundEmail) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.err != nil {
+		return t.err
+	}
+	t.sent = append(t.sent, email)
+	return nil
+}
+
+// SetError makes every following Send fail with err until it is reset with nil.
+func (t *MemoryMailTransport) SetError(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.err = err
+}
+
+// Sent returns a copy of the emails delivered so far.
+func (t *MemoryMailTransport) Sent() []models.OutboundEmail {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]models.OutboundEmail(nil), t.sent...)
+}
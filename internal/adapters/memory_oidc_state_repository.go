@@ -0,0 +1,72 @@
+package adapters
+
+import (
+	"context"
+	"massager/internal/models"
+	"sync"
+	"time"
+)
+
+type memoryOIDCState struct {
+	state     models.OIDCState
+	expiresAt time.Time
+}
+
+// MemoryOIDCStateRepository keeps pending OpenID Connect logins in process
+// memory, so the callback has to reach the instance that started the login.
+type MemoryOIDCStateRepository struct {
+	mu     sync.Mutex
+	states map[string]memoryOIDCState
+}
+
+func NewMemoryOIDCStateRepository() *MemoryOIDCStateRepository {
+	return &MemoryOIDCStateRepository{states: make(map[string]memoryOIDCState)}
+}
+
+func (r *MemoryOIDCStateRepository) SaveOIDCState(ctx context.Context, stateHash string, state models.OIDCState, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.states[stateHash] = memoryOIDCState{state: state, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+// ConsumeOIDCState removes the state under the lock, so a state can only be
+// used for a single callback.
+func (r *MemoryOIDCStateRepository) ConsumeOIDCState(ctx context.Context, stateHash string) (*models.OIDCState, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pending, found := r.states[stateHash]
+	if !found {
+		return nil, nil
+	}
+	delete(r.states, stateHash)
+
+	if !time.Now().Before(pending.expiresAt) {
+		return nil, nil
+	}
+	return &pending.state, nil
+}
+
+// Evict drops expired states and returns how many were removed.
+func (r *MemoryOIDCStateRepository) Evict() int {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	evicted := 0
+	for stateHash, pending := range r.states {
+		if !now.Before(pending.expiresAt) {
+			delete(r.states, stateHash)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// RunSweeper evicts expired states every interval until ctx is cancelled.
+func (r *MemoryOIDCStateRepository) RunSweeper(ctx context.Context, interval time.Duration) {
+	runEvictions(ctx, interval, r.Evict)
+}
@@ -0,0 +1,101 @@
+package adapters
+
+import (
+	"context"
+	"massager/internal/models"
+	"sync"
+	"time"
+)
+
+// MemoryRefreshTokenRepository keeps refresh tokens in process memory. Like
+// MemoryTokenRepository it suits single instance deployments only.
+type MemoryRefreshTokenRepository struct {
+	mu       sync.RWMutex
+	tokens   map[string]models.RefreshToken
+	families map[string]memoryEntry
+}
+
+func NewMemoryRefreshTokenRepository() *MemoryRefreshTokenRepository {
+	return &MemoryRefreshTokenRepository{
+		tokens:   make(map[string]models.RefreshToken),
+		families: make(map[string]memoryEntry),
+	}
+}
+
+func (r *MemoryRefreshTokenRepository) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token.Used = false
+	r.tokens[token.TokenHash] = token
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	token, found := r.tokens[tokenHash]
+	if !found || !time.Now().Before(token.ExpiresAt) {
+		return nil, nil
+	}
+	return &token, nil
+}
+
+func (r *MemoryRefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, tokenHash string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	token, found := r.tokens[tokenHash]
+	if !found || token.Used || !time.Now().Before(token.ExpiresAt) {
+		return false, nil
+	}
+	token.Used = true
+	r.tokens[tokenHash] = token
+	return true, nil
+}
+
+func (r *MemoryRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.families[familyID] = memoryEntry{expiresAt: memoryExpiry(expiration)}
+	return nil
+}
+
+func (r *MemoryRefreshTokenRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, found := r.families[familyID]
+	return found && !entry.expired(time.Now()), nil
+}
+
+// Evict drops expired tokens and family revocations and returns how many
+// were removed.
+func (r *MemoryRefreshTokenRepository) Evict() int {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	evicted := 0
+	for tokenHash, token := range r.tokens {
+		if !now.Before(token.ExpiresAt) {
+			delete(r.tokens, tokenHash)
+			evicted++
+		}
+	}
+	for familyID, entry := range r.families {
+		if entry.expired(now) {
+			delete(r.families, familyID)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// RunSweeper evicts expired entries every interval until ctx is cancelled.
+func (r *MemoryRefreshTokenRepository) RunSweeper(ctx context.Context, interval time.Duration) {
+	runEvictions(ctx, interval, r.Evict)
+}
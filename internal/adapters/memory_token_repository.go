@@ -0,0 +1,129 @@
+package adapters
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const defaultTokenEvictionInterval = time.Minute
+
+type memoryEntry struct {
+	value     time.Time
+	expiresAt time.Time // zero means the entry never expires
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// MemoryTokenRepository keeps revocations in process memory. It suits single
+// instance deployments only: revocations are lost on restart and are not
+// shared between instances.
+type MemoryTokenRepository struct {
+	mu      sync.RWMutex
+	revoked map[string]memoryEntry
+	cutoffs map[string]memoryEntry
+}
+
+func NewMemoryTokenRepository() *MemoryTokenRepository {
+	return &MemoryTokenRepository{
+		revoked: make(map[string]memoryEntry),
+		cutoffs: make(map[string]memoryEntry),
+	}
+}
+
+func (r *MemoryTokenRepository) IsRevoked(ctx context.Context, tokenHash string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, found := r.revoked[tokenHash]
+	return found && !entry.expired(time.Now()), nil
+}
+
+func (r *MemoryTokenRepository) Revoke(ctx context.Context, tokenHash string, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revoked[tokenHash] = memoryEntry{expiresAt: memoryExpiry(expiration)}
+	return nil
+}
+
+func (r *MemoryTokenRepository) RevokeAllBefore(ctx context.Context, username string, cutoff time.Time, expiration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cutoffs[username] = memoryEntry{value: cutoff, expiresAt: memoryExpiry(expiration)}
+	return nil
+}
+
+func (r *MemoryTokenRepository) GetRevokedBefore(ctx context.Context, username string) (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, found := r.cutoffs[username]
+	if !found || entry.expired(time.Now()) {
+		return time.Time{}, nil
+	}
+	return entry.value, nil
+}
+
+// Evict drops expired entries and returns how many were removed.
+func (r *MemoryTokenRepository) Evict() int {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	evicted := 0
+	for _, entries := range []map[string]memoryEntry{r.revoked, r.cutoffs} {
+		for key, entry := range entries {
+			if entry.expired(now) {
+				delete(entries, key)
+				evicted++
+			}
+		}
+	}
+	return evicted
+}
+
+// Len reports how many entries are held, expired or not.
+func (r *MemoryTokenRepository) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return len(r.revoked) + len(r.cutoffs)
+}
+
+// RunSweeper evicts expired entries every interval until ctx is cancelled.
+func (r *MemoryTokenRepository) RunSweeper(ctx context.Context, interval time.Duration) {
+	runEvictions(ctx, interval, r.Evict)
+}
+
+// runEvictions calls evict every interval until ctx is cancelled. It backs
+// the RunSweeper of every memory store.
+func runEvictions(ctx context.Context, interval time.Duration, evict func() int) {
+	if interval <= 0 {
+		interval = defaultTokenEvictionInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			evict()
+		}
+	}
+}
+
+// memoryExpiry mirrors Redis: a zero or negative expiration never expires.
+func memoryExpiry(expiration time.Duration) time.Time {
+	if expiration <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(expiration)
+}
@@ -0,0 +1,62 @@
+package adapters
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type RedisAttemptRepository struct {
+	client *redis.Client
+}
+
+func NewRedisAttemptRepository(client *redis.Client) *RedisAttemptRepository {
+	return &RedisAttemptRepository{client: client}
+}
+
+func (r *RedisAttemptRepository) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr("attempts:" + key)
+		pipe.Expire("attempts:"+key, window)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (r *RedisAttemptRepository) Reset(ctx context.Context, key string) error {
+	return r.client.Del("attempts:" + key).Err()
+}
+
+func (r *RedisAttemptRepository) Block(ctx context.Context, key string, until time.Time) error {
+	expiration := time.Until(until)
+	if expiration <= 0 {
+		return nil
+	}
+	return r.client.Set("blocked:"+key, until.UnixMilli(), expiration).Err()
+}
+
+func (r *RedisAttemptRepository) BlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	value, err := r.client.Get("blocked:" + key).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.UnixMilli(unix), nil
+}
+
+func (r *RedisAttemptRepository) Unblock(ctx context.Context, key string) error {
+	return r.client.Del("blocked:" + key).Err()
+}
@@ -0,0 +1,49 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"massager/internal/models"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type RedisOIDCStateRepository struct {
+	client *redis.Client
+}
+
+func NewRedisOIDCStateRepository(client *redis.Client) *RedisOIDCStateRepository {
+	return &RedisOIDCStateRepository{client: client}
+}
+
+func (r *RedisOIDCStateRepository) SaveOIDCState(ctx context.Context, stateHash string, state models.OIDCState, ttl time.Duration) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return r.client.Set("oidc_state:"+stateHash, data, ttl).Err()
+}
+
+// ConsumeOIDCState reads and deletes the state in one transaction, so a state
+// can only be used for a single callback.
+func (r *RedisOIDCStateRepository) ConsumeOIDCState(ctx context.Context, stateHash string) (*models.OIDCState, error) {
+	var get *redis.StringCmd
+	_, err := r.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		get = pipe.Get("oidc_state:" + stateHash)
+		pipe.Del("oidc_state:" + stateHash)
+		return nil
+	})
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state models.OIDCState
+	if err := json.Unmarshal([]byte(get.Val()), &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
@@ -0,0 +1,72 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"massager/internal/models"
+	"time"
+
+	"github.com/go-redis/redis"
+)
+
+type RedisRefreshTokenRepository struct {
+	client *redis.Client
+}
+
+func NewRedisRefreshTokenRepository(client *redis.Client) *RedisRefreshTokenRepository {
+	return &RedisRefreshTokenRepository{client: client}
+}
+
+func (r *RedisRefreshTokenRepository) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return r.client.Set("refresh:"+token.TokenHash, data, time.Until(token.ExpiresAt)).Err()
+}
+
+func (r *RedisRefreshTokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	data, err := r.client.Get("refresh:" + tokenHash).Bytes()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var token models.RefreshToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+
+	used, err := r.client.Exists("refresh_used:" + tokenHash).Result()
+	if err != nil {
+		return nil, err
+	}
+	token.Used = used == 1
+
+	return &token, nil
+}
+
+func (r *RedisRefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, tokenHash string) (bool, error) {
+	ttl, err := r.client.TTL("refresh:" + tokenHash).Result()
+	if err != nil {
+		return false, err
+	}
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+	return r.client.SetNX("refresh_used:"+tokenHash, "1", ttl).Result()
+}
+
+func (r *RedisRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, expiration time.Duration) error {
+	return r.client.Set("refresh_family_revoked:"+familyID, "1", expiration).Err()
+}
+
+func (r *RedisRefreshTokenRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	exists, err := r.client.Exists("refresh_family_revoked:" + familyID).Result()
+	if err != nil {
+		return false, err
+	}
+	return exists == 1, nil
+}
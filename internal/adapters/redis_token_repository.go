@@ -2,6 +2,7 @@ package adapters
 
 import (
 	"context"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis"
@@ -26,3 +27,32 @@ func (r *RedisTokenRepository) IsRevoked(ctx context.Context, tokenHash string)
 func (r *RedisTokenRepository) Revoke(ctx context.Context, tokenHash string, expiration time.Duration) error {
 	return r.client.Set("blacklist:"+tokenHash, "1", expiration).Err()
 }
+
+// legacyCutoffMillis separates cutoffs in seconds from those in milliseconds:
+// no millisecond timestamp since 2001 is below it.
+const legacyCutoffMillis = 1e12
+
+func (r *RedisTokenRepository) RevokeAllBefore(ctx context.Context, username string, cutoff time.Time, expiration time.Duration) error {
+	return r.client.Set("revoked_before:"+username, cutoff.UnixMilli(), expiration).Err()
+}
+
+func (r *RedisTokenRepository) GetRevokedBefore(ctx context.Context, username string) (time.Time, error) {
+	value, err := r.client.Get("revoked_before:" + username).Result()
+	if err == redis.Nil {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	unix, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return time.Time{}, err
+	}
+	// Cutoffs used to be stored in whole seconds; those expire with the
+	// refresh tokens they cover.
+	if unix < legacyCutoffMillis {
+		return time.Unix(unix, 0), nil
+	}
+	return time.UnixMilli(unix), nil
+}
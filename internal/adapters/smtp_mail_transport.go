@@ -0,0 +1,39 @@
+package adapters
+
+import (
+	"context"
+	"massager/app/config"
+	"massager/internal/models"
+	"strconv"
+
+	"gopkg.in/gomail.v2"
+)
+
+type SMTPMailTransport struct {
+	from   string
+	dialer *gomail.Dialer
+}
+
+func NewSMTPMailTransport(cfg config.EmailConfig) *SMTPMailTransport {
+	var port, _ = strconv.Atoi(cfg.SMTPort)
+	return &SMTPMailTransport{
+		from:   cfg.From,
+		dialer: gomail.NewDialer(cfg.SMTHost, port, cfg.Username, cfg.Password),
+	}
+}
+
+func (t *SMTPMailTransport) Send(ctx context.Context, email models.OutboundEmail) error {
+	return t.dialer.DialAndSend(newMailMessage(t.from, email))
+}
+
+// newMailMessage builds a multipart/alternative message with the plain text
+// part first, so clients that can show HTML prefer it.
+func newMailMessage(from string, email models.OutboundEmail) *gomail.Message {
+	var message = gomail.NewMessage()
+	message.SetHeader("From", from)
+	message.SetHeader("To", email.Recipient)
+	message.SetHeader("Subject", email.Subject)
+	message.SetBody("text/plain", email.TextBody)
+	message.AddAlternative("text/html", email.HTMLBody)
+	return message
+}
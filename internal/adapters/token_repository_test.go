@@ -0,0 +1,60 @@
+package adapters
+
+import (
+	"context"
+	"massager/app/tests"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryTokenRepository_Contract(t *testing.T) {
+	tests.TokenRepositoryContract(t, NewMemoryTokenRepository())
+}
+
+// The Redis run needs a server, e.g. HORIZON_TEST_REDIS_ADDR=localhost:6379.
+func TestRedisTokenRepository_Contract(t *testing.T) {
+	addr := os.Getenv("HORIZON_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("HORIZON_TEST_REDIS_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	require.NoError(t, client.Ping().Err())
+
+	tests.TokenRepositoryContract(t, NewRedisTokenRepository(client))
+}
+
+func TestMemoryRefreshTokenRepository_Contract(t *testing.T) {
+	tests.RefreshTokenRepositoryContract(t, NewMemoryRefreshTokenRepository())
+}
+
+func TestMemoryAttemptRepository_Contract(t *testing.T) {
+	tests.AttemptRepositoryContract(t, NewMemoryAttemptRepository())
+}
+
+func TestMemoryOIDCStateRepository_Contract(t *testing.T) {
+	tests.OIDCStateRepositoryContract(t, NewMemoryOIDCStateRepository())
+}
+
+func TestMemoryTokenRepository_Evict(t *testing.T) {
+	ctx := context.Background()
+	repository := NewMemoryTokenRepository()
+
+	require.NoError(t, repository.Revoke(ctx, "short", time.Millisecond))
+	require.NoError(t, repository.Revoke(ctx, "long", time.Hour))
+	require.NoError(t, repository.RevokeAllBefore(ctx, "validuser", time.Now(), time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+
+	assert.Equal(t, 2, repository.Evict())
+	assert.Equal(t, 1, repository.Len())
+
+	revoked, err := repository.IsRevoked(ctx, "long")
+	require.NoError(t, err)
+	assert.True(t, revoked)
+}
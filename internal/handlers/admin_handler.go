@@ -0,0 +1,288 @@
+package handlers
+
+// PROPRIETARY AND CONFIDENTIAL
+// This code contains trade secrets and confidential material of Finimen Sniper / FSC.
+// Any unauthorized use, disclosure, or duplication is strictly prohibited.
+// © 2025 Finimen Sniper / FSC. All rights reserved.
+
+import (
+	"errors"
+	"log/slog"
+	"massager/internal/models"
+	"massager/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type AdminHandler struct {
+	authService *services.AuthService
+	logger      *slog.Logger
+	tracer      trace.Tracer
+}
+
+func NewAdminHandler(authService *services.AuthService, logger *slog.Logger, tracer trace.Tracer) *AdminHandler {
+	return &AdminHandler{authService: authService, logger: logger, tracer: tracer}
+}
+
+// AdminHandler represents the administration handler
+// @Summary Unlock a user
+// @Tags admin
+// @Description Lifts a lockout caused by too many failed login attempts
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /admin/users/{username}/unlock [post]
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "AdminHandler.UnlockUser")
+	defer span.End()
+
+	username := c.Param("username")
+	span.SetAttributes(
+		attribute.String("admin.username", c.GetString("username")),
+		attribute.String("user.username", username),
+	)
+
+	if err := h.authService.UnlockUser(ctx, username); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to unlock user", "username", username, "error", err)
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	h.logger.Info("user unlocked by admin", "username", username, "admin", c.GetString("username"))
+	c.JSON(http.StatusOK, gin.H{"message": "User unlocked"})
+}
+
+// @Summary List users
+// @Tags admin
+// @Description Lists accounts whose username, email or display name contains the query. Requires the moderator role
+// @Produce json
+// @Security BearerAuth
+// @Param q query string false "Search query"
+// @Param limit query int false "Page size (default 20, max 50)"
+// @Param offset query int false "Offset of the first user"
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/users [get]
+func (h *AdminHandler) ListUsers(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "AdminHandler.ListUsers")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("admin.username", c.GetString("username")))
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	users, hasMore, err := h.authService.ListUsers(ctx, c.Query("q"), limit, offset)
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "offset": offset, "has_more": hasMore})
+}
+
+// @Summary Ban a user
+// @Tags admin
+// @Description Bans a user with a lower role. Their sessions end at once, their WebSocket connections are closed and further logins are refused. Requires the moderator role
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Param request body BanUserRequest false "Reason"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{username}/ban [post]
+func (h *AdminHandler) BanUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "AdminHandler.BanUser")
+	defer span.End()
+
+	admin := c.GetString("username")
+	username := c.Param("username")
+	span.SetAttributes(attribute.String("admin.username", admin), attribute.String("user.username", username))
+
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+			return
+		}
+	}
+
+	if err := h.authService.BanUser(ctx, admin, username, req.Reason); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to ban user", "username", username, "admin", admin, "error", err)
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User banned"})
+}
+
+// @Summary Unban a user
+// @Tags admin
+// @Description Lifts a ban. Requires the moderator role
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{username}/unban [post]
+func (h *AdminHandler) UnbanUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "AdminHandler.UnbanUser")
+	defer span.End()
+
+	admin := c.GetString("username")
+	username := c.Param("username")
+	span.SetAttributes(attribute.String("admin.username", admin), attribute.String("user.username", username))
+
+	if err := h.authService.UnbanUser(ctx, admin, username); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to unban user", "username", username, "admin", admin, "error", err)
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User unbanned"})
+}
+
+// @Summary Change a user's role
+// @Tags admin
+// @Description Sets the global role of a user with a lower role. A demotion ends the user's sessions. Requires the admin role
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Param request body SetRoleRequest true "Role"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{username}/role [put]
+func (h *AdminHandler) SetUserRole(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "AdminHandler.SetUserRole")
+	defer span.End()
+
+	admin := c.GetString("username")
+	username := c.Param("username")
+	span.SetAttributes(attribute.String("admin.username", admin), attribute.String("user.username", username))
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	if err := h.authService.SetUserRole(ctx, admin, username, models.Role(req.Role)); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to change role", "username", username, "admin", admin, "error", err)
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role updated"})
+}
+
+// @Summary Verify a user's email
+// @Tags admin
+// @Description Marks the email of a user as verified without a verification link. Requires the admin role
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{username}/verify [post]
+func (h *AdminHandler) VerifyEmail(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "AdminHandler.VerifyEmail")
+	defer span.End()
+
+	admin := c.GetString("username")
+	username := c.Param("username")
+	span.SetAttributes(attribute.String("admin.username", admin), attribute.String("user.username", username))
+
+	if err := h.authService.ForceVerifyEmail(ctx, admin, username); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to verify email", "username", username, "admin", admin, "error", err)
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+}
+
+// @Summary Reset a user's password
+// @Tags admin
+// @Description Disables the user's password, ends their sessions and emails them a reset link. Requires the admin role
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/users/{username}/reset-password [post]
+func (h *AdminHandler) ResetPassword(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "AdminHandler.ResetPassword")
+	defer span.End()
+
+	admin := c.GetString("username")
+	username := c.Param("username")
+	span.SetAttributes(attribute.String("admin.username", admin), attribute.String("user.username", username))
+
+	if err := h.authService.AdminResetPassword(ctx, admin, username); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to reset password", "username", username, "admin", admin, "error", err)
+		c.JSON(adminErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password reset email sent"})
+}
+
+func adminErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidRole), errors.Is(err, services.ErrInvalidInput):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrInsufficientRole):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,192 @@
+package handlers
+
+// PROPRIETARY AND CONFIDENTIAL
+// This code contains trade secrets and confidential material of Finimen Sniper / FSC.
+// Any unauthorized use, disclosure, or duplication is strictly prohibited.
+// © 2025 Finimen Sniper / FSC. All rights reserved.
+
+import (
+	"errors"
+	"massager/internal/models"
+	"massager/internal/services"
+	"massager/internal/services/validation"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// @Summary Create a bot
+// @Tags bots
+// @Description Creates a bot account owned by the caller. Bots authenticate with API keys only
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateBotRequest true "Bot"
+// @Success 201 {object} models.Profile
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /bots [post]
+func (a *AuthHandler) CreateBot(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.CreateBot")
+	defer span.End()
+
+	var req struct {
+		Username    string `json:"username"`
+		DisplayName string `json:"display_name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	username := c.GetString("username")
+	bot, err := a.service.CreateBot(ctx, username, req.Username, req.DisplayName)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("failed to create bot", "username", username, "bot", req.Username, "error", err)
+		c.JSON(apiKeyErrorStatus(err), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusCreated, bot)
+}
+
+// @Summary List bots
+// @Tags bots
+// @Description Bots owned by the caller
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string][]models.Profile
+// @Failure 401 {object} map[string]string
+// @Router /bots [get]
+func (a *AuthHandler) GetBots(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.GetBots")
+	defer span.End()
+
+	bots, err := a.service.GetBots(ctx, c.GetString("username"))
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Error("failed to list bots", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list bots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"bots": bots})
+}
+
+// @Summary Create an API key
+// @Tags api-keys
+// @Description Issues a scoped API key for the caller or one of their bots. The key is shown only once
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateAPIKeyRequest true "Key"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /keys [post]
+func (a *AuthHandler) CreateAPIKey(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.CreateAPIKey")
+	defer span.End()
+
+	var req struct {
+		Name          string   `json:"name"`
+		Scopes        []string `json:"scopes"`
+		Bot           string   `json:"bot"`
+		ExpiresInDays int      `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	request := models.NewAPIKey{Name: req.Name, Scopes: req.Scopes, Bot: req.Bot}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		request.ExpiresAt = &expiresAt
+	}
+
+	username := c.GetString("username")
+	rawKey, key, err := a.service.CreateAPIKey(ctx, username, request)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("failed to create api key", "username", username, "error", err)
+		c.JSON(apiKeyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"key": rawKey, "api_key": key})
+}
+
+// @Summary List API keys
+// @Tags api-keys
+// @Description Active API keys of the caller and their bots, without the secrets
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string][]models.APIKey
+// @Failure 401 {object} map[string]string
+// @Router /keys [get]
+func (a *AuthHandler) GetAPIKeys(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.GetAPIKeys")
+	defer span.End()
+
+	keys, err := a.service.GetAPIKeys(ctx, c.GetString("username"))
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Error("failed to list api keys", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+}
+
+// @Summary Revoke an API key
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "API key ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /keys/{id} [delete]
+func (a *AuthHandler) RevokeAPIKey(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.RevokeAPIKey")
+	defer span.End()
+
+	keyID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrAPIKeyNotFound.Error()})
+		return
+	}
+	span.SetAttributes(attribute.Int("api_key.id", keyID))
+
+	username := c.GetString("username")
+	if err := a.service.RevokeAPIKey(ctx, username, keyID); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("failed to revoke api key", "username", username, "key_id", keyID, "error", err)
+		c.JSON(apiKeyErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
+
+func apiKeyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrInvalidBot), errors.Is(err, services.ErrInvalidAPIKeyRequest), errors.Is(err, validation.ErrInvalid):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrBotNotFound), errors.Is(err, services.ErrAPIKeyNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrUsernameTaken), errors.Is(err, services.ErrBotLimitReached):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
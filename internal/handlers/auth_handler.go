@@ -6,10 +6,15 @@ package handlers
 // © 2025 Finimen Sniper / FSC. All rights reserved.
 
 import (
-	"fmt"
+	"errors"
 	"log/slog"
+	"massager/internal/models"
 	"massager/internal/services"
+	"massager/internal/services/validation"
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -29,13 +34,16 @@ func NewAuthHandler(service *services.AuthService, logger *slog.Logger, tracer t
 
 // @Summary User login
 // @Tags auth
-// @Description Authenticates the user and returns a token
+// @Description Authenticates the user and returns a token. Accounts with two-factor authentication get an mfa_token to exchange at /auth/login/mfa instead
 // @Accept json
 // @Produce json
 // @Param request body LoginRequest true "Data for login"
 // @Success 200 {object} map[string]string
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Router /auth/login [post]
 func (a *AuthHandler) Login(c *gin.Context) {
 	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.Login")
@@ -44,6 +52,7 @@ func (a *AuthHandler) Login(c *gin.Context) {
 	var req struct {
 		Username string `json:"username"`
 		Password string `json:"password"`
+		Device   string `json:"device"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -57,27 +66,345 @@ func (a *AuthHandler) Login(c *gin.Context) {
 		attribute.String("user.username", req.Username),
 	)
 
-	token, err := a.service.Login(ctx, req.Username, req.Password)
+	client := models.ClientInfo{
+		DeviceLabel: req.Device,
+		IPAddress:   c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+	}
+
+	result, err := a.service.Login(ctx, req.Username, req.Password, client)
 	if err != nil {
 		span.RecordError(err)
 		a.logger.Warn("login failed", "username", req.Username, "error", err)
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		c.JSON(loginErrorStatus(c, err, http.StatusUnauthorized), gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.MFAToken != "" {
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": result.MFAToken})
 		return
 	}
 
 	a.logger.Info("login successful", "username", req.Username)
-	c.JSON(http.StatusOK, gin.H{"token": token})
+	c.JSON(http.StatusOK, gin.H{"token": result.Tokens.AccessToken, "refresh_token": result.Tokens.RefreshToken})
+}
+
+// @Summary Complete two-factor login
+// @Tags auth
+// @Description Exchanges the mfa_token from /auth/login and a TOTP or recovery code for a token pair
+// @Accept json
+// @Produce json
+// @Param request body MFALoginRequest true "MFA token and code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /auth/login/mfa [post]
+func (a *AuthHandler) LoginMFA(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.LoginMFA")
+	defer span.End()
+
+	var req struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+		Device   string `json:"device"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	client := models.ClientInfo{
+		DeviceLabel: req.Device,
+		IPAddress:   c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+	}
+
+	tokens, err := a.service.CompleteMFALogin(ctx, req.MFAToken, req.Code, client)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("mfa login failed", "error", err)
+
+		switch err {
+		case services.ErrInvalidMFAToken, services.ErrInvalidMFACode:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		default:
+			c.JSON(loginErrorStatus(c, err, http.StatusInternalServerError), gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": tokens.AccessToken, "refresh_token": tokens.RefreshToken})
+}
+
+// @Summary Start TOTP enrollment
+// @Tags auth
+// @Description Generates a TOTP secret and returns it with an otpauth URI for authenticator apps. Two-factor authentication stays off until confirmed
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/mfa/enroll [post]
+func (a *AuthHandler) EnrollMFA(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.EnrollMFA")
+	defer span.End()
+
+	username := c.GetString("username")
+
+	secret, uri, err := a.service.EnrollTOTP(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("totp enrollment failed", "username", username, "error", err)
+		c.JSON(mfaErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"secret": secret, "otpauth_uri": uri})
+}
+
+// @Summary Confirm TOTP enrollment
+// @Tags auth
+// @Description Enables two-factor authentication with a first code from the authenticator app and returns single-use recovery codes
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Authentication code"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /auth/mfa/confirm [post]
+func (a *AuthHandler) ConfirmMFA(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.ConfirmMFA")
+	defer span.End()
+
+	var req struct {
+		Code string `json:"code"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	username := c.GetString("username")
+
+	recoveryCodes, err := a.service.ConfirmTOTP(ctx, username, req.Code)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("totp confirmation failed", "username", username, "error", err)
+		c.JSON(loginErrorStatus(c, err, mfaErrorStatus(err)), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": recoveryCodes})
+}
+
+// @Summary Disable two-factor authentication
+// @Tags auth
+// @Description Turns two-factor authentication off. Requires a current TOTP or an unused recovery code
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body MFACodeRequest true "Authentication or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /auth/mfa/disable [post]
+func (a *AuthHandler) DisableMFA(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.DisableMFA")
+	defer span.End()
+
+	var req struct {
+		Code string `json:"code"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	username := c.GetString("username")
+
+	if err := a.service.DisableTOTP(ctx, username, req.Code); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("disabling totp failed", "username", username, "error", err)
+		c.JSON(loginErrorStatus(c, err, mfaErrorStatus(err)), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// loginErrorStatus maps banned accounts to 403 and throttled logins to 423 for
+// locked accounts and 429 otherwise, setting Retry-After on the response.
+func loginErrorStatus(c *gin.Context, err error, fallback int) int {
+	if errors.Is(err, services.ErrAccountBanned) {
+		return http.StatusForbidden
+	}
+
+	var throttleErr *services.ThrottleError
+	if !errors.As(err, &throttleErr) {
+		return fallback
+	}
+
+	retryAfter := int(math.Ceil(throttleErr.RetryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+
+	if errors.Is(err, services.ErrAccountLocked) {
+		return http.StatusLocked
+	}
+	return http.StatusTooManyRequests
+}
+
+// errorBody adds the per-field messages of validation errors to the usual
+// error response.
+func errorBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+
+	var fieldErrs validation.Errors
+	if errors.As(err, &fieldErrs) {
+		body["fields"] = fieldErrs
+	}
+	return body
+}
+
+func mfaErrorStatus(err error) int {
+	switch err {
+	case services.ErrInvalidMFACode:
+		return http.StatusUnauthorized
+	case services.ErrMFAAlreadyEnabled:
+		return http.StatusConflict
+	case services.ErrMFANotEnabled, services.ErrMFANotEnrolled:
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// @Summary Refresh tokens
+// @Tags auth
+// @Description Exchanges a refresh token for a new access/refresh token pair. The presented refresh token is spent
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest true "Refresh token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /auth/refresh [post]
+func (a *AuthHandler) Refresh(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.Refresh")
+	defer span.End()
+
+	var req RefreshRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	tokens, err := a.service.RefreshTokens(ctx, req.RefreshToken)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("token refresh failed", "error", err)
+
+		switch err {
+		case services.ErrInvalidRefreshToken, services.ErrRefreshTokenReused:
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case services.ErrAccountBanned:
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": tokens.AccessToken, "refresh_token": tokens.RefreshToken})
 }
 
 // @Summary User logout
 // @Tags auth
-// @Description Terminates the user session
+// @Description Terminates the user session. The access token is revoked for the rest of its lifetime, the optional refresh token together with its family
 // @Accept json
 // @Produce json
-// @Success 200
+// @Security BearerAuth
+// @Param request body LogoutRequest false "Refresh token to revoke"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
 // @Router /auth/logout [post]
 func (a *AuthHandler) Logout(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.Logout")
+	defer span.End()
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	// The body is optional: a bare logout only revokes the access token.
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			span.RecordError(err)
+			a.logger.Warn("invalid input format", "error", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+			return
+		}
+	}
+
+	username := c.GetString("username")
+
+	if err := a.service.Logout(ctx, c.GetString("token"), req.RefreshToken); err != nil {
+		span.RecordError(err)
+		a.logger.Error("logout failed", "username", username, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	a.logger.Info("logout successful", "username", username)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
+// @Summary Log out everywhere
+// @Tags auth
+// @Description Revokes every token issued to the user so far and closes the user's WebSocket connections
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/logout-all [post]
+func (a *AuthHandler) LogoutAll(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.LogoutAll")
+	defer span.End()
+
+	username := c.GetString("username")
+
+	if err := a.service.RevokeAllSessions(ctx, username); err != nil {
+		span.RecordError(err)
+		a.logger.Error("revoke all sessions failed", "username", username, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
 
+	a.logger.Info("all sessions revoked", "username", username)
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out from all sessions"})
 }
 
 // AuthHandler represents the authentication handler
@@ -117,18 +444,149 @@ func (a *AuthHandler) Register(c *gin.Context) {
 	if err != nil {
 		span.RecordError(err)
 		a.logger.Warn("register failed", "username", req.Username, "error", err)
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, errorBody(err))
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"massage": "User registered successfully"})
 }
 
+// @Summary List active sessions
+// @Tags auth
+// @Description Returns the devices the account is currently logged in from
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/sessions [get]
+func (a *AuthHandler) GetSessions(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.GetSessions")
+	defer span.End()
+
+	username := c.GetString("username")
+
+	sessions, err := a.service.GetSessions(ctx, username, c.GetString("session_id"))
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Error("failed to get sessions", "username", username, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// @Summary Revoke a session
+// @Tags auth
+// @Description Logs the account out of one device
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Session ID"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /auth/sessions/{id} [delete]
+func (a *AuthHandler) RevokeSession(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.RevokeSession")
+	defer span.End()
+
+	username := c.GetString("username")
+	sessionID := c.Param("id")
+
+	err := a.service.RevokeSession(ctx, username, sessionID)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("failed to revoke session", "username", username, "sessionID", sessionID, "error", err)
+
+		switch err {
+		case services.ErrSessionNotFound:
+			c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
+
+// @Summary Request a password reset
+// @Tags auth
+// @Description Emails a password reset link. The response is the same whether or not the address is registered
+// @Accept json
+// @Produce json
+// @Param request body ForgotPasswordRequest true "Account email"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/password/forgot [post]
+func (a *AuthHandler) ForgotPassword(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.ForgotPassword")
+	defer span.End()
+
+	var req struct {
+		Email string `json:"email"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	if err := a.service.RequestPasswordReset(ctx, req.Email); err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "If the address is registered, a reset link has been sent"})
+}
+
+// @Summary Reset password
+// @Tags auth
+// @Description Sets a new password using the token from the reset email. All existing sessions are revoked
+// @Accept json
+// @Produce json
+// @Param request body ResetPasswordRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Router /auth/password/reset [post]
+func (a *AuthHandler) ResetPassword(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.ResetPassword")
+	defer span.End()
+
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	if err := a.service.ResetPassword(ctx, req.Token, req.Password); err != nil {
+		span.RecordError(err)
+		a.logger.Warn("password reset failed", "error", err)
+		c.JSON(http.StatusBadRequest, errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+}
+
 // @Summary Authentication middleware
 // @Tags auth
-// @Description Checks the JWT token in the Authorization header
+// @Description Checks the JWT token or API key in the Authorization header.
+// @Description API keys are only accepted when they hold one of the given scopes;
+// @Description routes without scopes are reserved for access tokens.
 // @Security BearerAuth
-func (s *AuthHandler) AuthMiddleware() gin.HandlerFunc {
+func (s *AuthHandler) AuthMiddleware(scopes ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenStr := c.GetHeader("Authorization")
 		if tokenStr == "" {
@@ -140,7 +598,7 @@ func (s *AuthHandler) AuthMiddleware() gin.HandlerFunc {
 
 		tokenStr = strings.TrimPrefix(tokenStr, "Bearer ")
 
-		username, err := s.service.ValidateToken(c.Request.Context(), tokenStr)
+		claims, err := s.service.Authenticate(c.Request.Context(), tokenStr)
 		if err != nil {
 			s.logger.Warn("token validation failed", "error", err)
 			c.JSON(401, gin.H{"error": err.Error()})
@@ -148,10 +606,47 @@ func (s *AuthHandler) AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		c.Set("username", username)
+		if claims.APIKeyID != 0 && !slices.ContainsFunc(scopes, claims.HasScope) {
+			s.logger.Warn("api key scope denied", "username", claims.Username, "key_id", claims.APIKeyID, "required", scopes)
+			c.JSON(http.StatusForbidden, gin.H{"error": "api key lacks the required scope"})
+			c.Abort()
+			return
+		}
+
+		c.Set("username", claims.Username)
+		c.Set("session_id", claims.SessionID)
 		c.Set("token", tokenStr)
+		c.Set("api_key_id", claims.APIKeyID)
+		c.Set("role", string(claims.Role))
+
+		s.logger.Debug("request authorized", "username", claims.Username)
+		c.Next()
+	}
+}
+
+// @Summary JSON Web Key Set
+// @Tags auth
+// @Description Public keys for verifying access tokens issued by this server
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /.well-known/jwks.json [get]
+func (a *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, a.service.JWKS())
+}
+
+// RequireRole only lets users holding at least the given global role through.
+// It has to run after AuthMiddleware; API keys never carry a role.
+func (s *AuthHandler) RequireRole(role models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("username")
+		if !models.Role(c.GetString("role")).AtLeast(role) {
+			s.logger.Warn("role check failed", "username", username, "required", role)
+			c.JSON(http.StatusForbidden, gin.H{"error": string(role) + " role required"})
+			c.Abort()
+			return
+		}
 
-		s.logger.Debug("request authorized", "username", username)
 		c.Next()
 	}
 }
@@ -181,29 +676,34 @@ func (a *AuthHandler) VerifyEmail(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Email verefied successfully"})
 }
 
-func (a *AuthHandler) GetVerificationToken(c *gin.Context) {
-	username := c.Query("username")
-	if username == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Username is required"})
-		return
+// @Summary Resend verification email
+// @Tags auth
+// @Description Sends a new verification link to an unverified account found by email or username. The response is the same whether or not such an account exists
+// @Accept json
+// @Produce json
+// @Param request body ResendVerificationRequest true "Email or username"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /auth/verification/resend [post]
+func (a *AuthHandler) ResendVerification(c *gin.Context) {
+	var req struct {
+		Email    string `json:"email"`
+		Username string `json:"username"`
 	}
 
-	token, err := a.service.GetVerificationToken(c.Request.Context(), username)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Email == "" && req.Username == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email or username is required"})
 		return
 	}
 
-	if token == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "No verification token found"})
+	if err := a.service.ResendVerification(c.Request.Context(), req.Email, req.Username); err != nil {
+		a.logger.Warn("verification resend failed", "error", err)
+		c.JSON(loginErrorStatus(c, err, http.StatusInternalServerError), gin.H{"error": err.Error()})
 		return
 	}
 
-	verificationLink := fmt.Sprintf("http://localhost:8080/api/auth/verify-email?token=%s", token)
-	c.JSON(http.StatusOK, gin.H{
-		"verification_link": verificationLink,
-		"message":           "Copy this link to verify your email",
-	})
+	c.JSON(http.StatusOK, gin.H{"message": "If the account exists and is not verified yet, a verification email has been sent"})
 }
 
 func (a *AuthHandler) GetVerificationStatus(c *gin.Context) {
@@ -6,7 +6,9 @@ package handlers
 // © 2025 Finimen Sniper / FSC. All rights reserved.
 
 import (
+	"errors"
 	"log/slog"
+	"massager/internal/models"
 	"massager/internal/services"
 	"net/http"
 	"strconv"
@@ -60,21 +62,17 @@ func (h *ChatHandler) CreateChat(c *gin.Context) {
 		attribute.StringSlice("user.membersIDs", req.MemberIDs),
 	)
 
-	uniqueMembers := make(map[string]bool)
-	uniqueMembers[userID] = true
-
+	// The creator goes first, followed by the requested members in order.
+	finalMembers := []string{userID}
+	seen := map[string]bool{userID: true}
 	for _, member := range req.MemberIDs {
-		if member != userID {
-			uniqueMembers[member] = true
+		if !seen[member] {
+			seen[member] = true
+			finalMembers = append(finalMembers, member)
 		}
 	}
 
-	finalMembers := make([]string, 0, len(uniqueMembers))
-	for member := range uniqueMembers {
-		finalMembers = append(finalMembers, member)
-	}
-
-	chatID, err := h.service.CreateChat(ctx, req.ChatName, finalMembers)
+	chatID, err := h.service.CreateChat(ctx, req.ChatName, userID, finalMembers)
 	if err != nil {
 		span.RecordError(err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -114,7 +112,7 @@ func (h *ChatHandler) GetUserChats(c *gin.Context) {
 
 // @Summary Get chat messages
 // @Tags chats
-// @Description Returns messages for the specified chat with pagination
+// @Description Returns messages for the specified chat with pagination (chat members only)
 // @Accept json
 // @Produce json
 // @Security BearerAuth
@@ -123,6 +121,7 @@ func (h *ChatHandler) GetUserChats(c *gin.Context) {
 // @Param offset query int false "Offset"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /chats/{chatId}/messages [get]
@@ -157,13 +156,16 @@ func (h *ChatHandler) GetChatMessages(c *gin.Context) {
 		limit = 100
 	}
 
-	messages, err := h.service.GetChatMessages(c.Request.Context(), chatID, limit, offset)
+	username := c.GetString("username")
+	messages, err := h.service.GetChatMessages(c.Request.Context(), chatID, username, limit, offset)
 	if err != nil {
 		h.logger.Error("Failed to get chat messages", "error", err, "chatID", chatID)
 
 		switch err {
 		case services.ErrChatNotFound:
 			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
+		case services.ErrNotChatMember:
+			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this chat"})
 		case services.ErrInvalidInput:
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
 		default:
@@ -178,7 +180,7 @@ func (h *ChatHandler) GetChatMessages(c *gin.Context) {
 
 // @Summary Delete chat
 // @Tags chats
-// @Description Deletes a chat (chat members only)
+// @Description Deletes a chat (chat owner only)
 // @Accept json
 // @Produce json
 // @Security BearerAuth
@@ -223,19 +225,341 @@ func (h *ChatHandler) DeleteChat(c *gin.Context) {
 		span.RecordError(err)
 		h.logger.Error("Failed to delete chat", "error", err, "chatID", chatID, "userID", username)
 
-		switch err {
-		case services.ErrChatNotFound:
-			c.JSON(http.StatusNotFound, gin.H{"error": "Chat not found"})
-		case services.ErrNotChatMember:
-			c.JSON(http.StatusForbidden, gin.H{"error": "You are not a member of this chat"})
-		case services.ErrInvalidInput:
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
-		default:
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete chat"})
+		status := chatErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to delete chat"})
+			return
 		}
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
 	h.logger.Info("Chat deleted successfully", "chatID", chatID, "userID", username)
 	c.JSON(http.StatusOK, gin.H{"message": "Chat deleted successfully"})
 }
+
+// @Summary Update chat details
+// @Tags chats
+// @Description Changes the name, description or avatar of a group (chat admins and owner). Omitted fields are left unchanged.
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param request body models.ChatUpdate true "Fields to change"
+// @Success 200 {object} models.Chat
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId} [patch]
+func (h *ChatHandler) UpdateChat(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.UpdateChat")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+
+	var req models.ChatUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	actor := c.GetString("username")
+	span.SetAttributes(attribute.Int("chat.id", chatID))
+
+	chat, err := h.service.UpdateChat(ctx, chatID, actor, req)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to update chat", "chatID", chatID, "actor", actor, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, chat)
+}
+
+// @Summary Change a member's role
+// @Tags chats
+// @Description Promotes a member to admin or demotes an admin to member (chat owner only)
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param username path string true "Member username"
+// @Param request body object true "New role: admin or member"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId}/members/{username}/role [put]
+func (h *ChatHandler) SetMemberRole(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.SetMemberRole")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+
+	var req struct {
+		Role models.ChatRole `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	actor := c.GetString("username")
+	target := c.Param("username")
+	span.SetAttributes(
+		attribute.Int("chat.id", chatID),
+		attribute.String("chat.target", target),
+		attribute.String("chat.role", string(req.Role)),
+	)
+
+	if err := h.service.SetMemberRole(ctx, chatID, actor, target, req.Role); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to change chat member role", "chatID", chatID, "actor", actor, "target", target, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"username": target, "role": req.Role})
+}
+
+// @Summary Transfer chat ownership
+// @Tags chats
+// @Description Makes another member the owner; the current owner becomes an admin
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param request body object true "Username of the new owner"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId}/transfer [post]
+func (h *ChatHandler) TransferOwnership(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.TransferOwnership")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+
+	var req struct {
+		Username string `json:"username" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	actor := c.GetString("username")
+	span.SetAttributes(attribute.Int("chat.id", chatID), attribute.String("chat.new_owner", req.Username))
+
+	if err := h.service.TransferOwnership(ctx, chatID, actor, req.Username); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to transfer chat ownership", "chatID", chatID, "actor", actor, "newOwner", req.Username, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"owner": req.Username})
+}
+
+// @Summary Add chat members
+// @Tags chats
+// @Description Adds users to the chat as members (chat admins and owner)
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param request body object true "Usernames to add"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId}/members [post]
+func (h *ChatHandler) AddMembers(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.AddMembers")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+
+	var req struct {
+		Usernames []string `json:"usernames" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	actor := c.GetString("username")
+	span.SetAttributes(attribute.Int("chat.id", chatID), attribute.StringSlice("chat.usernames", req.Usernames))
+
+	added, err := h.service.AddMembers(ctx, chatID, actor, req.Usernames)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to add chat members", "chatID", chatID, "actor", actor, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"added": added})
+}
+
+// @Summary Remove a chat member
+// @Tags chats
+// @Description Removes a member whose role is below the caller's
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param username path string true "Member username"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId}/members/{username} [delete]
+func (h *ChatHandler) RemoveMember(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.RemoveMember")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+
+	actor := c.GetString("username")
+	target := c.Param("username")
+	span.SetAttributes(attribute.Int("chat.id", chatID), attribute.String("chat.target", target))
+
+	if err := h.service.RemoveMember(ctx, chatID, actor, target); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to remove chat member", "chatID", chatID, "actor", actor, "target", target, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Member removed"})
+}
+
+// @Summary Leave a chat
+// @Tags chats
+// @Description Leaves the chat; an owner who leaves hands the chat to the longest-standing admin or member
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId}/leave [post]
+func (h *ChatHandler) LeaveChat(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.LeaveChat")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.Int("chat.id", chatID))
+
+	if err := h.service.LeaveChat(ctx, chatID, username); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to leave chat", "chatID", chatID, "userID", username, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Left the chat"})
+}
+
+// @Summary Open a direct chat
+// @Tags chats
+// @Description Returns the caller's direct chat with the user, creating it on first use
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Other participant"
+// @Success 200 {object} models.Chat
+// @Success 201 {object} models.Chat
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /dms/{username} [post]
+func (h *ChatHandler) OpenDirectChat(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.OpenDirectChat")
+	defer span.End()
+
+	username := c.GetString("username")
+	other := c.Param("username")
+	span.SetAttributes(attribute.String("chat.other", other))
+
+	chat, created, err := h.service.OpenDirectChat(ctx, username, other)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to open direct chat", "userID", username, "other", other, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if created {
+		c.JSON(http.StatusCreated, chat)
+		return
+	}
+	c.JSON(http.StatusOK, chat)
+}
+
+func chatErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrChatNotFound),
+		errors.Is(err, services.ErrUserNotFound),
+		errors.Is(err, services.ErrInvalidInvite),
+		errors.Is(err, services.ErrInviteNotFound),
+		errors.Is(err, services.ErrMessageNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNotChatMember),
+		errors.Is(err, services.ErrChatPermission):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrInvalidInput),
+		errors.Is(err, services.ErrInvalidChatRole),
+		errors.Is(err, services.ErrInsufficientMembers),
+		errors.Is(err, services.ErrInvalidInviteRequest):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrAlreadyChatMember),
+		errors.Is(err, services.ErrDirectChat):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,186 @@
+package handlers
+
+// PROPRIETARY AND CONFIDENTIAL
+// This code contains trade secrets and confidential material of Finimen Sniper / FSC.
+// Any unauthorized use, disclosure, or duplication is strictly prohibited.
+// © 2025 Finimen Sniper / FSC. All rights reserved.
+
+import (
+	"massager/internal/models"
+	"massager/internal/services"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// @Summary Create an invite link
+// @Tags invites
+// @Description Issues an invite link to the chat (chat admins and owner). The token is shown only once
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param request body CreateChatInviteRequest true "Invite"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId}/invites [post]
+func (h *ChatHandler) CreateInvite(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.CreateInvite")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+
+	var req struct {
+		ExpiresInHours int `json:"expires_in_hours"`
+		MaxUses        int `json:"max_uses"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ExpiresInHours < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	request := models.NewChatInvite{MaxUses: req.MaxUses}
+	if req.ExpiresInHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour)
+		request.ExpiresAt = &expiresAt
+	}
+
+	username := c.GetString("username")
+	token, invite, err := h.service.CreateInvite(ctx, chatID, username, request)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to create chat invite", "chatID", chatID, "username", username, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"token": token, "invite": invite})
+}
+
+// @Summary List invite links
+// @Tags invites
+// @Description Invite links of the chat that have not been revoked, without the tokens (chat admins and owner)
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Success 200 {object} map[string][]models.ChatInvite
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /chats/{chatId}/invites [get]
+func (h *ChatHandler) GetInvites(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.GetInvites")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+
+	invites, err := h.service.GetInvites(ctx, chatID, c.GetString("username"))
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to list chat invites", "chatID", chatID, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"invites": invites})
+}
+
+// @Summary Revoke an invite link
+// @Tags invites
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param inviteId path int true "Invite ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /chats/{chatId}/invites/{inviteId} [delete]
+func (h *ChatHandler) RevokeInvite(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.RevokeInvite")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+	inviteID, err := strconv.Atoi(c.Param("inviteId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrInviteNotFound.Error()})
+		return
+	}
+	span.SetAttributes(attribute.Int("chat.id", chatID), attribute.Int("invite.id", inviteID))
+
+	username := c.GetString("username")
+	if err := h.service.RevokeInvite(ctx, chatID, username, inviteID); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to revoke chat invite", "chatID", chatID, "inviteID", inviteID, "username", username, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invite revoked"})
+}
+
+// @Summary Preview an invite link
+// @Tags invites
+// @Description Shows the chat an invite leads to without joining it
+// @Produce json
+// @Security BearerAuth
+// @Param token path string true "Invite token"
+// @Success 200 {object} models.ChatInvitePreview
+// @Failure 404 {object} map[string]string
+// @Router /invites/{token} [get]
+func (h *ChatHandler) PreviewInvite(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.PreviewInvite")
+	defer span.End()
+
+	preview, err := h.service.PreviewInvite(ctx, c.Param("token"))
+	if err != nil {
+		span.RecordError(err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
+// @Summary Join a chat by invite
+// @Tags invites
+// @Description Joins the chat the invite leads to, using up one of its uses
+// @Produce json
+// @Security BearerAuth
+// @Param token path string true "Invite token"
+// @Success 200 {object} models.Chat
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /invites/{token}/join [post]
+func (h *ChatHandler) JoinByInvite(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.JoinByInvite")
+	defer span.End()
+
+	username := c.GetString("username")
+	chat, err := h.service.JoinByInvite(ctx, c.Param("token"), username)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to join chat by invite", "username", username, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, chat)
+}
@@ -0,0 +1,80 @@
+package handlers
+
+// PROPRIETARY AND CONFIDENTIAL
+// This code contains trade secrets and confidential material of Finimen Sniper / FSC.
+// Any unauthorized use, disclosure, or duplication is strictly prohibited.
+// © 2025 Finimen Sniper / FSC. All rights reserved.
+
+import (
+	"massager/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// @Summary Pin a message
+// @Tags chats
+// @Description Pins a message for every member (chat admins and owner; either side of a direct chat)
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param messageId path int true "Message ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId}/messages/{messageId}/pin [put]
+func (h *ChatHandler) PinMessage(c *gin.Context) {
+	h.setMessagePinned(c, true)
+}
+
+// @Summary Unpin a message
+// @Tags chats
+// @Description Unpins a message for every member (chat admins and owner; either side of a direct chat)
+// @Produce json
+// @Security BearerAuth
+// @Param chatId path int true "Chat ID"
+// @Param messageId path int true "Message ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /chats/{chatId}/messages/{messageId}/pin [delete]
+func (h *ChatHandler) UnpinMessage(c *gin.Context) {
+	h.setMessagePinned(c, false)
+}
+
+func (h *ChatHandler) setMessagePinned(c *gin.Context, pinned bool) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "ChatHandler.PinMessage")
+	defer span.End()
+
+	chatID, err := strconv.Atoi(c.Param("chatId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Chat ID is not int"})
+		return
+	}
+	messageID, err := strconv.Atoi(c.Param("messageId"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrMessageNotFound.Error()})
+		return
+	}
+	span.SetAttributes(attribute.Int("chat.id", chatID), attribute.Int("message.id", messageID), attribute.Bool("message.pinned", pinned))
+
+	username := c.GetString("username")
+	if err := h.service.PinMessage(ctx, chatID, messageID, username, pinned); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to change message pin", "chatID", chatID, "messageID", messageID, "username", username, "error", err)
+		c.JSON(chatErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if pinned {
+		c.JSON(http.StatusOK, gin.H{"message": "Message pinned"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Message unpinned"})
+}
@@ -1,14 +1,51 @@
-//go:build swagger
-// +build swagger
-
 package handlers
 
-// DTO strutcs only for Swagger documetation
+// DTO strutcs for Swagger documetation and request binding
 
 // LoginRequest represents login request data
 type LoginRequest struct {
 	Username string `json:"username" binding:"required"`
 	Password string `json:"password" binding:"required"`
+	Device   string `json:"device"`
+}
+
+// MFALoginRequest represents the second step of a two-factor login
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+	Device   string `json:"device"`
+}
+
+// MFACodeRequest represents a TOTP or recovery code
+type MFACodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// RefreshRequest represents token refresh request data
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// LogoutRequest represents logout request data
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// ForgotPasswordRequest represents password reset request data
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// ResetPasswordRequest represents new password data
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// ResendVerificationRequest represents a verification resend request; one of the fields is required
+type ResendVerificationRequest struct {
+	Email    string `json:"email" example:"john@example.com"`
+	Username string `json:"username" example:"john_doe"`
 }
 
 // RegisterRequest represents registration request data
@@ -23,3 +60,64 @@ type CreateChatRequest struct {
 	MemberIDs []string `json:"member_ids" binding:"required"`
 	ChatName  string   `json:"chat_name" binding:"required"`
 }
+
+// ChangePasswordRequest represents password change data
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangeEmailRequest represents email change data
+type ChangeEmailRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewEmail        string `json:"new_email" binding:"required,email" example:"john.new@example.com"`
+}
+
+// DeleteAccountRequest represents account deletion data
+type DeleteAccountRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// UpdateProfileRequest represents a partial profile update; omitted fields stay unchanged
+type UpdateProfileRequest struct {
+	DisplayName string `json:"display_name" example:"John Doe"`
+	Bio         string `json:"bio" example:"Backend developer"`
+	AvatarURL   string `json:"avatar_url" example:"https://example.com/avatar.png"`
+	Timezone    string `json:"timezone" example:"Europe/Berlin"`
+	// Discoverable controls whether the user appears in /users/search
+	Discoverable bool `json:"discoverable" example:"true"`
+}
+
+// CreateBotRequest represents bot creation data
+type CreateBotRequest struct {
+	Username    string `json:"username" binding:"required" example:"deploy-bot"`
+	DisplayName string `json:"display_name" example:"Deploy Bot"`
+}
+
+// CreateAPIKeyRequest represents API key creation data
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name" binding:"required" example:"CI notifications"`
+	Scopes []string `json:"scopes" binding:"required" example:"messages:read,messages:write"`
+	// Bot issues the key for one of the caller's bots instead of the caller
+	Bot string `json:"bot" example:"deploy-bot"`
+	// ExpiresInDays of 0 creates a key that does not expire
+	ExpiresInDays int `json:"expires_in_days" example:"90"`
+}
+
+// CreateChatInviteRequest represents invite link creation data
+type CreateChatInviteRequest struct {
+	// ExpiresInHours of 0 creates a link that does not expire
+	ExpiresInHours int `json:"expires_in_hours" example:"24"`
+	// MaxUses of 0 allows any number of joins
+	MaxUses int `json:"max_uses" example:"10"`
+}
+
+// BanUserRequest represents the reason given for a ban
+type BanUserRequest struct {
+	Reason string `json:"reason" example:"spam"`
+}
+
+// SetRoleRequest represents a change of a user's global role
+type SetRoleRequest struct {
+	Role string `json:"role" binding:"required" example:"moderator"`
+}
@@ -0,0 +1,118 @@
+package handlers
+
+// PROPRIETARY AND CONFIDENTIAL
+// This code contains trade secrets and confidential material of Finimen Sniper / FSC.
+// Any unauthorized use, disclosure, or duplication is strictly prohibited.
+// © 2025 Finimen Sniper / FSC. All rights reserved.
+
+import (
+	"errors"
+	"massager/internal/models"
+	"massager/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// @Summary List identity providers
+// @Tags auth
+// @Description Names of the configured single sign-on providers, for rendering login buttons
+// @Produce json
+// @Success 200 {object} map[string][]string
+// @Router /auth/oidc/providers [get]
+func (a *AuthHandler) OIDCProviders(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"providers": a.service.OIDCProviders()})
+}
+
+// @Summary Start single sign-on
+// @Tags auth
+// @Description Redirects the browser to the identity provider's login page
+// @Param provider path string true "Provider name"
+// @Success 302
+// @Failure 404 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /auth/oidc/{provider}/login [get]
+func (a *AuthHandler) OIDCLogin(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.OIDCLogin")
+	defer span.End()
+
+	provider := c.Param("provider")
+	span.SetAttributes(attribute.String("oidc.provider", provider))
+
+	authURL, err := a.service.StartOIDCLogin(ctx, provider)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("oidc login start failed", "provider", provider, "error", err)
+		c.JSON(oidcErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// @Summary Single sign-on callback
+// @Tags auth
+// @Description Completes the login the identity provider redirected back from and returns tokens like /auth/login
+// @Produce json
+// @Param provider path string true "Provider name"
+// @Param state query string true "State from the login redirect"
+// @Param code query string true "Authorization code"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /auth/oidc/{provider}/callback [get]
+func (a *AuthHandler) OIDCCallback(c *gin.Context) {
+	ctx, span := a.tracer.Start(c.Request.Context(), "AuthHandler.OIDCCallback")
+	defer span.End()
+
+	provider := c.Param("provider")
+	span.SetAttributes(attribute.String("oidc.provider", provider))
+
+	if providerError := c.Query("error"); providerError != "" {
+		a.logger.Warn("identity provider returned an error", "provider", provider, "error", providerError)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "login at identity provider failed: " + providerError})
+		return
+	}
+
+	client := models.ClientInfo{
+		DeviceLabel: provider,
+		IPAddress:   c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+	}
+
+	result, err := a.service.CompleteOIDCLogin(ctx, provider, c.Query("state"), c.Query("code"), client)
+	if err != nil {
+		span.RecordError(err)
+		a.logger.Warn("oidc login failed", "provider", provider, "error", err)
+		c.JSON(oidcErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	if result.MFAToken != "" {
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": result.MFAToken})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"token": result.Tokens.AccessToken, "refresh_token": result.Tokens.RefreshToken})
+}
+
+func oidcErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrUnknownOIDCProvider):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidOIDCState):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrOIDCAccountNotFound), errors.Is(err, services.ErrAccountBanned):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrOIDCEmailTaken):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrOIDCLoginFailed):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,413 @@
+package handlers
+
+// PROPRIETARY AND CONFIDENTIAL
+// This code contains trade secrets and confidential material of Finimen Sniper / FSC.
+// Any unauthorized use, disclosure, or duplication is strictly prohibited.
+// © 2025 Finimen Sniper / FSC. All rights reserved.
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"massager/internal/models"
+	"massager/internal/services"
+	"massager/internal/services/validation"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type UserHandler struct {
+	service *services.UserService
+	logger  *slog.Logger
+	tracer  trace.Tracer
+}
+
+func NewUserHandler(service *services.UserService, logger *slog.Logger, tracer trace.Tracer) *UserHandler {
+	return &UserHandler{service: service, logger: logger, tracer: tracer}
+}
+
+// UserHandler represents the account settings handler
+// @Summary Get own profile
+// @Tags users
+// @Description Returns the caller's profile together with email and verification state
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.OwnProfile
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me [get]
+func (h *UserHandler) GetMe(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.GetMe")
+	defer span.End()
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	profile, err := h.service.GetOwnProfile(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to get own profile", "username", username, "error", err)
+		c.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Update own profile
+// @Tags users
+// @Description Changes display name, bio, avatar URL or timezone. Omitted fields stay unchanged, empty strings clear them
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateProfileRequest true "Profile fields to change"
+// @Success 200 {object} models.OwnProfile
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me [patch]
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.UpdateMe")
+	defer span.End()
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	var req models.ProfileUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	profile, err := h.service.UpdateProfile(ctx, username, req)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("profile update failed", "username", username, "error", err)
+		c.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Delete own account
+// @Tags users
+// @Description Schedules the account for deletion after a grace period. Until then the deletion can be cancelled; afterwards the account is anonymised and its messages stay in their chats
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body DeleteAccountRequest true "Current password"
+// @Success 202 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me [delete]
+func (h *UserHandler) DeleteMe(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.DeleteMe")
+	defer span.End()
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	var req struct {
+		Password string `json:"password"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	deleteAt, err := h.service.RequestAccountDeletion(ctx, username, req.Password)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("account deletion failed", "username", username, "error", err)
+		c.JSON(loginErrorStatus(c, err, accountErrorStatus(err)), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":               "Account scheduled for deletion",
+		"deletion_scheduled_at": deleteAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// @Summary Cancel account deletion
+// @Tags users
+// @Description Keeps an account whose deletion grace period has not ended yet
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/deletion/cancel [post]
+func (h *UserHandler) CancelDeletion(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.CancelDeletion")
+	defer span.End()
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if err := h.service.CancelAccountDeletion(ctx, username); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("cancelling account deletion failed", "username", username, "error", err)
+		c.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Account deletion cancelled"})
+}
+
+// @Summary Export own data
+// @Tags users
+// @Description Downloads a zip archive with the caller's profile, chats and sent messages as JSON files
+// @Produce application/zip
+// @Security BearerAuth
+// @Success 200 {file} file
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/export [get]
+func (h *UserHandler) ExportMe(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.ExportMe")
+	defer span.End()
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	// Built in memory first so a failure can still be reported as JSON.
+	var archive bytes.Buffer
+	if err := h.service.ExportAccount(ctx, username, &archive); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("account export failed", "username", username, "error", err)
+		c.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+username+`-export.zip"`)
+	c.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+// @Summary Search users
+// @Tags users
+// @Description Finds verified users by username or display name for autocomplete. Users who turned off discoverability are never listed
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search text, at least 2 characters"
+// @Param limit query int false "Page size (default 20, max 50)"
+// @Param offset query int false "Number of results to skip"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/search [get]
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.SearchUsers")
+	defer span.End()
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	limit := 0
+	if limitStr := c.Query("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	offset := 0
+	if offsetStr := c.Query("offset"); offsetStr != "" {
+		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
+			offset = o
+		}
+	}
+
+	users, hasMore, err := h.service.SearchUsers(ctx, username, c.Query("q"), limit, offset)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("user search failed", "username", username, "error", err)
+		c.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "offset": offset, "has_more": hasMore})
+}
+
+// @Summary Get a user's profile
+// @Tags users
+// @Description Returns the public profile of a user
+// @Produce json
+// @Security BearerAuth
+// @Param username path string true "Username"
+// @Success 200 {object} models.Profile
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/{username} [get]
+func (h *UserHandler) GetUser(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.GetUser")
+	defer span.End()
+
+	username := c.Param("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	profile, err := h.service.GetProfile(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		h.logger.Warn("failed to get profile", "username", username, "error", err)
+		c.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Change password
+// @Tags users
+// @Description Changes the password after checking the current one. Every other session is logged out
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangePasswordRequest true "Current and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/password [post]
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.ChangePassword")
+	defer span.End()
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	if err := h.service.ChangePassword(ctx, username, c.GetString("session_id"), req.CurrentPassword, req.NewPassword); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("password change failed", "username", username, "error", err)
+		c.JSON(loginErrorStatus(c, err, accountErrorStatus(err)), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed, other sessions have been logged out"})
+}
+
+// @Summary Change email
+// @Tags users
+// @Description Sends a confirmation link to the new address and notifies the current one. The email changes once the link is opened
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ChangeEmailRequest true "Current password and new email"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 423 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/me/email [post]
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.ChangeEmail")
+	defer span.End()
+
+	username := c.GetString("username")
+	span.SetAttributes(attribute.String("user.username", username))
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewEmail        string `json:"new_email"`
+	}
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("invalid input format", "error", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format"})
+		return
+	}
+
+	if err := h.service.RequestEmailChange(ctx, username, req.CurrentPassword, req.NewEmail); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("email change failed", "username", username, "error", err)
+		c.JSON(loginErrorStatus(c, err, accountErrorStatus(err)), errorBody(err))
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Check the new address for a confirmation link"})
+}
+
+// @Summary Confirm email change
+// @Tags users
+// @Description Switches the account to the new address using the link sent to it
+// @Produce json
+// @Param token query string true "Email change token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /users/email/confirm [get]
+func (h *UserHandler) ConfirmEmailChange(c *gin.Context) {
+	ctx, span := h.tracer.Start(c.Request.Context(), "UserHandler.ConfirmEmailChange")
+	defer span.End()
+
+	if err := h.service.ConfirmEmailChange(ctx, c.Query("token")); err != nil {
+		span.RecordError(err)
+		h.logger.Warn("email change confirmation failed", "error", err)
+		c.JSON(accountErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email changed successfully"})
+}
+
+func accountErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrWrongPassword):
+		return http.StatusForbidden
+	case errors.Is(err, services.ErrEmailTaken):
+		return http.StatusConflict
+	case errors.Is(err, services.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidEmailChangeToken),
+		errors.Is(err, services.ErrInvalidProfile),
+		errors.Is(err, services.ErrInvalidInput),
+		errors.Is(err, validation.ErrInvalid),
+		errors.Is(err, services.ErrPasswordUnchanged),
+		errors.Is(err, services.ErrEmailUnchanged),
+		errors.Is(err, services.ErrAccountSettingsIncomplete):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrDeletionNotScheduled),
+		errors.Is(err, services.ErrDeletionAlreadyPending):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -7,9 +7,11 @@ package handlers
 
 import (
 	"log/slog"
+	"massager/internal/models"
 	"massager/internal/services"
 	internalWebsocket "massager/internal/websocet"
 	"net/http"
+	"strings"
 
 	libWebsocket "github.com/gorilla/websocket"
 	"go.opentelemetry.io/otel/trace"
@@ -37,9 +39,11 @@ func NewWebSocketHandler(hub *internalWebsocket.Hub, authService *services.AuthS
 // @Summary WebSocket connection
 // @Tags websocket
 // @Description Establishes a real-time WebSocket connection
-// @Param token query string false "JWT token (cookie alternative)"
+// @Description API keys need the messages:read scope to connect and messages:write to send
+// @Param token query string false "JWT token or API key (cookie alternative)"
 // @Success 101 "Switching Protocols"
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Router /ws [get]
 func (h *WebsocetHandler) HandleWebSocket(c *gin.Context) {
 	token := c.Query("token")
@@ -49,20 +53,31 @@ func (h *WebsocetHandler) HandleWebSocket(c *gin.Context) {
 			token = cookie.Value
 		}
 	}
+	if token == "" {
+		token = strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	}
 
-	userID, err := h.AuthService.ValidateToken(c.Request.Context(), token)
+	claims, err := h.AuthService.Authenticate(c.Request.Context(), token)
 	if err != nil {
 		h.Logger.Warn("Unauthorized WebSocket connection attempt")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
 
+	if !claims.HasScope(models.ScopeMessagesRead) {
+		h.Logger.Warn("WebSocket connection with insufficient api key scope", "userID", claims.Username)
+		c.JSON(http.StatusForbidden, gin.H{"error": "api key lacks the required scope"})
+		return
+	}
+
 	upgrader := libWebsocket.Upgrader{
 		CheckOrigin: func(r *http.Request) bool {
 			return true
 		},
 	}
 
+	userID := claims.Username
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		h.Logger.Error("WebSocket upgrade failed", "error", err)
@@ -70,10 +85,11 @@ func (h *WebsocetHandler) HandleWebSocket(c *gin.Context) {
 	}
 
 	client := &internalWebsocket.Client{
-		Hub:    h.Hub,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
-		UserID: userID,
+		Hub:      h.Hub,
+		Conn:     conn,
+		Send:     make(chan []byte, 256),
+		UserID:   userID,
+		ReadOnly: !claims.HasScope(models.ScopeMessagesWrite),
 	}
 
 	client.Hub.Register <- client
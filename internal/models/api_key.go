@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// Scopes an API key can be granted. Requests made with a key may only use
+// endpoints that accept one of its scopes.
+const (
+	ScopeChatsRead     = "chats:read"
+	ScopeChatsWrite    = "chats:write"
+	ScopeMessagesRead  = "messages:read"
+	ScopeMessagesWrite = "messages:write"
+	ScopeUsersRead     = "users:read"
+)
+
+var APIKeyScopes = []string{ScopeChatsRead, ScopeChatsWrite, ScopeMessagesRead, ScopeMessagesWrite, ScopeUsersRead}
+
+// APIKey is a long-lived credential of a user or one of their bots. Only the
+// hash of the key is stored; Prefix lets the owner tell keys apart.
+type APIKey struct {
+	ID         int        `json:"id"`
+	Username   string     `json:"username"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	KeyHash    string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"-"`
+}
+
+// NewAPIKey describes a key to create.
+type NewAPIKey struct {
+	Name   string
+	Scopes []string
+	// Bot is the bot the key acts as; empty for a personal key.
+	Bot       string
+	ExpiresAt *time.Time
+}
@@ -3,18 +3,137 @@ package models
 import "time"
 
 type Chat struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
+	ID   int      `json:"id"`
+	Kind ChatKind `json:"kind"`
+	// Name is empty for direct chats.
+	Name string `json:"name"`
+	// Title is what the requesting user should see: the name of a group, or
+	// the other participant's display name in a direct chat.
+	Title     string    `json:"title,omitempty"`
 	Members   []string  `json:"members"`
 	CreatedAt time.Time `json:"created_at"`
+
+	Description string `json:"description,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	// CreatedBy is empty for chats whose creator is not known.
+	CreatedBy string `json:"created_by,omitempty"`
+
+	// DisplayNames maps member usernames to their display names.
+	DisplayNames map[string]string `json:"display_names,omitempty"`
+	// Roles maps member usernames to their role in the chat.
+	Roles map[string]ChatRole `json:"roles,omitempty"`
+}
+
+// ChatUpdate holds the fields of a partial chat update; nil fields are left
+// unchanged and an empty description or avatar URL clears it.
+type ChatUpdate struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	AvatarURL   *string `json:"avatar_url"`
+}
+
+// IsDirect reports whether this is a one-to-one chat. Chats stored before
+// kinds existed are groups.
+func (c *Chat) IsDirect() bool {
+	return c.Kind == ChatKindDirect
+}
+
+// TitleFor returns the title the given member should see.
+func (c *Chat) TitleFor(username string) string {
+	if !c.IsDirect() {
+		return c.Name
+	}
+	for _, member := range c.Members {
+		if member != username {
+			if name := c.DisplayNames[member]; name != "" {
+				return name
+			}
+			return member
+		}
+	}
+	return username
+}
+
+// Role returns the member's role, or "" when username is not a member.
+func (c *Chat) Role(username string) ChatRole {
+	return c.Roles[username]
+}
+
+// Owner returns the username of the chat's owner.
+func (c *Chat) Owner() string {
+	for username, role := range c.Roles {
+		if role == ChatRoleOwner {
+			return username
+		}
+	}
+	return ""
+}
+
+type ChatKind string
+
+const (
+	ChatKindGroup  ChatKind = "group"
+	ChatKindDirect ChatKind = "direct"
+)
+
+// ChatRole is a member's role within one chat. Each role includes the rights
+// of the ones below it.
+type ChatRole string
+
+const (
+	ChatRoleMember ChatRole = "member"
+	ChatRoleAdmin  ChatRole = "admin"
+	ChatRoleOwner  ChatRole = "owner"
+)
+
+var chatRoleRanks = map[ChatRole]int{ChatRoleMember: 1, ChatRoleAdmin: 2, ChatRoleOwner: 3}
+
+func (r ChatRole) Valid() bool {
+	return chatRoleRanks[r] != 0
+}
+
+// AtLeast reports whether r grants the rights of other. Unknown roles grant nothing.
+func (r ChatRole) AtLeast(other ChatRole) bool {
+	return r.Valid() && chatRoleRanks[r] >= chatRoleRanks[other]
+}
+
+// ChatPermission is an action within a chat that not every member may take.
+// Renaming covers the description and avatar as well.
+type ChatPermission string
+
+const (
+	ChatPermissionDelete        ChatPermission = "delete"
+	ChatPermissionRename        ChatPermission = "rename"
+	ChatPermissionManageMembers ChatPermission = "manage_members"
+	ChatPermissionManageRoles   ChatPermission = "manage_roles"
+	ChatPermissionPin           ChatPermission = "pin"
+)
+
+// chatPermissions is the least role each permission needs.
+var chatPermissions = map[ChatPermission]ChatRole{
+	ChatPermissionDelete:        ChatRoleOwner,
+	ChatPermissionManageRoles:   ChatRoleOwner,
+	ChatPermissionRename:        ChatRoleAdmin,
+	ChatPermissionManageMembers: ChatRoleAdmin,
+	ChatPermissionPin:           ChatRoleAdmin,
+}
+
+// Can reports whether the role grants the permission. Unknown permissions are
+// granted to nobody.
+func (r ChatRole) Can(permission ChatPermission) bool {
+	least, ok := chatPermissions[permission]
+	return ok && r.AtLeast(least)
 }
 
 type Message struct {
-	Type      string `json:"type"`
-	ChatID    int    `json:"chat_id,omitempty"`
-	Sender    string `json:"sender,omitempty"`
-	Content   string `json:"content,omitempty"`
-	Timestamp string `json:"timestamp,omitempty"`
+	ID                int    `json:"id,omitempty"`
+	Type              string `json:"type"`
+	ChatID            int    `json:"chat_id,omitempty"`
+	Sender            string `json:"sender,omitempty"`
+	SenderDisplayName string `json:"sender_display_name,omitempty"`
+	Content           string `json:"content,omitempty"`
+	Timestamp         string `json:"timestamp,omitempty"`
+	Pinned            bool   `json:"pinned,omitempty"`
 
 	ChatName  string   `json:"chat_name,omitempty"`
 	Members   []string `json:"members,omitempty"`
@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// ChatInvite lets anyone holding the link join a chat. Only the hash of the
+// token is stored; Prefix lets admins tell links apart.
+type ChatInvite struct {
+	ID        int    `json:"id"`
+	ChatID    int    `json:"chat_id"`
+	CreatedBy string `json:"created_by,omitempty"`
+	Prefix    string `json:"prefix"`
+	TokenHash string `json:"-"`
+	// MaxUses of 0 means the link can be used any number of times.
+	MaxUses   int        `json:"max_uses"`
+	Uses      int        `json:"uses"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	RevokedAt *time.Time `json:"-"`
+}
+
+// Usable reports whether the invite can still be used to join.
+func (i *ChatInvite) Usable(now time.Time) bool {
+	return i.RevokedAt == nil &&
+		(i.ExpiresAt == nil || now.Before(*i.ExpiresAt)) &&
+		(i.MaxUses == 0 || i.Uses < i.MaxUses)
+}
+
+// NewChatInvite describes an invite to create.
+type NewChatInvite struct {
+	ExpiresAt *time.Time
+	MaxUses   int
+}
+
+// ChatInvitePreview is what anyone holding an invite may see of the chat
+// before joining.
+type ChatInvitePreview struct {
+	ChatID      int        `json:"chat_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	AvatarURL   string     `json:"avatar_url,omitempty"`
+	MemberCount int        `json:"member_count"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
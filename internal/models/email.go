@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+type EmailStatus string
+
+const (
+	EmailPending EmailStatus = "pending"
+	EmailSent    EmailStatus = "sent"
+	// EmailDead is an email that ran out of delivery attempts.
+	EmailDead EmailStatus = "dead"
+)
+
+// OutboundEmail is a rendered email kept in the outbox until it is delivered.
+type OutboundEmail struct {
+	ID            int
+	Recipient     string
+	Subject       string
+	HTMLBody      string
+	TextBody      string
+	Status        EmailStatus
+	Attempts      int
+	NextAttemptAt time.Time
+	LastError     string
+	CreatedAt     time.Time
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+type Session struct {
+	ID          string     `json:"id"`
+	Username    string     `json:"-"`
+	DeviceLabel string     `json:"device_label"`
+	IPAddress   string     `json:"ip_address"`
+	UserAgent   string     `json:"user_agent"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	RevokedAt   *time.Time `json:"-"`
+	Current     bool       `json:"current"`
+}
+
+// ClientInfo describes the device a login request comes from.
+type ClientInfo struct {
+	DeviceLabel string
+	IPAddress   string
+	UserAgent   string
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+type TokenPair struct {
+	AccessToken  string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// LoginResult carries either the issued tokens or, for accounts with two-factor
+// authentication, the short-lived token that has to be exchanged together with a code.
+type LoginResult struct {
+	Tokens   *TokenPair
+	MFAToken string
+}
+
+type RefreshToken struct {
+	TokenHash string    `json:"token_hash"`
+	FamilyID  string    `json:"family_id"`
+	Username  string    `json:"username"`
+	IssuedAt  time.Time `json:"issued_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Used      bool      `json:"-"`
+}
+
+type TokenClaims struct {
+	Username  string
+	SessionID string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	Role      Role
+	// APIKeyID is set when the request was authenticated with an API key
+	// instead of an access token; Scopes then limit what it may do.
+	APIKeyID int
+	Scopes   []string
+}
+
+// HasScope reports whether the credential may be used for scope. Access
+// tokens carry the full rights of their user.
+func (c *TokenClaims) HasScope(scope string) bool {
+	return c.APIKeyID == 0 || slices.Contains(c.Scopes, scope)
+}
+
+// OIDCState is what a pending OpenID Connect login remembers between the
+// redirect to the provider and the callback.
+type OIDCState struct {
+	Provider     string `json:"provider"`
+	Nonce        string `json:"nonce"`
+	CodeVerifier string `json:"code_verifier"`
+}
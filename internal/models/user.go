@@ -1,23 +1,121 @@
 package models
 
-import "errors"
+import (
+	"time"
+)
 
 type User struct {
+	Username             string    `json:"username"`
+	Password             string    `json:"password"`
+	Email                string    `json:"emal"`
+	IsVerefied           bool      `json:"is_verefied"`
+	VerifyToken          string    `json:"-"`
+	VerifyTokenExpiresAt time.Time `json:"-"`
+	TOTPSecret           string    `json:"-"`
+	TOTPEnabled          bool      `json:"totp_enabled"`
+	DisplayName          string    `json:"display_name"`
+	Bio                  string    `json:"bio"`
+	AvatarURL            string    `json:"avatar_url"`
+	Timezone             string    `json:"timezone"`
+	Discoverable         bool      `json:"discoverable"`
+	DeletionScheduledAt  time.Time `json:"-"`
+	IsBot                bool      `json:"is_bot"`
+	// BotOwner is the user who created the bot; empty for humans.
+	BotOwner  string    `json:"-"`
+	Role      Role      `json:"role"`
+	BannedAt  time.Time `json:"-"`
+	BanReason string    `json:"-"`
+}
+
+// Role is a user's global role. Each role includes the rights of the ones below it.
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleModerator Role = "moderator"
+	RoleAdmin     Role = "admin"
+)
+
+var roleRanks = map[Role]int{RoleUser: 1, RoleModerator: 2, RoleAdmin: 3}
+
+func (r Role) Valid() bool {
+	return roleRanks[r] != 0
+}
+
+// AtLeast reports whether r grants the rights of other. Unknown roles grant nothing.
+func (r Role) AtLeast(other Role) bool {
+	return r.Valid() && roleRanks[r] >= roleRanks[other]
+}
+
+// UserSummary is what administrators see about an account in listings.
+type UserSummary struct {
+	Username    string     `json:"username"`
+	Email       string     `json:"email"`
+	DisplayName string     `json:"display_name"`
+	Role        Role       `json:"role"`
+	IsVerified  bool       `json:"is_verified"`
+	IsBot       bool       `json:"is_bot"`
+	BannedAt    *time.Time `json:"banned_at,omitempty"`
+	BanReason   string     `json:"ban_reason,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Profile is the public part of an account that other users can see.
+type Profile struct {
 	Username    string `json:"username"`
-	Password    string `json:"password"`
-	Email       string `json:"emal"`
-	IsVerefied  bool   `json:"is_verefied"`
-	VerifyToken string `json:"-"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	Timezone    string `json:"timezone"`
+	IsBot       bool   `json:"is_bot"`
 }
 
-func NewUser(username, password, email string) *User {
-	return &User{Username: username, Password: password, Email: email}
+// OwnProfile is what the owner of an account sees about it.
+type OwnProfile struct {
+	Profile
+	Email        string `json:"email"`
+	IsVerified   bool   `json:"is_verified"`
+	TOTPEnabled  bool   `json:"totp_enabled"`
+	Discoverable bool   `json:"discoverable"`
+	Role         Role   `json:"role"`
+	// DeletionScheduledAt is set while the account waits out its deletion grace period.
+	DeletionScheduledAt *time.Time `json:"deletion_scheduled_at,omitempty"`
+}
+
+// ProfileUpdate holds the fields of a partial profile update; nil fields are
+// left unchanged and empty strings clear them.
+type ProfileUpdate struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	AvatarURL   *string `json:"avatar_url"`
+	Timezone    *string `json:"timezone"`
+	// Discoverable controls whether the user shows up in user search.
+	Discoverable *bool `json:"discoverable"`
+}
+
+func (u *User) Profile() Profile {
+	return Profile{
+		Username:    u.Username,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarURL:   u.AvatarURL,
+		Timezone:    u.Timezone,
+		IsBot:       u.IsBot,
+	}
 }
 
-func ValidateUser(user *User) error {
-	if user.Username == "" || user.Password == "" || user.Email == "" {
-		return errors.New("Empty fields detected")
-	} else {
-		return nil
+func (u *User) IsBanned() bool {
+	return !u.BannedAt.IsZero()
+}
+
+// Name returns the display name, or the username when none is set.
+func (u *User) Name() string {
+	if u.DisplayName != "" {
+		return u.DisplayName
 	}
+	return u.Username
+}
+
+func NewUser(username, password, email string) *User {
+	return &User{Username: username, Password: password, Email: email}
 }
@@ -0,0 +1,19 @@
+package ports
+
+import (
+	"context"
+	"massager/internal/models"
+	"time"
+)
+
+type IAPIKeyRepository interface {
+	// CreateAPIKey stores the key for key.Username and returns its id.
+	CreateAPIKey(ctx context.Context, key models.APIKey) (int, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error)
+	// GetOwnedAPIKeys lists the active keys of the user and of their bots.
+	GetOwnedAPIKeys(ctx context.Context, owner string) ([]models.APIKey, error)
+	// RevokeAPIKey revokes the key if it belongs to the owner or one of their
+	// bots and reports whether it did.
+	RevokeAPIKey(ctx context.Context, id int, owner string) (bool, error)
+	TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error
+}
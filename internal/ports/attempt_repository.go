@@ -0,0 +1,19 @@
+package ports
+
+import (
+	"context"
+	"time"
+)
+
+// AttemptRepository keeps short-lived counters and blocks used for throttling,
+// e.g. failed logins per username or IP address.
+type AttemptRepository interface {
+	// Increment bumps the counter for key and returns the new value. The counter
+	// is forgotten once no increment happened for window.
+	Increment(ctx context.Context, key string, window time.Duration) (int64, error)
+	Reset(ctx context.Context, key string) error
+	Block(ctx context.Context, key string, until time.Time) error
+	// BlockedUntil returns the zero time when key is not blocked.
+	BlockedUntil(ctx context.Context, key string) (time.Time, error)
+	Unblock(ctx context.Context, key string) error
+}
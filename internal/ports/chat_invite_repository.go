@@ -0,0 +1,22 @@
+package ports
+
+import (
+	"context"
+	"massager/internal/models"
+	"time"
+)
+
+type IChatInviteRepository interface {
+	// CreateInvite stores the invite and returns its id.
+	CreateInvite(ctx context.Context, invite models.ChatInvite) (int, error)
+	GetInviteByHash(ctx context.Context, tokenHash string) (*models.ChatInvite, error)
+	// GetChatInvites lists the invites of the chat that have not been revoked.
+	GetChatInvites(ctx context.Context, chatID int) ([]models.ChatInvite, error)
+	// RevokeInvite revokes the invite if it belongs to the chat and reports
+	// whether it did.
+	RevokeInvite(ctx context.Context, chatID, id int) (bool, error)
+	// UseInvite counts one use and adds username to the invite's chat in one
+	// transaction. It reports false, changing nothing, when the invite is no
+	// longer usable at now or username is already in the chat.
+	UseInvite(ctx context.Context, id int, username string, now time.Time) (bool, error)
+}
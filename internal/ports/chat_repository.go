@@ -6,14 +6,32 @@ import (
 )
 
 type IChatRepository interface {
-	CreateChat(ctx context.Context, chatName string, memberIDs []string) (int, error)
+	// CreateChat makes createdBy, who has to be one of the members, the owner.
+	CreateChat(ctx context.Context, chatName, createdBy string, memberIDs []string) (int, error)
 	GetChatByID(ctx context.Context, chatID int) (*models.Chat, error)
+	// GetOrCreateDirectChat returns the direct chat between the two users,
+	// creating it when they have none.
+	GetOrCreateDirectChat(ctx context.Context, username, other string) (chatID int, created bool, err error)
 	GetUserChats(ctx context.Context, userID string) (*[]models.Chat, error)
+	UpdateChat(ctx context.Context, chatID int, update models.ChatUpdate) error
 	DeleteChat(ctx context.Context, chatID int) error
+	// AddMembers adds the users as plain members, all or none.
+	AddMembers(ctx context.Context, chatID int, usernames []string) error
+	// RemoveMember returns the member promoted to owner when the owner is
+	// removed, and "" otherwise.
+	RemoveMember(ctx context.Context, chatID int, username string) (newOwner string, err error)
+	SetMemberRole(ctx context.Context, chatID int, username string, role models.ChatRole) error
+	// TransferOwnership makes newOwner the owner and the previous owner an admin.
+	TransferOwnership(ctx context.Context, chatID int, newOwner string) error
 }
 
 type IMessageRepository interface {
 	CreateMessage(ctx context.Context, senderID, content string, chatID int) error
+	// CreateSystemMessage records a change to the chat made by actor.
+	CreateSystemMessage(ctx context.Context, actor, content string, chatID int) error
 	GetMessages(ctx context.Context, chatID, limit, offset int) ([]models.Message, error)
+	GetMessagesBySender(ctx context.Context, senderID string) ([]models.Message, error)
+	// SetMessagePinned reports whether the chat has a message with that id.
+	SetMessagePinned(ctx context.Context, chatID, messageID int, actor string, pinned bool) (bool, error)
 	DeleteMessagesByChatID(ctx context.Context, chatID int) error
 }
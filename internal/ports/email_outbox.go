@@ -0,0 +1,26 @@
+package ports
+
+import (
+	"context"
+	"massager/internal/models"
+	"time"
+)
+
+type IEmailOutboxRepository interface {
+	EnqueueEmail(ctx context.Context, email models.OutboundEmail) (int, error)
+	// ClaimDueEmails returns up to limit pending emails due at now, counts the
+	// attempt and hides them from other workers until leaseUntil. An email whose
+	// worker dies is picked up again once the lease runs out.
+	ClaimDueEmails(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboundEmail, error)
+	MarkEmailSent(ctx context.Context, id int, sentAt time.Time) error
+	RetryEmail(ctx context.Context, id int, lastError string, retryAt time.Time) error
+	MarkEmailDead(ctx context.Context, id int, lastError string) error
+	// DeleteFinishedEmails removes sent and dead emails queued before the
+	// cutoff and returns how many were removed.
+	DeleteFinishedEmails(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MailTransport delivers a rendered email, e.g. over SMTP.
+type MailTransport interface {
+	Send(ctx context.Context, email models.OutboundEmail) error
+}
@@ -0,0 +1,13 @@
+package ports
+
+import (
+	"context"
+	"massager/internal/models"
+	"time"
+)
+
+type OIDCStateRepository interface {
+	SaveOIDCState(ctx context.Context, stateHash string, state models.OIDCState, ttl time.Duration) error
+	// ConsumeOIDCState returns nil when the state is unknown, expired or already used.
+	ConsumeOIDCState(ctx context.Context, stateHash string) (*models.OIDCState, error)
+}
@@ -2,18 +2,29 @@ package ports
 
 import (
 	"context"
+	"massager/internal/models"
+	"time"
 )
 
 type IMessageService interface {
-	SendMessage(ctx context.Context, senderID, content string, chatID int) error
+	SendMessage(ctx context.Context, senderID, content string, chatID int) (*models.Message, error)
+	IsChatMember(ctx context.Context, chatID int, username string) (bool, error)
 }
 
 type IEmailService interface {
 	SendVerificationEmail(email, token string) error
+	SendPasswordResetEmail(email, token string) error
+	SendAccountLockedEmail(email string, lockedUntil time.Time) error
+	SendEmailChangeVerification(email, token string) error
+	SendEmailChangeNotice(oldEmail, newEmail string) error
+	SendAccountDeletionScheduled(email string, deleteAt time.Time) error
 }
 
 type IHasher interface {
 	GenerateFromPassword(password []byte, cost int) ([]byte, error)
 	CompareHashAndPassword(storedPaswsord []byte, userPassword []byte) error
 	DefaultCost() int
+	// NeedsRehash reports whether a stored hash was made with outdated
+	// parameters and should be replaced on the next successful login.
+	NeedsRehash(storedPassword []byte) bool
 }
@@ -0,0 +1,16 @@
+package ports
+
+import (
+	"context"
+	"massager/internal/models"
+	"time"
+)
+
+type ISessionRepository interface {
+	CreateSession(ctx context.Context, session models.Session) error
+	GetSession(ctx context.Context, sessionID string) (*models.Session, error)
+	GetUserSessions(ctx context.Context, username string) ([]models.Session, error)
+	TouchSession(ctx context.Context, sessionID string, seenAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID string) error
+	RevokeUserSessions(ctx context.Context, username string) error
+}
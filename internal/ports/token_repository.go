@@ -2,10 +2,23 @@ package ports
 
 import (
 	"context"
+	"massager/internal/models"
 	"time"
 )
 
 type TokenRepository interface {
 	IsRevoked(ctx context.Context, tokenHash string) (bool, error)
 	Revoke(ctx context.Context, tokenHash string, expiration time.Duration) error
+	// RevokeAllBefore makes every token of the user issued before cutoff invalid.
+	RevokeAllBefore(ctx context.Context, username string, cutoff time.Time, expiration time.Duration) error
+	GetRevokedBefore(ctx context.Context, username string) (time.Time, error)
+}
+
+type RefreshTokenRepository interface {
+	SaveRefreshToken(ctx context.Context, token models.RefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	// MarkRefreshTokenUsed returns false when the token had already been used.
+	MarkRefreshTokenUsed(ctx context.Context, tokenHash string) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string, expiration time.Duration) error
+	IsFamilyRevoked(ctx context.Context, familyID string) (bool, error)
 }
@@ -3,6 +3,7 @@ package ports
 import (
 	"context"
 	"massager/internal/models"
+	"time"
 )
 
 type IUserRepository interface {
@@ -13,9 +14,41 @@ type IUserRepository interface {
 type IUserRepositoryReader interface {
 	GetUserByName(context.Context, string) (*models.User, error)
 	GetUserByVerifyToken(context.Context, string) (*models.User, error)
+	GetUserByEmail(context.Context, string) (*models.User, error)
+	GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error)
+	ListUsers(ctx context.Context, query string, limit, offset int) ([]models.UserSummary, error)
+	// GetBots lists the bots owned by the user.
+	GetBots(ctx context.Context, owner string) ([]models.Profile, error)
+	SearchUsers(ctx context.Context, query, excludeUsername string, limit, offset int) ([]models.Profile, error)
+	// GetUsersDueForDeletion lists accounts whose deletion grace period ended before now.
+	GetUsersDueForDeletion(ctx context.Context, now time.Time) ([]string, error)
 }
 
 type IUserRepositoryWriter interface {
-	CreateUser(context.Context, string, string, string, string) error
+	CreateUser(ctx context.Context, username, hashedPassword, email, verifyToken string, verifyTokenExpiresAt time.Time) error
+	SetVerifyToken(ctx context.Context, username, verifyToken string, expiresAt time.Time) error
 	MarkUserAsVerified(context.Context, string) error
+	UpdatePassword(ctx context.Context, username, hashedPassword string) error
+	UpdateProfile(ctx context.Context, username string, update models.ProfileUpdate) error
+	SetPendingEmail(ctx context.Context, username, email, tokenHash string, expiresAt time.Time) error
+	ConfirmPendingEmail(ctx context.Context, tokenHash string) (string, string, error)
+	CreatePasswordResetToken(ctx context.Context, username, tokenHash string, expiresAt time.Time) error
+	GetPasswordResetTokenUser(ctx context.Context, tokenHash string) (string, error)
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error)
+	SetTOTPSecret(ctx context.Context, username, secret string) error
+	EnableTOTP(ctx context.Context, username string, recoveryCodeHashes []string) error
+	DisableTOTP(ctx context.Context, username string) error
+	AcceptTOTPStep(ctx context.Context, username string, step int64) (bool, error)
+	ConsumeRecoveryCode(ctx context.Context, username, codeHash string) (bool, error)
+	ScheduleDeletion(ctx context.Context, username string, deleteAt time.Time) error
+	CancelDeletion(ctx context.Context, username string) (bool, error)
+	// AnonymizeUser turns the account into a tombstone. Messages stay in their
+	// chats and are shown under the tombstone's name.
+	AnonymizeUser(ctx context.Context, username string) error
+	LinkIdentity(ctx context.Context, username, provider, subject, email string) error
+	CreateUserWithIdentity(ctx context.Context, username, email, provider, subject string) error
+	CreateBot(ctx context.Context, owner, username, displayName string) error
+	SetRole(ctx context.Context, username string, role models.Role) error
+	BanUser(ctx context.Context, username, reason string, bannedAt time.Time) error
+	UnbanUser(ctx context.Context, username string) error
 }
@@ -0,0 +1,130 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+	"massager/internal/models"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+//go:embed migrations/017_create_api_keys_table_up.sql
+var createAPIKeysTableQuery string
+
+const apiKeyColumns = `k.id, u.username, k.name, k.prefix, k.key_hash, k.scopes, k.created_at, k.last_used_at, k.expires_at, k.revoked_at`
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB, logger *slog.Logger) (*APIKeyRepository, error) {
+	var repo = APIKeyRepository{db: db}
+	var _, err = repo.db.Exec(createAPIKeysTableQuery)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
+
+	logger.Info("api key repository initialization")
+
+	return &repo, nil
+}
+
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key models.APIKey) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, created_at, expires_at)
+		SELECT id, $2, $3, $4, $5, $6, $7 FROM users WHERE username = $1
+		RETURNING id`,
+		key.Username, key.Name, key.Prefix, key.KeyHash, pq.Array(key.Scopes), key.CreatedAt, key.ExpiresAt).Scan(&id)
+	return id, err
+}
+
+// GetAPIKeyByHash ignores keys of banned users and of bots whose owner is banned.
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		LEFT JOIN users o ON o.id = u.owner_id
+		WHERE k.key_hash = $1 AND u.banned_at IS NULL AND o.banned_at IS NULL`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+func (r *APIKeyRepository) GetOwnedAPIKeys(ctx context.Context, owner string) ([]models.APIKey, error) {
+	query := `
+		SELECT ` + apiKeyColumns + `
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		LEFT JOIN users o ON o.id = u.owner_id
+		WHERE (u.username = $1 OR (u.is_bot AND o.username = $1)) AND k.revoked_at IS NULL
+		ORDER BY k.created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id int, owner string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND revoked_at IS NULL AND user_id IN (
+			SELECT u.id FROM users u
+			LEFT JOIN users o ON o.id = u.owner_id
+			WHERE u.username = $2 OR (u.is_bot AND o.username = $2))`, id, owner)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id int, usedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE api_keys SET last_used_at = $1 WHERE id = $2", usedAt, id)
+	return err
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var lastUsedAt, expiresAt, revokedAt sql.NullTime
+
+	err := row.Scan(&key.ID, &key.Username, &key.Name, &key.Prefix, &key.KeyHash, pq.Array(&key.Scopes),
+		&key.CreatedAt, &lastUsedAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return &key, nil
+}
@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+	"time"
+)
+
+//go:embed migrations/027_create_attempts_table_up.sql
+var createAttemptsTableQuery string
+
+// AttemptRepository keeps attempt counters and blocks in Postgres, so login
+// protection holds across instances without Redis.
+type AttemptRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewAttemptRepository(db *sql.DB, logger *slog.Logger) (*AttemptRepository, error) {
+	var repo = AttemptRepository{db: db, logger: logger}
+	var _, err = repo.db.Exec(createAttemptsTableQuery)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
+
+	logger.Info("attempt repository initialization")
+
+	return &repo, nil
+}
+
+// Increment mirrors Redis INCR followed by EXPIRE: an expired counter starts
+// over and every attempt restarts the window.
+func (r *AttemptRepository) Increment(ctx context.Context, key string, window time.Duration) (int64, error) {
+	now := time.Now()
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO attempts (attempt_key, count, expires_at) VALUES ($1, 1, $2)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			count = CASE WHEN attempts.expires_at > $3 THEN attempts.count + 1 ELSE 1 END,
+			expires_at = EXCLUDED.expires_at
+		RETURNING count`, key, now.Add(window), now).Scan(&count)
+	return count, err
+}
+
+func (r *AttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM attempts WHERE attempt_key = $1", key)
+	return err
+}
+
+func (r *AttemptRepository) Block(ctx context.Context, key string, until time.Time) error {
+	if !time.Now().Before(until) {
+		return nil
+	}
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO attempt_blocks (attempt_key, blocked_until) VALUES ($1, $2)
+		ON CONFLICT (attempt_key) DO UPDATE SET blocked_until = EXCLUDED.blocked_until`,
+		key, until)
+	return err
+}
+
+func (r *AttemptRepository) BlockedUntil(ctx context.Context, key string) (time.Time, error) {
+	var until time.Time
+	err := r.db.QueryRowContext(ctx, `
+		SELECT blocked_until FROM attempt_blocks
+		WHERE attempt_key = $1 AND blocked_until > $2`, key, time.Now()).Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return until, nil
+}
+
+func (r *AttemptRepository) Unblock(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM attempt_blocks WHERE attempt_key = $1", key)
+	return err
+}
+
+// DeleteExpired removes expired counters and blocks and returns how many rows
+// were deleted.
+func (r *AttemptRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+
+	counters, err := r.db.ExecContext(ctx, "DELETE FROM attempts WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	blocks, err := r.db.ExecContext(ctx, "DELETE FROM attempt_blocks WHERE blocked_until <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+
+	deletedCounters, _ := counters.RowsAffected()
+	deletedBlocks, _ := blocks.RowsAffected()
+	return deletedCounters + deletedBlocks, nil
+}
+
+// RunSweeper deletes expired rows every interval until ctx is cancelled.
+func (r *AttemptRepository) RunSweeper(ctx context.Context, interval time.Duration) {
+	runSweeper(ctx, interval, r.logger, "attempts", r.DeleteExpired)
+}
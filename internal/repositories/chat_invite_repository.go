@@ -0,0 +1,152 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+	"massager/internal/models"
+	"time"
+)
+
+//go:embed migrations/025_create_chat_invites_table_up.sql
+var createChatInvitesTableQuery string
+
+const chatInviteColumns = `i.id, i.chat_id, COALESCE(u.username, ''), i.prefix, i.token_hash, i.max_uses, i.uses, i.created_at, i.expires_at, i.revoked_at`
+
+type ChatInviteRepository struct {
+	db *sql.DB
+}
+
+func NewChatInviteRepository(db *sql.DB, logger *slog.Logger) (*ChatInviteRepository, error) {
+	var repo = ChatInviteRepository{db: db}
+	var _, err = repo.db.Exec(createChatInvitesTableQuery)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
+
+	logger.Info("chat invite repository initialization")
+
+	return &repo, nil
+}
+
+func (r *ChatInviteRepository) CreateInvite(ctx context.Context, invite models.ChatInvite) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO chat_invites (chat_id, created_by, prefix, token_hash, max_uses, created_at, expires_at)
+		VALUES ($1, (SELECT id FROM users WHERE username = $2), $3, $4, $5, $6, $7)
+		RETURNING id`,
+		invite.ChatID, invite.CreatedBy, invite.Prefix, invite.TokenHash, invite.MaxUses, invite.CreatedAt, invite.ExpiresAt).Scan(&id)
+	return id, err
+}
+
+func (r *ChatInviteRepository) GetInviteByHash(ctx context.Context, tokenHash string) (*models.ChatInvite, error) {
+	query := `
+		SELECT ` + chatInviteColumns + `
+		FROM chat_invites i
+		LEFT JOIN users u ON u.id = i.created_by
+		WHERE i.token_hash = $1`
+
+	invite, err := scanChatInvite(r.db.QueryRowContext(ctx, query, tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return invite, err
+}
+
+func (r *ChatInviteRepository) GetChatInvites(ctx context.Context, chatID int) ([]models.ChatInvite, error) {
+	query := `
+		SELECT ` + chatInviteColumns + `
+		FROM chat_invites i
+		LEFT JOIN users u ON u.id = i.created_by
+		WHERE i.chat_id = $1 AND i.revoked_at IS NULL
+		ORDER BY i.created_at DESC, i.id DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invites := []models.ChatInvite{}
+	for rows.Next() {
+		invite, err := scanChatInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, *invite)
+	}
+
+	return invites, rows.Err()
+}
+
+func (r *ChatInviteRepository) RevokeInvite(ctx context.Context, chatID, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE chat_invites SET revoked_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND chat_id = $2 AND revoked_at IS NULL`, id, chatID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// UseInvite checks and counts the use in one statement, so concurrent joins
+// cannot exceed max_uses, and adds the member through the same insert as
+// AddMembers in the same transaction.
+func (r *ChatInviteRepository) UseInvite(ctx context.Context, id int, username string, now time.Time) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var chatID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE chat_invites SET uses = uses + 1
+		WHERE id = $1
+			AND revoked_at IS NULL
+			AND (expires_at IS NULL OR expires_at > $2)
+			AND (max_uses = 0 OR uses < max_uses)
+		RETURNING chat_id`, id, now).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// A concurrent join may have added the user already; the use is then
+	// rolled back like for an invite that is no longer usable.
+	inserted, err := addMemberTx(ctx, tx, chatID, username)
+	if err != nil || !inserted {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func scanChatInvite(row rowScanner) (*models.ChatInvite, error) {
+	var invite models.ChatInvite
+	var expiresAt, revokedAt sql.NullTime
+
+	err := row.Scan(&invite.ID, &invite.ChatID, &invite.CreatedBy, &invite.Prefix, &invite.TokenHash,
+		&invite.MaxUses, &invite.Uses, &invite.CreatedAt, &expiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		invite.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		invite.RevokedAt = &revokedAt.Time
+	}
+
+	return &invite, nil
+}
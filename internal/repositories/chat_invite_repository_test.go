@@ -0,0 +1,55 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"massager/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatInviteRepository_FailedJoinKeepsTheUse(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	users, err := NewUserRepository(db, slog.Default())
+	require.NoError(t, err)
+	chats, err := NewChatRepository(db, slog.Default())
+	require.NoError(t, err)
+	invites, err := NewChatInviteRepository(db, slog.Default())
+	require.NoError(t, err)
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	owner := "invite-owner-" + hex.EncodeToString(suffix)
+	joiner := "invite-joiner-" + hex.EncodeToString(suffix)
+	for _, username := range []string{owner, joiner} {
+		require.NoError(t, users.CreateUser(ctx, username, "!", username+"@example.invalid", "", time.Now()))
+	}
+
+	chatID, err := chats.CreateChat(ctx, "invites", owner, []string{owner})
+	require.NoError(t, err)
+	inviteID, err := invites.CreateInvite(ctx, models.ChatInvite{
+		ChatID: chatID, CreatedBy: owner, Prefix: "test", TokenHash: "invite-" + hex.EncodeToString(suffix),
+		MaxUses: 1, CreatedAt: time.Now(),
+	})
+	require.NoError(t, err)
+
+	// The owner is already a member, so the use is not counted.
+	used, err := invites.UseInvite(ctx, inviteID, owner, time.Now())
+	require.NoError(t, err)
+	assert.False(t, used)
+
+	used, err = invites.UseInvite(ctx, inviteID, joiner, time.Now())
+	require.NoError(t, err)
+	assert.True(t, used)
+
+	used, err = invites.UseInvite(ctx, inviteID, joiner, time.Now())
+	require.NoError(t, err)
+	assert.False(t, used, "the single use is spent")
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	_ "embed"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"massager/internal/models"
@@ -16,22 +17,39 @@ var createChatTableQuery string
 //go:embed migrations/004_create_chat_participants_up.sql
 var createСhatParticipantsQuery string
 
+//go:embed migrations/021_add_chat_roles_up.sql
+var addChatRolesQuery string
+
+//go:embed migrations/022_add_chat_kind_up.sql
+var addChatKindQuery string
+
+//go:embed migrations/023_add_chat_details_up.sql
+var addChatDetailsQuery string
+
+var chatMigrations = []string{
+	createChatTableQuery,
+	createСhatParticipantsQuery,
+	addChatRolesQuery,
+	addChatKindQuery,
+	addChatDetailsQuery,
+}
+
 type ChatRepository struct {
 	db *sql.DB
 }
 
 func NewChatRepository(db *sql.DB, logger *slog.Logger) (*ChatRepository, error) {
 	var repo = ChatRepository{db: db}
-	var _, err = repo.db.Exec(string(createChatTableQuery))
-	_, err = repo.db.Exec(string(createСhatParticipantsQuery))
-	if err != nil {
-		logger.Error(err.Error())
-		return nil, err
+	for _, migration := range chatMigrations {
+		if _, err := repo.db.Exec(migration); err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
 	}
 
 	logger.Info("chat repository initialization stage 1")
 
-	err = db.Ping()
+	var err = db.Ping()
 	if err != nil {
 		logger.Error(err.Error())
 		return nil, err
@@ -42,7 +60,8 @@ func NewChatRepository(db *sql.DB, logger *slog.Logger) (*ChatRepository, error)
 	return &repo, nil
 }
 
-func (r *ChatRepository) CreateChat(ctx context.Context, name string, memberUsernames []string) (int, error) {
+// CreateChat makes createdBy the owner; createdBy has to be one of the members.
+func (r *ChatRepository) CreateChat(ctx context.Context, name, createdBy string, memberUsernames []string) (int, error) {
 	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
@@ -50,23 +69,30 @@ func (r *ChatRepository) CreateChat(ctx context.Context, name string, memberUser
 	defer tx.Rollback()
 
 	var chatId int64
-	err = tx.QueryRowContext(ctx, "INSERT INTO chats (chatname) VALUES ($1) RETURNING id", name).Scan(&chatId)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO chats (chatname, created_by)
+		VALUES ($1, (SELECT id FROM users WHERE username = $2))
+		RETURNING id`, name, createdBy).Scan(&chatId)
 	if err != nil {
 		return 0, err
 	}
 
-	userIDs, err := r.getUserIDsByUsernames(ctx, tx, memberUsernames)
-	if err != nil {
-		return 0, fmt.Errorf("failed to find users: %v", err)
-	}
+	for _, username := range memberUsernames {
+		role := models.ChatRoleMember
+		if username == createdBy {
+			role = models.ChatRoleOwner
+		}
 
-	for _, userID := range userIDs {
-		_, err = tx.ExecContext(ctx,
-			"INSERT INTO chat_participants (chat_id, user_id) VALUES ($1, $2)",
-			chatId, userID)
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO chat_participants (chat_id, user_id, role)
+			SELECT $1, id, $3 FROM users WHERE username = $2`,
+			chatId, username, role)
 		if err != nil {
 			return 0, err
 		}
+		if inserted, _ := result.RowsAffected(); inserted == 0 {
+			return 0, fmt.Errorf("failed to find users: %s not found", username)
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -76,26 +102,82 @@ func (r *ChatRepository) CreateChat(ctx context.Context, name string, memberUser
 	return int(chatId), nil
 }
 
+// GetOrCreateDirectChat returns the direct chat between the two users,
+// creating it when they have none. created reports whether it was new.
+func (r *ChatRepository) GetOrCreateDirectChat(ctx context.Context, username, other string) (chatID int, created bool, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback()
+
+	var userID, otherID int
+	err = tx.QueryRowContext(ctx, `
+		SELECT
+			(SELECT id FROM users WHERE username = $1),
+			(SELECT id FROM users WHERE username = $2)`, username, other).Scan(&userID, &otherID)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to find users %s and %s: %w", username, other, err)
+	}
+
+	directKey := fmt.Sprintf("%d:%d", min(userID, otherID), max(userID, otherID))
+
+	// A concurrent request for the same pair loses on the unique index and
+	// falls through to the lookup below.
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO chats (chatname, kind, direct_key, created_by)
+		VALUES ('', 'direct', $1, $2)
+		ON CONFLICT (direct_key) WHERE kind = 'direct' DO NOTHING
+		RETURNING id`, directKey, userID).Scan(&chatID)
+	if err == sql.ErrNoRows {
+		err = tx.QueryRowContext(ctx,
+			"SELECT id FROM chats WHERE kind = 'direct' AND direct_key = $1", directKey).Scan(&chatID)
+		if err != nil {
+			return 0, false, err
+		}
+		return chatID, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO chat_participants (chat_id, user_id)
+		VALUES ($1, $2), ($1, $3)`, chatID, userID, otherID)
+	if err != nil {
+		return 0, false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, false, err
+	}
+
+	return chatID, true, nil
+}
+
 func (r *ChatRepository) GetUserChats(ctx context.Context, username string) (*[]models.Chat, error) {
 	var chats []models.Chat
 
 	query := `
 		SELECT 
 			c.id, 
+			c.kind,
 			c.chatname,
-			cp.joined_at,
-			ARRAY_AGG(u.username) as members
+			c.description,
+			c.avatar_url,
+			me.joined_at,
+			COALESCE(creator.username, '') as created_by,
+			ARRAY_AGG(u.username) as members,
+			JSON_OBJECT_AGG(u.username, COALESCE(NULLIF(u.display_name, ''), u.username)) as display_names,
+			JSON_OBJECT_AGG(u.username, cp.role) as roles
 		FROM chats c
+		JOIN chat_participants me ON me.chat_id = c.id
+		JOIN users mu ON mu.id = me.user_id AND mu.username = $1
 		JOIN chat_participants cp ON c.id = cp.chat_id
 		JOIN users u ON u.id = cp.user_id
-		WHERE c.id IN (
-			SELECT chat_id 
-			FROM chat_participants cp2
-			JOIN users u2 ON u2.id = cp2.user_id 
-			WHERE u2.username = $1
-		)
-		GROUP BY c.id, c.chatname, cp.joined_at
-		ORDER BY cp.joined_at DESC`
+		LEFT JOIN users creator ON creator.id = c.created_by
+		GROUP BY c.id, c.kind, c.chatname, c.description, c.avatar_url, me.joined_at, creator.username
+		ORDER BY me.joined_at DESC`
 
 	rows, err := r.db.QueryContext(ctx, query, username)
 	if err != nil {
@@ -107,12 +189,22 @@ func (r *ChatRepository) GetUserChats(ctx context.Context, username string) (*[]
 		var chat models.Chat
 		var joinedAt sql.NullTime
 		var members string // PostgreSQL reterns ARRAY_AGG like string
+		var displayNames, roles []byte
 
-		err := rows.Scan(&chat.ID, &chat.Name, &joinedAt, &members)
+		err := rows.Scan(&chat.ID, &chat.Kind, &chat.Name, &chat.Description, &chat.AvatarURL, &joinedAt, &chat.CreatedBy, &members, &displayNames, &roles)
 		if err != nil {
 			return nil, err
 		}
 
+		if err := json.Unmarshal(displayNames, &chat.DisplayNames); err != nil {
+			return nil, err
+		}
+		if !chat.IsDirect() {
+			if err := json.Unmarshal(roles, &chat.Roles); err != nil {
+				return nil, err
+			}
+		}
+
 		if joinedAt.Valid {
 			chat.CreatedAt = joinedAt.Time
 		}
@@ -139,20 +231,30 @@ func (r *ChatRepository) GetUserChats(ctx context.Context, username string) (*[]
 func (r *ChatRepository) GetChatByID(ctx context.Context, chatID int) (*models.Chat, error) {
 	var chat models.Chat
 	var members string
+	var createdAt sql.NullTime
+	var displayNames, roles []byte
 
 	query := `
 		SELECT 
 			c.id, 
+			c.kind,
 			c.chatname,
-			ARRAY_AGG(u.username) as members
+			c.description,
+			c.avatar_url,
+			c.created_at,
+			COALESCE(creator.username, '') as created_by,
+			ARRAY_AGG(u.username) as members,
+			JSON_OBJECT_AGG(u.username, COALESCE(NULLIF(u.display_name, ''), u.username)) as display_names,
+			JSON_OBJECT_AGG(u.username, cp.role) as roles
 		FROM chats c
 		JOIN chat_participants cp ON c.id = cp.chat_id
 		JOIN users u ON u.id = cp.user_id
+		LEFT JOIN users creator ON creator.id = c.created_by
 		WHERE c.id = $1
-		GROUP BY c.id, c.chatname`
+		GROUP BY c.id, c.kind, c.chatname, c.description, c.avatar_url, c.created_at, creator.username`
 
 	err := r.db.QueryRowContext(ctx, query, chatID).
-		Scan(&chat.ID, &chat.Name, &members)
+		Scan(&chat.ID, &chat.Kind, &chat.Name, &chat.Description, &chat.AvatarURL, &createdAt, &chat.CreatedBy, &members, &displayNames, &roles)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -160,6 +262,20 @@ func (r *ChatRepository) GetChatByID(ctx context.Context, chatID int) (*models.C
 		return nil, err
 	}
 
+	if err := json.Unmarshal(displayNames, &chat.DisplayNames); err != nil {
+		return nil, err
+	}
+	// Direct chats have no roles.
+	if !chat.IsDirect() {
+		if err := json.Unmarshal(roles, &chat.Roles); err != nil {
+			return nil, err
+		}
+	}
+
+	if createdAt.Valid {
+		chat.CreatedAt = createdAt.Time
+	}
+
 	if members != "" {
 		members = strings.Trim(members, "{}")
 		if members != "" {
@@ -170,74 +286,166 @@ func (r *ChatRepository) GetChatByID(ctx context.Context, chatID int) (*models.C
 	return &chat, nil
 }
 
+func (r *ChatRepository) UpdateChat(ctx context.Context, chatID int, update models.ChatUpdate) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE chats SET
+			chatname = COALESCE($1, chatname),
+			description = COALESCE($2, description),
+			avatar_url = COALESCE($3, avatar_url)
+		WHERE id = $4`,
+		update.Name, update.Description, update.AvatarURL, chatID)
+	return err
+}
+
 func (r *ChatRepository) DeleteChat(ctx context.Context, chatID int) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM chats WHERE id = $1", chatID)
 	return err
 }
 
-func (r *ChatRepository) getChatMembers(ctx context.Context, chatID int) ([]string, error) {
-	query := `
-		SELECT u.username 
-		FROM users u
-		JOIN chat_participants cp ON u.id = cp.user_id
-		WHERE cp.chat_id = $1`
+// AddMembers adds the users as plain members, all or none.
+func (r *ChatRepository) AddMembers(ctx context.Context, chatID int, usernames []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
 
-	rows, err := r.db.QueryContext(ctx, query, chatID)
+	for _, username := range usernames {
+		inserted, err := addMemberTx(ctx, tx, chatID, username)
+		if err != nil {
+			return err
+		}
+		if !inserted {
+			return fmt.Errorf("failed to add %s to chat %d: user not found or already a member", username, chatID)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// addMemberTx stores the user as a plain member of the chat inside tx. It is
+// the single place memberships are added after a chat is created, so manual
+// adds and invite joins follow the same rules. inserted is false when the
+// user does not exist or is already a member.
+func addMemberTx(ctx context.Context, tx *sql.Tx, chatID int, username string) (inserted bool, err error) {
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO chat_participants (chat_id, user_id, role)
+		SELECT $1, id, $3 FROM users WHERE username = $2
+		ON CONFLICT (chat_id, user_id) DO NOTHING`,
+		chatID, username, models.ChatRoleMember)
 	if err != nil {
-		return nil, err
+		return false, err
 	}
-	defer rows.Close()
 
-	var members []string
-	for rows.Next() {
-		var username string
-		if err := rows.Scan(&username); err != nil {
-			return nil, err
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
+// RemoveMember takes the user out of the chat. When the owner leaves, the
+// longest-standing admin, or failing that member, becomes the owner and is
+// returned as newOwner.
+func (r *ChatRepository) RemoveMember(ctx context.Context, chatID int, username string) (newOwner string, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var role models.ChatRole
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM chat_participants
+		WHERE chat_id = $1 AND user_id = (SELECT id FROM users WHERE username = $2)
+		RETURNING role`, chatID, username).Scan(&role)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", fmt.Errorf("%s is not a member of chat %d", username, chatID)
 		}
-		members = append(members, username)
+		return "", err
 	}
 
-	return members, nil
+	if role == models.ChatRoleOwner {
+		err = tx.QueryRowContext(ctx, `
+			UPDATE chat_participants cp SET role = 'owner'
+			FROM users u
+			WHERE u.id = cp.user_id AND cp.id = (
+				SELECT id FROM chat_participants
+				WHERE chat_id = $1
+				ORDER BY role = 'admin' DESC, joined_at, id
+				LIMIT 1
+			)
+			RETURNING u.username`, chatID).Scan(&newOwner)
+		if err != nil && err != sql.ErrNoRows {
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return newOwner, nil
 }
 
-func (r *ChatRepository) getUserIDsByUsernames(ctx context.Context, tx *sql.Tx, usernames []string) ([]int, error) {
-	if len(usernames) == 0 {
-		return []int{}, nil
+func (r *ChatRepository) SetMemberRole(ctx context.Context, chatID int, username string, role models.ChatRole) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE chat_participants SET role = $3
+		WHERE chat_id = $1 AND user_id = (SELECT id FROM users WHERE username = $2)`,
+		chatID, username, role)
+	return err
+}
+
+// TransferOwnership makes newOwner the owner and the previous owner an admin.
+func (r *ChatRepository) TransferOwnership(ctx context.Context, chatID int, newOwner string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The previous owner steps down first: a chat has at most one owner.
+	_, err = tx.ExecContext(ctx,
+		"UPDATE chat_participants SET role = 'admin' WHERE chat_id = $1 AND role = 'owner'", chatID)
+	if err != nil {
+		return err
 	}
 
-	// Create placeholders for IN request
-	placeholders := make([]string, len(usernames))
-	args := make([]interface{}, len(usernames))
-	for i, username := range usernames {
-		placeholders[i] = fmt.Sprintf("$%d", i+1)
-		args[i] = username
+	result, err := tx.ExecContext(ctx, `
+		UPDATE chat_participants SET role = 'owner'
+		WHERE chat_id = $1 AND user_id = (SELECT id FROM users WHERE username = $2)`,
+		chatID, newOwner)
+	if err != nil {
+		return err
+	}
+	if updated, _ := result.RowsAffected(); updated == 0 {
+		return fmt.Errorf("%s is not a member of chat %d", newOwner, chatID)
 	}
 
-	query := fmt.Sprintf(`
-		SELECT id 
-		FROM users 
-		WHERE username IN (%s)`, strings.Join(placeholders, ","))
+	return tx.Commit()
+}
 
-	rows, err := tx.QueryContext(ctx, query, args...)
+func (r *ChatRepository) getChatMembers(ctx context.Context, chatID int) ([]string, error) {
+	query := `
+		SELECT u.username 
+		FROM users u
+		JOIN chat_participants cp ON u.id = cp.user_id
+		WHERE cp.chat_id = $1`
+
+	rows, err := r.db.QueryContext(ctx, query, chatID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var userIDs []int
+	var members []string
 	for rows.Next() {
-		var userID int
-		if err := rows.Scan(&userID); err != nil {
+		var username string
+		if err := rows.Scan(&username); err != nil {
 			return nil, err
 		}
-		userIDs = append(userIDs, userID)
-	}
-
-	if len(userIDs) != len(usernames) {
-		return nil, fmt.Errorf("some users not found")
+		members = append(members, username)
 	}
 
-	return userIDs, nil
+	return members, nil
 }
 
 func (r *ChatRepository) getUsernameByID(ctx context.Context, userID int) (string, error) {
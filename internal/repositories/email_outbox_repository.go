@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+	"massager/internal/models"
+	"time"
+)
+
+//go:embed migrations/020_create_email_outbox_table_up.sql
+var createEmailOutboxTableQuery string
+
+type EmailOutboxRepository struct {
+	db *sql.DB
+}
+
+func NewEmailOutboxRepository(db *sql.DB, logger *slog.Logger) (*EmailOutboxRepository, error) {
+	var repo = EmailOutboxRepository{db: db}
+	var _, err = repo.db.Exec(createEmailOutboxTableQuery)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
+
+	logger.Info("email outbox repository initialization")
+
+	return &repo, nil
+}
+
+func (r *EmailOutboxRepository) EnqueueEmail(ctx context.Context, email models.OutboundEmail) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO email_outbox (recipient, subject, html_body, text_body, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id`,
+		email.Recipient, email.Subject, email.HTMLBody, email.TextBody, time.Now()).Scan(&id)
+	return id, err
+}
+
+func (r *EmailOutboxRepository) ClaimDueEmails(ctx context.Context, now, leaseUntil time.Time, limit int) ([]models.OutboundEmail, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		UPDATE email_outbox SET attempts = attempts + 1, next_attempt_at = $2
+		WHERE id IN (
+			SELECT id FROM email_outbox
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, recipient, subject, html_body, text_body, status, attempts, next_attempt_at, last_error, created_at`,
+		now, leaseUntil, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var emails []models.OutboundEmail
+	for rows.Next() {
+		var email models.OutboundEmail
+		if err := rows.Scan(&email.ID, &email.Recipient, &email.Subject, &email.HTMLBody, &email.TextBody,
+			&email.Status, &email.Attempts, &email.NextAttemptAt, &email.LastError, &email.CreatedAt); err != nil {
+			return nil, err
+		}
+		emails = append(emails, email)
+	}
+
+	return emails, rows.Err()
+}
+
+// MarkEmailSent records the delivery and clears the bodies, which may hold
+// live verification or reset links.
+func (r *EmailOutboxRepository) MarkEmailSent(ctx context.Context, id int, sentAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE email_outbox SET status = 'sent', sent_at = $2, last_error = '', html_body = '', text_body = ''
+		WHERE id = $1`, id, sentAt)
+	return err
+}
+
+func (r *EmailOutboxRepository) RetryEmail(ctx context.Context, id int, lastError string, retryAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE email_outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1", id, lastError, retryAt)
+	return err
+}
+
+// MarkEmailDead gives up on the email and clears its bodies like MarkEmailSent.
+func (r *EmailOutboxRepository) MarkEmailDead(ctx context.Context, id int, lastError string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE email_outbox SET status = 'dead', last_error = $2, html_body = '', text_body = ''
+		WHERE id = $1`, id, lastError)
+	return err
+}
+
+func (r *EmailOutboxRepository) DeleteFinishedEmails(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM email_outbox WHERE status IN ('sent', 'dead') AND created_at < $1", before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,50 @@
+package repositories
+
+import (
+	"context"
+	"log/slog"
+	"massager/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmailOutboxRepository_FinishedEmailsKeepNoBodies(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	outbox, err := NewEmailOutboxRepository(db, slog.Default())
+	require.NoError(t, err)
+
+	email := models.OutboundEmail{
+		Recipient: "user@example.invalid",
+		Subject:   "Reset Your Password",
+		HTMLBody:  `<a href="https://chat.example.com/reset?token=secret">reset</a>`,
+		TextBody:  "https://chat.example.com/reset?token=secret",
+	}
+	sentID, err := outbox.EnqueueEmail(ctx, email)
+	require.NoError(t, err)
+	deadID, err := outbox.EnqueueEmail(ctx, email)
+	require.NoError(t, err)
+
+	require.NoError(t, outbox.MarkEmailSent(ctx, sentID, time.Now()))
+	require.NoError(t, outbox.MarkEmailDead(ctx, deadID, "mailbox unavailable"))
+
+	for _, id := range []int{sentID, deadID} {
+		var htmlBody, textBody string
+		err := db.QueryRowContext(ctx, "SELECT html_body, text_body FROM email_outbox WHERE id = $1", id).
+			Scan(&htmlBody, &textBody)
+		require.NoError(t, err)
+		assert.Empty(t, htmlBody)
+		assert.Empty(t, textBody)
+	}
+
+	_, err = outbox.DeleteFinishedEmails(ctx, time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	var left int
+	require.NoError(t, db.QueryRowContext(ctx,
+		"SELECT COUNT(*) FROM email_outbox WHERE id IN ($1, $2)", sentID, deadID).Scan(&left))
+	assert.Zero(t, left)
+}
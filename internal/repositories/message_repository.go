@@ -11,21 +11,38 @@ import (
 //go:embed migrations/005_create_messages_table_up.sql
 var createMessageTableQuery string
 
+//go:embed migrations/014_restrict_message_sender_delete_up.sql
+var restrictMessageSenderDeleteQuery string
+
+//go:embed migrations/024_add_message_kind_up.sql
+var addMessageKindQuery string
+
+//go:embed migrations/030_add_message_pins_up.sql
+var addMessagePinsQuery string
+
+var messageMigrations = []string{
+	createMessageTableQuery,
+	restrictMessageSenderDeleteQuery,
+	addMessageKindQuery,
+	addMessagePinsQuery,
+}
+
 type MessageRepository struct {
 	db *sql.DB
 }
 
 func NewMessageRepository(db *sql.DB, logger *slog.Logger) (*MessageRepository, error) {
 	var repo = MessageRepository{db: db}
-	var _, err = repo.db.Exec(string(createMessageTableQuery))
-	if err != nil {
-		logger.Error(err.Error())
-		return nil, err
+	for _, migration := range messageMigrations {
+		if _, err := repo.db.Exec(migration); err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
 	}
 
 	logger.Info("messege initialization: stage 1")
 
-	err = db.Ping()
+	var err = db.Ping()
 	if err != nil {
 		logger.Error(err.Error())
 		return nil, err
@@ -50,14 +67,27 @@ func (r *MessageRepository) CreateMessage(ctx context.Context, senderName, conte
 	return nil
 }
 
+// CreateSystemMessage records a change to the chat made by actor.
+func (r *MessageRepository) CreateSystemMessage(ctx context.Context, actor, content string, chatID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO messages (chat_id, sender_id, message_content, kind)
+		SELECT $1, id, $3, 'system' FROM users WHERE username = $2`,
+		chatID, actor, content)
+	return err
+}
+
 func (r *MessageRepository) GetMessages(ctx context.Context, chatID, limit, offset int) ([]models.Message, error) {
 	query := `
 		SELECT 
+			m.id,
+			CASE m.kind WHEN 'system' THEN 'system' ELSE 'message' END,
 			u.username,
+			COALESCE(NULLIF(u.display_name, ''), u.username),
 			m.message_content,
 			m.created_at,
 			c.chatname,
-			m.chat_id
+			m.chat_id,
+			m.pinned_at IS NOT NULL
 		FROM messages m
 		JOIN users u ON m.sender_id = u.id
 		JOIN chats c ON m.chat_id = c.id
@@ -75,7 +105,7 @@ func (r *MessageRepository) GetMessages(ctx context.Context, chatID, limit, offs
 	for rows.Next() {
 		var message models.Message
 
-		err = rows.Scan(&message.Sender, &message.Content, &message.Timestamp, &message.ChatName, &message.ChatID)
+		err = rows.Scan(&message.ID, &message.Type, &message.Sender, &message.SenderDisplayName, &message.Content, &message.Timestamp, &message.ChatName, &message.ChatID, &message.Pinned)
 		if err != nil {
 			return nil, err
 		}
@@ -90,6 +120,58 @@ func (r *MessageRepository) GetMessages(ctx context.Context, chatID, limit, offs
 	return messages, nil
 }
 
+// GetMessagesBySender returns every message the user has sent, oldest first.
+// System messages are left out.
+func (r *MessageRepository) GetMessagesBySender(ctx context.Context, senderName string) ([]models.Message, error) {
+	query := `
+		SELECT m.message_content, m.created_at, c.chatname, m.chat_id
+		FROM messages m
+		JOIN users u ON m.sender_id = u.id
+		JOIN chats c ON m.chat_id = c.id
+		WHERE u.username = $1 AND m.kind = 'user'
+		ORDER BY m.created_at, m.id`
+
+	rows, err := r.db.QueryContext(ctx, query, senderName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []models.Message{}
+	for rows.Next() {
+		var message models.Message
+		if err := rows.Scan(&message.Content, &message.Timestamp, &message.ChatName, &message.ChatID); err != nil {
+			return nil, err
+		}
+		message.Sender = senderName
+		messages = append(messages, message)
+	}
+
+	return messages, rows.Err()
+}
+
+// SetMessagePinned pins or unpins the message and reports whether the chat has
+// a message with that id.
+func (r *MessageRepository) SetMessagePinned(ctx context.Context, chatID, messageID int, actor string, pinned bool) (bool, error) {
+	var result sql.Result
+	var err error
+	if pinned {
+		result, err = r.db.ExecContext(ctx, `
+			UPDATE messages SET pinned_at = CURRENT_TIMESTAMP, pinned_by = (SELECT id FROM users WHERE username = $3)
+			WHERE id = $1 AND chat_id = $2`, messageID, chatID, actor)
+	} else {
+		result, err = r.db.ExecContext(ctx, `
+			UPDATE messages SET pinned_at = NULL, pinned_by = NULL
+			WHERE id = $1 AND chat_id = $2`, messageID, chatID)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	return affected > 0, err
+}
+
 func (r *MessageRepository) DeleteMessagesByChatID(ctx context.Context, chatID int) error {
 	_, err := r.db.ExecContext(ctx, "DELETE FROM messages WHERE chat_id = $1", chatID)
 	return err
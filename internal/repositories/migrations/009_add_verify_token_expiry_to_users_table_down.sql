@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_users_verify_token;
+ALTER TABLE users DROP COLUMN IF EXISTS verify_token_expires_at;
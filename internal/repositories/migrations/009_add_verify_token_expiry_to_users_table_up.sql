@@ -0,0 +1,3 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS verify_token_expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_verify_token ON users(verify_token);
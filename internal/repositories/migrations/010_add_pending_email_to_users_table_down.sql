@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_users_pending_email_token;
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email_expires_at;
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email_token;
+ALTER TABLE users DROP COLUMN IF EXISTS pending_email;
@@ -0,0 +1,5 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email TEXT;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email_token VARCHAR(255);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email_expires_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_users_pending_email_token ON users(pending_email_token);
@@ -0,0 +1,10 @@
+-- Messages outlive their sender: accounts are anonymised instead of deleted,
+-- and a raw delete of a user with messages now fails instead of cascading.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'messages_sender_id_fkey' AND confdeltype = 'c') THEN
+        ALTER TABLE messages DROP CONSTRAINT messages_sender_id_fkey;
+        ALTER TABLE messages ADD CONSTRAINT messages_sender_id_fkey
+            FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE RESTRICT;
+    END IF;
+END $$;
\ No newline at end of file
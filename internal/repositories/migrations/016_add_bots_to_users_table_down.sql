@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_users_owner;
+ALTER TABLE users DROP COLUMN IF EXISTS owner_id;
+ALTER TABLE users DROP COLUMN IF EXISTS is_bot;
\ No newline at end of file
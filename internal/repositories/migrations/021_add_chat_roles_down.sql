@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_chat_participants_owner;
+ALTER TABLE chat_participants DROP COLUMN IF EXISTS role;
+ALTER TABLE chats DROP COLUMN IF EXISTS created_at;
+ALTER TABLE chats DROP COLUMN IF EXISTS created_by;
\ No newline at end of file
@@ -0,0 +1,14 @@
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS created_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE chat_participants ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'member' CHECK (role IN ('owner', 'admin', 'member'));
+
+-- Chats created before roles existed are owned by their earliest participant.
+UPDATE chat_participants SET role = 'owner'
+WHERE id IN (SELECT MIN(id) FROM chat_participants GROUP BY chat_id)
+    AND chat_id NOT IN (SELECT chat_id FROM chat_participants WHERE role = 'owner');
+
+UPDATE chats c SET created_by = cp.user_id
+FROM chat_participants cp
+WHERE cp.chat_id = c.id AND cp.role = 'owner' AND c.created_by IS NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chat_participants_owner ON chat_participants(chat_id) WHERE role = 'owner';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_chats_direct_key;
+ALTER TABLE chats DROP COLUMN IF EXISTS direct_key;
+ALTER TABLE chats DROP COLUMN IF EXISTS kind;
\ No newline at end of file
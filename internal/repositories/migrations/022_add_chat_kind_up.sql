@@ -0,0 +1,7 @@
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'group' CHECK (kind IN ('direct', 'group'));
+
+-- direct_key is "<lower user id>:<higher user id>" for direct chats, so each
+-- pair of users has at most one.
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS direct_key TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_chats_direct_key ON chats(direct_key) WHERE kind = 'direct';
@@ -0,0 +1,2 @@
+ALTER TABLE chats DROP COLUMN IF EXISTS avatar_url;
+ALTER TABLE chats DROP COLUMN IF EXISTS description;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS kind;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- System messages record changes to the chat; their sender is the member who
+-- made the change.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS kind TEXT NOT NULL DEFAULT 'user' CHECK (kind IN ('user', 'system'));
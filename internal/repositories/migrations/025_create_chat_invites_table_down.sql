@@ -0,0 +1 @@
+DROP TABLE IF EXISTS chat_invites;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS revoked_refresh_families;
+DROP TABLE IF EXISTS refresh_tokens;
\ No newline at end of file
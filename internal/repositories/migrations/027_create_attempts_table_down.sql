@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS attempt_blocks;
+DROP TABLE IF EXISTS attempts;
\ No newline at end of file
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS attempts (
+    attempt_key TEXT PRIMARY KEY,
+    count BIGINT NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attempts_expires ON attempts(expires_at);
+
+CREATE TABLE IF NOT EXISTS attempt_blocks (
+    attempt_key TEXT PRIMARY KEY,
+    blocked_until TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_attempt_blocks_until ON attempt_blocks(blocked_until);
@@ -0,0 +1,2 @@
+-- Lower-cased addresses cannot be restored.
+SELECT 1;
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- Addresses are stored lower-case. Rows that would collide with another
+-- account once lowered are left for an administrator to resolve.
+UPDATE users u SET email = LOWER(u.email)
+WHERE u.email <> LOWER(u.email)
+    AND NOT EXISTS (SELECT 1 FROM users o WHERE o.id <> u.id AND LOWER(o.email) = LOWER(u.email));
+
+UPDATE users SET pending_email = LOWER(pending_email)
+WHERE pending_email <> LOWER(pending_email);
@@ -0,0 +1,2 @@
+ALTER TABLE messages DROP COLUMN IF EXISTS pinned_by;
+ALTER TABLE messages DROP COLUMN IF EXISTS pinned_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+-- Pinned messages are shown at the top of the chat to every member.
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS pinned_at TIMESTAMP;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS pinned_by INTEGER REFERENCES users(id) ON DELETE SET NULL;
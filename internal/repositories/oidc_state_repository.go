@@ -0,0 +1,82 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+	"massager/internal/models"
+	"time"
+)
+
+//go:embed migrations/028_create_oidc_states_table_up.sql
+var createOIDCStatesTableQuery string
+
+// OIDCStateRepository keeps pending OpenID Connect logins in Postgres for
+// deployments without Redis.
+type OIDCStateRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewOIDCStateRepository(db *sql.DB, logger *slog.Logger) (*OIDCStateRepository, error) {
+	var repo = OIDCStateRepository{db: db, logger: logger}
+	var _, err = repo.db.Exec(createOIDCStatesTableQuery)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
+
+	logger.Info("oidc state repository initialization")
+
+	return &repo, nil
+}
+
+func (r *OIDCStateRepository) SaveOIDCState(ctx context.Context, stateHash string, state models.OIDCState, ttl time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO oidc_states (state_hash, provider, nonce, code_verifier, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (state_hash) DO UPDATE SET provider = EXCLUDED.provider, nonce = EXCLUDED.nonce,
+			code_verifier = EXCLUDED.code_verifier, expires_at = EXCLUDED.expires_at`,
+		stateHash, state.Provider, state.Nonce, state.CodeVerifier, time.Now().Add(ttl))
+	return err
+}
+
+// ConsumeOIDCState deletes the row it reads, so a state can only be used for
+// a single callback.
+func (r *OIDCStateRepository) ConsumeOIDCState(ctx context.Context, stateHash string) (*models.OIDCState, error) {
+	var state models.OIDCState
+	var expiresAt time.Time
+	err := r.db.QueryRowContext(ctx, `
+		DELETE FROM oidc_states WHERE state_hash = $1
+		RETURNING provider, nonce, code_verifier, expires_at`, stateHash).
+		Scan(&state.Provider, &state.Nonce, &state.CodeVerifier, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !time.Now().Before(expiresAt) {
+		return nil, nil
+	}
+	return &state, nil
+}
+
+// DeleteExpired removes abandoned logins and returns how many rows were
+// deleted.
+func (r *OIDCStateRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM oidc_states WHERE expires_at <= $1", time.Now())
+	if err != nil {
+		return 0, err
+	}
+
+	deleted, _ := result.RowsAffected()
+	return deleted, nil
+}
+
+// RunSweeper deletes expired states every interval until ctx is cancelled.
+func (r *OIDCStateRepository) RunSweeper(ctx context.Context, interval time.Duration) {
+	runSweeper(ctx, interval, r.logger, "oidc states", r.DeleteExpired)
+}
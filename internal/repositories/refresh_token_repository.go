@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+	"massager/internal/models"
+	"time"
+)
+
+//go:embed migrations/026_create_refresh_tokens_table_up.sql
+var createRefreshTokensTableQuery string
+
+// RefreshTokenRepository keeps refresh tokens and revoked token families in
+// Postgres for deployments without Redis.
+type RefreshTokenRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewRefreshTokenRepository(db *sql.DB, logger *slog.Logger) (*RefreshTokenRepository, error) {
+	var repo = RefreshTokenRepository{db: db, logger: logger}
+	var _, err = repo.db.Exec(createRefreshTokensTableQuery)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
+
+	logger.Info("refresh token repository initialization")
+
+	return &repo, nil
+}
+
+func (r *RefreshTokenRepository) SaveRefreshToken(ctx context.Context, token models.RefreshToken) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (token_hash, family_id, username, issued_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		token.TokenHash, token.FamilyID, token.Username, token.IssuedAt, token.ExpiresAt)
+	return err
+}
+
+func (r *RefreshTokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	var usedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, `
+		SELECT token_hash, family_id, username, issued_at, expires_at, used_at FROM refresh_tokens
+		WHERE token_hash = $1 AND expires_at > $2`, tokenHash, time.Now()).
+		Scan(&token.TokenHash, &token.FamilyID, &token.Username, &token.IssuedAt, &token.ExpiresAt, &usedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	token.Used = usedAt.Valid
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed only succeeds for the first caller: the update matches
+// nothing once used_at is set.
+func (r *RefreshTokenRepository) MarkRefreshTokenUsed(ctx context.Context, tokenHash string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens SET used_at = $2
+		WHERE token_hash = $1 AND used_at IS NULL`, tokenHash, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return updated == 1, nil
+}
+
+func (r *RefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string, expiration time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO revoked_refresh_families (family_id, expires_at) VALUES ($1, $2)
+		ON CONFLICT (family_id) DO UPDATE SET expires_at = EXCLUDED.expires_at`,
+		familyID, expiresAt(expiration))
+	return err
+}
+
+func (r *RefreshTokenRepository) IsFamilyRevoked(ctx context.Context, familyID string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM revoked_refresh_families
+			WHERE family_id = $1 AND (expires_at IS NULL OR expires_at > $2)
+		)`, familyID, time.Now()).Scan(&revoked)
+	return revoked, err
+}
+
+// DeleteExpired removes expired refresh tokens and family revocations and
+// returns how many rows were deleted.
+func (r *RefreshTokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+
+	tokens, err := r.db.ExecContext(ctx, "DELETE FROM refresh_tokens WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	families, err := r.db.ExecContext(ctx, "DELETE FROM revoked_refresh_families WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+
+	deletedTokens, _ := tokens.RowsAffected()
+	deletedFamilies, _ := families.RowsAffected()
+	return deletedTokens + deletedFamilies, nil
+}
+
+// RunSweeper deletes expired rows every interval until ctx is cancelled.
+func (r *RefreshTokenRepository) RunSweeper(ctx context.Context, interval time.Duration) {
+	runSweeper(ctx, interval, r.logger, "refresh tokens", r.DeleteExpired)
+}
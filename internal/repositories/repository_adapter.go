@@ -15,6 +15,15 @@ type RepositoryAdapter struct {
 	User    *UserRepository
 	Chat    *ChatRepository
 	Message *MessageRepository
+	Session *SessionRepository
+	APIKey  *APIKeyRepository
+	Token   *TokenRepository
+	Outbox  *EmailOutboxRepository
+	Invite  *ChatInviteRepository
+
+	RefreshToken *RefreshTokenRepository
+	Attempt      *AttemptRepository
+	OIDCState    *OIDCStateRepository
 }
 
 func NewRepositoryAdapter(cfg config.DatabaseConfig, cfgConn config.DatabaseConnectionsConfig, logger *slog.Logger) (*RepositoryAdapter, error) {
@@ -54,9 +63,50 @@ func NewRepositoryAdapter(cfg config.DatabaseConfig, cfgConn config.DatabaseConn
 		return nil, e
 	}
 
+	var sessionRepo, err4 = NewSessionRepository(db, logger)
+	if err4 != nil {
+		return nil, err4
+	}
+
+	var apiKeyRepo, err5 = NewAPIKeyRepository(db, logger)
+	if err5 != nil {
+		return nil, err5
+	}
+
+	var tokenRepo, err6 = NewTokenRepository(db, logger)
+	if err6 != nil {
+		return nil, err6
+	}
+
+	var outboxRepo, err7 = NewEmailOutboxRepository(db, logger)
+	if err7 != nil {
+		return nil, err7
+	}
+
+	var inviteRepo, err8 = NewChatInviteRepository(db, logger)
+	if err8 != nil {
+		return nil, err8
+	}
+
+	var refreshTokenRepo, err9 = NewRefreshTokenRepository(db, logger)
+	if err9 != nil {
+		return nil, err9
+	}
+
+	var attemptRepo, err10 = NewAttemptRepository(db, logger)
+	if err10 != nil {
+		return nil, err10
+	}
+
+	var oidcStateRepo, err11 = NewOIDCStateRepository(db, logger)
+	if err11 != nil {
+		return nil, err11
+	}
+
 	logger.Info("adapter initialization: stage 3")
 
-	return &RepositoryAdapter{User: userRepo, Message: messageRepo, Chat: chatRepo}, nil
+	return &RepositoryAdapter{User: userRepo, Message: messageRepo, Chat: chatRepo, Session: sessionRepo, APIKey: apiKeyRepo, Token: tokenRepo, Outbox: outboxRepo, Invite: inviteRepo,
+		RefreshToken: refreshTokenRepo, Attempt: attemptRepo, OIDCState: oidcStateRepo}, nil
 }
 
 func (r *RepositoryAdapter) Close(logger *slog.Logger) error {
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+	"massager/internal/models"
+	"time"
+)
+
+//go:embed migrations/006_create_sessions_table_up.sql
+var createSessionTableQuery string
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB, logger *slog.Logger) (*SessionRepository, error) {
+	var repo = SessionRepository{db: db}
+	var _, err = repo.db.Exec(createSessionTableQuery)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
+
+	logger.Info("session repository initialization")
+
+	return &repo, nil
+}
+
+func (r *SessionRepository) CreateSession(ctx context.Context, session models.Session) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO sessions (id, user_id, device_label, ip_address, user_agent, created_at, last_seen_at)
+		SELECT $1, id, $3, $4, $5, $6, $6 FROM users WHERE username = $2`,
+		session.ID, session.Username, session.DeviceLabel, session.IPAddress, session.UserAgent, session.CreatedAt)
+	return err
+}
+
+func (r *SessionRepository) GetSession(ctx context.Context, sessionID string) (*models.Session, error) {
+	query := `
+		SELECT s.id, u.username, s.device_label, s.ip_address, s.user_agent, s.created_at, s.last_seen_at, s.revoked_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE s.id = $1`
+
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return session, err
+}
+
+func (r *SessionRepository) GetUserSessions(ctx context.Context, username string) ([]models.Session, error) {
+	query := `
+		SELECT s.id, u.username, s.device_label, s.ip_address, s.user_agent, s.created_at, s.last_seen_at, s.revoked_at
+		FROM sessions s
+		JOIN users u ON u.id = s.user_id
+		WHERE u.username = $1 AND s.revoked_at IS NULL
+		ORDER BY s.last_seen_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sessions []models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *session)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *SessionRepository) TouchSession(ctx context.Context, sessionID string, seenAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE sessions SET last_seen_at = $1 WHERE id = $2", seenAt, sessionID)
+	return err
+}
+
+func (r *SessionRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL", sessionID)
+	return err
+}
+
+func (r *SessionRepository) RevokeUserSessions(ctx context.Context, username string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE sessions SET revoked_at = CURRENT_TIMESTAMP
+		WHERE revoked_at IS NULL AND user_id = (SELECT id FROM users WHERE username = $1)`, username)
+	return err
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var session models.Session
+	var revokedAt sql.NullTime
+
+	err := row.Scan(&session.ID, &session.Username, &session.DeviceLabel, &session.IPAddress, &session.UserAgent,
+		&session.CreatedAt, &session.LastSeenAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+
+	return &session, nil
+}
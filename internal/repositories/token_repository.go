@@ -0,0 +1,137 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"log/slog"
+	"time"
+)
+
+//go:embed migrations/019_create_revoked_tokens_table_up.sql
+var createRevokedTokensTableQuery string
+
+const defaultTokenSweepInterval = 10 * time.Minute
+
+// TokenRepository keeps revoked access tokens and revoke-all cutoffs in
+// Postgres for deployments without Redis. Expired rows are ignored on read and
+// removed by RunSweeper.
+type TokenRepository struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+func NewTokenRepository(db *sql.DB, logger *slog.Logger) (*TokenRepository, error) {
+	var repo = TokenRepository{db: db, logger: logger}
+	var _, err = repo.db.Exec(createRevokedTokensTableQuery)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
+
+	logger.Info("token repository initialization")
+
+	return &repo, nil
+}
+
+func (r *TokenRepository) IsRevoked(ctx context.Context, tokenHash string) (bool, error) {
+	var revoked bool
+	err := r.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM revoked_tokens
+			WHERE token_hash = $1 AND (expires_at IS NULL OR expires_at > $2)
+		)`, tokenHash, time.Now()).Scan(&revoked)
+	return revoked, err
+}
+
+func (r *TokenRepository) Revoke(ctx context.Context, tokenHash string, expiration time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO revoked_tokens (token_hash, expires_at) VALUES ($1, $2)
+		ON CONFLICT (token_hash) DO UPDATE SET expires_at = EXCLUDED.expires_at`,
+		tokenHash, expiresAt(expiration))
+	return err
+}
+
+func (r *TokenRepository) RevokeAllBefore(ctx context.Context, username string, cutoff time.Time, expiration time.Duration) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO token_cutoffs (username, revoked_before, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (username) DO UPDATE SET revoked_before = EXCLUDED.revoked_before, expires_at = EXCLUDED.expires_at`,
+		username, cutoff, expiresAt(expiration))
+	return err
+}
+
+func (r *TokenRepository) GetRevokedBefore(ctx context.Context, username string) (time.Time, error) {
+	var cutoff time.Time
+	err := r.db.QueryRowContext(ctx, `
+		SELECT revoked_before FROM token_cutoffs
+		WHERE username = $1 AND (expires_at IS NULL OR expires_at > $2)`,
+		username, time.Now()).Scan(&cutoff)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return cutoff, nil
+}
+
+// DeleteExpired removes the revocations that no longer matter and returns how
+// many rows were deleted.
+func (r *TokenRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	now := time.Now()
+
+	tokens, err := r.db.ExecContext(ctx, "DELETE FROM revoked_tokens WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+	cutoffs, err := r.db.ExecContext(ctx, "DELETE FROM token_cutoffs WHERE expires_at <= $1", now)
+	if err != nil {
+		return 0, err
+	}
+
+	deletedTokens, _ := tokens.RowsAffected()
+	deletedCutoffs, _ := cutoffs.RowsAffected()
+	return deletedTokens + deletedCutoffs, nil
+}
+
+// RunSweeper deletes expired revocations every interval until ctx is cancelled.
+func (r *TokenRepository) RunSweeper(ctx context.Context, interval time.Duration) {
+	runSweeper(ctx, interval, r.logger, "token revocations", r.DeleteExpired)
+}
+
+// runSweeper calls deleteExpired every interval until ctx is cancelled. It
+// backs the RunSweeper of every table that holds short-lived auth state.
+func runSweeper(ctx context.Context, interval time.Duration, logger *slog.Logger, what string,
+	deleteExpired func(ctx context.Context) (int64, error)) {
+	if interval <= 0 {
+		interval = defaultTokenSweepInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			deleted, err := deleteExpired(ctx)
+			if err != nil {
+				logger.Error("failed to delete expired "+what, "error", err)
+				continue
+			}
+			if deleted > 0 {
+				logger.Debug("expired "+what+" deleted", "count", deleted)
+			}
+		}
+	}
+}
+
+// expiresAt mirrors Redis: a zero or negative expiration never expires.
+func expiresAt(expiration time.Duration) *time.Time {
+	if expiration <= 0 {
+		return nil
+	}
+	at := time.Now().Add(expiration)
+	return &at
+}
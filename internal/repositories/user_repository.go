@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"log/slog"
 	"massager/internal/models"
+	"strings"
+	"time"
 
 	_ "embed"
 )
@@ -12,19 +14,72 @@ import (
 //go:embed migrations/001_create_user_table_up.sql
 var createUserTableQuery string
 
+//go:embed migrations/007_create_password_reset_tokens_table_up.sql
+var createPasswordResetTokensTableQuery string
+
+//go:embed migrations/008_add_totp_to_users_table_up.sql
+var addTOTPToUsersTableQuery string
+
+//go:embed migrations/009_add_verify_token_expiry_to_users_table_up.sql
+var addVerifyTokenExpiryToUsersTableQuery string
+
+//go:embed migrations/010_add_pending_email_to_users_table_up.sql
+var addPendingEmailToUsersTableQuery string
+
+//go:embed migrations/011_add_profile_to_users_table_up.sql
+var addProfileToUsersTableQuery string
+
+//go:embed migrations/012_add_user_search_up.sql
+var addUserSearchQuery string
+
+//go:embed migrations/013_add_account_deletion_to_users_table_up.sql
+var addAccountDeletionToUsersTableQuery string
+
+//go:embed migrations/015_create_user_identities_table_up.sql
+var createUserIdentitiesTableQuery string
+
+//go:embed migrations/016_add_bots_to_users_table_up.sql
+var addBotsToUsersTableQuery string
+
+//go:embed migrations/018_add_roles_and_bans_to_users_table_up.sql
+var addRolesAndBansToUsersTableQuery string
+
+//go:embed migrations/029_lowercase_user_emails_up.sql
+var lowercaseUserEmailsQuery string
+
+//go:embed migrations/031_add_totp_last_step_to_users_table_up.sql
+var addTOTPLastStepToUsersTableQuery string
+
+var userMigrations = []string{
+	createUserTableQuery,
+	createPasswordResetTokensTableQuery,
+	addTOTPToUsersTableQuery,
+	addVerifyTokenExpiryToUsersTableQuery,
+	addPendingEmailToUsersTableQuery,
+	addProfileToUsersTableQuery,
+	addUserSearchQuery,
+	addAccountDeletionToUsersTableQuery,
+	createUserIdentitiesTableQuery,
+	addBotsToUsersTableQuery,
+	addRolesAndBansToUsersTableQuery,
+	lowercaseUserEmailsQuery,
+	addTOTPLastStepToUsersTableQuery,
+}
+
 type UserRepository struct {
 	db *sql.DB
 }
 
 func NewUserRepository(db *sql.DB, logger *slog.Logger) (*UserRepository, error) {
 	var repo = UserRepository{db: db}
-	var _, err = repo.db.Exec(string(createUserTableQuery))
-	if err != nil {
-		logger.Error(err.Error())
-		return nil, err
+	for _, migration := range userMigrations {
+		if _, err := repo.db.Exec(migration); err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
 	}
 
-	err = db.Ping()
+	var err = db.Ping()
 	if err != nil {
 		logger.Error(err.Error())
 		return nil, err
@@ -34,12 +89,23 @@ func NewUserRepository(db *sql.DB, logger *slog.Logger) (*UserRepository, error)
 
 func (r *UserRepository) GetUserByName(ctx context.Context, name string) (*models.User, error) {
 	var password, email string
-	var isVerified bool
-	var verifyToken sql.NullString
+	var isVerified, totpEnabled, discoverable, isBot bool
+	var verifyToken, totpSecret sql.NullString
+	var displayName, bio, avatarURL, timezone, botOwner, banReason sql.NullString
+	var role string
+	var deletionScheduledAt, bannedAt sql.NullTime
 
-	query := "SELECT passwordHash, email, is_verified, verify_token FROM users WHERE username = $1"
+	query := `
+		SELECT u.passwordHash, u.email, u.is_verified, u.verify_token, u.totp_secret, COALESCE(u.totp_enabled, FALSE),
+			u.display_name, u.bio, u.avatar_url, u.timezone, u.discoverable, u.deletion_scheduled_at,
+			u.is_bot, o.username, u.role, u.banned_at, u.ban_reason
+		FROM users u
+		LEFT JOIN users o ON o.id = u.owner_id
+		WHERE u.username = $1`
 	row := r.db.QueryRowContext(ctx, query, name)
-	err := row.Scan(&password, &email, &isVerified, &verifyToken)
+	err := row.Scan(&password, &email, &isVerified, &verifyToken, &totpSecret, &totpEnabled,
+		&displayName, &bio, &avatarURL, &timezone, &discoverable, &deletionScheduledAt,
+		&isBot, &botOwner, &role, &bannedAt, &banReason)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -52,6 +118,25 @@ func (r *UserRepository) GetUserByName(ctx context.Context, name string) (*model
 	if verifyToken.Valid {
 		user.VerifyToken = verifyToken.String
 	}
+	if totpSecret.Valid {
+		user.TOTPSecret = totpSecret.String
+	}
+	user.TOTPEnabled = totpEnabled
+	user.DisplayName = displayName.String
+	user.Bio = bio.String
+	user.AvatarURL = avatarURL.String
+	user.Timezone = timezone.String
+	user.Discoverable = discoverable
+	if deletionScheduledAt.Valid {
+		user.DeletionScheduledAt = deletionScheduledAt.Time
+	}
+	user.IsBot = isBot
+	user.BotOwner = botOwner.String
+	user.Role = models.Role(role)
+	if bannedAt.Valid {
+		user.BannedAt = bannedAt.Time
+	}
+	user.BanReason = banReason.String
 
 	return user, err
 }
@@ -59,10 +144,11 @@ func (r *UserRepository) GetUserByName(ctx context.Context, name string) (*model
 func (r *UserRepository) GetUserByVerifyToken(ctx context.Context, token string) (*models.User, error) {
 	var username, password, email string
 	var isVerified bool
+	var expiresAt sql.NullTime
 
-	query := "SELECT username, passwordHash, email, is_verified FROM users WHERE verify_token = $1"
+	query := "SELECT username, passwordHash, email, is_verified, verify_token_expires_at FROM users WHERE verify_token = $1"
 	row := r.db.QueryRowContext(ctx, query, token)
-	err := row.Scan(&username, &password, &email, &isVerified)
+	err := row.Scan(&username, &password, &email, &isVerified, &expiresAt)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -74,21 +160,546 @@ func (r *UserRepository) GetUserByVerifyToken(ctx context.Context, token string)
 	user := models.NewUser(username, password, email)
 	user.IsVerefied = isVerified
 	user.VerifyToken = token
+	if expiresAt.Valid {
+		user.VerifyTokenExpiresAt = expiresAt.Time
+	}
 
 	return user, nil
 }
 
-func (r *UserRepository) CreateUser(ctx context.Context, username, hashedPassword, email, verifyToken string) error {
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	var username, password string
+	var isVerified bool
+
+	query := "SELECT username, passwordHash, is_verified FROM users WHERE email = $1"
+	row := r.db.QueryRowContext(ctx, query, email)
+	err := row.Scan(&username, &password, &isVerified)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	user := models.NewUser(username, password, email)
+	user.IsVerefied = isVerified
+
+	return user, nil
+}
+
+// SearchUsers finds verified, discoverable users whose username or display name
+// starts with the query or is similar to it. Prefix matches come first.
+func (r *UserRepository) SearchUsers(ctx context.Context, query, excludeUsername string, limit, offset int) ([]models.Profile, error) {
+	query = strings.ToLower(query)
+	prefix := likeEscaper.Replace(query) + "%"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT username, COALESCE(display_name, ''), COALESCE(bio, ''), COALESCE(avatar_url, ''), COALESCE(timezone, '')
+		FROM users
+		WHERE is_verified = TRUE AND discoverable = TRUE AND username <> $2
+			AND (LOWER(username) LIKE $3 OR LOWER(display_name) LIKE $3
+				OR LOWER(username) % $1 OR LOWER(display_name) % $1)
+		ORDER BY
+			(LOWER(username) LIKE $3 OR COALESCE(LOWER(display_name) LIKE $3, FALSE)) DESC,
+			GREATEST(similarity(LOWER(username), $1), COALESCE(similarity(LOWER(display_name), $1), 0)) DESC,
+			username
+		LIMIT $4 OFFSET $5`,
+		query, excludeUsername, prefix, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	profiles := []models.Profile{}
+	for rows.Next() {
+		var profile models.Profile
+		if err := rows.Scan(&profile.Username, &profile.DisplayName, &profile.Bio, &profile.AvatarURL, &profile.Timezone); err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+
+	return profiles, rows.Err()
+}
+
+// ListUsers is the administrators' view of the accounts whose username, email
+// or display name contains the query; an empty query lists everyone.
+func (r *UserRepository) ListUsers(ctx context.Context, query string, limit, offset int) ([]models.UserSummary, error) {
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(query)) + "%"
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT username, email, COALESCE(display_name, ''), role, is_verified, is_bot, banned_at, COALESCE(ban_reason, ''), created_at
+		FROM users
+		WHERE deleted_at IS NULL
+			AND (LOWER(username) LIKE $1 OR LOWER(email) LIKE $1 OR LOWER(COALESCE(display_name, '')) LIKE $1)
+		ORDER BY username
+		LIMIT $2 OFFSET $3`,
+		pattern, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []models.UserSummary{}
+	for rows.Next() {
+		var user models.UserSummary
+		var bannedAt sql.NullTime
+		var createdAt sql.NullTime
+		if err := rows.Scan(&user.Username, &user.Email, &user.DisplayName, &user.Role, &user.IsVerified, &user.IsBot,
+			&bannedAt, &user.BanReason, &createdAt); err != nil {
+			return nil, err
+		}
+		if bannedAt.Valid {
+			user.BannedAt = &bannedAt.Time
+		}
+		user.CreatedAt = createdAt.Time
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// GetBots lists the bots owned by the user, oldest first.
+func (r *UserRepository) GetBots(ctx context.Context, owner string) ([]models.Profile, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT b.username, COALESCE(b.display_name, ''), COALESCE(b.bio, ''), COALESCE(b.avatar_url, ''), COALESCE(b.timezone, '')
+		FROM users b
+		JOIN users o ON o.id = b.owner_id
+		WHERE o.username = $1 AND b.is_bot = TRUE AND b.deleted_at IS NULL
+		ORDER BY b.id`, owner)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bots := []models.Profile{}
+	for rows.Next() {
+		bot := models.Profile{IsBot: true}
+		if err := rows.Scan(&bot.Username, &bot.DisplayName, &bot.Bio, &bot.AvatarURL, &bot.Timezone); err != nil {
+			return nil, err
+		}
+		bots = append(bots, bot)
+	}
+
+	return bots, rows.Err()
+}
+
+// GetUserByIdentity finds the user an external identity is linked to.
+func (r *UserRepository) GetUserByIdentity(ctx context.Context, provider, subject string) (*models.User, error) {
+	var username string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT u.username FROM user_identities i
+		JOIN users u ON u.id = i.user_id
+		WHERE i.provider = $1 AND i.subject = $2`, provider, subject).Scan(&username)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetUserByName(ctx, username)
+}
+
+// likeEscaper escapes LIKE wildcards with the default escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func (r *UserRepository) CreateUser(ctx context.Context, username, hashedPassword, email, verifyToken string, verifyTokenExpiresAt time.Time) error {
 	_, err := r.db.ExecContext(ctx,
-		"INSERT INTO users (username, passwordHash, email, verify_token) VALUES ($1, $2, $3, $4)",
-		username, hashedPassword, email, verifyToken)
+		"INSERT INTO users (username, passwordHash, email, verify_token, verify_token_expires_at) VALUES ($1, $2, $3, $4, $5)",
+		username, hashedPassword, email, verifyToken, verifyTokenExpiresAt)
 
 	return err
 }
 
+// SetVerifyToken replaces the user's verification token, invalidating the previous one.
+func (r *UserRepository) SetVerifyToken(ctx context.Context, username, verifyToken string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE users SET verify_token = $1, verify_token_expires_at = $2 WHERE username = $3 AND is_verified = FALSE",
+		verifyToken, expiresAt, username)
+	return err
+}
+
 func (r *UserRepository) MarkUserAsVerified(ctx context.Context, username string) error {
 	_, err := r.db.ExecContext(ctx,
-		"UPDATE users SET is_verified = TRUE, verify_token = NULL WHERE username = $1",
+		"UPDATE users SET is_verified = TRUE, verify_token = NULL, verify_token_expires_at = NULL WHERE username = $1",
+		username)
+	return err
+}
+
+func (r *UserRepository) UpdatePassword(ctx context.Context, username, hashedPassword string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE users SET passwordHash = $1 WHERE username = $2",
+		hashedPassword, username)
+	return err
+}
+
+// UpdateProfile changes the profile fields that are set in the update.
+func (r *UserRepository) UpdateProfile(ctx context.Context, username string, update models.ProfileUpdate) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users SET
+			display_name = COALESCE($1, display_name),
+			bio = COALESCE($2, bio),
+			avatar_url = COALESCE($3, avatar_url),
+			timezone = COALESCE($4, timezone),
+			discoverable = COALESCE($5, discoverable)
+		WHERE username = $6`,
+		update.DisplayName, update.Bio, update.AvatarURL, update.Timezone, update.Discoverable, username)
+	return err
+}
+
+// SetPendingEmail parks a new address until the link sent to it is opened. A
+// newer request replaces the previous one.
+func (r *UserRepository) SetPendingEmail(ctx context.Context, username, email, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE users SET pending_email = $1, pending_email_token = $2, pending_email_expires_at = $3 WHERE username = $4",
+		email, tokenHash, expiresAt, username)
+	return err
+}
+
+// ConfirmPendingEmail makes the pending address the user's email. It returns an
+// empty username when the token is unknown or expired.
+func (r *UserRepository) ConfirmPendingEmail(ctx context.Context, tokenHash string) (string, string, error) {
+	var username, email string
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE users SET email = pending_email, is_verified = TRUE,
+			pending_email = NULL, pending_email_token = NULL, pending_email_expires_at = NULL
+		WHERE pending_email_token = $1 AND pending_email IS NOT NULL AND pending_email_expires_at > CURRENT_TIMESTAMP
+		RETURNING username, email`, tokenHash).Scan(&username, &email)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return username, email, nil
+}
+
+func (r *UserRepository) CreatePasswordResetToken(ctx context.Context, username, tokenHash string, expiresAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+		SELECT id, $2, $3 FROM users WHERE username = $1`,
+		username, tokenHash, expiresAt)
+	return err
+}
+
+// GetPasswordResetTokenUser returns the owner of a valid reset token without
+// spending it, or an empty username when the token is unknown, expired or used.
+func (r *UserRepository) GetPasswordResetTokenUser(ctx context.Context, tokenHash string) (string, error) {
+	var username string
+	err := r.db.QueryRowContext(ctx, `
+		SELECT u.username FROM password_reset_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.used_at IS NULL AND t.expires_at > CURRENT_TIMESTAMP`,
+		tokenHash).Scan(&username)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+// ConsumePasswordResetToken spends a valid reset token together with every other
+// outstanding token of the same user. It returns an empty username when the token
+// is unknown, expired or already used.
+func (r *UserRepository) ConsumePasswordResetToken(ctx context.Context, tokenHash string) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP
+		WHERE token_hash = $1 AND used_at IS NULL AND expires_at > CURRENT_TIMESTAMP
+		RETURNING user_id`, tokenHash).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND used_at IS NULL", userID)
+	if err != nil {
+		return "", err
+	}
+
+	var username string
+	if err := tx.QueryRowContext(ctx, "SELECT username FROM users WHERE id = $1", userID).Scan(&username); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+
+	return username, nil
+}
+
+// SetTOTPSecret stores a pending secret; it only takes effect once EnableTOTP
+// confirms the enrollment.
+func (r *UserRepository) SetTOTPSecret(ctx context.Context, username, secret string) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE users SET totp_secret = $1, totp_enabled = FALSE WHERE username = $2",
+		secret, username)
+	return err
+}
+
+func (r *UserRepository) EnableTOTP(ctx context.Context, username string, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx,
+		"UPDATE users SET totp_enabled = TRUE WHERE username = $1 RETURNING id", username).Scan(&userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	for _, codeHash := range recoveryCodeHashes {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO recovery_codes (user_id, code_hash) VALUES ($1, $2)", userID, codeHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *UserRepository) DisableTOTP(ctx context.Context, username string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx,
+		"UPDATE users SET totp_secret = NULL, totp_enabled = FALSE WHERE username = $1 RETURNING id", username).Scan(&userID)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recovery_codes WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// AcceptTOTPStep records step as the user's last accepted TOTP time step and
+// reports false when it is not later than the one already recorded.
+func (r *UserRepository) AcceptTOTPStep(ctx context.Context, username string, step int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET totp_last_step = $1 WHERE username = $2 AND totp_last_step < $1",
+		step, username)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ConsumeRecoveryCode spends an unused recovery code and reports whether one matched.
+func (r *UserRepository) ConsumeRecoveryCode(ctx context.Context, username, codeHash string) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE recovery_codes SET used_at = CURRENT_TIMESTAMP
+		WHERE id = (
+			SELECT rc.id FROM recovery_codes rc
+			JOIN users u ON u.id = rc.user_id
+			WHERE u.username = $1 AND rc.code_hash = $2 AND rc.used_at IS NULL
+			LIMIT 1
+		) AND used_at IS NULL`, username, codeHash)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+// ScheduleDeletion marks the account for deletion once deleteAt has passed.
+func (r *UserRepository) ScheduleDeletion(ctx context.Context, username string, deleteAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = $1 WHERE username = $2 AND deleted_at IS NULL",
+		deleteAt, username)
+	return err
+}
+
+// CancelDeletion clears a scheduled deletion and reports whether one was pending.
+func (r *UserRepository) CancelDeletion(ctx context.Context, username string) (bool, error) {
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE users SET deletion_scheduled_at = NULL WHERE username = $1 AND deletion_scheduled_at IS NOT NULL AND deleted_at IS NULL",
 		username)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected == 1, nil
+}
+
+func (r *UserRepository) GetUsersDueForDeletion(ctx context.Context, now time.Time) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT username FROM users WHERE deletion_scheduled_at <= $1 AND deleted_at IS NULL ORDER BY deletion_scheduled_at",
+		now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usernames []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		usernames = append(usernames, username)
+	}
+
+	return usernames, rows.Err()
+}
+
+// AnonymizeUser replaces everything that identifies the user with a tombstone
+// named after the row id, drops their credentials, sessions and group chat
+// memberships, revokes their and their bots' API keys, and keeps the row so
+// their messages stay in other people's chats. The tombstone stays in direct
+// chats, so the other side still sees whom the chat was with.
+func (r *UserRepository) AnonymizeUser(ctx context.Context, username string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users SET
+			username = 'deleted-' || id,
+			email = 'deleted-' || id || '@invalid',
+			passwordHash = '!',
+			is_verified = FALSE,
+			verify_token = NULL,
+			verify_token_expires_at = NULL,
+			totp_secret = NULL,
+			totp_enabled = FALSE,
+			pending_email = NULL,
+			pending_email_token = NULL,
+			pending_email_expires_at = NULL,
+			display_name = 'Deleted user',
+			bio = NULL,
+			avatar_url = NULL,
+			timezone = NULL,
+			discoverable = FALSE,
+			deletion_scheduled_at = NULL,
+			role = 'user',
+			banned_at = NULL,
+			ban_reason = NULL,
+			deleted_at = CURRENT_TIMESTAMP
+		WHERE username = $1 AND deleted_at IS NULL
+		RETURNING id`, username).Scan(&userID)
+	if err != nil {
+		return err
+	}
+
+	for _, query := range []string{
+		"DELETE FROM recovery_codes WHERE user_id = $1",
+		"DELETE FROM password_reset_tokens WHERE user_id = $1",
+		"DELETE FROM sessions WHERE user_id = $1",
+		"DELETE FROM chat_participants WHERE user_id = $1 AND chat_id IN (SELECT id FROM chats WHERE kind <> 'direct')",
+		"DELETE FROM user_identities WHERE user_id = $1",
+		"UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE revoked_at IS NULL AND user_id IN (SELECT id FROM users WHERE id = $1 OR owner_id = $1)",
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// LinkIdentity attaches an external identity to an existing user.
+func (r *UserRepository) LinkIdentity(ctx context.Context, username, provider, subject, email string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO user_identities (user_id, provider, subject, email)
+		SELECT id, $2, $3, $4 FROM users WHERE username = $1`,
+		username, provider, subject, email)
+	return err
+}
+
+// CreateUserWithIdentity creates a verified user that can only log in through
+// the external identity; the password hash can never match.
+func (r *UserRepository) CreateUserWithIdentity(ctx context.Context, username, email, provider, subject string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRowContext(ctx,
+		"INSERT INTO users (username, passwordHash, email, is_verified) VALUES ($1, '!', $2, TRUE) RETURNING id",
+		username, email).Scan(&userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		"INSERT INTO user_identities (user_id, provider, subject, email) VALUES ($1, $2, $3, $4)",
+		userID, provider, subject, email)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// CreateBot creates a verified bot account owned by owner. Bots have no
+// password or mailbox and can only authenticate with API keys.
+func (r *UserRepository) CreateBot(ctx context.Context, owner, username, displayName string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO users (username, passwordHash, email, is_verified, is_bot, owner_id, display_name)
+		SELECT $2, '!', LOWER($2) || '@bot.invalid', TRUE, TRUE, id, NULLIF($3, '') FROM users WHERE username = $1`,
+		owner, username, displayName)
+	return err
+}
+
+func (r *UserRepository) SetRole(ctx context.Context, username string, role models.Role) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET role = $1 WHERE username = $2", string(role), username)
+	return err
+}
+
+func (r *UserRepository) BanUser(ctx context.Context, username, reason string, bannedAt time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE users SET banned_at = $1, ban_reason = NULLIF($2, '') WHERE username = $3",
+		bannedAt, reason, username)
+	return err
+}
+
+func (r *UserRepository) UnbanUser(ctx context.Context, username string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET banned_at = NULL, ban_reason = NULL WHERE username = $1", username)
 	return err
 }
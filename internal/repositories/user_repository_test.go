@@ -0,0 +1,48 @@
+package repositories
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserRepository_AnonymizeUserStaysInDirectChats(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDB(t)
+
+	users, err := NewUserRepository(db, slog.Default())
+	require.NoError(t, err)
+	chats, err := NewChatRepository(db, slog.Default())
+	require.NoError(t, err)
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	gone := "anon-gone-" + hex.EncodeToString(suffix)
+	other := "anon-other-" + hex.EncodeToString(suffix)
+	for _, username := range []string{gone, other} {
+		require.NoError(t, users.CreateUser(ctx, username, "!", username+"@example.invalid", "", time.Now()))
+	}
+
+	directID, _, err := chats.GetOrCreateDirectChat(ctx, other, gone)
+	require.NoError(t, err)
+	groupID, err := chats.CreateChat(ctx, "group", other, []string{other, gone})
+	require.NoError(t, err)
+
+	require.NoError(t, users.AnonymizeUser(ctx, gone))
+
+	direct, err := chats.GetChatByID(ctx, directID)
+	require.NoError(t, err)
+	require.Len(t, direct.Members, 2, "the tombstone stays in the direct chat")
+	assert.NotContains(t, direct.Members, gone)
+	assert.NotEqual(t, other, direct.TitleFor(other))
+
+	group, err := chats.GetChatByID(ctx, groupID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{other}, group.Members)
+}
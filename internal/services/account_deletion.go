@@ -0,0 +1,252 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"massager/app/config"
+	"massager/internal/models"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrDeletionNotScheduled   = errors.New("account deletion is not scheduled")
+	ErrDeletionAlreadyPending = errors.New("account deletion is already scheduled")
+)
+
+const (
+	defaultDeletionGracePeriod   = 7 * 24 * time.Hour
+	defaultDeletionPurgeInterval = time.Hour
+	deletedUsernamePrefix        = "deleted-"
+)
+
+// SetAccountDeletion configures how long a deleted account can still be restored.
+func (s *UserService) SetAccountDeletion(cfg config.AccountDeletionConfig) {
+	if cfg.GracePeriod <= 0 {
+		cfg.GracePeriod = defaultDeletionGracePeriod
+	}
+	s.deletionGracePeriod = cfg.GracePeriod
+}
+
+// RequestAccountDeletion schedules the account for deletion after the grace
+// period. The account keeps working until then, so the owner can log in and
+// cancel. It returns the time the account will be anonymised.
+func (s *UserService) RequestAccountDeletion(ctx context.Context, username, password string) (time.Time, error) {
+	ctx, span := s.tracer.Start(ctx, "UserService.RequestAccountDeletion")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if password == "" {
+		span.RecordError(ErrAccountSettingsIncomplete)
+		return time.Time{}, ErrAccountSettingsIncomplete
+	}
+
+	user, err := s.checkPassword(ctx, username, password)
+	if err != nil {
+		span.RecordError(err)
+		return time.Time{}, err
+	}
+	if !user.DeletionScheduledAt.IsZero() {
+		span.RecordError(ErrDeletionAlreadyPending)
+		return user.DeletionScheduledAt, ErrDeletionAlreadyPending
+	}
+
+	deleteAt := time.Now().Add(s.deletionGracePeriod)
+	if err := s.userRepo.ScheduleDeletion(ctx, username, deleteAt); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to schedule account deletion", "username", username, "error", err)
+		return time.Time{}, errors.New("failed to delete account")
+	}
+
+	if err := s.emailService.SendAccountDeletionScheduled(user.Email, deleteAt); err != nil {
+		span.RecordError(err)
+		s.logger.Warn("failed to send account deletion notice", "username", username, "error", err)
+	}
+
+	span.SetStatus(codes.Ok, "account deletion scheduled")
+	s.logger.Info("account deletion scheduled", "username", username, "delete_at", deleteAt)
+	return deleteAt, nil
+}
+
+// CancelAccountDeletion keeps an account whose deletion is still pending.
+func (s *UserService) CancelAccountDeletion(ctx context.Context, username string) error {
+	ctx, span := s.tracer.Start(ctx, "UserService.CancelAccountDeletion")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	cancelled, err := s.userRepo.CancelDeletion(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to cancel account deletion", "username", username, "error", err)
+		return errors.New("failed to cancel account deletion")
+	}
+	if !cancelled {
+		span.RecordError(ErrDeletionNotScheduled)
+		return ErrDeletionNotScheduled
+	}
+
+	span.SetStatus(codes.Ok, "account deletion cancelled")
+	s.logger.Info("account deletion cancelled", "username", username)
+	return nil
+}
+
+// PurgeDueAccounts anonymises every account whose grace period is over and
+// returns how many were anonymised. An account that fails is retried on the
+// next run.
+func (s *UserService) PurgeDueAccounts(ctx context.Context) (int, error) {
+	ctx, span := s.tracer.Start(ctx, "UserService.PurgeDueAccounts")
+	defer span.End()
+
+	usernames, err := s.userRepo.GetUsersDueForDeletion(ctx, time.Now())
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to list accounts due for deletion", "error", err)
+		return 0, errors.New("failed to purge accounts")
+	}
+
+	purged := 0
+	for _, username := range usernames {
+		// Tokens are revoked by username, which the tombstone no longer has.
+		if err := s.authService.RevokeAllSessions(ctx, username); err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to revoke sessions of deleted account", "username", username, "error", err)
+			continue
+		}
+
+		if err := s.leaveGroupChats(ctx, username); err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to leave chats of deleted account", "username", username, "error", err)
+			continue
+		}
+
+		if err := s.userRepo.AnonymizeUser(ctx, username); err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to anonymise account", "username", username, "error", err)
+			continue
+		}
+
+		purged++
+		s.logger.Info("account deleted", "username", username)
+	}
+
+	span.SetAttributes(attribute.Int("accounts.purged", purged))
+	span.SetStatus(codes.Ok, "accounts purged")
+	return purged, nil
+}
+
+// leaveGroupChats makes the user leave every group they are in, so the other
+// members get the same member_removed update as for any other leave, the next
+// admin or oldest member takes over a group they owned and a group they were
+// alone in is deleted. Anonymising alone would drop the participation
+// silently and could leave a group without an owner.
+func (s *UserService) leaveGroupChats(ctx context.Context, username string) error {
+	chats, err := s.chatRepo.GetUserChats(ctx, username)
+	if err != nil {
+		return err
+	}
+	if chats == nil {
+		return nil
+	}
+
+	for _, chat := range *chats {
+		if chat.IsDirect() {
+			continue
+		}
+
+		if err := s.chatService.LeaveChat(ctx, chat.ID, username); err != nil {
+			return err
+		}
+		s.logger.Info("deleted account left chat", "chat_id", chat.ID, "username", username)
+	}
+	return nil
+}
+
+// RunDeletionWorker purges due accounts every interval until ctx is done.
+func (s *UserService) RunDeletionWorker(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultDeletionPurgeInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.PurgeDueAccounts(ctx)
+		}
+	}
+}
+
+// ExportAccount writes a zip archive with the user's profile, chats and the
+// messages they sent, each as a JSON file.
+func (s *UserService) ExportAccount(ctx context.Context, username string, w io.Writer) error {
+	ctx, span := s.tracer.Start(ctx, "UserService.ExportAccount")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	profile, err := s.GetOwnProfile(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	chats, err := s.chatRepo.GetUserChats(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to get chats for export", "username", username, "error", err)
+		return errors.New("failed to export account")
+	}
+	if chats == nil {
+		chats = &[]models.Chat{}
+	}
+
+	messages, err := s.messageRepo.GetMessagesBySender(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to get messages for export", "username", username, "error", err)
+		return errors.New("failed to export account")
+	}
+
+	archive := zip.NewWriter(w)
+	for _, file := range []struct {
+		name string
+		data any
+	}{
+		{"profile.json", profile},
+		{"chats.json", chats},
+		{"messages.json", messages},
+	} {
+		entry, err := archive.Create(file.name)
+		if err != nil {
+			span.RecordError(err)
+			return err
+		}
+
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "account exported")
+	s.logger.Info("account exported", "username", username)
+	return nil
+}
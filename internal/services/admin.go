@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"massager/internal/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrAccountBanned    = errors.New("account is banned")
+	ErrInsufficientRole = errors.New("insufficient role")
+	ErrInvalidRole      = errors.New("invalid role")
+)
+
+const maxBanReasonLength = 256
+
+// disabledPasswordHash matches no password, so the account can only be
+// recovered through a reset link.
+const disabledPasswordHash = "!"
+
+// roleOf treats accounts without a known role as regular users.
+func roleOf(user *models.User) models.Role {
+	if !user.Role.Valid() {
+		return models.RoleUser
+	}
+	return user.Role
+}
+
+// BootstrapAdmins promotes the configured users to administrators so a fresh
+// installation has someone who can hand out roles.
+func (s *AuthService) BootstrapAdmins(ctx context.Context, usernames []string) {
+	for _, username := range usernames {
+		user, err := s.userRepo.GetUserByName(ctx, username)
+		if err != nil {
+			s.logger.Error("failed to look up configured admin", "username", username, "error", err)
+			continue
+		}
+		if user == nil || user.IsBot {
+			s.logger.Warn("configured admin does not exist", "username", username)
+			continue
+		}
+		if roleOf(user) == models.RoleAdmin {
+			continue
+		}
+
+		if err := s.userRepo.SetRole(ctx, username, models.RoleAdmin); err != nil {
+			s.logger.Error("failed to promote configured admin", "username", username, "error", err)
+			continue
+		}
+		s.logger.Info("configured admin promoted", "username", username)
+	}
+}
+
+// ListUsers pages through all accounts matching the query. One extra row is
+// fetched to tell whether there is a next page.
+func (s *AuthService) ListUsers(ctx context.Context, query string, limit, offset int) ([]models.UserSummary, bool, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.ListUsers")
+	defer span.End()
+
+	query = strings.TrimSpace(query)
+	span.SetAttributes(attribute.String("search.query", query))
+
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	users, err := s.userRepo.ListUsers(ctx, query, limit+1, offset)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to list users", "error", err)
+		return nil, false, err
+	}
+
+	hasMore := len(users) > limit
+	if hasMore {
+		users = users[:limit]
+	}
+
+	span.SetStatus(codes.Ok, "users listed")
+	return users, hasMore, nil
+}
+
+// BanUser bans the user, ends all of their sessions and closes their
+// WebSocket connections and those of their bots.
+func (s *AuthService) BanUser(ctx context.Context, actor, username, reason string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.BanUser")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("admin.username", actor), attribute.String("user.username", username))
+
+	reason = strings.TrimSpace(reason)
+	if utf8.RuneCountInString(reason) > maxBanReasonLength {
+		return fmt.Errorf("%w: reason must be at most %d characters", ErrInvalidInput, maxBanReasonLength)
+	}
+
+	if _, err := s.moderationTarget(ctx, actor, username, models.RoleModerator); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if err := s.userRepo.BanUser(ctx, username, reason, time.Now()); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to ban user", "username", username, "error", err)
+		return errors.New("failed to ban user")
+	}
+
+	if err := s.RevokeAllSessions(ctx, username); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	// Bots act on behalf of their owner; their keys stop working with the ban.
+	if s.wsHub != nil {
+		bots, err := s.userRepo.GetBots(ctx, username)
+		if err != nil {
+			s.logger.Warn("failed to list bots of banned user", "username", username, "error", err)
+		}
+		for _, bot := range bots {
+			s.wsHub.DisconnectUser(bot.Username)
+		}
+	}
+
+	span.SetStatus(codes.Ok, "user banned")
+	s.logger.Info("user banned", "username", username, "by", actor, "reason", reason)
+	return nil
+}
+
+func (s *AuthService) UnbanUser(ctx context.Context, actor, username string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.UnbanUser")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("admin.username", actor), attribute.String("user.username", username))
+
+	if _, err := s.moderationTarget(ctx, actor, username, models.RoleModerator); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if err := s.userRepo.UnbanUser(ctx, username); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to unban user", "username", username, "error", err)
+		return errors.New("failed to unban user")
+	}
+
+	span.SetStatus(codes.Ok, "user unbanned")
+	s.logger.Info("user unbanned", "username", username, "by", actor)
+	return nil
+}
+
+// SetUserRole changes a user's global role. A promotion shows up in the next
+// access token; a demotion ends the user's sessions so it applies at once.
+func (s *AuthService) SetUserRole(ctx context.Context, actor, username string, role models.Role) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.SetUserRole")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("admin.username", actor),
+		attribute.String("user.username", username),
+		attribute.String("user.role", string(role)),
+	)
+
+	if !role.Valid() {
+		return fmt.Errorf("%w: role must be one of user, moderator or admin", ErrInvalidRole)
+	}
+
+	target, err := s.moderationTarget(ctx, actor, username, models.RoleAdmin)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if target.IsBot && role != models.RoleUser {
+		return fmt.Errorf("%w: bots cannot hold a role", ErrInvalidRole)
+	}
+
+	current := roleOf(target)
+	if current == role {
+		return nil
+	}
+
+	if err := s.userRepo.SetRole(ctx, username, role); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to set role", "username", username, "error", err)
+		return errors.New("failed to set role")
+	}
+
+	if !role.AtLeast(current) {
+		if err := s.RevokeAllSessions(ctx, username); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	span.SetStatus(codes.Ok, "role changed")
+	s.logger.Info("role changed", "username", username, "from", current, "to", role, "by", actor)
+	return nil
+}
+
+// ForceVerifyEmail marks the user's email as verified without a verification link.
+func (s *AuthService) ForceVerifyEmail(ctx context.Context, actor, username string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.ForceVerifyEmail")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("admin.username", actor), attribute.String("user.username", username))
+
+	target, err := s.moderationTarget(ctx, actor, username, models.RoleAdmin)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if target.IsVerefied {
+		return nil
+	}
+
+	if err := s.userRepo.MarkUserAsVerified(ctx, username); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to mark user as verified", "username", username, "error", err)
+		return errors.New("verification failed")
+	}
+
+	span.SetStatus(codes.Ok, "email verified")
+	s.logger.Info("email verified by admin", "username", username, "by", actor)
+	return nil
+}
+
+// AdminResetPassword disables the user's password, ends their sessions and
+// emails them a reset link. The administrator never learns a password.
+func (s *AuthService) AdminResetPassword(ctx context.Context, actor, username string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.AdminResetPassword")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("admin.username", actor), attribute.String("user.username", username))
+
+	target, err := s.moderationTarget(ctx, actor, username, models.RoleAdmin)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if target.IsBot {
+		return fmt.Errorf("%w: bots have no password", ErrInvalidInput)
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, username, disabledPasswordHash); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to disable password", "username", username, "error", err)
+		return errors.New("password reset failed")
+	}
+
+	if err := s.RevokeAllSessions(ctx, username); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if err := s.sendPasswordResetLink(ctx, username, target.Email); err != nil {
+		span.RecordError(err)
+		return errors.New("failed to send password reset email")
+	}
+
+	span.SetStatus(codes.Ok, "password reset")
+	s.logger.Info("password reset by admin", "username", username, "by", actor)
+	return nil
+}
+
+// moderationTarget loads the user an administrative action is aimed at. The
+// actor's role is read from the database rather than from their token, must
+// be at least required and must outrank the target's.
+func (s *AuthService) moderationTarget(ctx context.Context, actor, username string, required models.Role) (*models.User, error) {
+	if actor == username {
+		return nil, fmt.Errorf("%w: cannot act on your own account", ErrInsufficientRole)
+	}
+
+	actorUser, err := s.userRepo.GetUserByName(ctx, actor)
+	if err != nil {
+		s.logger.Error("failed to get user", "username", actor, "error", err)
+		return nil, err
+	}
+	if actorUser == nil || actorUser.IsBanned() || !roleOf(actorUser).AtLeast(required) {
+		return nil, ErrInsufficientRole
+	}
+
+	target, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		s.logger.Error("failed to get user", "username", username, "error", err)
+		return nil, err
+	}
+	if target == nil {
+		return nil, ErrUserNotFound
+	}
+	if roleOf(target).AtLeast(roleOf(actorUser)) {
+		return nil, fmt.Errorf("%w: %s is not below your role", ErrInsufficientRole, username)
+	}
+
+	return target, nil
+}
@@ -0,0 +1,273 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"massager/internal/models"
+	"massager/internal/ports"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrInvalidAPIKey        = errors.New("invalid api key")
+	ErrInvalidAPIKeyRequest = errors.New("invalid api key request")
+	ErrAPIKeyNotFound       = errors.New("api key not found")
+	ErrInvalidBot           = errors.New("invalid bot")
+	ErrBotNotFound          = errors.New("bot not found")
+	ErrBotLimitReached      = errors.New("bot limit reached")
+	ErrUsernameTaken        = errors.New("username already exists")
+)
+
+const (
+	// APIKeyPrefix marks API keys so they can be told apart from access tokens
+	// (and found by secret scanners).
+	APIKeyPrefix = "hzn_"
+
+	// apiKeyDisplayLength is how much of a key is kept in clear for listings.
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+	maxAPIKeyNameLength = 64
+	maxBotsPerUser      = 10
+)
+
+func (s *AuthService) SetAPIKeys(apiKeys ports.IAPIKeyRepository) {
+	s.apiKeys = apiKeys
+}
+
+// Authenticate accepts either an access token or an API key.
+func (s *AuthService) Authenticate(ctx context.Context, credential string) (*models.TokenClaims, error) {
+	if strings.HasPrefix(credential, APIKeyPrefix) {
+		return s.ValidateAPIKey(ctx, credential)
+	}
+	return s.ValidateToken(ctx, credential)
+}
+
+// ValidateAPIKey resolves an API key to the user it acts as. The returned
+// claims carry the key's scopes.
+func (s *AuthService) ValidateAPIKey(ctx context.Context, rawKey string) (*models.TokenClaims, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.ValidateAPIKey")
+	defer span.End()
+
+	if s.apiKeys == nil {
+		span.RecordError(ErrInvalidAPIKey)
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.apiKeys.GetAPIKeyByHash(ctx, hashToken(rawKey))
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("api key lookup failed", "error", err)
+		return nil, err
+	}
+
+	now := time.Now()
+	if key == nil || key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		span.RecordError(ErrInvalidAPIKey)
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > sessionTouchInterval {
+		if err := s.apiKeys.TouchAPIKey(ctx, key.ID, now); err != nil {
+			s.logger.Warn("failed to update api key usage", "key_id", key.ID, "error", err)
+		}
+	}
+
+	span.SetAttributes(attribute.String("token.username", key.Username), attribute.Int("api_key.id", key.ID))
+	span.SetStatus(codes.Ok, "api key valid")
+	return &models.TokenClaims{Username: key.Username, IssuedAt: key.CreatedAt, APIKeyID: key.ID, Scopes: key.Scopes}, nil
+}
+
+// CreateBot creates a bot account owned by the caller.
+func (s *AuthService) CreateBot(ctx context.Context, owner, username, displayName string) (*models.Profile, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.CreateBot")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", owner), attribute.String("bot.username", username))
+
+	displayName = strings.TrimSpace(displayName)
+	switch {
+	case username == "" || sanitizeUsername(username) != username:
+		return nil, fmt.Errorf("%w: username may only contain lowercase letters, digits, dots, dashes and underscores", ErrInvalidBot)
+	case strings.HasPrefix(username, deletedUsernamePrefix):
+		return nil, ErrUsernameTaken
+	case utf8.RuneCountInString(displayName) > maxDisplayNameLength:
+		return nil, fmt.Errorf("%w: display name must be at most %d characters", ErrInvalidBot, maxDisplayNameLength)
+	}
+
+	if err := s.policy.ValidateUsername(username); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	existing, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if existing != nil {
+		span.RecordError(ErrUsernameTaken)
+		return nil, ErrUsernameTaken
+	}
+
+	bots, err := s.userRepo.GetBots(ctx, owner)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if len(bots) >= maxBotsPerUser {
+		span.RecordError(ErrBotLimitReached)
+		return nil, ErrBotLimitReached
+	}
+
+	if err := s.userRepo.CreateBot(ctx, owner, username, displayName); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to create bot", "owner", owner, "bot", username, "error", err)
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "bot created")
+	s.logger.Info("bot created", "owner", owner, "bot", username)
+	return &models.Profile{Username: username, DisplayName: displayName, IsBot: true}, nil
+}
+
+func (s *AuthService) GetBots(ctx context.Context, owner string) ([]models.Profile, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.GetBots")
+	defer span.End()
+
+	bots, err := s.userRepo.GetBots(ctx, owner)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "bots listed")
+	return bots, nil
+}
+
+// CreateAPIKey issues a key for the caller or, with request.Bot set, for one
+// of their bots. The raw key is only ever returned here.
+func (s *AuthService) CreateAPIKey(ctx context.Context, owner string, request models.NewAPIKey) (string, *models.APIKey, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.CreateAPIKey")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", owner))
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return "", nil, fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidAPIKeyRequest, maxAPIKeyNameLength)
+	}
+
+	scopes, err := normalizeScopes(request.Scopes)
+	if err != nil {
+		span.RecordError(err)
+		return "", nil, err
+	}
+
+	if request.ExpiresAt != nil && !request.ExpiresAt.After(time.Now()) {
+		return "", nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidAPIKeyRequest)
+	}
+
+	subject := owner
+	if request.Bot != "" {
+		bot, err := s.userRepo.GetUserByName(ctx, request.Bot)
+		if err != nil {
+			span.RecordError(err)
+			return "", nil, err
+		}
+		if bot == nil || !bot.IsBot || bot.BotOwner != owner {
+			span.RecordError(ErrBotNotFound)
+			return "", nil, ErrBotNotFound
+		}
+		subject = bot.Username
+	}
+
+	secret, err := generateSecureToken()
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to generate api key", "error", err)
+		return "", nil, err
+	}
+	rawKey := APIKeyPrefix + secret
+
+	key := models.APIKey{
+		Username:  subject,
+		Name:      name,
+		Prefix:    rawKey[:apiKeyDisplayLength],
+		KeyHash:   hashToken(rawKey),
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+		ExpiresAt: request.ExpiresAt,
+	}
+
+	key.ID, err = s.apiKeys.CreateAPIKey(ctx, key)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to store api key", "username", subject, "error", err)
+		return "", nil, err
+	}
+
+	span.SetStatus(codes.Ok, "api key created")
+	s.logger.Info("api key created", "owner", owner, "username", subject, "key_id", key.ID, "scopes", scopes)
+	return rawKey, &key, nil
+}
+
+// GetAPIKeys lists the active keys of the caller and their bots.
+func (s *AuthService) GetAPIKeys(ctx context.Context, owner string) ([]models.APIKey, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.GetAPIKeys")
+	defer span.End()
+
+	keys, err := s.apiKeys.GetOwnedAPIKeys(ctx, owner)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "api keys listed")
+	return keys, nil
+}
+
+func (s *AuthService) RevokeAPIKey(ctx context.Context, owner string, keyID int) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.RevokeAPIKey")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", owner), attribute.Int("api_key.id", keyID))
+
+	revoked, err := s.apiKeys.RevokeAPIKey(ctx, keyID, owner)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke api key", "key_id", keyID, "error", err)
+		return err
+	}
+	if !revoked {
+		span.RecordError(ErrAPIKeyNotFound)
+		return ErrAPIKeyNotFound
+	}
+
+	span.SetStatus(codes.Ok, "api key revoked")
+	s.logger.Info("api key revoked", "owner", owner, "key_id", keyID)
+	return nil
+}
+
+// normalizeScopes rejects unknown scopes and returns the rest sorted and deduplicated.
+func normalizeScopes(scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidAPIKeyRequest)
+	}
+
+	normalized := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !slices.Contains(models.APIKeyScopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKeyRequest, scope)
+		}
+		normalized = append(normalized, scope)
+	}
+
+	slices.Sort(normalized)
+	return slices.Compact(normalized), nil
+}
@@ -6,34 +6,105 @@ import (
 	"encoding/hex"
 	"errors"
 	"log/slog"
+	"massager/app/config"
+	"massager/internal/models"
 	"massager/internal/ports"
+	"massager/internal/services/jwtkeys"
+	"massager/internal/services/oidc"
+	"massager/internal/services/validation"
+	websocket "massager/internal/websocet"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+	ErrSessionNotFound     = errors.New("session not found")
+)
+
+const (
+	defaultAccessTokenTTL  = time.Hour
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	// sessionTouchInterval limits how often last-seen timestamps are written.
+	sessionTouchInterval = time.Minute
+)
+
 type AuthService struct {
 	userRepo     ports.IUserRepository
 	hasher       ports.IHasher
 	logger       *slog.Logger
 	tokenRepo    ports.TokenRepository
-	jwtKey       []byte
+	refreshRepo  ports.RefreshTokenRepository
+	sessionRepo  ports.ISessionRepository
+	keys         *jwtkeys.KeySet
 	emailService ports.IEmailService
+	wsHub        *websocket.Hub
+	loginGuard   *LoginGuard
+	attempts     ports.AttemptRepository
+	verification config.VerificationConfig
+	policy       *validation.Policy
 	tracer       trace.Tracer
+
+	oidcProviders map[string]*oidc.Provider
+	oidcStates    ports.OIDCStateRepository
+
+	apiKeys ports.IAPIKeyRepository
+
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
 }
 
-func NewAuthService(repo ports.IUserRepository, emailService ports.IEmailService, hasher ports.IHasher, tokenRepo ports.TokenRepository, jwtKey []byte,
-	logger *slog.Logger, tracer trace.Tracer) *AuthService {
-	return &AuthService{userRepo: repo, emailService: emailService, hasher: hasher, tokenRepo: tokenRepo, jwtKey: jwtKey, logger: logger, tracer: tracer}
+func NewAuthService(repo ports.IUserRepository, emailService ports.IEmailService, hasher ports.IHasher, tokenRepo ports.TokenRepository,
+	refreshRepo ports.RefreshTokenRepository, sessionRepo ports.ISessionRepository, jwtKey []byte, logger *slog.Logger, tracer trace.Tracer) *AuthService {
+	return &AuthService{userRepo: repo, emailService: emailService, hasher: hasher, tokenRepo: tokenRepo, refreshRepo: refreshRepo, sessionRepo: sessionRepo,
+		keys: jwtkeys.NewHMACKeySet(jwtKey), logger: logger, tracer: tracer, accessTokenTTL: defaultAccessTokenTTL, refreshTokenTTL: defaultRefreshTokenTTL,
+		verification: config.VerificationConfig{TokenTTL: defaultVerificationTokenTTL}, policy: validation.Default()}
+}
+
+// SetValidationPolicy replaces the default rules for usernames, emails and
+// passwords.
+func (s *AuthService) SetValidationPolicy(policy *validation.Policy) {
+	s.policy = policy
+}
+
+// SetKeySet replaces the HS256 key passed to NewAuthService, e.g. with
+// asymmetric keys loaded by jwtkeys.Load.
+func (s *AuthService) SetKeySet(keys *jwtkeys.KeySet) {
+	s.keys = keys
+}
+
+// JWKS returns the public keys other services need to verify our access tokens.
+func (s *AuthService) JWKS() jwtkeys.JWKS {
+	return s.keys.JWKS()
+}
+
+func (s *AuthService) SetWSHub(wsHub *websocket.Hub) {
+	s.wsHub = wsHub
+}
+
+func (s *AuthService) SetTokenTTL(accessTokenTTL, refreshTokenTTL time.Duration) {
+	if accessTokenTTL > 0 {
+		s.accessTokenTTL = accessTokenTTL
+	}
+	if refreshTokenTTL > 0 {
+		s.refreshTokenTTL = refreshTokenTTL
+	}
 }
 
 func (s *AuthService) Register(c context.Context, username, password, email string) error {
 	c, span := s.tracer.Start(c, "AuthService.Register")
 	defer span.End()
 
+	email = normalizeEmail(email)
 	span.SetAttributes(
 		attribute.String("user.username", username),
 		attribute.String("user.email", email),
@@ -44,6 +115,19 @@ func (s *AuthService) Register(c context.Context, username, password, email stri
 		return errors.New("username, password and email are required")
 	}
 
+	// Deleted accounts are renamed to deleted-<id>.
+	if strings.HasPrefix(strings.ToLower(username), deletedUsernamePrefix) {
+		span.RecordError(errors.New("reserved username"))
+		s.logger.Warn("reserved username in registration", "username", username)
+		return errors.New("username already exists")
+	}
+
+	if err := s.policy.ValidateRegistration(username, email, password); err != nil {
+		span.RecordError(err)
+		s.logger.Warn("registration rejected by validation policy", "username", username, "error", err)
+		return err
+	}
+
 	s.logger.Debug("attempting user registration", "username", username, "email", email)
 
 	existingUser, err := s.userRepo.GetUserByName(c, username)
@@ -58,7 +142,7 @@ func (s *AuthService) Register(c context.Context, username, password, email stri
 		return errors.New("username already exists")
 	}
 
-	verifyToken, err := generateVerificationToken()
+	verifyToken, err := generateSecureToken()
 	if err != nil {
 		span.RecordError(err)
 		s.logger.Error("failed to generate verification token", "error", err)
@@ -72,7 +156,7 @@ func (s *AuthService) Register(c context.Context, username, password, email stri
 		return errors.New("registration failed")
 	}
 
-	err = s.userRepo.CreateUser(c, username, string(hashedPassword), email, verifyToken)
+	err = s.userRepo.CreateUser(c, username, string(hashedPassword), email, hashToken(verifyToken), time.Now().Add(s.verification.TokenTTL))
 	if err != nil {
 		span.RecordError(err)
 		s.logger.Warn("user creation failed", "error", err)
@@ -93,25 +177,34 @@ func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
 	ctx, span := s.tracer.Start(ctx, "AuthService.VerifyEmail")
 	defer span.End()
 
-	span.SetAttributes(attribute.String("verification.token", token))
-
 	if token == "" {
 		span.RecordError(errors.New("verification token is required"))
 		return errors.New("verifiaction token is requered")
 	}
 
-	user, err := s.userRepo.GetUserByVerifyToken(ctx, token)
+	user, err := s.userRepo.GetUserByVerifyToken(ctx, hashToken(token))
 	if err != nil {
 		span.RecordError(err)
 		s.logger.Warn("failed to find user by verifiaction token", "error", err)
 		return errors.New("invalid verification token")
 	}
 
+	if user == nil {
+		span.RecordError(errors.New("unknown verification token"))
+		return errors.New("invalid verification token")
+	}
+
 	if user.IsVerefied {
 		span.RecordError(errors.New("email already verified"))
 		return errors.New("email already verefied")
 	}
 
+	if !user.VerifyTokenExpiresAt.IsZero() && time.Now().After(user.VerifyTokenExpiresAt) {
+		span.RecordError(ErrVerificationTokenExpired)
+		s.logger.Warn("expired verification token", "username", user.Username)
+		return ErrVerificationTokenExpired
+	}
+
 	err = s.userRepo.MarkUserAsVerified(ctx, user.Username)
 	if err != nil {
 		span.RecordError(err)
@@ -124,7 +217,9 @@ func (s *AuthService) VerifyEmail(ctx context.Context, token string) error {
 	return nil
 }
 
-func (s *AuthService) Login(ctx context.Context, username, password string) (string, error) {
+// Login checks the password. Accounts with two-factor authentication get an
+// MFA token instead of a token pair; it is exchanged in CompleteMFALogin.
+func (s *AuthService) Login(ctx context.Context, username, password string, client models.ClientInfo) (*models.LoginResult, error) {
 	ctx, span := s.tracer.Start(ctx, "AuthService.VerifyEmail")
 	defer span.End()
 
@@ -136,145 +231,627 @@ func (s *AuthService) Login(ctx context.Context, username, password string) (str
 		err := errors.New("username and password are required")
 		span.RecordError(err)
 		s.logger.Warn("empty username or password")
-		return "", err
+		return nil, err
 	}
 
 	s.logger.Debug("attempting login", "username", username)
 
+	if err := s.checkLoginAllowed(ctx, username, client.IPAddress); err != nil {
+		span.RecordError(err)
+		s.logger.Warn("login attempt rejected", "username", username, "error", err)
+		return nil, err
+	}
+
 	user, err := s.userRepo.GetUserByName(ctx, username)
 	if err != nil {
 		span.RecordError(err)
 		s.logger.Warn("user not found", "username", username, "error", err)
-		return "", errors.New("invalid credentials")
+		return nil, errors.New("invalid credentials")
 	}
 
 	if user == nil {
 		span.RecordError(errors.New("user not found"))
 		s.logger.Warn("user not found", "username", username)
-		return "", errors.New("invalid credentials")
+		s.recordLoginFailure(ctx, username, client.IPAddress, nil)
+		return nil, errors.New("invalid credentials")
 	}
 
 	if !user.IsVerefied {
 		span.RecordError(errors.New("email not verified"))
 		s.logger.Warn("attempt to login with unverified email", "username", username)
-		return "", errors.New("email not verified. Please check your email for verification link")
+		return nil, errors.New("email not verified. Please check your email for verification link")
 	}
 
 	if err := s.hasher.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
 		span.RecordError(err)
 		s.logger.Warn("invalid password", "username", username)
-		return "", errors.New("invalid credentials")
+		s.recordLoginFailure(ctx, username, client.IPAddress, user)
+		return nil, errors.New("invalid credentials")
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"username": user.Username,
-		"exp":      time.Now().Add(time.Hour * 1).Unix(),
-	})
+	s.upgradePasswordHash(ctx, user, password)
+
+	if user.IsBanned() {
+		span.RecordError(ErrAccountBanned)
+		s.logger.Warn("login attempt of banned user", "username", username)
+		return nil, ErrAccountBanned
+	}
+
+	if user.TOTPEnabled {
+		mfaToken, err := s.issueMFAToken(user.Username)
+		if err != nil {
+			span.RecordError(err)
+			s.logger.Error("mfa token generation failed", "error", err)
+			return nil, errors.New("authentication failed")
+		}
 
-	tokenString, err := token.SignedString(s.jwtKey)
+		span.SetStatus(codes.Ok, "second factor required")
+		s.logger.Info("password accepted, second factor required", "username", username)
+		return &models.LoginResult{MFAToken: mfaToken}, nil
+	}
+
+	tokens, err := s.startSession(ctx, user, client)
 	if err != nil {
 		span.RecordError(err)
 		s.logger.Error("token generation failed", "error", err)
-		return "", errors.New("authentication failed")
+		return nil, errors.New("authentication failed")
 	}
 
+	s.recordLoginSuccess(ctx, user.Username)
+
 	span.SetStatus(codes.Ok, "login successful")
 	s.logger.Info("login successful", "username", username)
-	return tokenString, nil
+	return &models.LoginResult{Tokens: tokens}, nil
+}
+
+// RefreshTokens rotates a refresh token: the presented token is spent and a new
+// access/refresh pair of the same family is issued. Presenting an already spent
+// token revokes the whole family, since one of the holders must be an attacker.
+func (s *AuthService) RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.RefreshTokens")
+	defer span.End()
+
+	if refreshToken == "" {
+		span.RecordError(ErrInvalidRefreshToken)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	tokenHash := hashToken(refreshToken)
+	span.SetAttributes(attribute.String("token.hash", tokenHash))
+
+	stored, err := s.refreshRepo.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("refresh token lookup failed", "error", err)
+		return nil, err
+	}
+	if stored == nil {
+		span.RecordError(ErrInvalidRefreshToken)
+		s.logger.Warn("unknown refresh token")
+		return nil, ErrInvalidRefreshToken
+	}
+
+	span.SetAttributes(attribute.String("user.username", stored.Username))
+
+	familyRevoked, err := s.refreshRepo.IsFamilyRevoked(ctx, stored.FamilyID)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("refresh token family check failed", "error", err)
+		return nil, err
+	}
+	if familyRevoked {
+		span.RecordError(ErrInvalidRefreshToken)
+		s.logger.Warn("refresh token of revoked family presented", "username", stored.Username)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		span.RecordError(ErrInvalidRefreshToken)
+		s.logger.Warn("expired refresh token presented", "username", stored.Username)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	revokedBefore, err := s.tokenRepo.GetRevokedBefore(ctx, stored.Username)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("token cutoff check failed", "error", err)
+		return nil, err
+	}
+	if issuedBeforeCutoff(stored.IssuedAt, revokedBefore) {
+		span.RecordError(ErrInvalidRefreshToken)
+		s.logger.Warn("refresh token issued before revocation cutoff", "username", stored.Username)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	firstUse := !stored.Used
+	if firstUse {
+		firstUse, err = s.refreshRepo.MarkRefreshTokenUsed(ctx, tokenHash)
+		if err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to mark refresh token as used", "error", err)
+			return nil, err
+		}
+	}
+
+	if !firstUse {
+		if err := s.refreshRepo.RevokeFamily(ctx, stored.FamilyID, s.refreshTokenTTL); err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to revoke refresh token family", "error", err)
+			return nil, err
+		}
+		span.RecordError(ErrRefreshTokenReused)
+		s.logger.Warn("refresh token reuse detected, family revoked", "username", stored.Username, "family", stored.FamilyID)
+		return nil, ErrRefreshTokenReused
+	}
+
+	// The user is loaded again so the new access token carries their current role.
+	user, err := s.userRepo.GetUserByName(ctx, stored.Username)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to get user", "username", stored.Username, "error", err)
+		return nil, err
+	}
+	if user == nil {
+		span.RecordError(ErrInvalidRefreshToken)
+		return nil, ErrInvalidRefreshToken
+	}
+	if user.IsBanned() {
+		span.RecordError(ErrAccountBanned)
+		s.logger.Warn("refresh attempt of banned user", "username", stored.Username)
+		return nil, ErrAccountBanned
+	}
+
+	tokens, err := s.issueTokenPair(ctx, user, stored.FamilyID)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("token generation failed", "error", err)
+		return nil, errors.New("authentication failed")
+	}
+
+	span.SetStatus(codes.Ok, "tokens refreshed")
+	s.logger.Info("tokens refreshed", "username", stored.Username)
+	return tokens, nil
+}
+
+// upgradePasswordHash replaces a hash made with an older algorithm or weaker
+// parameters while the plaintext password is at hand. Failures only get logged.
+func (s *AuthService) upgradePasswordHash(ctx context.Context, user *models.User, password string) {
+	if !s.hasher.NeedsRehash([]byte(user.Password)) {
+		return
+	}
+
+	hashedPassword, err := s.hasher.GenerateFromPassword([]byte(password), s.hasher.DefaultCost())
+	if err != nil {
+		s.logger.Warn("password rehash failed", "username", user.Username, "error", err)
+		return
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, user.Username, string(hashedPassword)); err != nil {
+		s.logger.Warn("failed to store rehashed password", "username", user.Username, "error", err)
+		return
+	}
+
+	s.logger.Info("password hash upgraded", "username", user.Username)
 }
 
-func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (string, error) {
+// startSession records a new session for the device and issues its first token
+// pair. The session ID doubles as the refresh token family.
+func (s *AuthService) startSession(ctx context.Context, user *models.User, client models.ClientInfo) (*models.TokenPair, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:          uuid.New().String(),
+		Username:    user.Username,
+		DeviceLabel: client.DeviceLabel,
+		IPAddress:   client.IPAddress,
+		UserAgent:   client.UserAgent,
+		CreatedAt:   now,
+		LastSeenAt:  now,
+	}
+
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, err
+	}
+
+	return s.issueTokenPair(ctx, user, session.ID)
+}
+
+func (s *AuthService) issueTokenPair(ctx context.Context, user *models.User, sessionID string) (*models.TokenPair, error) {
+	username := user.Username
+	now := time.Now()
+	accessExpiresAt := now.Add(s.accessTokenTTL)
+
+	accessToken, err := s.keys.Sign(jwt.MapClaims{
+		"username": username,
+		"sid":      sessionID,
+		"role":     string(roleOf(user)),
+		"typ":      accessTokenType,
+		"iat":      numericDate(now),
+		"exp":      accessExpiresAt.Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := generateSecureToken()
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.refreshRepo.SaveRefreshToken(ctx, models.RefreshToken{
+		TokenHash: hashToken(refreshToken),
+		FamilyID:  sessionID,
+		Username:  username,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.refreshTokenTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{AccessToken: accessToken, RefreshToken: refreshToken, ExpiresAt: accessExpiresAt}, nil
+}
+
+func (s *AuthService) ValidateToken(ctx context.Context, tokenString string) (*models.TokenClaims, error) {
 	ctx, span := s.tracer.Start(ctx, "AuthService.ValidateToken")
 	defer span.End()
 
 	if tokenString == "" {
 		err := errors.New("token is required")
 		span.RecordError(err)
-		return "", err
+		return nil, err
 	}
 
-	hash := sha256.Sum256([]byte(tokenString))
-	tokenHash := hex.EncodeToString(hash[:])
+	tokenHash := hashToken(tokenString)
 	span.SetAttributes(attribute.String("token.hash", tokenHash))
 
 	isRevoked, err := s.tokenRepo.IsRevoked(ctx, tokenHash)
 	if err != nil {
 		span.RecordError(err)
 		s.logger.Error("token revocation check failed", "error", err)
-		return "", err
+		return nil, err
 	}
 	if isRevoked {
 		var err = errors.New("token revoked")
 		span.RecordError(err)
-		return "", err
+		return nil, err
 	}
 
-	token, err := jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errors.New("unexpected signing method")
-		}
-		return s.jwtKey, nil
-	})
+	claims, err := s.parseAccessToken(tokenString)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
 
+	revokedBefore, err := s.tokenRepo.GetRevokedBefore(ctx, claims.Username)
 	if err != nil {
 		span.RecordError(err)
+		s.logger.Error("token cutoff check failed", "error", err)
+		return nil, err
+	}
+	if issuedBeforeCutoff(claims.IssuedAt, revokedBefore) {
+		var err = errors.New("token revoked")
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if claims.SessionID != "" {
+		if err := s.checkSession(ctx, claims); err != nil {
+			span.RecordError(err)
+			return nil, err
+		}
+	}
+
+	span.SetAttributes(attribute.String("token.username", claims.Username))
+	span.SetStatus(codes.Ok, "token valid")
+	s.logger.Debug("token validated", "username", claims.Username)
+	return claims, nil
+}
+
+// parseAccessToken verifies the signature and expiry of an access token without
+// consulting the revocation state.
+func (s *AuthService) parseAccessToken(tokenString string) (*models.TokenClaims, error) {
+	claims, err := s.parseSignedToken(tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens issued before "typ" was introduced are access tokens.
+	if typ, ok := claims["typ"]; ok && typ != accessTokenType {
+		return nil, errors.New("invalid token type")
+	}
+
+	exp, _ := claims["exp"].(float64)
+	username, _ := claims["username"].(string)
+
+	// Tokens issued before "iat" was introduced are treated as issued at the epoch,
+	// so a revoke-all cutoff applies to them as well.
+	iat, _ := claims["iat"].(float64)
+	sessionID, _ := claims["sid"].(string)
+
+	// Tokens issued before roles were introduced belong to regular users.
+	role := models.RoleUser
+	if claimedRole, ok := claims["role"].(string); ok && models.Role(claimedRole).Valid() {
+		role = models.Role(claimedRole)
+	}
+
+	return &models.TokenClaims{
+		Username:  username,
+		SessionID: sessionID,
+		IssuedAt:  time.UnixMilli(int64(math.Round(iat * 1000))),
+		ExpiresAt: time.Unix(int64(exp), 0),
+		Role:      role,
+	}, nil
+}
+
+// parseSignedToken checks the signature, expiry and subject shared by every
+// token type the service issues.
+func (s *AuthService) parseSignedToken(tokenString string) (jwt.MapClaims, error) {
+	token, err := jwt.Parse(tokenString, s.keys.Keyfunc)
+
+	if err != nil {
 		s.logger.Warn("token parsing failed", "error", err)
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	if !token.Valid {
-		err := errors.New("invalid token")
-		span.RecordError(err)
-		return "", err
+		return nil, errors.New("invalid token")
 	}
 
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		err := errors.New("invalid token claims")
-		span.RecordError(err)
-		return "", err
+		return nil, errors.New("invalid token claims")
 	}
 
 	exp, ok := claims["exp"].(float64)
 	if !ok {
-		err := errors.New("token expiration missing")
-		span.RecordError(err)
-		return "", err
+		return nil, errors.New("token expiration missing")
 	}
 
 	if time.Now().Unix() > int64(exp) {
-		err := errors.New("token expired")
-		span.RecordError(err)
-		return "", err
+		return nil, errors.New("token expired")
 	}
 
 	username, ok := claims["username"].(string)
 	if !ok || username == "" {
-		err := errors.New("username missing in token")
-		span.RecordError(err)
-		return "", err
+		return nil, errors.New("username missing in token")
 	}
-	span.SetAttributes(attribute.String("token.username", username))
-	span.SetStatus(codes.Ok, "token valid")
-	s.logger.Debug("token validated", "username", username)
-	return username, nil
+
+	return claims, nil
+}
+
+func (s *AuthService) checkSession(ctx context.Context, claims *models.TokenClaims) error {
+	session, err := s.sessionRepo.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		s.logger.Error("session lookup failed", "error", err)
+		return err
+	}
+
+	if session == nil || session.RevokedAt != nil || session.Username != claims.Username {
+		return errors.New("session revoked")
+	}
+
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err := s.sessionRepo.TouchSession(ctx, session.ID, time.Now()); err != nil {
+			s.logger.Warn("failed to update session last seen time", "sessionID", session.ID, "error", err)
+		}
+	}
+
+	return nil
 }
 
 func (s *AuthService) RevokeToken(ctx context.Context, tokenString string, expiration time.Duration) error {
-	hash := sha256.Sum256([]byte(tokenString))
-	tokenHash := hex.EncodeToString(hash[:])
-	return s.tokenRepo.Revoke(ctx, tokenHash, expiration)
+	return s.tokenRepo.Revoke(ctx, hashToken(tokenString), expiration)
 }
 
-func (s *AuthService) GetVerificationToken(ctx context.Context, username string) (string, error) {
-	user, err := s.userRepo.GetUserByName(ctx, username)
+// Logout revokes the access token for the rest of its lifetime. When a refresh
+// token of the same user is supplied, its whole family is revoked as well.
+func (s *AuthService) Logout(ctx context.Context, accessToken, refreshToken string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.Logout")
+	defer span.End()
+
+	claims, err := s.parseAccessToken(accessToken)
 	if err != nil {
-		return "", err
+		span.RecordError(err)
+		return err
 	}
-	if user == nil {
-		return "", errors.New("user not found")
+
+	span.SetAttributes(attribute.String("user.username", claims.Username))
+
+	if err := s.RevokeToken(ctx, accessToken, time.Until(claims.ExpiresAt)); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke access token", "username", claims.Username, "error", err)
+		return errors.New("logout failed")
+	}
+
+	if claims.SessionID != "" {
+		if err := s.revokeSession(ctx, claims.SessionID); err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to revoke session", "sessionID", claims.SessionID, "error", err)
+			return errors.New("logout failed")
+		}
+	}
+
+	if refreshToken != "" {
+		stored, err := s.refreshRepo.GetRefreshToken(ctx, hashToken(refreshToken))
+		if err != nil {
+			span.RecordError(err)
+			s.logger.Error("refresh token lookup failed", "error", err)
+			return errors.New("logout failed")
+		}
+
+		if stored != nil && stored.Username == claims.Username {
+			if err := s.refreshRepo.RevokeFamily(ctx, stored.FamilyID, s.refreshTokenTTL); err != nil {
+				span.RecordError(err)
+				s.logger.Error("failed to revoke refresh token family", "error", err)
+				return errors.New("logout failed")
+			}
+		}
+	}
+
+	span.SetStatus(codes.Ok, "logout successful")
+	s.logger.Info("logout successful", "username", claims.Username)
+	return nil
+}
+
+// RevokeAllSessions invalidates every access and refresh token issued to the user
+// so far and closes the user's open WebSocket connections.
+func (s *AuthService) RevokeAllSessions(ctx context.Context, username string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.RevokeAllSessions")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if username == "" {
+		err := errors.New("username is required")
+		span.RecordError(err)
+		return err
+	}
+
+	// The cutoff only has to outlive the longest-lived token it invalidates.
+	if err := s.tokenRepo.RevokeAllBefore(ctx, username, time.Now(), s.refreshTokenTTL); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke user tokens", "username", username, "error", err)
+		return errors.New("failed to revoke sessions")
+	}
+
+	if err := s.sessionRepo.RevokeUserSessions(ctx, username); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke user sessions", "username", username, "error", err)
+		return errors.New("failed to revoke sessions")
+	}
+
+	if s.wsHub != nil {
+		s.wsHub.DisconnectUser(username)
+	}
+
+	span.SetStatus(codes.Ok, "all sessions revoked")
+	s.logger.Info("all sessions revoked", "username", username)
+	return nil
+}
+
+// RevokeOtherSessions ends every session of the user except the current one.
+// Without a current session it falls back to RevokeAllSessions.
+func (s *AuthService) RevokeOtherSessions(ctx context.Context, username, currentSessionID string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.RevokeOtherSessions")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.username", username),
+		attribute.String("session.id", currentSessionID),
+	)
+
+	if currentSessionID == "" {
+		return s.RevokeAllSessions(ctx, username)
+	}
+
+	sessions, err := s.sessionRepo.GetUserSessions(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to get user sessions", "username", username, "error", err)
+		return errors.New("failed to revoke sessions")
+	}
+
+	for _, session := range sessions {
+		if session.ID == currentSessionID {
+			continue
+		}
+		if err := s.revokeSession(ctx, session.ID); err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to revoke session", "sessionID", session.ID, "error", err)
+			return errors.New("failed to revoke sessions")
+		}
+	}
+
+	span.SetStatus(codes.Ok, "other sessions revoked")
+	s.logger.Info("other sessions revoked", "username", username)
+	return nil
+}
+
+// GetSessions lists the user's active sessions, marking the one the request
+// was made from.
+func (s *AuthService) GetSessions(ctx context.Context, username, currentSessionID string) ([]models.Session, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.GetSessions")
+	defer span.End()
+
+	sessions, err := s.sessionRepo.GetUserSessions(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to get user sessions", "username", username, "error", err)
+		return nil, err
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentSessionID
 	}
-	return user.VerifyToken, nil
+
+	span.SetStatus(codes.Ok, "sessions retrieved")
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions: its refresh tokens stop working
+// immediately and its access tokens are rejected by ValidateToken.
+func (s *AuthService) RevokeSession(ctx context.Context, username, sessionID string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.RevokeSession")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.String("user.username", username),
+		attribute.String("session.id", sessionID),
+	)
+
+	session, err := s.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("session lookup failed", "error", err)
+		return err
+	}
+
+	if session == nil || session.Username != username || session.RevokedAt != nil {
+		span.RecordError(ErrSessionNotFound)
+		return ErrSessionNotFound
+	}
+
+	if err := s.revokeSession(ctx, sessionID); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke session", "sessionID", sessionID, "error", err)
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "session revoked")
+	s.logger.Info("session revoked", "username", username, "sessionID", sessionID)
+	return nil
+}
+
+func (s *AuthService) revokeSession(ctx context.Context, sessionID string) error {
+	if err := s.sessionRepo.RevokeSession(ctx, sessionID); err != nil {
+		return err
+	}
+	return s.refreshRepo.RevokeFamily(ctx, sessionID, s.refreshTokenTTL)
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// numericDate renders t as a JWT NumericDate with millisecond precision, so a
+// revoke-all cutoff can tell apart tokens issued within the same second.
+func numericDate(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+// issuedBeforeCutoff reports whether a token issued at issuedAt is invalidated
+// by a revoke-all cutoff. A token issued at the cutoff itself counts as
+// before it: issue times are truncated to milliseconds, so it may well have
+// been issued a moment earlier.
+func issuedBeforeCutoff(issuedAt, cutoff time.Time) bool {
+	return !cutoff.IsZero() && !issuedAt.After(cutoff)
+}
+
+// normalizeEmail is the one rule for addresses: they are stored and looked up
+// lower-case, so the repository can compare them exactly.
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
 }
 
 func (s *AuthService) GetUserVerificationStatus(ctx context.Context, username string) (bool, error) {
@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"massager/internal/models"
+	"massager/internal/ports"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrInvalidInvite        = errors.New("invite link is invalid or has expired")
+	ErrInvalidInviteRequest = errors.New("invalid invite request")
+	ErrInviteNotFound       = errors.New("invite not found")
+)
+
+// invitePrefixLength is how much of a token is kept in clear for listings.
+const invitePrefixLength = 8
+
+func (s *ChatService) SetInvites(invites ports.IChatInviteRepository) {
+	s.invites = invites
+}
+
+// CreateInvite issues an invite link to the chat. The token is returned only
+// here; the invite keeps just its hash.
+func (s *ChatService) CreateInvite(ctx context.Context, chatID int, actor string, request models.NewChatInvite) (string, *models.ChatInvite, error) {
+	ctx, span := s.tracer.Start(ctx, "ChatService.CreateInvite")
+	defer span.End()
+
+	span.SetAttributes(attribute.Int("chat.id", chatID), attribute.String("user.username", actor))
+
+	now := time.Now()
+	switch {
+	case request.MaxUses < 0:
+		return "", nil, fmt.Errorf("%w: max uses must not be negative", ErrInvalidInviteRequest)
+	case request.ExpiresAt != nil && !request.ExpiresAt.After(now):
+		return "", nil, fmt.Errorf("%w: expiry must be in the future", ErrInvalidInviteRequest)
+	}
+
+	if _, err := s.authorize(ctx, chatID, actor, models.ChatPermissionManageMembers); err != nil {
+		span.RecordError(err)
+		return "", nil, err
+	}
+
+	token, err := generateSecureToken()
+	if err != nil {
+		span.RecordError(err)
+		return "", nil, err
+	}
+
+	invite := models.ChatInvite{
+		ChatID:    chatID,
+		CreatedBy: actor,
+		Prefix:    token[:invitePrefixLength],
+		TokenHash: hashToken(token),
+		MaxUses:   request.MaxUses,
+		CreatedAt: now,
+		ExpiresAt: request.ExpiresAt,
+	}
+
+	invite.ID, err = s.invites.CreateInvite(ctx, invite)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to create chat invite", "chatID", chatID, "error", err)
+		return "", nil, err
+	}
+
+	span.SetStatus(codes.Ok, "invite created")
+	s.logger.Info("chat invite created", "chatID", chatID, "inviteID", invite.ID, "createdBy", actor)
+	return token, &invite, nil
+}
+
+// GetInvites lists the chat's invites that have not been revoked.
+func (s *ChatService) GetInvites(ctx context.Context, chatID int, actor string) ([]models.ChatInvite, error) {
+	ctx, span := s.tracer.Start(ctx, "ChatService.GetInvites")
+	defer span.End()
+
+	if _, err := s.authorize(ctx, chatID, actor, models.ChatPermissionManageMembers); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	invites, err := s.invites.GetChatInvites(ctx, chatID)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to list chat invites", "chatID", chatID, "error", err)
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "invites listed")
+	return invites, nil
+}
+
+func (s *ChatService) RevokeInvite(ctx context.Context, chatID int, actor string, inviteID int) error {
+	ctx, span := s.tracer.Start(ctx, "ChatService.RevokeInvite")
+	defer span.End()
+
+	if _, err := s.authorize(ctx, chatID, actor, models.ChatPermissionManageMembers); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	revoked, err := s.invites.RevokeInvite(ctx, chatID, inviteID)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke chat invite", "chatID", chatID, "inviteID", inviteID, "error", err)
+		return err
+	}
+	if !revoked {
+		return ErrInviteNotFound
+	}
+
+	span.SetStatus(codes.Ok, "invite revoked")
+	s.logger.Info("chat invite revoked", "chatID", chatID, "inviteID", inviteID, "revokedBy", actor)
+	return nil
+}
+
+// PreviewInvite shows what the invite leads to without joining.
+func (s *ChatService) PreviewInvite(ctx context.Context, token string) (*models.ChatInvitePreview, error) {
+	ctx, span := s.tracer.Start(ctx, "ChatService.PreviewInvite")
+	defer span.End()
+
+	invite, chat, err := s.resolveInvite(ctx, token)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "invite previewed")
+	return &models.ChatInvitePreview{
+		ChatID:      chat.ID,
+		Name:        chat.Name,
+		Description: chat.Description,
+		AvatarURL:   chat.AvatarURL,
+		MemberCount: len(chat.Members),
+		ExpiresAt:   invite.ExpiresAt,
+	}, nil
+}
+
+// JoinByInvite adds the user to the invite's chat with the same checks, insert
+// and member_added fan-out as a manual add. The use is counted in the same
+// transaction that stores the membership, so a failed join does not burn one
+// of the invite's uses.
+func (s *ChatService) JoinByInvite(ctx context.Context, token, username string) (*models.Chat, error) {
+	ctx, span := s.tracer.Start(ctx, "ChatService.JoinByInvite")
+	defer span.End()
+
+	if username == "" {
+		return nil, ErrInvalidInput
+	}
+
+	invite, chat, err := s.resolveInvite(ctx, token)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("chat.id", chat.ID), attribute.Int("invite.id", invite.ID))
+
+	if slices.Contains(chat.Members, username) {
+		return nil, ErrAlreadyChatMember
+	}
+
+	added, err := s.newMembers(ctx, chat, []string{username})
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	used, err := s.invites.UseInvite(ctx, invite.ID, username, time.Now())
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to use chat invite", "inviteID", invite.ID, "error", err)
+		return nil, err
+	}
+	if !used {
+		// A concurrent join of the same user gets the same answer as the
+		// membership check above.
+		if member, err := s.IsChatMember(ctx, chat.ID, username); err == nil && member {
+			return nil, ErrAlreadyChatMember
+		}
+		return nil, ErrInvalidInvite
+	}
+
+	s.membersAdded(chat, username, added)
+
+	chat.Title = chat.TitleFor(username)
+
+	span.SetStatus(codes.Ok, "joined by invite")
+	s.logger.Info("user joined chat by invite", "chatID", chat.ID, "inviteID", invite.ID, "userID", username)
+	return chat, nil
+}
+
+// resolveInvite finds a usable invite and its chat. Unknown, revoked, expired
+// and used-up invites all look the same to the caller.
+func (s *ChatService) resolveInvite(ctx context.Context, token string) (*models.ChatInvite, *models.Chat, error) {
+	if token == "" || s.invites == nil {
+		return nil, nil, ErrInvalidInvite
+	}
+
+	invite, err := s.invites.GetInviteByHash(ctx, hashToken(token))
+	if err != nil {
+		s.logger.Error("chat invite lookup failed", "error", err)
+		return nil, nil, err
+	}
+	if invite == nil || !invite.Usable(time.Now()) {
+		return nil, nil, ErrInvalidInvite
+	}
+
+	chat, err := s.chatRepo.GetChatByID(ctx, invite.ChatID)
+	if err != nil {
+		s.logger.Error("failed to check chat existence", "chatID", invite.ChatID, "error", err)
+		return nil, nil, err
+	}
+	if chat == nil || chat.IsDirect() {
+		return nil, nil, ErrInvalidInvite
+	}
+
+	return invite, chat, nil
+}
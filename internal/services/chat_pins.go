@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"massager/internal/models"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var ErrMessageNotFound = errors.New("message not found")
+
+// PinMessage pins or unpins a message of the chat for every member. In groups
+// this takes an admin; in direct chats either member may do it.
+func (s *ChatService) PinMessage(ctx context.Context, chatID, messageID int, actor string, pinned bool) error {
+	ctx, span := s.tracer.Start(ctx, "ChatService.PinMessage")
+	defer span.End()
+
+	span.SetAttributes(
+		attribute.Int("chat.id", chatID),
+		attribute.Int("message.id", messageID),
+		attribute.Bool("message.pinned", pinned),
+	)
+
+	if actor == "" || messageID <= 0 {
+		return ErrInvalidInput
+	}
+
+	chat, err := s.authorize(ctx, chatID, actor, models.ChatPermissionPin)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	found, err := s.messageRepo.SetMessagePinned(ctx, chatID, messageID, actor, pinned)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to pin message", "chatID", chatID, "messageID", messageID, "error", err)
+		return err
+	}
+	if !found {
+		span.RecordError(ErrMessageNotFound)
+		return ErrMessageNotFound
+	}
+
+	s.notifyMessagePinned(chat, messageID, actor, pinned)
+
+	span.SetStatus(codes.Ok, "message pin changed")
+	s.logger.Info("message pin changed", "chatID", chatID, "messageID", messageID, "pinned", pinned, "actor", actor)
+	return nil
+}
+
+func (s *ChatService) notifyMessagePinned(chat *models.Chat, messageID int, actor string, pinned bool) {
+	if s.wsHub == nil {
+		return
+	}
+
+	notification := map[string]interface{}{
+		"type":       "message_pinned",
+		"chat_id":    chat.ID,
+		"message_id": messageID,
+		"pinned":     pinned,
+		"pinned_by":  actor,
+		"updated_at": time.Now().Format(time.RFC3339),
+	}
+
+	for _, member := range chat.Members {
+		s.wsHub.BroadcastToUser(member, notification)
+	}
+}
@@ -3,11 +3,15 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"massager/internal/models"
 	"massager/internal/ports"
 	websocket "massager/internal/websocet"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
@@ -19,6 +23,15 @@ var (
 	ErrUserNotFound        = errors.New("user not found")
 	ErrChatNotFound        = errors.New("chat not found")
 	ErrNotChatMember       = errors.New("user is not a member of this chat")
+	ErrChatPermission      = errors.New("your role in this chat does not allow this action")
+	ErrInvalidChatRole     = errors.New("invalid chat role")
+	ErrAlreadyChatMember   = errors.New("user is already a member of this chat")
+	ErrDirectChat          = errors.New("direct chats have no name, roles or membership changes")
+)
+
+const (
+	maxChatNameLength        = 128
+	maxChatDescriptionLength = 500
 )
 
 type ChatService struct {
@@ -27,6 +40,7 @@ type ChatService struct {
 	userRepo    ports.IUserRepository
 	logger      *slog.Logger
 	wsHub       *websocket.Hub
+	invites     ports.IChatInviteRepository
 	tracer      trace.Tracer
 }
 
@@ -49,35 +63,43 @@ func (s *ChatService) notifyChatCreated(chat *models.Chat, createdBy string) {
 		return
 	}
 
-	notification := map[string]interface{}{
-		"type":       "chat_created",
-		"chat_id":    chat.ID,
-		"chat_name":  chat.Name,
-		"members":    chat.Members,
-		"created_by": createdBy,
-	}
-
 	for _, member := range chat.Members {
 		if member != createdBy {
-			s.wsHub.BroadcastToUser(member, notification)
+			s.wsHub.BroadcastToUser(member, map[string]interface{}{
+				"type":          "chat_created",
+				"chat_id":       chat.ID,
+				"kind":          chat.Kind,
+				"chat_name":     chat.Name,
+				"title":         chat.TitleFor(member),
+				"members":       chat.Members,
+				"display_names": chat.DisplayNames,
+				"created_by":    createdBy,
+			})
 		}
 	}
 
 	s.logger.Info("notified chat members", "chatID", chat.ID, "members", chat.Members)
 }
 
-func (s *ChatService) CreateChat(ctx context.Context, chatName string, memberIDs []string) (int, error) {
+// CreateChat creates a chat owned by createdBy, who is added to the members
+// when missing.
+func (s *ChatService) CreateChat(ctx context.Context, chatName, createdBy string, memberIDs []string) (int, error) {
 	ctx, span := s.tracer.Start(ctx, "ChatService.CreateChat")
 	defer span.End()
 
-	if chatName == "" {
+	if chatName == "" || createdBy == "" {
 		return 0, ErrInvalidInput
 	}
 
+	if !slices.Contains(memberIDs, createdBy) {
+		memberIDs = append([]string{createdBy}, memberIDs...)
+	}
+
 	if len(memberIDs) < 2 {
 		return 0, ErrInsufficientMembers
 	}
 
+	displayNames := make(map[string]string, len(memberIDs))
 	for _, userID := range memberIDs {
 		user, err := s.userRepo.GetUserByName(ctx, userID)
 		if err != nil {
@@ -88,9 +110,10 @@ func (s *ChatService) CreateChat(ctx context.Context, chatName string, memberIDs
 			s.logger.Warn("user not found", "userID", userID)
 			return 0, ErrUserNotFound
 		}
+		displayNames[userID] = user.Name()
 	}
 
-	chatID, err := s.chatRepo.CreateChat(ctx, chatName, memberIDs)
+	chatID, err := s.chatRepo.CreateChat(ctx, chatName, createdBy, memberIDs)
 	if err != nil {
 		s.logger.Error("failed to create chat in repository", "error", err)
 		return 0, err
@@ -98,14 +121,13 @@ func (s *ChatService) CreateChat(ctx context.Context, chatName string, memberIDs
 
 	chat := &models.Chat{
 		ID:        chatID,
+		Kind:      models.ChatKindGroup,
 		Name:      chatName,
 		Members:   memberIDs,
 		CreatedAt: time.Now(),
-	}
+		CreatedBy: createdBy,
 
-	var createdBy string
-	if len(memberIDs) > 0 {
-		createdBy = memberIDs[0]
+		DisplayNames: displayNames,
 	}
 
 	s.notifyChatCreated(chat, createdBy)
@@ -139,28 +161,35 @@ func (s *ChatService) GetUserChats(ctx context.Context, userID string) ([]models
 		return nil, err
 	}
 
+	for i := range *chatPointers {
+		chat := &(*chatPointers)[i]
+		chat.Title = chat.TitleFor(userID)
+	}
+
 	span.SetStatus(codes.Ok, "users chat got successfully")
 	s.logger.Info("retrieved user chats", "userID", userID, "chatCount", len(*chatPointers))
 	return *chatPointers, nil
 }
 
-func (s *ChatService) SendMessage(ctx context.Context, senderID, content string, chatID int) error {
+// SendMessage stores the message and returns it with the sender's display name
+// filled in, ready to be broadcast.
+func (s *ChatService) SendMessage(ctx context.Context, senderID, content string, chatID int) (*models.Message, error) {
 	ctx, span := s.tracer.Start(ctx, "ChatService.SendMessage")
 	defer span.End()
 
 	s.logger.Info("SendMessage called", "chatID", chatID, "senderID", senderID, "content", content)
 	if senderID == "" || content == "" {
-		return ErrInvalidInput
+		return nil, ErrInvalidInput
 	}
 
 	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
 	if err != nil {
 		s.logger.Error("failed to check chat existence", "chatID", chatID, "error", err)
-		return err
+		return nil, err
 	}
 	if chat == nil {
 		s.logger.Warn("chat not found", "chatID", chatID)
-		return ErrChatNotFound
+		return nil, ErrChatNotFound
 	}
 
 	isMember := false
@@ -173,25 +202,48 @@ func (s *ChatService) SendMessage(ctx context.Context, senderID, content string,
 
 	if !isMember {
 		s.logger.Warn("user is not a member of the chat", "userID", senderID, "chatID", chatID)
-		return ErrNotChatMember
+		return nil, ErrNotChatMember
 	}
 
 	err = s.messageRepo.CreateMessage(ctx, senderID, content, chatID)
 	s.logger.Info("chat created successfully", "chatID", chatID, "senderID", senderID)
 	if err != nil {
 		s.logger.Error("failed to send message", "chatID", chatID, "senderID", senderID, "error", err)
-		return err
+		return nil, err
+	}
+
+	senderDisplayName := chat.DisplayNames[senderID]
+	if senderDisplayName == "" {
+		senderDisplayName = senderID
 	}
 
 	span.SetStatus(codes.Ok, "messege sended successfully")
 	s.logger.Info("message sent successfully", "chatID", chatID, "senderID", senderID)
-	return nil
+	return &models.Message{
+		Type:              "message",
+		ChatID:            chatID,
+		Sender:            senderID,
+		SenderDisplayName: senderDisplayName,
+		Content:           content,
+		Timestamp:         time.Now().Format(time.RFC3339),
+	}, nil
 }
 
-func (s *ChatService) GetChatMessages(ctx context.Context, chatID, limit, offset int) ([]models.Message, error) {
+// GetChatMessages returns a page of the chat's history. Only current members
+// may read it, so a removed member loses access together with the membership.
+func (s *ChatService) GetChatMessages(ctx context.Context, chatID int, username string, limit, offset int) ([]models.Message, error) {
 	ctx, span := s.tracer.Start(ctx, "ChatService.GetChatMessages")
 	defer span.End()
 
+	if username == "" {
+		return nil, ErrInvalidInput
+	}
+
+	if _, err := s.memberChat(ctx, chatID, username); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
 	if limit <= 0 {
 		limit = 50
 	}
@@ -218,62 +270,618 @@ func (s *ChatService) DeleteChat(ctx context.Context, chatID int, username strin
 		return ErrInvalidInput
 	}
 
+	chat, err := s.authorize(ctx, chatID, username, models.ChatPermissionDelete)
+	if err != nil {
+		return err
+	}
+
+	err = s.chatRepo.DeleteChat(ctx, chatID)
+	if err != nil {
+		s.logger.Error("failed to delete chat", "chatID", chatID, "error", err)
+		return err
+	}
+
+	err = s.messageRepo.DeleteMessagesByChatID(ctx, chatID)
+	if err != nil {
+		s.logger.Error("failed to delete chat messages", "chatID", chatID, "error", err)
+	}
+
+	s.notifyChatDeleted(chat, username)
+
+	span.SetStatus(codes.Ok, "chat deleted successfully")
+	s.logger.Info("chat deleted successfully", "chatID", chatID, "deletedBy", username)
+	return nil
+}
+
+func (s *ChatService) notifyChatDeleted(chat *models.Chat, deletedBy string) {
+	if s.wsHub == nil {
+		return
+	}
+
+	deletedByDisplayName := chat.DisplayNames[deletedBy]
+	if deletedByDisplayName == "" {
+		deletedByDisplayName = deletedBy
+	}
+
+	notification := map[string]interface{}{
+		"type":                    "chat_deleted",
+		"chat_id":                 chat.ID,
+		"deleted_by":              deletedBy,
+		"deleted_by_display_name": deletedByDisplayName,
+		"deleted_at":              time.Now().Format(time.RFC3339),
+	}
+
+	for _, member := range chat.Members {
+		s.wsHub.BroadcastToUser(member, notification)
+	}
+
+	s.logger.Info("notified chat members about deletion", "chatID", chat.ID, "members", chat.Members)
+}
+
+// memberChat loads the chat and checks that username is one of its members.
+func (s *ChatService) memberChat(ctx context.Context, chatID int, username string) (*models.Chat, error) {
 	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
 	if err != nil {
 		s.logger.Error("failed to check chat existence", "chatID", chatID, "error", err)
-		return err
+		return nil, err
 	}
 	if chat == nil {
 		s.logger.Warn("chat not found", "chatID", chatID)
-		return ErrChatNotFound
+		return nil, ErrChatNotFound
+	}
+
+	if !slices.Contains(chat.Members, username) {
+		s.logger.Warn("user is not a member of the chat", "userID", username, "chatID", chatID)
+		return nil, ErrNotChatMember
+	}
+
+	return chat, nil
+}
+
+// authorize loads the chat and checks that username's role in it grants the
+// permission.
+func (s *ChatService) authorize(ctx context.Context, chatID int, username string, permission models.ChatPermission) (*models.Chat, error) {
+	chat, err := s.memberChat(ctx, chatID, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// Both sides of a direct chat are equals, but its name, roles and members
+	// are fixed.
+	if chat.IsDirect() {
+		if permission == models.ChatPermissionDelete || permission == models.ChatPermissionPin {
+			return chat, nil
+		}
+		return nil, ErrDirectChat
+	}
+
+	role := chat.Role(username)
+	if !role.Can(permission) {
+		s.logger.Warn("chat permission denied", "userID", username, "chatID", chatID, "role", role, "permission", permission)
+		return nil, ErrChatPermission
+	}
+
+	return chat, nil
+}
+
+// SetMemberRole promotes a member to admin or demotes an admin to member.
+// Ownership changes hands only through TransferOwnership.
+func (s *ChatService) SetMemberRole(ctx context.Context, chatID int, actor, target string, role models.ChatRole) error {
+	ctx, span := s.tracer.Start(ctx, "ChatService.SetMemberRole")
+	defer span.End()
+
+	if actor == "" || target == "" {
+		return ErrInvalidInput
+	}
+	if role != models.ChatRoleAdmin && role != models.ChatRoleMember {
+		return ErrInvalidChatRole
+	}
+
+	chat, err := s.authorize(ctx, chatID, actor, models.ChatPermissionManageRoles)
+	if err != nil {
+		return err
+	}
+
+	current := chat.Role(target)
+	if current == "" {
+		return ErrNotChatMember
+	}
+	if current == models.ChatRoleOwner {
+		return ErrInvalidChatRole
+	}
+	if current == role {
+		return nil
+	}
+
+	if err := s.chatRepo.SetMemberRole(ctx, chatID, target, role); err != nil {
+		s.logger.Error("failed to set chat member role", "chatID", chatID, "target", target, "error", err)
+		return err
+	}
+
+	chat.Roles[target] = role
+	s.notifyRoleChanged(chat, actor, target)
+
+	span.SetStatus(codes.Ok, "chat member role changed")
+	s.logger.Info("chat member role changed", "chatID", chatID, "actor", actor, "target", target, "role", role)
+	return nil
+}
+
+// TransferOwnership hands the chat to another member; the previous owner
+// stays on as an admin.
+func (s *ChatService) TransferOwnership(ctx context.Context, chatID int, actor, newOwner string) error {
+	ctx, span := s.tracer.Start(ctx, "ChatService.TransferOwnership")
+	defer span.End()
+
+	if actor == "" || newOwner == "" || actor == newOwner {
+		return ErrInvalidInput
+	}
+
+	chat, err := s.authorize(ctx, chatID, actor, models.ChatPermissionManageRoles)
+	if err != nil {
+		return err
+	}
+	if chat.Role(newOwner) == "" {
+		return ErrNotChatMember
+	}
+
+	if err := s.chatRepo.TransferOwnership(ctx, chatID, newOwner); err != nil {
+		s.logger.Error("failed to transfer chat ownership", "chatID", chatID, "newOwner", newOwner, "error", err)
+		return err
+	}
+
+	chat.Roles[actor] = models.ChatRoleAdmin
+	chat.Roles[newOwner] = models.ChatRoleOwner
+	s.notifyRoleChanged(chat, actor, actor, newOwner)
+
+	span.SetStatus(codes.Ok, "chat ownership transferred")
+	s.logger.Info("chat ownership transferred", "chatID", chatID, "from", actor, "to", newOwner)
+	return nil
+}
+
+func (s *ChatService) notifyRoleChanged(chat *models.Chat, changedBy string, targets ...string) {
+	if s.wsHub == nil {
+		return
+	}
+
+	for _, target := range targets {
+		notification := map[string]interface{}{
+			"type":       "chat_role_changed",
+			"chat_id":    chat.ID,
+			"username":   target,
+			"role":       chat.Roles[target],
+			"changed_by": changedBy,
+		}
+
+		for _, member := range chat.Members {
+			s.wsHub.BroadcastToUser(member, notification)
+		}
+	}
+
+	s.logger.Info("notified chat members about role change", "chatID", chat.ID, "targets", targets)
+}
+
+// OpenDirectChat returns the direct chat between username and other, creating
+// it on first use. created reports whether the chat is new.
+func (s *ChatService) OpenDirectChat(ctx context.Context, username, other string) (chat *models.Chat, created bool, err error) {
+	ctx, span := s.tracer.Start(ctx, "ChatService.OpenDirectChat")
+	defer span.End()
+
+	if username == "" || other == "" || username == other {
+		return nil, false, ErrInvalidInput
+	}
+
+	user, err := s.userRepo.GetUserByName(ctx, other)
+	if err != nil {
+		s.logger.Error("failed to check user existence", "userID", other, "error", err)
+		return nil, false, ErrUserNotFound
+	}
+	if user == nil {
+		s.logger.Warn("user not found", "userID", other)
+		return nil, false, ErrUserNotFound
+	}
+
+	chatID, created, err := s.chatRepo.GetOrCreateDirectChat(ctx, username, other)
+	if err != nil {
+		s.logger.Error("failed to open direct chat", "userID", username, "other", other, "error", err)
+		return nil, false, err
+	}
+
+	chat, err = s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		s.logger.Error("failed to load direct chat", "chatID", chatID, "error", err)
+		return nil, false, err
+	}
+	if chat == nil {
+		return nil, false, ErrChatNotFound
+	}
+	chat.Title = chat.TitleFor(username)
+
+	if created {
+		s.notifyChatCreated(chat, username)
+	}
+
+	span.SetStatus(codes.Ok, "direct chat opened")
+	s.logger.Info("direct chat opened", "chatID", chatID, "userID", username, "other", other, "created", created)
+	return chat, created, nil
+}
+
+// UpdateChat applies a partial update to a group's name, description and
+// avatar. Each change is recorded as a system message and pushed to the members
+// as a chat_updated event.
+func (s *ChatService) UpdateChat(ctx context.Context, chatID int, actor string, update models.ChatUpdate) (*models.Chat, error) {
+	ctx, span := s.tracer.Start(ctx, "ChatService.UpdateChat")
+	defer span.End()
+
+	if actor == "" {
+		return nil, ErrInvalidInput
+	}
+	if err := normalizeChatUpdate(&update); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	chat, err := s.authorize(ctx, chatID, actor, models.ChatPermissionRename)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only fields that actually change are written and announced.
+	var changes []string
+	if update.Name != nil && *update.Name != chat.Name {
+		changes = append(changes, fmt.Sprintf("renamed the chat to %q", *update.Name))
+		chat.Name = *update.Name
+	} else {
+		update.Name = nil
+	}
+	if update.Description != nil && *update.Description != chat.Description {
+		if *update.Description == "" {
+			changes = append(changes, "removed the description")
+		} else {
+			changes = append(changes, "changed the description")
+		}
+		chat.Description = *update.Description
+	} else {
+		update.Description = nil
+	}
+	if update.AvatarURL != nil && *update.AvatarURL != chat.AvatarURL {
+		if *update.AvatarURL == "" {
+			changes = append(changes, "removed the avatar")
+		} else {
+			changes = append(changes, "changed the avatar")
+		}
+		chat.AvatarURL = *update.AvatarURL
+	} else {
+		update.AvatarURL = nil
+	}
+
+	chat.Title = chat.TitleFor(actor)
+	if len(changes) == 0 {
+		return chat, nil
+	}
+
+	if err := s.chatRepo.UpdateChat(ctx, chatID, update); err != nil {
+		s.logger.Error("failed to update chat", "chatID", chatID, "error", err)
+		return nil, err
+	}
+
+	actorName := chat.DisplayNames[actor]
+	if actorName == "" {
+		actorName = actor
+	}
+	note := actorName + " " + strings.Join(changes, ", ")
+	if err := s.messageRepo.CreateSystemMessage(ctx, actor, note, chatID); err != nil {
+		s.logger.Error("failed to record chat update", "chatID", chatID, "error", err)
+	}
+
+	s.notifyChatUpdated(chat, actor, note)
+
+	span.SetStatus(codes.Ok, "chat updated")
+	s.logger.Info("chat updated", "chatID", chatID, "updatedBy", actor, "changes", changes)
+	return chat, nil
+}
+
+func normalizeChatUpdate(update *models.ChatUpdate) error {
+	for _, field := range []*string{update.Name, update.Description, update.AvatarURL} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+
+	if update.Name == nil && update.Description == nil && update.AvatarURL == nil {
+		return fmt.Errorf("%w: nothing to update", ErrInvalidInput)
+	}
+
+	if update.Name != nil {
+		if *update.Name == "" {
+			return fmt.Errorf("%w: chat name must not be empty", ErrInvalidInput)
+		}
+		if utf8.RuneCountInString(*update.Name) > maxChatNameLength {
+			return fmt.Errorf("%w: chat name must be at most %d characters", ErrInvalidInput, maxChatNameLength)
+		}
+	}
+
+	if update.Description != nil && utf8.RuneCountInString(*update.Description) > maxChatDescriptionLength {
+		return fmt.Errorf("%w: description must be at most %d characters", ErrInvalidInput, maxChatDescriptionLength)
+	}
+
+	if update.AvatarURL != nil && *update.AvatarURL != "" {
+		if len(*update.AvatarURL) > maxAvatarURLLength {
+			return fmt.Errorf("%w: avatar url must be at most %d characters", ErrInvalidInput, maxAvatarURLLength)
+		}
+		if !isWebURL(*update.AvatarURL) {
+			return fmt.Errorf("%w: avatar url must be an http(s) url", ErrInvalidInput)
+		}
+	}
+
+	return nil
+}
+
+func (s *ChatService) notifyChatUpdated(chat *models.Chat, updatedBy, note string) {
+	if s.wsHub == nil {
+		return
+	}
+
+	notification := map[string]interface{}{
+		"type":        "chat_updated",
+		"chat_id":     chat.ID,
+		"chat_name":   chat.Name,
+		"title":       chat.Name,
+		"description": chat.Description,
+		"avatar_url":  chat.AvatarURL,
+		"updated_by":  updatedBy,
+		"message":     note,
+		"updated_at":  time.Now().Format(time.RFC3339),
 	}
 
-	isMember := false
 	for _, member := range chat.Members {
-		if member == username {
-			isMember = true
-			break
+		s.wsHub.BroadcastToUser(member, notification)
+	}
+
+	s.logger.Info("notified chat members about update", "chatID", chat.ID, "members", chat.Members)
+}
+
+// IsChatMember reports whether the user belongs to the chat; a missing chat
+// has no members.
+func (s *ChatService) IsChatMember(ctx context.Context, chatID int, username string) (bool, error) {
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
+	if err != nil {
+		return false, err
+	}
+	return chat != nil && slices.Contains(chat.Members, username), nil
+}
+
+// AddMembers adds users to the chat as plain members and returns the ones that
+// were added. Users who already belong to the chat are skipped.
+func (s *ChatService) AddMembers(ctx context.Context, chatID int, actor string, usernames []string) ([]string, error) {
+	ctx, span := s.tracer.Start(ctx, "ChatService.AddMembers")
+	defer span.End()
+
+	if actor == "" || len(usernames) == 0 {
+		return nil, ErrInvalidInput
+	}
+
+	chat, err := s.authorize(ctx, chatID, actor, models.ChatPermissionManageMembers)
+	if err != nil {
+		return nil, err
+	}
+
+	added, err := s.addMembers(ctx, chat, actor, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	span.SetStatus(codes.Ok, "chat members added")
+	return added, nil
+}
+
+// addMembers adds the users to the chat. The caller has checked that addedBy
+// may add members to the chat.
+func (s *ChatService) addMembers(ctx context.Context, chat *models.Chat, addedBy string, usernames []string) ([]string, error) {
+	added, err := s.newMembers(ctx, chat, usernames)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.chatRepo.AddMembers(ctx, chat.ID, added); err != nil {
+		s.logger.Error("failed to add chat members", "chatID", chat.ID, "members", added, "error", err)
+		return nil, err
+	}
+
+	s.membersAdded(chat, addedBy, added)
+	return added, nil
+}
+
+// newMembers checks that the users exist and returns those not yet in the
+// chat. Together with membersAdded it is the membership path shared by manual
+// adds and invite links.
+func (s *ChatService) newMembers(ctx context.Context, chat *models.Chat, usernames []string) ([]string, error) {
+	var added []string
+	for _, username := range usernames {
+		if username == "" {
+			return nil, ErrInvalidInput
+		}
+		if slices.Contains(chat.Members, username) || slices.Contains(added, username) {
+			continue
+		}
+
+		user, err := s.userRepo.GetUserByName(ctx, username)
+		if err != nil {
+			s.logger.Error("failed to check user existence", "userID", username, "error", err)
+			return nil, ErrUserNotFound
+		}
+		if user == nil {
+			s.logger.Warn("user not found", "userID", username)
+			return nil, ErrUserNotFound
+		}
+
+		added = append(added, username)
+		if chat.DisplayNames == nil {
+			chat.DisplayNames = make(map[string]string)
 		}
+		chat.DisplayNames[username] = user.Name()
 	}
 
-	if !isMember {
-		s.logger.Warn("user is not a member of the chat", "userID", username, "chatID", chatID)
+	if len(added) == 0 {
+		return nil, ErrAlreadyChatMember
+	}
+	return added, nil
+}
+
+// membersAdded updates the chat, the hub rooms and the members once the new
+// members are stored.
+func (s *ChatService) membersAdded(chat *models.Chat, addedBy string, added []string) {
+	chat.Members = append(chat.Members, added...)
+	for _, username := range added {
+		chat.Roles[username] = models.ChatRoleMember
+		if s.wsHub != nil {
+			s.wsHub.JoinChatRoom(chat.ID, username)
+		}
+	}
+	s.notifyMembersAdded(chat, addedBy, added)
+
+	s.logger.Info("chat members added", "chatID", chat.ID, "addedBy", addedBy, "members", added)
+}
+
+// RemoveMember takes target out of the chat. Members can only remove those
+// whose role is below their own, so the owner can never be removed.
+func (s *ChatService) RemoveMember(ctx context.Context, chatID int, actor, target string) error {
+	ctx, span := s.tracer.Start(ctx, "ChatService.RemoveMember")
+	defer span.End()
+
+	if actor == "" || target == "" {
+		return ErrInvalidInput
+	}
+	if actor == target {
+		return s.LeaveChat(ctx, chatID, actor)
+	}
+
+	chat, err := s.authorize(ctx, chatID, actor, models.ChatPermissionManageMembers)
+	if err != nil {
+		return err
+	}
+
+	targetRole := chat.Role(target)
+	if targetRole == "" {
 		return ErrNotChatMember
 	}
+	if targetRole.AtLeast(chat.Role(actor)) {
+		s.logger.Warn("chat member outranks the actor", "chatID", chatID, "actor", actor, "target", target)
+		return ErrChatPermission
+	}
 
-	err = s.chatRepo.DeleteChat(ctx, chatID)
+	return s.removeMember(ctx, chat, actor, target)
+}
+
+// LeaveChat removes the user from the chat. An owner who leaves hands the chat
+// to the longest-standing admin or member; the last member to leave deletes it.
+func (s *ChatService) LeaveChat(ctx context.Context, chatID int, username string) error {
+	ctx, span := s.tracer.Start(ctx, "ChatService.LeaveChat")
+	defer span.End()
+
+	if username == "" {
+		return ErrInvalidInput
+	}
+
+	chat, err := s.chatRepo.GetChatByID(ctx, chatID)
 	if err != nil {
-		s.logger.Error("failed to delete chat", "chatID", chatID, "error", err)
+		s.logger.Error("failed to check chat existence", "chatID", chatID, "error", err)
 		return err
 	}
+	if chat == nil {
+		return ErrChatNotFound
+	}
+	if !slices.Contains(chat.Members, username) {
+		return ErrNotChatMember
+	}
+	if chat.IsDirect() {
+		return ErrDirectChat
+	}
 
-	err = s.messageRepo.DeleteMessagesByChatID(ctx, chatID)
+	if len(chat.Members) == 1 {
+		if err := s.chatRepo.DeleteChat(ctx, chatID); err != nil {
+			s.logger.Error("failed to delete chat", "chatID", chatID, "error", err)
+			return err
+		}
+		if err := s.messageRepo.DeleteMessagesByChatID(ctx, chatID); err != nil {
+			s.logger.Error("failed to delete chat messages", "chatID", chatID, "error", err)
+		}
+		if s.wsHub != nil {
+			s.wsHub.LeaveChatRoom(chatID, username)
+		}
+
+		s.logger.Info("last member left, chat deleted", "chatID", chatID, "userID", username)
+		return nil
+	}
+
+	return s.removeMember(ctx, chat, username, username)
+}
+
+func (s *ChatService) removeMember(ctx context.Context, chat *models.Chat, actor, target string) error {
+	newOwner, err := s.chatRepo.RemoveMember(ctx, chat.ID, target)
 	if err != nil {
-		s.logger.Error("failed to delete chat messages", "chatID", chatID, "error", err)
+		s.logger.Error("failed to remove chat member", "chatID", chat.ID, "target", target, "error", err)
+		return err
 	}
 
-	s.notifyChatDeleted(chatID, chat.Members, username)
+	if s.wsHub != nil {
+		s.wsHub.LeaveChatRoom(chat.ID, target)
+	}
 
-	span.SetStatus(codes.Ok, "chat deleted successfully")
-	s.logger.Info("chat deleted successfully", "chatID", chatID, "deletedBy", username)
+	remaining := slices.DeleteFunc(slices.Clone(chat.Members), func(member string) bool { return member == target })
+	s.notifyMemberRemoved(chat, actor, target, remaining)
+
+	chat.Members = remaining
+	delete(chat.Roles, target)
+	if newOwner != "" {
+		chat.Roles[newOwner] = models.ChatRoleOwner
+		s.notifyRoleChanged(chat, target, newOwner)
+	}
+
+	s.logger.Info("chat member removed", "chatID", chat.ID, "removedBy", actor, "target", target, "newOwner", newOwner)
 	return nil
 }
 
-func (s *ChatService) notifyChatDeleted(chatID int, members []string, deletedBy string) {
+func (s *ChatService) notifyMembersAdded(chat *models.Chat, addedBy string, added []string) {
+	if s.wsHub == nil {
+		return
+	}
+
+	notification := map[string]interface{}{
+		"type":          "member_added",
+		"chat_id":       chat.ID,
+		"chat_name":     chat.Name,
+		"members":       chat.Members,
+		"display_names": chat.DisplayNames,
+		"roles":         chat.Roles,
+		"added":         added,
+		"added_by":      addedBy,
+	}
+
+	for _, member := range chat.Members {
+		s.wsHub.BroadcastToUser(member, notification)
+	}
+
+	s.logger.Info("notified chat members about new members", "chatID", chat.ID, "added", added)
+}
+
+// notifyMemberRemoved tells the remaining members and the removed user.
+func (s *ChatService) notifyMemberRemoved(chat *models.Chat, removedBy, removed string, remaining []string) {
 	if s.wsHub == nil {
 		return
 	}
 
 	notification := map[string]interface{}{
-		"type":       "chat_deleted",
-		"chat_id":    chatID,
-		"deleted_by": deletedBy,
-		"deleted_at": time.Now().Format(time.RFC3339),
+		"type":       "member_removed",
+		"chat_id":    chat.ID,
+		"username":   removed,
+		"removed_by": removedBy,
+		"members":    remaining,
 	}
 
-	for _, member := range members {
+	s.wsHub.BroadcastToUser(removed, notification)
+	for _, member := range remaining {
 		s.wsHub.BroadcastToUser(member, notification)
 	}
 
-	s.logger.Info("notified chat members about deletion", "chatID", chatID, "members", members)
+	s.logger.Info("notified chat members about removal", "chatID", chat.ID, "removed", removed)
 }
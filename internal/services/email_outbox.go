@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"massager/app/config"
+	"time"
+)
+
+const (
+	defaultOutboxPollInterval = 5 * time.Second
+	defaultOutboxBatchSize    = 20
+	defaultOutboxMaxAttempts  = 8
+	defaultOutboxRetryBase    = 30 * time.Second
+	defaultOutboxRetryMax     = time.Hour
+	defaultOutboxRetention    = 7 * 24 * time.Hour
+
+	// outboxLease is how long a claimed email stays hidden from other workers.
+	outboxLease = 5 * time.Minute
+	// outboxPurgeInterval is how often finished emails are swept.
+	outboxPurgeInterval = time.Hour
+)
+
+func outboxDefaults(cfg config.EmailOutboxConfig) config.EmailOutboxConfig {
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = defaultOutboxPollInterval
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultOutboxBatchSize
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = defaultOutboxMaxAttempts
+	}
+	if cfg.RetryBase <= 0 {
+		cfg.RetryBase = defaultOutboxRetryBase
+	}
+	if cfg.RetryMax <= 0 {
+		cfg.RetryMax = defaultOutboxRetryMax
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultOutboxRetention
+	}
+	return cfg
+}
+
+// ProcessOutbox tries to deliver one batch of due emails and returns how many
+// were sent. Failed emails are retried with exponential backoff and marked
+// dead after the last attempt.
+func (e *EmailService) ProcessOutbox(ctx context.Context) (int, error) {
+	now := time.Now()
+
+	emails, err := e.outbox.ClaimDueEmails(ctx, now, now.Add(outboxLease), e.delivery.BatchSize)
+	if err != nil {
+		e.logger.Error("failed to claim queued emails", "error", err)
+		return 0, err
+	}
+
+	sent := 0
+	for _, email := range emails {
+		sendErr := e.transport.Send(ctx, email)
+		if sendErr == nil {
+			if err := e.outbox.MarkEmailSent(ctx, email.ID, time.Now()); err != nil {
+				e.logger.Error("failed to mark email as sent", "id", email.ID, "error", err)
+			}
+			sent++
+			e.logger.Info("email sent", "id", email.ID, "email", email.Recipient, "attempts", email.Attempts)
+			continue
+		}
+
+		if email.Attempts >= e.delivery.MaxAttempts {
+			e.logger.Error("giving up on email", "id", email.ID, "email", email.Recipient, "attempts", email.Attempts, "error", sendErr)
+			if err := e.outbox.MarkEmailDead(ctx, email.ID, sendErr.Error()); err != nil {
+				e.logger.Error("failed to mark email as dead", "id", email.ID, "error", err)
+			}
+			continue
+		}
+
+		retryAt := time.Now().Add(e.retryDelay(email.Attempts))
+		e.logger.Warn("email delivery failed, will retry", "id", email.ID, "email", email.Recipient,
+			"attempts", email.Attempts, "retry_at", retryAt, "error", sendErr)
+		if err := e.outbox.RetryEmail(ctx, email.ID, sendErr.Error(), retryAt); err != nil {
+			e.logger.Error("failed to schedule email retry", "id", email.ID, "error", err)
+		}
+	}
+
+	return sent, nil
+}
+
+// PurgeOutbox deletes sent and dead emails older than the retention period
+// and returns how many were deleted.
+func (e *EmailService) PurgeOutbox(ctx context.Context) (int64, error) {
+	purged, err := e.outbox.DeleteFinishedEmails(ctx, time.Now().Add(-e.delivery.Retention))
+	if err != nil {
+		e.logger.Error("failed to purge finished emails", "error", err)
+		return 0, err
+	}
+
+	if purged > 0 {
+		e.logger.Info("finished emails purged", "count", purged)
+	}
+	return purged, nil
+}
+
+// RunOutboxWorker delivers queued emails every poll interval and purges
+// finished ones every hour until ctx is done.
+func (e *EmailService) RunOutboxWorker(ctx context.Context) {
+	ticker := time.NewTicker(e.delivery.PollInterval)
+	defer ticker.Stop()
+	purgeTicker := time.NewTicker(outboxPurgeInterval)
+	defer purgeTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			e.ProcessOutbox(ctx)
+		case <-purgeTicker.C:
+			e.PurgeOutbox(ctx)
+		}
+	}
+}
+
+// retryDelay doubles the base delay after every failed attempt, up to the
+// configured maximum.
+func (e *EmailService) retryDelay(attempts int) time.Duration {
+	delay := e.delivery.RetryBase
+	for i := 1; i < attempts && delay < e.delivery.RetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, e.delivery.RetryMax)
+}
@@ -1,98 +1,145 @@
 package services
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/hex"
 	"fmt"
 	"log/slog"
 	"massager/app/config"
-	"strconv"
-
-	"gopkg.in/gomail.v2"
+	"massager/internal/models"
+	"massager/internal/ports"
+	"massager/internal/services/mailtemplates"
+	"net/url"
+	"strings"
+	"time"
 )
 
+const defaultPublicURL = "http://localhost:8080"
+
+// EmailService renders emails and queues them in the outbox. Delivery happens
+// in RunOutboxWorker, so a mail server outage only delays the emails.
 type EmailService struct {
-	from   string
-	dialer *gomail.Dialer
-	logger *slog.Logger
+	outbox    ports.IEmailOutboxRepository
+	transport ports.MailTransport
+	templates *mailtemplates.Renderer
+	locale    string
+	publicURL string
+	delivery  config.EmailOutboxConfig
+	logger    *slog.Logger
 }
 
-func NewEmailService(config config.EmailConfig, loggger *slog.Logger) *EmailService {
-	var port, _ = strconv.Atoi(config.SMTPort)
-	var dialer = gomail.NewDialer(config.SMTHost, port, config.Username, config.Password)
+// NewEmailService builds links from publicURL, the address users reach the
+// server at.
+func NewEmailService(config config.EmailConfig, publicURL string, outbox ports.IEmailOutboxRepository, transport ports.MailTransport,
+	loggger *slog.Logger) *EmailService {
+	if publicURL == "" {
+		publicURL = defaultPublicURL
+	}
 
 	return &EmailService{
-		logger: loggger,
-		dialer: dialer,
-		from:   config.From,
+		logger:    loggger,
+		outbox:    outbox,
+		transport: transport,
+		templates: mailtemplates.New(config.TemplatesDir, config.Locale),
+		locale:    config.Locale,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+		delivery:  outboxDefaults(config.Outbox),
 	}
 }
 
 func (e *EmailService) SendVerificationEmail(email, token string) error {
-	verificationLink := fmt.Sprintf("http://localhost:8080/api/auth/verify-email?token=%s", token)
-
-	var message = gomail.NewMessage()
-	message.SetHeader("From", e.from)
-	message.SetHeader("To", email)
-	message.SetHeader("Subject", "Verify Your Email Address")
-
-	htmlBody := fmt.Sprintf(`
-		<!DOCTYPE html>
-		<html>
-		<head>
-			<meta charset="UTF-8">
-			<title>Verify Your Email</title>
-		</head>
-		<body>
-			<h2>Email Verification</h2>
-			<p>Hello,</p>
-			<p>Please verify your email address by clicking the button below:</p>
-			<p>
-				<a href="%s" style="
-					background-color: #007bff; 
-					color: white; 
-					padding: 12px 24px; 
-					text-decoration: none; 
-					border-radius: 4px; 
-					display: inline-block;
-				">Verify Email</a>
-			</p>
-			<p>Or copy and paste this link in your browser:</p>
-			<p>%s</p>
-			<p>If you didn't create an account, please ignore this email.</p>
-			<br>
-			<p>Best regards,<br>Your App Team</p>
-		</body>
-		</html>
-	`, verificationLink, verificationLink)
-
-	message.SetBody("text/html", htmlBody)
-
-	textBody := fmt.Sprintf(`
-		Verify Your Email Address
-		
-		Please verify your email address by visiting the following link:
-		%s
-		
-		If you didn't create an account, please ignore this email.
-		
-		Best regards,
-		Your App Team
-	`, verificationLink)
-
-	message.AddAlternative("text/plain", textBody)
-
-	if err := e.dialer.DialAndSend(message); err != nil {
-		e.logger.Error("failed to send verification email", "error", err, "email", email)
-		return fmt.Errorf("failed to send verification email: %w", err)
+	verificationLink := e.link("/api/auth/verify-email", token)
+
+	if err := e.send(email, "verification", map[string]any{"Link": verificationLink}); err != nil {
+		e.logger.Error("failed to queue verification email", "error", err, "email", email)
+		return fmt.Errorf("failed to queue verification email: %w", err)
+	}
+
+	e.logger.Info("verification email queued", "email", email)
+	return nil
+}
+
+func (e *EmailService) SendPasswordResetEmail(email, token string) error {
+	resetLink := e.link("/reset-password", token)
+
+	if err := e.send(email, "password_reset", map[string]any{"Link": resetLink}); err != nil {
+		e.logger.Error("failed to queue password reset email", "error", err, "email", email)
+		return fmt.Errorf("failed to queue password reset email: %w", err)
 	}
 
-	e.logger.Info("sending verification email", "email", email, "link", verificationLink)
-	fmt.Printf("Verification link for %s: %s\n", email, verificationLink)
+	e.logger.Info("password reset email queued", "email", email)
 	return nil
 }
 
-func generateVerificationToken() (string, error) {
+func (e *EmailService) SendAccountLockedEmail(email string, lockedUntil time.Time) error {
+	until := lockedUntil.UTC().Format("2006-01-02 15:04 MST")
+
+	if err := e.send(email, "account_locked", map[string]any{"Until": until}); err != nil {
+		e.logger.Error("failed to queue account locked email", "error", err, "email", email)
+		return fmt.Errorf("failed to queue account locked email: %w", err)
+	}
+
+	e.logger.Info("account locked email queued", "email", email)
+	return nil
+}
+
+func (e *EmailService) SendEmailChangeVerification(email, token string) error {
+	confirmLink := e.link("/api/users/email/confirm", token)
+
+	if err := e.send(email, "email_change_verification", map[string]any{"Link": confirmLink}); err != nil {
+		e.logger.Error("failed to queue email change verification", "error", err, "email", email)
+		return fmt.Errorf("failed to queue email change verification: %w", err)
+	}
+
+	e.logger.Info("email change verification queued", "email", email)
+	return nil
+}
+
+func (e *EmailService) SendEmailChangeNotice(oldEmail, newEmail string) error {
+	if err := e.send(oldEmail, "email_change_notice", map[string]any{"NewEmail": newEmail}); err != nil {
+		e.logger.Error("failed to queue email change notice", "error", err, "email", oldEmail)
+		return fmt.Errorf("failed to queue email change notice: %w", err)
+	}
+
+	e.logger.Info("email change notice queued", "email", oldEmail)
+	return nil
+}
+
+func (e *EmailService) SendAccountDeletionScheduled(email string, deleteAt time.Time) error {
+	at := deleteAt.UTC().Format("2006-01-02 15:04 MST")
+
+	if err := e.send(email, "account_deletion_scheduled", map[string]any{"DeleteAt": at}); err != nil {
+		e.logger.Error("failed to queue account deletion notice", "error", err, "email", email)
+		return fmt.Errorf("failed to queue account deletion notice: %w", err)
+	}
+
+	e.logger.Info("account deletion notice queued", "email", email)
+	return nil
+}
+
+// send renders the named template in the configured locale and queues it.
+func (e *EmailService) send(to, template string, data map[string]any) error {
+	rendered, err := e.templates.Render(template, e.locale, data)
+	if err != nil {
+		return err
+	}
+
+	_, err = e.outbox.EnqueueEmail(context.Background(), models.OutboundEmail{
+		Recipient: to,
+		Subject:   rendered.Subject,
+		HTMLBody:  rendered.HTML,
+		TextBody:  rendered.Text,
+	})
+	return err
+}
+
+// link points at path on the public URL with the token in the query string.
+func (e *EmailService) link(path, token string) string {
+	return e.publicURL + path + "?" + url.Values{"token": {token}}.Encode()
+}
+
+func generateSecureToken() (string, error) {
 	bytes := make([]byte, 32)
 	if _, err := rand.Read(bytes); err != nil {
 		return "", err
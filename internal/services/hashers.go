@@ -1,12 +1,30 @@
 package services
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"massager/app/config"
+	"massager/internal/ports"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var ErrUnknownHashFormat = errors.New("unknown password hash format")
 
 type BcryptHasher struct {
+	Cost int
 }
 
 func (b *BcryptHasher) DefaultCost() int {
-	return bcrypt.DefaultCost
+	if b.Cost == 0 {
+		return bcrypt.DefaultCost
+	}
+	return b.Cost
 }
 
 func (b *BcryptHasher) GenerateFromPassword(password []byte, cost int) ([]byte, error) {
@@ -22,3 +40,174 @@ func (b *BcryptHasher) CompareHashAndPassword(storedPaswsord []byte, userPasswor
 
 	return nil
 }
+
+func (b *BcryptHasher) NeedsRehash(storedPassword []byte) bool {
+	cost, err := bcrypt.Cost(storedPassword)
+	return err != nil || cost != b.DefaultCost()
+}
+
+// Argon2idHasher stores hashes in the PHC string format:
+// $argon2id$v=19$m=<memory KiB>,t=<iterations>,p=<parallelism>$<salt>$<hash>
+type Argon2idHasher struct {
+	params config.Argon2Config
+}
+
+func NewArgon2idHasher(params config.Argon2Config) *Argon2idHasher {
+	if params.Memory == 0 {
+		params.Memory = 64 * 1024
+	}
+	if params.Iterations == 0 {
+		params.Iterations = 3
+	}
+	if params.Parallelism == 0 {
+		params.Parallelism = 2
+	}
+	if params.SaltLength == 0 {
+		params.SaltLength = 16
+	}
+	if params.KeyLength == 0 {
+		params.KeyLength = 32
+	}
+	return &Argon2idHasher{params: params}
+}
+
+// DefaultCost returns the configured number of iterations.
+func (a *Argon2idHasher) DefaultCost() int {
+	return int(a.params.Iterations)
+}
+
+// GenerateFromPassword uses cost as the number of iterations; the remaining
+// parameters come from the config.
+func (a *Argon2idHasher) GenerateFromPassword(password []byte, cost int) ([]byte, error) {
+	params := a.params
+	if cost > 0 {
+		params.Iterations = uint32(cost)
+	}
+
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	hash := argon2.IDKey(password, salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+
+	encoded := fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
+	return []byte(encoded), nil
+}
+
+func (a *Argon2idHasher) CompareHashAndPassword(storedPaswsord []byte, userPassword []byte) error {
+	params, salt, hash, err := decodeArgon2id(string(storedPaswsord))
+	if err != nil {
+		return err
+	}
+
+	candidate := argon2.IDKey(userPassword, salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(hash)))
+	if subtle.ConstantTimeCompare(hash, candidate) != 1 {
+		return bcrypt.ErrMismatchedHashAndPassword
+	}
+	return nil
+}
+
+func (a *Argon2idHasher) NeedsRehash(storedPassword []byte) bool {
+	params, salt, hash, err := decodeArgon2id(string(storedPassword))
+	if err != nil {
+		return true
+	}
+
+	return params.Memory != a.params.Memory ||
+		params.Iterations != a.params.Iterations ||
+		params.Parallelism != a.params.Parallelism ||
+		uint32(len(salt)) != a.params.SaltLength ||
+		uint32(len(hash)) != a.params.KeyLength
+}
+
+func decodeArgon2id(encoded string) (config.Argon2Config, []byte, []byte, error) {
+	var params config.Argon2Config
+
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	hash, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(hash) == 0 {
+		return params, nil, nil, ErrUnknownHashFormat
+	}
+
+	return params, salt, hash, nil
+}
+
+// CompositeHasher hashes new passwords with the configured algorithm and
+// verifies every format it knows, so bcrypt and Argon2id hashes can coexist
+// while users are migrated on their next login.
+type CompositeHasher struct {
+	current ports.IHasher
+	bcrypt  *BcryptHasher
+	argon2  *Argon2idHasher
+}
+
+func NewPasswordHasher(cfg config.PasswordHashingConfig) *CompositeHasher {
+	hasher := &CompositeHasher{
+		bcrypt: &BcryptHasher{Cost: cfg.BcryptCost},
+		argon2: NewArgon2idHasher(cfg.Argon2),
+	}
+
+	hasher.current = hasher.argon2
+	if cfg.Algorithm == "bcrypt" {
+		hasher.current = hasher.bcrypt
+	}
+	return hasher
+}
+
+func (h *CompositeHasher) DefaultCost() int {
+	return h.current.DefaultCost()
+}
+
+func (h *CompositeHasher) GenerateFromPassword(password []byte, cost int) ([]byte, error) {
+	return h.current.GenerateFromPassword(password, cost)
+}
+
+func (h *CompositeHasher) CompareHashAndPassword(storedPaswsord []byte, userPassword []byte) error {
+	hasher := h.hasherFor(storedPaswsord)
+	if hasher == nil {
+		return ErrUnknownHashFormat
+	}
+	return hasher.CompareHashAndPassword(storedPaswsord, userPassword)
+}
+
+// NeedsRehash reports hashes made with another algorithm or outdated parameters.
+func (h *CompositeHasher) NeedsRehash(storedPassword []byte) bool {
+	if h.hasherFor(storedPassword) != h.current {
+		return true
+	}
+	return h.current.NeedsRehash(storedPassword)
+}
+
+func (h *CompositeHasher) hasherFor(storedPassword []byte) ports.IHasher {
+	switch {
+	case strings.HasPrefix(string(storedPassword), "$argon2id$"):
+		return h.argon2
+	case strings.HasPrefix(string(storedPassword), "$2a$"),
+		strings.HasPrefix(string(storedPassword), "$2b$"),
+		strings.HasPrefix(string(storedPassword), "$2y$"):
+		return h.bcrypt
+	default:
+		return nil
+	}
+}
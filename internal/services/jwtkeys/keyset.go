@@ -0,0 +1,211 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"massager/app/config"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrUnexpectedMethod = errors.New("unexpected signing method")
+)
+
+type key struct {
+	id         string
+	method     jwt.SigningMethod
+	signingKey interface{} // nil for verification-only keys
+	verifyKey  interface{}
+}
+
+// KeySet signs tokens with one key and verifies them with any key it holds, so
+// a new signing key can be rolled out while tokens of the previous one are live.
+type KeySet struct {
+	signing *key
+	keys    map[string]*key
+	hmac    *key
+}
+
+// NewHMACKeySet signs and verifies HS256 tokens without a "kid" header.
+func NewHMACKeySet(secret []byte) *KeySet {
+	hmacKey := &key{method: jwt.SigningMethodHS256, signingKey: secret, verifyKey: secret}
+	return &KeySet{signing: hmacKey, keys: map[string]*key{}, hmac: hmacKey}
+}
+
+// Load builds the key set described by the config. Without configured keys it
+// falls back to HS256 with the shared secret.
+func Load(cfg config.JWTConfig) (*KeySet, error) {
+	if len(cfg.Keys) == 0 {
+		return NewHMACKeySet([]byte(cfg.SecretKey)), nil
+	}
+
+	ks := &KeySet{keys: make(map[string]*key, len(cfg.Keys))}
+	for _, keyConfig := range cfg.Keys {
+		k, err := loadKey(keyConfig)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", keyConfig.ID, err)
+		}
+		if _, exists := ks.keys[k.id]; exists {
+			return nil, fmt.Errorf("jwt key %q: duplicate id", k.id)
+		}
+		ks.keys[k.id] = k
+	}
+
+	signingKeyID := cfg.SigningKeyID
+	if signingKeyID == "" {
+		signingKeyID = cfg.Keys[0].ID
+	}
+
+	signing, ok := ks.keys[signingKeyID]
+	if !ok {
+		return nil, fmt.Errorf("jwt signing key %q: %w", signingKeyID, ErrUnknownKey)
+	}
+	if signing.signingKey == nil {
+		return nil, fmt.Errorf("jwt signing key %q: private key required", signingKeyID)
+	}
+	ks.signing = signing
+
+	if cfg.AllowHMAC && cfg.SecretKey != "" {
+		ks.hmac = &key{method: jwt.SigningMethodHS256, verifyKey: []byte(cfg.SecretKey)}
+	}
+
+	return ks, nil
+}
+
+func loadKey(cfg config.JWTKeyConfig) (*key, error) {
+	if cfg.ID == "" {
+		return nil, errors.New("id is required")
+	}
+
+	k := &key{id: cfg.ID}
+
+	switch cfg.Algorithm {
+	case "RS256":
+		k.method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			k.signingKey, k.verifyKey = privateKey, &privateKey.PublicKey
+		} else if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if k.verifyKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		}
+	case "EdDSA":
+		k.method = jwt.SigningMethodEdDSA
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			privateKey, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			k.signingKey, k.verifyKey = privateKey, privateKey.(crypto.Signer).Public()
+		} else if cfg.PublicKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			if k.verifyKey, err = jwt.ParseEdPublicKeyFromPEM(pem); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", cfg.Algorithm)
+	}
+
+	if k.verifyKey == nil {
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+	return k, nil
+}
+
+// Sign signs the claims with the current signing key and names it in the "kid" header.
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.signing.method, claims)
+	if ks.signing.id != "" {
+		token.Header["kid"] = ks.signing.id
+	}
+	return token.SignedString(ks.signing.signingKey)
+}
+
+// Keyfunc picks the verification key by "kid" for jwt.Parse. The algorithm in
+// the token header has to match the key, so a public key can never be used as
+// an HMAC secret.
+func (ks *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	k := ks.hmac
+	if kid, ok := token.Header["kid"].(string); ok {
+		k = ks.keys[kid]
+	}
+
+	if k == nil {
+		return nil, ErrUnknownKey
+	}
+	if token.Method.Alg() != k.method.Alg() {
+		return nil, ErrUnexpectedMethod
+	}
+	return k.verifyKey, nil
+}
+
+// JWK is a public key in RFC 7517 format.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public verification keys. HMAC secrets are never published.
+func (ks *KeySet) JWKS() JWKS {
+	jwks := JWKS{Keys: []JWK{}}
+	for _, k := range ks.keys {
+		jwk := JWK{Kid: k.id, Use: "sig", Alg: k.method.Alg()}
+
+		switch publicKey := k.verifyKey.(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(publicKey.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(publicKey.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(publicKey)
+		default:
+			continue
+		}
+
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	sort.Slice(jwks.Keys, func(i, j int) bool { return jwks.Keys[i].Kid < jwks.Keys[j].Kid })
+	return jwks
+}
@@ -0,0 +1,154 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"massager/app/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600))
+	return path
+}
+
+func rsaKeyFiles(t *testing.T) (string, string) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	publicDER, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+
+	return writePEM(t, "rsa.pem", "PRIVATE KEY", privateDER), writePEM(t, "rsa.pub.pem", "PUBLIC KEY", publicDER)
+}
+
+func edKeyFile(t *testing.T) string {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	return writePEM(t, "ed.pem", "PRIVATE KEY", privateDER)
+}
+
+func testClaims() jwt.MapClaims {
+	return jwt.MapClaims{"username": "validuser", "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func TestRotationKeepsOldTokensValid(t *testing.T) {
+	rsaPrivate, rsaPublic := rsaKeyFiles(t)
+	edPrivate := edKeyFile(t)
+
+	before, err := Load(config.JWTConfig{
+		SigningKeyID: "old",
+		Keys:         []config.JWTKeyConfig{{ID: "old", Algorithm: "RS256", PrivateKeyFile: rsaPrivate}},
+	})
+	require.NoError(t, err)
+
+	oldToken, err := before.Sign(testClaims())
+	require.NoError(t, err)
+
+	after, err := Load(config.JWTConfig{
+		SigningKeyID: "new",
+		Keys: []config.JWTKeyConfig{
+			{ID: "new", Algorithm: "EdDSA", PrivateKeyFile: edPrivate},
+			{ID: "old", Algorithm: "RS256", PublicKeyFile: rsaPublic},
+		},
+	})
+	require.NoError(t, err)
+
+	newToken, err := after.Sign(testClaims())
+	require.NoError(t, err)
+
+	for _, tokenString := range []string{oldToken, newToken} {
+		token, err := jwt.Parse(tokenString, after.Keyfunc)
+		assert.NoError(t, err)
+		assert.True(t, token.Valid)
+	}
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(newToken, jwt.MapClaims{})
+	require.NoError(t, err)
+	assert.Equal(t, "new", parsed.Header["kid"])
+	assert.Equal(t, "EdDSA", parsed.Method.Alg())
+
+	jwks := after.JWKS()
+	require.Len(t, jwks.Keys, 2)
+	assert.Equal(t, "OKP", jwks.Keys[0].Kty)
+	assert.Equal(t, "RSA", jwks.Keys[1].Kty)
+	assert.NotEmpty(t, jwks.Keys[1].N)
+	assert.Equal(t, "AQAB", jwks.Keys[1].E)
+}
+
+func TestKeyfuncRejectsUnexpectedTokens(t *testing.T) {
+	rsaPrivate, _ := rsaKeyFiles(t)
+
+	ks, err := Load(config.JWTConfig{
+		SecretKey: "secret",
+		Keys:      []config.JWTKeyConfig{{ID: "main", Algorithm: "RS256", PrivateKeyFile: rsaPrivate}},
+	})
+	require.NoError(t, err)
+
+	hmacToken, err := NewHMACKeySet([]byte("secret")).Sign(testClaims())
+	require.NoError(t, err)
+
+	unknownKid := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	unknownKid.Header["kid"] = "missing"
+	unknownKidToken, err := unknownKid.SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	// HS256 with kid "main" would be verified with the RSA key if the algorithm
+	// were not pinned to the key.
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	confused.Header["kid"] = "main"
+	confusedToken, err := confused.SignedString([]byte("secret"))
+	require.NoError(t, err)
+
+	for name, tokenString := range map[string]string{
+		"hmac not allowed":   hmacToken,
+		"unknown kid":        unknownKidToken,
+		"algorithm mismatch": confusedToken,
+	} {
+		_, err := jwt.Parse(tokenString, ks.Keyfunc)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestAllowHMACDuringMigration(t *testing.T) {
+	rsaPrivate, _ := rsaKeyFiles(t)
+
+	ks, err := Load(config.JWTConfig{
+		SecretKey: "secret",
+		AllowHMAC: true,
+		Keys:      []config.JWTKeyConfig{{ID: "main", Algorithm: "RS256", PrivateKeyFile: rsaPrivate}},
+	})
+	require.NoError(t, err)
+
+	hmacToken, err := NewHMACKeySet([]byte("secret")).Sign(testClaims())
+	require.NoError(t, err)
+
+	_, err = jwt.Parse(hmacToken, ks.Keyfunc)
+	assert.NoError(t, err)
+	assert.Len(t, ks.JWKS().Keys, 1, "the HMAC secret must not be published")
+}
+
+func TestLoadRejectsVerificationOnlySigningKey(t *testing.T) {
+	_, rsaPublic := rsaKeyFiles(t)
+
+	_, err := Load(config.JWTConfig{
+		Keys: []config.JWTKeyConfig{{ID: "main", Algorithm: "RS256", PublicKeyFile: rsaPublic}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,245 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"massager/app/config"
+	"massager/internal/models"
+	"massager/internal/ports"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrTooManyAttempts = errors.New("too many failed login attempts, try again later")
+	ErrAccountLocked   = errors.New("account is temporarily locked after too many failed login attempts")
+)
+
+// ThrottleError reports a rejected attempt together with the time the caller
+// has to wait before trying again.
+type ThrottleError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *ThrottleError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ThrottleError) Unwrap() error {
+	return e.Err
+}
+
+// LoginGuard slows down password guessing. Failed logins are counted per username
+// and per IP address; past a threshold every further failure doubles the wait
+// before the next attempt, and too many failures for one username lock the account.
+type LoginGuard struct {
+	attempts ports.AttemptRepository
+	config   config.LoginProtectionConfig
+	logger   *slog.Logger
+}
+
+func NewLoginGuard(attempts ports.AttemptRepository, config config.LoginProtectionConfig, logger *slog.Logger) *LoginGuard {
+	if config.FailureWindow <= 0 {
+		config.FailureWindow = 15 * time.Minute
+	}
+	if config.BackoffBase <= 0 {
+		config.BackoffBase = time.Second
+	}
+	if config.BackoffMax <= 0 {
+		config.BackoffMax = 5 * time.Minute
+	}
+	if config.LockoutDuration <= 0 {
+		config.LockoutDuration = 15 * time.Minute
+	}
+
+	return &LoginGuard{attempts: attempts, config: config, logger: logger}
+}
+
+// Check returns a *ThrottleError if a login for username from ip must be rejected
+// without looking at the password.
+func (g *LoginGuard) Check(ctx context.Context, username, ip string) error {
+	lockedUntil, err := g.attempts.BlockedUntil(ctx, lockKey(username))
+	if err != nil {
+		return err
+	}
+	if wait := time.Until(lockedUntil); wait > 0 {
+		return &ThrottleError{Err: ErrAccountLocked, RetryAfter: wait}
+	}
+
+	for _, key := range g.backoffKeys(username, ip) {
+		blockedUntil, err := g.attempts.BlockedUntil(ctx, key)
+		if err != nil {
+			return err
+		}
+		if wait := time.Until(blockedUntil); wait > 0 {
+			return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: wait}
+		}
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login. When it locks the account, the end of the
+// lockout is returned; otherwise the returned time is zero.
+func (g *LoginGuard) RecordFailure(ctx context.Context, username, ip string) (time.Time, error) {
+	now := time.Now()
+
+	failures, err := g.attempts.Increment(ctx, failuresKey(username), g.config.FailureWindow)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	// The IP is counted first so the attempt that locks the account counts too.
+	if ip != "" {
+		ipFailures, err := g.attempts.Increment(ctx, ipFailuresKey(ip), g.config.FailureWindow)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if err := g.backoff(ctx, ipBackoffKey(ip), ipFailures, g.config.IPBackoffAfter); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if g.config.LockoutAfter > 0 && failures >= int64(g.config.LockoutAfter) {
+		lockedUntil := now.Add(g.config.LockoutDuration)
+		if err := g.attempts.Block(ctx, lockKey(username), lockedUntil); err != nil {
+			return time.Time{}, err
+		}
+		if err := g.attempts.Reset(ctx, failuresKey(username)); err != nil {
+			return time.Time{}, err
+		}
+
+		g.logger.Warn("account locked after failed logins", "username", username, "until", lockedUntil)
+		return lockedUntil, nil
+	}
+
+	if err := g.backoff(ctx, userBackoffKey(username), failures, g.config.BackoffAfter); err != nil {
+		return time.Time{}, err
+	}
+
+	return time.Time{}, nil
+}
+
+// RecordSuccess clears the username's failures. The IP counter is left alone so
+// a valid account cannot be used to reset it.
+func (g *LoginGuard) RecordSuccess(ctx context.Context, username string) error {
+	if err := g.attempts.Reset(ctx, failuresKey(username)); err != nil {
+		return err
+	}
+	return g.attempts.Unblock(ctx, userBackoffKey(username))
+}
+
+// Unlock lifts a lockout and the backoff for username.
+func (g *LoginGuard) Unlock(ctx context.Context, username string) error {
+	if err := g.attempts.Unblock(ctx, lockKey(username)); err != nil {
+		return err
+	}
+	return g.RecordSuccess(ctx, username)
+}
+
+func (g *LoginGuard) backoff(ctx context.Context, key string, failures int64, threshold int) error {
+	if threshold <= 0 || failures < int64(threshold) {
+		return nil
+	}
+
+	delay := g.config.BackoffBase
+	for i := int64(threshold); i < failures && delay < g.config.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > g.config.BackoffMax {
+		delay = g.config.BackoffMax
+	}
+
+	return g.attempts.Block(ctx, key, time.Now().Add(delay))
+}
+
+func (g *LoginGuard) backoffKeys(username, ip string) []string {
+	keys := []string{userBackoffKey(username)}
+	if ip != "" {
+		keys = append(keys, ipBackoffKey(ip))
+	}
+	return keys
+}
+
+func failuresKey(username string) string    { return "login:user:" + username }
+func ipFailuresKey(ip string) string        { return "login:ip:" + ip }
+func lockKey(username string) string        { return "login_lock:user:" + username }
+func userBackoffKey(username string) string { return "login_backoff:user:" + username }
+func ipBackoffKey(ip string) string         { return "login_backoff:ip:" + ip }
+
+func (s *AuthService) SetLoginGuard(loginGuard *LoginGuard) {
+	s.loginGuard = loginGuard
+}
+
+// checkLoginAllowed returns a *ThrottleError while the username or IP address is
+// backing off or locked.
+func (s *AuthService) checkLoginAllowed(ctx context.Context, username, ip string) error {
+	if s.loginGuard == nil {
+		return nil
+	}
+
+	err := s.loginGuard.Check(ctx, username, ip)
+	var throttleErr *ThrottleError
+	if err != nil && !errors.As(err, &throttleErr) {
+		s.logger.Error("login attempt check failed", "username", username, "error", err)
+		return errors.New("authentication failed")
+	}
+	return err
+}
+
+func (s *AuthService) recordLoginFailure(ctx context.Context, username, ip string, user *models.User) {
+	if s.loginGuard == nil {
+		return
+	}
+
+	lockedUntil, err := s.loginGuard.RecordFailure(ctx, username, ip)
+	if err != nil {
+		s.logger.Error("failed to record login failure", "username", username, "error", err)
+		return
+	}
+
+	if !lockedUntil.IsZero() && user != nil {
+		if err := s.emailService.SendAccountLockedEmail(user.Email, lockedUntil); err != nil {
+			s.logger.Warn("failed to send account locked email", "username", username, "error", err)
+		}
+	}
+}
+
+func (s *AuthService) recordLoginSuccess(ctx context.Context, username string) {
+	if s.loginGuard == nil {
+		return
+	}
+
+	if err := s.loginGuard.RecordSuccess(ctx, username); err != nil {
+		s.logger.Warn("failed to reset login failures", "username", username, "error", err)
+	}
+}
+
+// UnlockUser lifts a lockout caused by failed logins.
+func (s *AuthService) UnlockUser(ctx context.Context, username string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.UnlockUser")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if _, err := s.getExistingUser(ctx, username); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if s.loginGuard != nil {
+		if err := s.loginGuard.Unlock(ctx, username); err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to unlock user", "username", username, "error", err)
+			return errors.New("failed to unlock user")
+		}
+	}
+
+	span.SetStatus(codes.Ok, "user unlocked")
+	s.logger.Info("user unlocked", "username", username)
+	return nil
+}
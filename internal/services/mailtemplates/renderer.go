@@ -0,0 +1,150 @@
+package mailtemplates
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var embedded embed.FS
+
+var ErrTemplateNotFound = errors.New("email template not found")
+
+// Message is a rendered email.
+type Message struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// Renderer renders an email from <name>.html and <name>.txt, each wrapped in
+// the matching layout. The text template also defines the "subject" block.
+//
+// Every file is looked up on its own, first for the most specific locale
+// ("pt-BR", then "pt", then the default locale) and finally without one. At
+// each step the override directory wins over the built-in templates, so a
+// deployment can replace a single file for a single language.
+type Renderer struct {
+	sources       []fs.FS
+	defaultLocale string
+}
+
+// New uses the built-in templates, overridden by those in dir when it is set.
+func New(dir, defaultLocale string) *Renderer {
+	builtin, _ := fs.Sub(embedded, "templates")
+
+	sources := []fs.FS{builtin}
+	if dir != "" {
+		sources = []fs.FS{os.DirFS(dir), builtin}
+	}
+	return &Renderer{sources: sources, defaultLocale: defaultLocale}
+}
+
+func (r *Renderer) Render(name, locale string, data any) (*Message, error) {
+	locales := r.locales(locale)
+
+	textLayout, err := r.read("layout.txt", locales)
+	if err != nil {
+		return nil, err
+	}
+	textBody, err := r.read(name+".txt", locales)
+	if err != nil {
+		return nil, err
+	}
+	htmlLayout, err := r.read("layout.html", locales)
+	if err != nil {
+		return nil, err
+	}
+	htmlBody, err := r.read(name+".html", locales)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := texttemplate.New("layout.txt").Parse(textLayout)
+	if err == nil {
+		_, err = text.Parse(textBody)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("email template %s.txt: %w", name, err)
+	}
+
+	html, err := htmltemplate.New("layout.html").Funcs(htmltemplate.FuncMap{"button": button}).Parse(htmlLayout)
+	if err == nil {
+		_, err = html.Parse(htmlBody)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("email template %s.html: %w", name, err)
+	}
+
+	var subject, textOut, htmlOut bytes.Buffer
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return nil, fmt.Errorf("email template %s subject: %w", name, err)
+	}
+	if err := text.Execute(&textOut, data); err != nil {
+		return nil, fmt.Errorf("email template %s.txt: %w", name, err)
+	}
+	if err := html.Execute(&htmlOut, data); err != nil {
+		return nil, fmt.Errorf("email template %s.html: %w", name, err)
+	}
+
+	return &Message{
+		Subject: strings.TrimSpace(subject.String()),
+		HTML:    htmlOut.String(),
+		Text:    strings.TrimSpace(textOut.String()) + "\n",
+	}, nil
+}
+
+// locales lists the directories to try, most specific first. The empty string
+// stands for the unlocalized templates.
+func (r *Renderer) locales(locale string) []string {
+	var locales []string
+	add := func(candidate string) {
+		for _, existing := range locales {
+			if existing == candidate {
+				return
+			}
+		}
+		locales = append(locales, candidate)
+	}
+
+	for _, candidate := range []string{locale, r.defaultLocale} {
+		if candidate == "" {
+			continue
+		}
+		add(candidate)
+		if language, _, found := strings.Cut(candidate, "-"); found {
+			add(language)
+		}
+	}
+	add("")
+	return locales
+}
+
+func (r *Renderer) read(file string, locales []string) (string, error) {
+	for _, locale := range locales {
+		for _, source := range r.sources {
+			content, err := fs.ReadFile(source, path.Join(locale, file))
+			if err == nil {
+				return string(content), nil
+			}
+			if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrTemplateNotFound, file)
+}
+
+// button lets the HTML templates pass a link and its label to the shared
+// "button" block.
+func button(link, label string) map[string]string {
+	return map[string]string{"Link": link, "Label": label}
+}
@@ -0,0 +1,83 @@
+package mailtemplates
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender_BuiltinTemplates(t *testing.T) {
+	renderer := New("", "en")
+
+	for _, name := range []string{
+		"verification",
+		"password_reset",
+		"account_locked",
+		"email_change_verification",
+		"email_change_notice",
+		"account_deletion_scheduled",
+	} {
+		t.Run(name, func(t *testing.T) {
+			message, err := renderer.Render(name, "", map[string]any{
+				"Link":     "https://chat.example.com/confirm?token=abc",
+				"Until":    "2025-01-01 10:00 UTC",
+				"NewEmail": "new@example.com",
+				"DeleteAt": "2025-01-08 10:00 UTC",
+			})
+			require.NoError(t, err)
+
+			assert.NotEmpty(t, message.Subject)
+			assert.Contains(t, message.HTML, "<!DOCTYPE html>")
+			assert.Contains(t, message.Text, "Your App Team")
+			assert.NotContains(t, message.Text, "<no value>")
+		})
+	}
+}
+
+func TestRender_LinkAndEscaping(t *testing.T) {
+	message, err := New("", "en").Render("verification", "", map[string]any{
+		"Link": `https://chat.example.com/api/auth/verify-email?token=a&b="<x>`,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Verify Your Email Address", message.Subject)
+	assert.Contains(t, message.Text, `https://chat.example.com/api/auth/verify-email?token=a&b="<x>`)
+	assert.NotContains(t, message.HTML, `"<x>`)
+}
+
+func TestRender_LocaleOverrides(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "pt"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "pt", "verification.txt"),
+		[]byte(`{{define "subject"}}Verifique seu e-mail{{end}}{{define "content"}}Abra {{.Link}}{{end}}`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "layout.txt"),
+		[]byte(`{{template "content" .}} -- Horizon`), 0o600))
+
+	renderer := New(dir, "en")
+
+	t.Run("Language of a regional locale", func(t *testing.T) {
+		message, err := renderer.Render("verification", "pt-BR", map[string]any{"Link": "https://x"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "Verifique seu e-mail", message.Subject)
+		assert.Equal(t, "Abra https://x -- Horizon\n", message.Text)
+		// Files without a localized override fall back to the built-in ones.
+		assert.Contains(t, message.HTML, "Verify Email")
+	})
+
+	t.Run("Unknown locale", func(t *testing.T) {
+		message, err := renderer.Render("verification", "de", map[string]any{"Link": "https://x"})
+		require.NoError(t, err)
+
+		assert.Equal(t, "Verify Your Email Address", message.Subject)
+		assert.Contains(t, message.Text, "-- Horizon")
+	})
+}
+
+func TestRender_UnknownTemplate(t *testing.T) {
+	_, err := New("", "en").Render("missing", "", nil)
+	assert.ErrorIs(t, err, ErrTemplateNotFound)
+}
@@ -0,0 +1,335 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"massager/internal/models"
+	"massager/internal/services/totp"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrInvalidMFAToken   = errors.New("invalid or expired mfa token")
+	ErrInvalidMFACode    = errors.New("invalid authentication code")
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnabled     = errors.New("two-factor authentication not enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication enrollment not started")
+)
+
+const (
+	accessTokenType = "access"
+	mfaTokenType    = "mfa_pending"
+	mfaTokenTTL     = 5 * time.Minute
+
+	totpIssuer        = "Horizon Messenger"
+	recoveryCodeCount = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CompleteMFALogin exchanges the MFA token returned by Login and a TOTP or
+// recovery code for a token pair. Each MFA token can be exchanged only once.
+func (s *AuthService) CompleteMFALogin(ctx context.Context, mfaToken, code string, client models.ClientInfo) (*models.TokenPair, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.CompleteMFALogin")
+	defer span.End()
+
+	claims, err := s.parseSignedToken(mfaToken)
+	if err != nil || claims["typ"] != mfaTokenType {
+		span.RecordError(ErrInvalidMFAToken)
+		return nil, ErrInvalidMFAToken
+	}
+
+	tokenHash := hashToken(mfaToken)
+	isRevoked, err := s.tokenRepo.IsRevoked(ctx, tokenHash)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("mfa token revocation check failed", "error", err)
+		return nil, err
+	}
+	if isRevoked {
+		span.RecordError(ErrInvalidMFAToken)
+		return nil, ErrInvalidMFAToken
+	}
+
+	username := claims["username"].(string)
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if err := s.checkLoginAllowed(ctx, username, client.IPAddress); err != nil {
+		span.RecordError(err)
+		s.logger.Warn("mfa login attempt rejected", "username", username, "error", err)
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to get user", "username", username, "error", err)
+		return nil, errors.New("authentication failed")
+	}
+	if user == nil || !user.TOTPEnabled {
+		span.RecordError(ErrInvalidMFAToken)
+		return nil, ErrInvalidMFAToken
+	}
+
+	ok, err := s.verifySecondFactor(ctx, user, code)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("second factor check failed", "username", username, "error", err)
+		return nil, errors.New("authentication failed")
+	}
+	if !ok {
+		span.RecordError(ErrInvalidMFACode)
+		s.logger.Warn("invalid second factor", "username", username)
+		s.recordLoginFailure(ctx, username, client.IPAddress, user)
+		return nil, ErrInvalidMFACode
+	}
+
+	if user.IsBanned() {
+		span.RecordError(ErrAccountBanned)
+		s.logger.Warn("mfa login attempt of banned user", "username", username)
+		return nil, ErrAccountBanned
+	}
+
+	exp, _ := claims["exp"].(float64)
+	if err := s.tokenRepo.Revoke(ctx, tokenHash, time.Until(time.UnixMilli(int64(math.Round(exp*1000))))); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke mfa token", "username", username, "error", err)
+		return nil, errors.New("authentication failed")
+	}
+
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("token generation failed", "error", err)
+		return nil, errors.New("authentication failed")
+	}
+
+	s.recordLoginSuccess(ctx, user.Username)
+
+	span.SetStatus(codes.Ok, "login successful")
+	s.logger.Info("login successful", "username", username, "mfa", true)
+	return tokens, nil
+}
+
+// EnrollTOTP stores a fresh secret for the user and returns it together with an
+// otpauth URI for authenticator apps. The secret is inactive until ConfirmTOTP.
+func (s *AuthService) EnrollTOTP(ctx context.Context, username string) (string, string, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.EnrollTOTP")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	user, err := s.getExistingUser(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		return "", "", err
+	}
+	if user.TOTPEnabled {
+		span.RecordError(ErrMFAAlreadyEnabled)
+		return "", "", ErrMFAAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to generate totp secret", "error", err)
+		return "", "", errors.New("enrollment failed")
+	}
+
+	if err := s.userRepo.SetTOTPSecret(ctx, username, secret); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to store totp secret", "username", username, "error", err)
+		return "", "", errors.New("enrollment failed")
+	}
+
+	span.SetStatus(codes.Ok, "totp enrollment started")
+	s.logger.Info("totp enrollment started", "username", username)
+	return secret, totp.URI(totpIssuer, username, secret), nil
+}
+
+// ConfirmTOTP enables two-factor authentication once the user proves the
+// authenticator works, and returns the recovery codes. Only their hashes are
+// kept. Wrong codes count against the login lockout like in DisableTOTP.
+func (s *AuthService) ConfirmTOTP(ctx context.Context, username, code string) ([]string, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.ConfirmTOTP")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if err := s.checkLoginAllowed(ctx, username, ""); err != nil {
+		span.RecordError(err)
+		s.logger.Warn("totp confirmation rejected", "username", username, "error", err)
+		return nil, err
+	}
+
+	user, err := s.getExistingUser(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	if user.TOTPEnabled {
+		span.RecordError(ErrMFAAlreadyEnabled)
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		span.RecordError(ErrMFANotEnrolled)
+		return nil, ErrMFANotEnrolled
+	}
+
+	ok, err := s.acceptTOTP(ctx, user, code)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("totp check failed", "username", username, "error", err)
+		return nil, errors.New("enrollment failed")
+	}
+	if !ok {
+		span.RecordError(ErrInvalidMFACode)
+		s.logger.Warn("invalid totp confirmation code", "username", username)
+		s.recordLoginFailure(ctx, username, "", user)
+		return nil, ErrInvalidMFACode
+	}
+	s.recordLoginSuccess(ctx, username)
+
+	recoveryCodes := make([]string, recoveryCodeCount)
+	codeHashes := make([]string, recoveryCodeCount)
+	for i := range recoveryCodes {
+		recoveryCode, err := generateRecoveryCode()
+		if err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to generate recovery code", "error", err)
+			return nil, errors.New("enrollment failed")
+		}
+		recoveryCodes[i] = recoveryCode
+		codeHashes[i] = hashToken(normalizeRecoveryCode(recoveryCode))
+	}
+
+	if err := s.userRepo.EnableTOTP(ctx, username, codeHashes); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to enable totp", "username", username, "error", err)
+		return nil, errors.New("enrollment failed")
+	}
+
+	span.SetStatus(codes.Ok, "totp enabled")
+	s.logger.Info("two-factor authentication enabled", "username", username)
+	return recoveryCodes, nil
+}
+
+// DisableTOTP turns two-factor authentication off. A current TOTP or an unused
+// recovery code is required so a stolen access token alone cannot do it, and
+// wrong codes count against the login lockout so they cannot be guessed.
+func (s *AuthService) DisableTOTP(ctx context.Context, username, code string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.DisableTOTP")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if err := s.checkLoginAllowed(ctx, username, ""); err != nil {
+		span.RecordError(err)
+		s.logger.Warn("disabling totp rejected", "username", username, "error", err)
+		return err
+	}
+
+	user, err := s.getExistingUser(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if !user.TOTPEnabled {
+		span.RecordError(ErrMFANotEnabled)
+		return ErrMFANotEnabled
+	}
+
+	ok, err := s.verifySecondFactor(ctx, user, code)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("second factor check failed", "username", username, "error", err)
+		return errors.New("failed to disable two-factor authentication")
+	}
+	if !ok {
+		span.RecordError(ErrInvalidMFACode)
+		s.logger.Warn("invalid code when disabling totp", "username", username)
+		s.recordLoginFailure(ctx, username, "", user)
+		return ErrInvalidMFACode
+	}
+	s.recordLoginSuccess(ctx, username)
+
+	if err := s.userRepo.DisableTOTP(ctx, username); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to disable totp", "username", username, "error", err)
+		return errors.New("failed to disable two-factor authentication")
+	}
+
+	span.SetStatus(codes.Ok, "totp disabled")
+	s.logger.Info("two-factor authentication disabled", "username", username)
+	return nil
+}
+
+func (s *AuthService) issueMFAToken(username string) (string, error) {
+	now := time.Now()
+	return s.keys.Sign(jwt.MapClaims{
+		"username": username,
+		"typ":      mfaTokenType,
+		"iat":      numericDate(now),
+		"exp":      numericDate(now.Add(mfaTokenTTL)),
+	})
+}
+
+// verifySecondFactor accepts a current TOTP code or spends one recovery code.
+func (s *AuthService) verifySecondFactor(ctx context.Context, user *models.User, code string) (bool, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return false, nil
+	}
+
+	if ok, err := s.acceptTOTP(ctx, user, code); ok || err != nil {
+		return ok, err
+	}
+
+	return s.userRepo.ConsumeRecoveryCode(ctx, user.Username, hashToken(normalizeRecoveryCode(code)))
+}
+
+// acceptTOTP checks a TOTP code and uses up its time step. A code stays valid
+// for the whole skew window, so without this an observed code could be
+// replayed for up to a minute and a half.
+func (s *AuthService) acceptTOTP(ctx context.Context, user *models.User, code string) (bool, error) {
+	step, ok := totp.Match(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return false, nil
+	}
+	return s.userRepo.AcceptTOTPStep(ctx, user.Username, int64(step))
+}
+
+func (s *AuthService) getExistingUser(ctx context.Context, username string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		s.logger.Error("failed to get user", "username", username, "error", err)
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// generateRecoveryCode returns a code like "abcde-fghij".
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 5)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	code := strings.ToLower(recoveryCodeEncoding.EncodeToString(raw))
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.ReplaceAll(code, "-", "")
+}
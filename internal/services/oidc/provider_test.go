@@ -0,0 +1,171 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"massager/app/config"
+	"massager/app/tests"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func fakeProvider(issuer *tests.FakeOIDCIssuer) *Provider {
+	return NewProvider(config.OIDCProviderConfig{
+		Name:         "corp",
+		Issuer:       issuer.URL,
+		ClientID:     tests.FakeOIDCClientID,
+		ClientSecret: tests.FakeOIDCClientSecret,
+		RedirectURL:  "http://horizon.test/callback",
+	}, issuer.Client())
+}
+
+func TestProvider_Authenticate(t *testing.T) {
+	issuer := tests.NewFakeOIDCIssuer()
+	defer issuer.Close()
+
+	provider := fakeProvider(issuer)
+	issuer.SetUser(tests.FakeOIDCUser{Subject: "sub-1", Email: "valid@corp.example", EmailVerified: true, Name: "Valid User"})
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	require.NoError(t, err)
+	callback, err := issuer.Authorize(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, "state", callback.Query().Get("state"))
+
+	claims, err := provider.Authenticate(context.Background(), callback.Query().Get("code"), "verifier", "nonce")
+	require.NoError(t, err)
+	assert.Equal(t, &Claims{Subject: "sub-1", Email: "valid@corp.example", EmailVerified: true, Name: "Valid User"}, claims)
+}
+
+func TestProvider_AuthenticateRejectsWrongVerifier(t *testing.T) {
+	issuer := tests.NewFakeOIDCIssuer()
+	defer issuer.Close()
+
+	provider := fakeProvider(issuer)
+	issuer.SetUser(tests.FakeOIDCUser{Subject: "sub-1"})
+
+	authURL, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	require.NoError(t, err)
+	callback, err := issuer.Authorize(authURL)
+	require.NoError(t, err)
+
+	_, err = provider.Authenticate(context.Background(), callback.Query().Get("code"), "other-verifier", "nonce")
+	assert.ErrorIs(t, err, ErrTokenExchange)
+}
+
+func TestProvider_VerifyIDToken(t *testing.T) {
+	issuer := tests.NewFakeOIDCIssuer()
+	defer issuer.Close()
+
+	user := tests.FakeOIDCUser{Subject: "sub-1", Email: "valid@corp.example", EmailVerified: true}
+	foreignKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var ts = []struct {
+		name    string
+		token   func() string
+		wantErr bool
+	}{
+		{
+			name:  "Valid token",
+			token: func() string { return issuer.SignIDToken(issuer.IDTokenClaims(user, "nonce")) },
+		},
+		{
+			name: "Wrong nonce",
+			token: func() string {
+				return issuer.SignIDToken(issuer.IDTokenClaims(user, "other-nonce"))
+			},
+			wantErr: true,
+		},
+		{
+			name: "Wrong audience",
+			token: func() string {
+				claims := issuer.IDTokenClaims(user, "nonce")
+				claims["aud"] = "another-client"
+				return issuer.SignIDToken(claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Wrong issuer",
+			token: func() string {
+				claims := issuer.IDTokenClaims(user, "nonce")
+				claims["iss"] = "https://evil.example"
+				return issuer.SignIDToken(claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Expired token",
+			token: func() string {
+				claims := issuer.IDTokenClaims(user, "nonce")
+				claims["exp"] = time.Now().Add(-time.Hour).Unix()
+				return issuer.SignIDToken(claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Several audiences without azp",
+			token: func() string {
+				claims := issuer.IDTokenClaims(user, "nonce")
+				claims["aud"] = []string{tests.FakeOIDCClientID, "another-client"}
+				return issuer.SignIDToken(claims)
+			},
+			wantErr: true,
+		},
+		{
+			name: "Signed by a foreign key",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodRS256, issuer.IDTokenClaims(user, "nonce"))
+				token.Header["kid"] = "fake-key"
+				signed, err := token.SignedString(foreignKey)
+				require.NoError(t, err)
+				return signed
+			},
+			wantErr: true,
+		},
+		{
+			name: "Symmetric algorithm",
+			token: func() string {
+				token := jwt.NewWithClaims(jwt.SigningMethodHS256, issuer.IDTokenClaims(user, "nonce"))
+				signed, err := token.SignedString([]byte(tests.FakeOIDCClientSecret))
+				require.NoError(t, err)
+				return signed
+			},
+			wantErr: true,
+		},
+	}
+
+	provider := fakeProvider(issuer)
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := provider.VerifyIDToken(context.Background(), tt.token(), "nonce")
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidIDToken)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "sub-1", claims.Subject)
+		})
+	}
+}
+
+func TestProvider_DiscoveryIssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"issuer":"https://evil.example","authorization_endpoint":"https://evil.example/a","token_endpoint":"https://evil.example/t","jwks_uri":"https://evil.example/k"}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(config.OIDCProviderConfig{Name: "corp", Issuer: server.URL, ClientID: "horizon"}, server.Client())
+
+	_, err := provider.AuthCodeURL(context.Background(), "state", "nonce", "verifier")
+	assert.ErrorIs(t, err, ErrDiscovery)
+}
@@ -0,0 +1,304 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"massager/internal/models"
+	"massager/internal/ports"
+	"massager/internal/services/oidc"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+	ErrInvalidOIDCState    = errors.New("invalid or expired login state")
+	ErrOIDCLoginFailed     = errors.New("identity provider login failed")
+	ErrOIDCAccountNotFound = errors.New("no account is linked to this identity")
+	ErrOIDCEmailTaken      = errors.New("an account with this email already exists")
+)
+
+const (
+	oidcStateTTL = 10 * time.Minute
+
+	maxOIDCUsernameAttempts = 20
+)
+
+// SetOIDC enables login through the given OpenID providers.
+func (s *AuthService) SetOIDC(providers []*oidc.Provider, states ports.OIDCStateRepository) {
+	s.oidcProviders = make(map[string]*oidc.Provider, len(providers))
+	for _, provider := range providers {
+		s.oidcProviders[provider.Name()] = provider
+	}
+	s.oidcStates = states
+}
+
+// OIDCProviders lists the names of the configured identity providers.
+func (s *AuthService) OIDCProviders() []string {
+	names := make([]string, 0, len(s.oidcProviders))
+	for name := range s.oidcProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// StartOIDCLogin returns the provider URL the browser has to visit. The state,
+// nonce and PKCE verifier are kept server-side until the callback.
+func (s *AuthService) StartOIDCLogin(ctx context.Context, providerName string) (string, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.StartOIDCLogin")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("oidc.provider", providerName))
+
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		span.RecordError(ErrUnknownOIDCProvider)
+		return "", ErrUnknownOIDCProvider
+	}
+
+	var secrets [3]string
+	for i := range secrets {
+		secret, err := generateSecureToken()
+		if err != nil {
+			span.RecordError(err)
+			s.logger.Error("failed to generate oidc login secrets", "error", err)
+			return "", ErrOIDCLoginFailed
+		}
+		secrets[i] = secret
+	}
+	state, nonce, codeVerifier := secrets[0], secrets[1], secrets[2]
+
+	authURL, err := provider.AuthCodeURL(ctx, state, nonce, codeVerifier)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to build oidc authorization url", "provider", providerName, "error", err)
+		return "", ErrOIDCLoginFailed
+	}
+
+	pending := models.OIDCState{Provider: providerName, Nonce: nonce, CodeVerifier: codeVerifier}
+	if err := s.oidcStates.SaveOIDCState(ctx, hashToken(state), pending, oidcStateTTL); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to store oidc login state", "error", err)
+		return "", ErrOIDCLoginFailed
+	}
+
+	span.SetStatus(codes.Ok, "oidc login started")
+	return authURL, nil
+}
+
+// CompleteOIDCLogin handles the provider's callback: it redeems the code,
+// maps the external subject to a user and starts a normal session. Accounts
+// with two-factor authentication still get an MFA token instead.
+func (s *AuthService) CompleteOIDCLogin(ctx context.Context, providerName, state, code string, client models.ClientInfo) (*models.LoginResult, error) {
+	ctx, span := s.tracer.Start(ctx, "AuthService.CompleteOIDCLogin")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("oidc.provider", providerName))
+
+	provider, ok := s.oidcProviders[providerName]
+	if !ok {
+		span.RecordError(ErrUnknownOIDCProvider)
+		return nil, ErrUnknownOIDCProvider
+	}
+	if state == "" || code == "" {
+		span.RecordError(ErrInvalidOIDCState)
+		return nil, ErrInvalidOIDCState
+	}
+
+	pending, err := s.oidcStates.ConsumeOIDCState(ctx, hashToken(state))
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to load oidc login state", "error", err)
+		return nil, ErrOIDCLoginFailed
+	}
+	if pending == nil || pending.Provider != providerName {
+		span.RecordError(ErrInvalidOIDCState)
+		s.logger.Warn("unknown oidc login state presented", "provider", providerName)
+		return nil, ErrInvalidOIDCState
+	}
+
+	claims, err := provider.Authenticate(ctx, code, pending.CodeVerifier, pending.Nonce)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Warn("oidc authentication failed", "provider", providerName, "error", err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	span.SetAttributes(attribute.String("oidc.subject", claims.Subject))
+
+	user, err := s.resolveOIDCUser(ctx, provider, claims)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.String("user.username", user.Username))
+
+	if user.IsBanned() {
+		span.RecordError(ErrAccountBanned)
+		s.logger.Warn("oidc login attempt of banned user", "username", user.Username)
+		return nil, ErrAccountBanned
+	}
+
+	if user.TOTPEnabled {
+		mfaToken, err := s.issueMFAToken(user.Username)
+		if err != nil {
+			span.RecordError(err)
+			s.logger.Error("mfa token generation failed", "error", err)
+			return nil, errors.New("authentication failed")
+		}
+
+		span.SetStatus(codes.Ok, "second factor required")
+		return &models.LoginResult{MFAToken: mfaToken}, nil
+	}
+
+	tokens, err := s.startSession(ctx, user, client)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("token generation failed", "error", err)
+		return nil, errors.New("authentication failed")
+	}
+
+	span.SetStatus(codes.Ok, "oidc login successful")
+	s.logger.Info("oidc login successful", "username", user.Username, "provider", providerName)
+	return &models.LoginResult{Tokens: tokens}, nil
+}
+
+// resolveOIDCUser finds the user linked to the subject. Unknown subjects are
+// linked to the local account with the same email or get a new account,
+// depending on the provider's settings. Both need an email the provider
+// vouches for; linking also needs a local account that confirmed the address,
+// so nobody can pre-register someone else's email and wait for their login.
+func (s *AuthService) resolveOIDCUser(ctx context.Context, provider *oidc.Provider, claims *oidc.Claims) (*models.User, error) {
+	cfg := provider.Config()
+
+	user, err := s.userRepo.GetUserByIdentity(ctx, cfg.Name, claims.Subject)
+	if err != nil {
+		s.logger.Error("failed to look up identity", "provider", cfg.Name, "error", err)
+		return nil, ErrOIDCLoginFailed
+	}
+	if user != nil {
+		return user, nil
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		s.logger.Warn("oidc identity without verified email", "provider", cfg.Name, "subject", claims.Subject)
+		return nil, ErrOIDCAccountNotFound
+	}
+
+	email := normalizeEmail(claims.Email)
+	existing, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		s.logger.Error("failed to look up user by email", "error", err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	if existing != nil {
+		if !cfg.LinkByEmail || !existing.IsVerefied {
+			s.logger.Warn("oidc identity matches an account that cannot be linked", "provider", cfg.Name, "username", existing.Username)
+			return nil, ErrOIDCEmailTaken
+		}
+
+		if err := s.userRepo.LinkIdentity(ctx, existing.Username, cfg.Name, claims.Subject, email); err != nil {
+			s.logger.Error("failed to link identity", "username", existing.Username, "error", err)
+			return nil, ErrOIDCLoginFailed
+		}
+
+		s.logger.Info("oidc identity linked", "username", existing.Username, "provider", cfg.Name)
+		return existing, nil
+	}
+
+	if !cfg.AutoProvision {
+		return nil, ErrOIDCAccountNotFound
+	}
+
+	username, err := s.freeOIDCUsername(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.CreateUserWithIdentity(ctx, username, email, cfg.Name, claims.Subject); err != nil {
+		s.logger.Error("failed to provision oidc user", "username", username, "error", err)
+		return nil, ErrOIDCLoginFailed
+	}
+
+	if claims.Name != "" {
+		displayName := truncateRunes(strings.TrimSpace(claims.Name), maxDisplayNameLength)
+		if err := s.userRepo.UpdateProfile(ctx, username, models.ProfileUpdate{DisplayName: &displayName}); err != nil {
+			s.logger.Warn("failed to set display name of provisioned user", "username", username, "error", err)
+		}
+	}
+
+	s.logger.Info("oidc user provisioned", "username", username, "provider", cfg.Name)
+	return s.getExistingUser(ctx, username)
+}
+
+// freeOIDCUsername derives a username from the claims and appends a number
+// until it is not taken.
+func (s *AuthService) freeOIDCUsername(ctx context.Context, claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base, _, _ = strings.Cut(claims.Email, "@")
+	}
+	// Provisioned names follow the same rules as registered ones; a name the
+	// policy rejects, such as a reserved or too short one, becomes "user".
+	base = sanitizeUsername(base)
+	if strings.HasPrefix(base, deletedUsernamePrefix) || s.policy.ValidateUsername(s.oidcUsernameCandidate(base, 1)) != nil {
+		base = "user"
+	}
+
+	for attempt := 1; attempt <= maxOIDCUsernameAttempts; attempt++ {
+		candidate := s.oidcUsernameCandidate(base, attempt)
+		if s.policy.ValidateUsername(candidate) != nil {
+			continue
+		}
+
+		existing, err := s.userRepo.GetUserByName(ctx, candidate)
+		if err != nil {
+			s.logger.Error("failed to check username", "error", err)
+			return "", ErrOIDCLoginFailed
+		}
+		if existing == nil {
+			return candidate, nil
+		}
+	}
+
+	s.logger.Warn("no free username for oidc user", "base", base)
+	return "", ErrOIDCLoginFailed
+}
+
+// oidcUsernameCandidate numbers every attempt after the first, shortening base
+// so the result stays within the policy's maximum length.
+func (s *AuthService) oidcUsernameCandidate(base string, attempt int) string {
+	suffix := ""
+	if attempt > 1 {
+		suffix = fmt.Sprint(attempt)
+	}
+	return truncateRunes(base, s.policy.UsernameMaxLength()-len(suffix)) + suffix
+}
+
+// sanitizeUsername keeps letters, digits, dots, dashes and underscores.
+func sanitizeUsername(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(name)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '.' || r == '-' || r == '_' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
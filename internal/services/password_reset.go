@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+const passwordResetTokenTTL = time.Hour
+
+// RequestPasswordReset emails a single-use reset link to the owner of the address.
+// Unknown addresses are not reported back, so the endpoint cannot be used to
+// find out which emails are registered.
+func (s *AuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.RequestPasswordReset")
+	defer span.End()
+
+	email = normalizeEmail(email)
+	span.SetAttributes(attribute.String("user.email", email))
+
+	if email == "" {
+		err := errors.New("email is required")
+		span.RecordError(err)
+		return err
+	}
+
+	user, err := s.userRepo.GetUserByEmail(ctx, email)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to look up user by email", "error", err)
+		return nil
+	}
+	if user == nil {
+		s.logger.Info("password reset requested for unknown email")
+		return nil
+	}
+
+	if err := s.sendPasswordResetLink(ctx, user.Username, email); err != nil {
+		span.RecordError(err)
+		return nil
+	}
+
+	span.SetStatus(codes.Ok, "password reset requested")
+	s.logger.Info("password reset requested", "username", user.Username)
+	return nil
+}
+
+// sendPasswordResetLink stores a new reset token for the user and emails it.
+func (s *AuthService) sendPasswordResetLink(ctx context.Context, username, email string) error {
+	resetToken, err := generateSecureToken()
+	if err != nil {
+		s.logger.Error("failed to generate reset token", "error", err)
+		return err
+	}
+
+	err = s.userRepo.CreatePasswordResetToken(ctx, username, hashToken(resetToken), time.Now().Add(passwordResetTokenTTL))
+	if err != nil {
+		s.logger.Error("failed to store reset token", "username", username, "error", err)
+		return err
+	}
+
+	if err := s.emailService.SendPasswordResetEmail(email, resetToken); err != nil {
+		s.logger.Warn("failed to send password reset email", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password using a reset token and revokes every token
+// issued to the user before the reset.
+func (s *AuthService) ResetPassword(ctx context.Context, resetToken, newPassword string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.ResetPassword")
+	defer span.End()
+
+	if resetToken == "" || newPassword == "" {
+		err := errors.New("token and new password are required")
+		span.RecordError(err)
+		return err
+	}
+
+	// The token is only looked up here, so a password the policy rejects does
+	// not spend it.
+	username, err := s.userRepo.GetPasswordResetTokenUser(ctx, hashToken(resetToken))
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to look up reset token", "error", err)
+		return errors.New("password reset failed")
+	}
+	if username == "" {
+		span.RecordError(ErrInvalidResetToken)
+		s.logger.Warn("invalid password reset token presented")
+		return ErrInvalidResetToken
+	}
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if err := s.policy.ValidatePassword(newPassword, username); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	hashedPassword, err := s.hasher.GenerateFromPassword([]byte(newPassword), s.hasher.DefaultCost())
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("password hashing failed", "error", err)
+		return errors.New("password reset failed")
+	}
+
+	// A concurrent reset may have spent the token since the lookup.
+	consumed, err := s.userRepo.ConsumePasswordResetToken(ctx, hashToken(resetToken))
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to consume reset token", "error", err)
+		return errors.New("password reset failed")
+	}
+	if consumed != username {
+		span.RecordError(ErrInvalidResetToken)
+		s.logger.Warn("password reset token spent concurrently", "username", username)
+		return ErrInvalidResetToken
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, username, string(hashedPassword)); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to update password", "username", username, "error", err)
+		return errors.New("password reset failed")
+	}
+
+	if err := s.RevokeAllSessions(ctx, username); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke sessions after password reset", "username", username, "error", err)
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "password reset")
+	s.logger.Info("password reset successfully", "username", username)
+	return nil
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"massager/internal/models"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var ErrInvalidProfile = errors.New("invalid profile")
+
+const (
+	maxDisplayNameLength = 64
+	maxBioLength         = 500
+	maxAvatarURLLength   = 512
+
+	minSearchQueryLength = 2
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 50
+)
+
+// GetOwnProfile returns the caller's profile together with their account details.
+func (s *UserService) GetOwnProfile(ctx context.Context, username string) (*models.OwnProfile, error) {
+	ctx, span := s.tracer.Start(ctx, "UserService.GetOwnProfile")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	user, err := s.getUser(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	profile := &models.OwnProfile{
+		Profile:      user.Profile(),
+		Email:        user.Email,
+		IsVerified:   user.IsVerefied,
+		TOTPEnabled:  user.TOTPEnabled,
+		Discoverable: user.Discoverable,
+		Role:         roleOf(user),
+	}
+	if !user.DeletionScheduledAt.IsZero() {
+		profile.DeletionScheduledAt = &user.DeletionScheduledAt
+	}
+
+	span.SetStatus(codes.Ok, "profile retrieved")
+	return profile, nil
+}
+
+// GetProfile returns the public profile of any user.
+func (s *UserService) GetProfile(ctx context.Context, username string) (*models.Profile, error) {
+	ctx, span := s.tracer.Start(ctx, "UserService.GetProfile")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	user, err := s.getUser(ctx, username)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	profile := user.Profile()
+	span.SetStatus(codes.Ok, "profile retrieved")
+	return &profile, nil
+}
+
+// UpdateProfile validates and applies a partial profile update.
+func (s *UserService) UpdateProfile(ctx context.Context, username string, update models.ProfileUpdate) (*models.OwnProfile, error) {
+	ctx, span := s.tracer.Start(ctx, "UserService.UpdateProfile")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if err := normalizeProfileUpdate(&update); err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdateProfile(ctx, username, update); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to update profile", "username", username, "error", err)
+		return nil, errors.New("failed to update profile")
+	}
+
+	s.logger.Info("profile updated", "username", username)
+	return s.GetOwnProfile(ctx, username)
+}
+
+// SearchUsers looks up verified users who allow being found, by username or
+// display name prefix and by similarity. The caller is never part of the result.
+// The second result reports whether another page follows.
+func (s *UserService) SearchUsers(ctx context.Context, requester, query string, limit, offset int) ([]models.Profile, bool, error) {
+	ctx, span := s.tracer.Start(ctx, "UserService.SearchUsers")
+	defer span.End()
+
+	query = strings.TrimSpace(query)
+	span.SetAttributes(
+		attribute.String("user.username", requester),
+		attribute.String("search.query", query),
+	)
+
+	if utf8.RuneCountInString(query) < minSearchQueryLength {
+		err := fmt.Errorf("%w: search query must be at least %d characters", ErrInvalidInput, minSearchQueryLength)
+		span.RecordError(err)
+		return nil, false, err
+	}
+
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	// One extra row tells whether there is a next page.
+	profiles, err := s.userRepo.SearchUsers(ctx, query, requester, limit+1, offset)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("user search failed", "error", err)
+		return nil, false, errors.New("failed to search users")
+	}
+
+	hasMore := len(profiles) > limit
+	if hasMore {
+		profiles = profiles[:limit]
+	}
+
+	span.SetStatus(codes.Ok, "users found")
+	return profiles, hasMore, nil
+}
+
+func (s *UserService) getUser(ctx context.Context, username string) (*models.User, error) {
+	user, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		s.logger.Error("failed to get user", "username", username, "error", err)
+		return nil, errors.New("failed to get user")
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+func isWebURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "https" || parsed.Scheme == "http") && parsed.Host != ""
+}
+
+func normalizeProfileUpdate(update *models.ProfileUpdate) error {
+	for _, field := range []*string{update.DisplayName, update.Bio, update.AvatarURL, update.Timezone} {
+		if field != nil {
+			*field = strings.TrimSpace(*field)
+		}
+	}
+
+	if update.DisplayName != nil && utf8.RuneCountInString(*update.DisplayName) > maxDisplayNameLength {
+		return fmt.Errorf("%w: display name must be at most %d characters", ErrInvalidProfile, maxDisplayNameLength)
+	}
+
+	if update.Bio != nil && utf8.RuneCountInString(*update.Bio) > maxBioLength {
+		return fmt.Errorf("%w: bio must be at most %d characters", ErrInvalidProfile, maxBioLength)
+	}
+
+	if update.AvatarURL != nil && *update.AvatarURL != "" {
+		if len(*update.AvatarURL) > maxAvatarURLLength {
+			return fmt.Errorf("%w: avatar url must be at most %d characters", ErrInvalidProfile, maxAvatarURLLength)
+		}
+		if !isWebURL(*update.AvatarURL) {
+			return fmt.Errorf("%w: avatar url must be an http(s) url", ErrInvalidProfile)
+		}
+	}
+
+	if update.Timezone != nil && *update.Timezone != "" {
+		if _, err := time.LoadLocation(*update.Timezone); err != nil {
+			return fmt.Errorf("%w: unknown timezone %q", ErrInvalidProfile, *update.Timezone)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,250 @@
+package services_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"massager/app/config"
+	"massager/app/tests"
+	"massager/internal/models"
+	"massager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRequestAccountDeletion_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name          string
+		password      string
+		user          *models.User
+		setupMocks    func(*tests.MockRepository, *tests.MockHasher, *tests.MockEmailService)
+		expectedError error
+	}{
+		{
+			name:     "Deletion is scheduled after the grace period",
+			password: "correctpassword",
+			user:     &models.User{Username: "validuser", Password: "stored_hash", Email: "valid@gmail.com"},
+			setupMocks: func(mr *tests.MockRepository, mh *tests.MockHasher, me *tests.MockEmailService) {
+				mh.On("CompareHashAndPassword", []byte("stored_hash"), []byte("correctpassword")).Return(nil)
+				mr.On("ScheduleDeletion", mock.Anything, "validuser", mock.MatchedBy(func(at time.Time) bool {
+					return time.Until(at) > 47*time.Hour && time.Until(at) <= 48*time.Hour
+				})).Return(nil)
+				me.On("SendAccountDeletionScheduled", "valid@gmail.com", mock.AnythingOfType("time.Time")).Return(nil)
+			},
+		},
+		{
+			name:     "Wrong password",
+			password: "wrongpassword",
+			user:     &models.User{Username: "validuser", Password: "stored_hash", Email: "valid@gmail.com"},
+			setupMocks: func(mr *tests.MockRepository, mh *tests.MockHasher, me *tests.MockEmailService) {
+				mh.On("CompareHashAndPassword", []byte("stored_hash"), []byte("wrongpassword")).Return(bcrypt.ErrMismatchedHashAndPassword)
+			},
+			expectedError: services.ErrWrongPassword,
+		},
+		{
+			name:     "Already scheduled",
+			password: "correctpassword",
+			user: &models.User{Username: "validuser", Password: "stored_hash", Email: "valid@gmail.com",
+				DeletionScheduledAt: time.Now().Add(time.Hour)},
+			setupMocks: func(mr *tests.MockRepository, mh *tests.MockHasher, me *tests.MockEmailService) {
+				mh.On("CompareHashAndPassword", []byte("stored_hash"), []byte("correctpassword")).Return(nil)
+			},
+			expectedError: services.ErrDeletionAlreadyPending,
+		},
+		{
+			name:          "Missing password",
+			user:          &models.User{Username: "validuser", Password: "stored_hash", Email: "valid@gmail.com"},
+			setupMocks:    func(mr *tests.MockRepository, mh *tests.MockHasher, me *tests.MockEmailService) {},
+			expectedError: services.ErrAccountSettingsIncomplete,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			mockHasher := &tests.MockHasher{}
+			mockEmailService := &tests.MockEmailService{}
+			mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(tt.user, nil)
+			tt.setupMocks(mockRepository, mockHasher, mockEmailService)
+
+			userService := newTestUserService(mockRepository, mockEmailService, mockHasher,
+				&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+			userService.SetAccountDeletion(config.AccountDeletionConfig{GracePeriod: 48 * time.Hour})
+
+			_, err := userService.RequestAccountDeletion(context.Background(), "validuser", tt.password)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mockRepository.AssertNotCalled(t, "ScheduleDeletion", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			mockRepository.AssertExpectations(t)
+			mockEmailService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCancelAccountDeletion(t *testing.T) {
+	t.Run("Pending deletion is cancelled", func(t *testing.T) {
+		t.Parallel()
+
+		mockRepository := &tests.MockRepository{}
+		mockRepository.On("CancelDeletion", mock.Anything, "validuser").Return(true, nil)
+
+		userService := newTestUserService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+			&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+		assert.NoError(t, userService.CancelAccountDeletion(context.Background(), "validuser"))
+	})
+
+	t.Run("Nothing to cancel", func(t *testing.T) {
+		t.Parallel()
+
+		mockRepository := &tests.MockRepository{}
+		mockRepository.On("CancelDeletion", mock.Anything, "validuser").Return(false, nil)
+
+		userService := newTestUserService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+			&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+		assert.ErrorIs(t, userService.CancelAccountDeletion(context.Background(), "validuser"), services.ErrDeletionNotScheduled)
+	})
+}
+
+func TestPurgeDueAccounts_RevokesSessionsBeforeAnonymising(t *testing.T) {
+	mockRepository := &tests.MockRepository{}
+	mockChatRepository := &tests.MockChatRepository{}
+	mockTokenRepository := &tests.MockTokenRepository{}
+	mockSessionRepository := &tests.MockSessionRepository{}
+
+	mockRepository.On("GetUsersDueForDeletion", mock.Anything, mock.AnythingOfType("time.Time")).Return([]string{"gone", "stuck"}, nil)
+
+	mockTokenRepository.On("RevokeAllBefore", mock.Anything, "gone", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration")).Return(nil)
+	mockSessionRepository.On("RevokeUserSessions", mock.Anything, "gone").Return(nil)
+	mockChatRepository.On("GetUserChats", mock.Anything, "gone").Return(&[]models.Chat{}, nil)
+	mockRepository.On("AnonymizeUser", mock.Anything, "gone").Return(nil)
+
+	// A failure leaves the account for the next run.
+	mockTokenRepository.On("RevokeAllBefore", mock.Anything, "stuck", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration")).Return(errors.New("redis down"))
+
+	authService := services.NewAuthService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+		mockTokenRepository, &tests.MockRefreshTokenRepository{}, mockSessionRepository,
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	userService := services.NewUserService(mockRepository, mockChatRepository, &tests.MockMessageRepository{},
+		&tests.MockEmailService{}, &tests.MockHasher{}, authService, slog.Default(), tests.NoopTracer())
+
+	purged, err := userService.PurgeDueAccounts(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	mockRepository.AssertExpectations(t)
+	mockRepository.AssertNotCalled(t, "AnonymizeUser", mock.Anything, "stuck")
+}
+
+func TestPurgeDueAccounts_LeavesGroupChats(t *testing.T) {
+	mockRepository := &tests.MockRepository{}
+	mockChatRepository := &tests.MockChatRepository{}
+	mockTokenRepository := &tests.MockTokenRepository{}
+	mockSessionRepository := &tests.MockSessionRepository{}
+	mockMessageRepository := &tests.MockMessageRepository{}
+
+	mockRepository.On("GetUsersDueForDeletion", mock.Anything, mock.AnythingOfType("time.Time")).Return([]string{"gone"}, nil)
+	mockTokenRepository.On("RevokeAllBefore", mock.Anything, "gone", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration")).Return(nil)
+	mockSessionRepository.On("RevokeUserSessions", mock.Anything, "gone").Return(nil)
+
+	owned := models.Chat{ID: 7, Kind: models.ChatKindGroup, Name: "owned", Members: []string{"gone", "alice"},
+		Roles: map[string]models.ChatRole{"gone": models.ChatRoleOwner, "alice": models.ChatRoleAdmin}}
+	alone := models.Chat{ID: 10, Kind: models.ChatKindGroup, Name: "alone", Members: []string{"gone"},
+		Roles: map[string]models.ChatRole{"gone": models.ChatRoleOwner}}
+	joined := models.Chat{ID: 8, Kind: models.ChatKindGroup, Name: "joined", Members: []string{"gone", "bob"},
+		Roles: map[string]models.ChatRole{"gone": models.ChatRoleMember, "bob": models.ChatRoleOwner}}
+	mockChatRepository.On("GetUserChats", mock.Anything, "gone").Return(&[]models.Chat{
+		owned,
+		joined,
+		{ID: 9, Kind: models.ChatKindDirect, Members: []string{"gone", "carol"},
+			Roles: map[string]models.ChatRole{"gone": models.ChatRoleOwner, "carol": models.ChatRoleOwner}},
+		alone,
+	}, nil)
+	mockChatRepository.On("GetChatByID", mock.Anything, 7).Return(&owned, nil)
+	mockChatRepository.On("GetChatByID", mock.Anything, 8).Return(&joined, nil)
+	mockChatRepository.On("GetChatByID", mock.Anything, 10).Return(&alone, nil)
+
+	var handedOver bool
+	mockChatRepository.On("RemoveMember", mock.Anything, 7, "gone").Return("alice", nil).Run(func(mock.Arguments) {
+		handedOver = true
+	})
+	// Leaving a group the account did not own goes through the same path, so
+	// the remaining members see the removal.
+	mockChatRepository.On("RemoveMember", mock.Anything, 8, "gone").Return("", nil)
+	// A group nobody else is left in goes away, as when its last member leaves.
+	mockChatRepository.On("DeleteChat", mock.Anything, 10).Return(nil)
+	mockMessageRepository.On("DeleteMessagesByChatID", mock.Anything, 10).Return(nil)
+	mockRepository.On("AnonymizeUser", mock.Anything, "gone").Return(nil).Run(func(mock.Arguments) {
+		assert.True(t, handedOver, "ownership has to be handed over before the account is anonymised")
+	})
+
+	authService := services.NewAuthService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+		mockTokenRepository, &tests.MockRefreshTokenRepository{}, mockSessionRepository,
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	userService := services.NewUserService(mockRepository, mockChatRepository, mockMessageRepository,
+		&tests.MockEmailService{}, &tests.MockHasher{}, authService, slog.Default(), tests.NoopTracer())
+
+	purged, err := userService.PurgeDueAccounts(context.Background())
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+	mockChatRepository.AssertExpectations(t)
+	mockChatRepository.AssertNumberOfCalls(t, "RemoveMember", 2)
+	mockChatRepository.AssertNotCalled(t, "RemoveMember", mock.Anything, 9, "gone")
+	mockMessageRepository.AssertExpectations(t)
+	mockRepository.AssertExpectations(t)
+}
+
+func TestExportAccount_WritesZipArchive(t *testing.T) {
+	mockRepository := &tests.MockRepository{}
+	mockChatRepository := &tests.MockChatRepository{}
+	mockMessageRepository := &tests.MockMessageRepository{}
+
+	mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(&models.User{
+		Username: "validuser", Password: "stored_hash", Email: "valid@gmail.com", DisplayName: "John Doe",
+	}, nil)
+	mockChatRepository.On("GetUserChats", mock.Anything, "validuser").Return(&[]models.Chat{{ID: 7, Name: "team"}}, nil)
+	mockMessageRepository.On("GetMessagesBySender", mock.Anything, "validuser").Return([]models.Message{
+		{ChatID: 7, ChatName: "team", Sender: "validuser", Content: "hello"},
+	}, nil)
+
+	userService := services.NewUserService(mockRepository, mockChatRepository, mockMessageRepository,
+		&tests.MockEmailService{}, &tests.MockHasher{}, nil, slog.Default(), tests.NoopTracer())
+
+	var archive bytes.Buffer
+	assert.NoError(t, userService.ExportAccount(context.Background(), "validuser", &archive))
+
+	reader, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	assert.NoError(t, err)
+
+	files := map[string]string{}
+	for _, file := range reader.File {
+		rc, err := file.Open()
+		assert.NoError(t, err)
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[file.Name] = string(data)
+	}
+
+	assert.Len(t, files, 3)
+	assert.Contains(t, files["profile.json"], `"email": "valid@gmail.com"`)
+	assert.NotContains(t, files["profile.json"], "stored_hash")
+	assert.Contains(t, files["messages.json"], `"content": "hello"`)
+
+	var chats []models.Chat
+	assert.NoError(t, json.Unmarshal([]byte(files["chats.json"]), &chats))
+	assert.Equal(t, 7, chats[0].ID)
+}
@@ -0,0 +1,301 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"massager/app/tests"
+	"massager/internal/handlers"
+	"massager/internal/models"
+	"massager/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type adminMocks struct {
+	repository *tests.MockRepository
+	tokens     *tests.MockTokenRepository
+	sessions   *tests.MockSessionRepository
+	email      *tests.MockEmailService
+}
+
+func newAdminAuthService() (*services.AuthService, adminMocks) {
+	mocks := adminMocks{
+		repository: &tests.MockRepository{},
+		tokens:     &tests.MockTokenRepository{},
+		sessions:   &tests.MockSessionRepository{},
+		email:      &tests.MockEmailService{},
+	}
+	authService := services.NewAuthService(mocks.repository, mocks.email, &tests.MockHasher{},
+		mocks.tokens, &tests.MockRefreshTokenRepository{}, mocks.sessions,
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	return authService, mocks
+}
+
+func (m adminMocks) expectSessionsRevoked(username string) {
+	m.tokens.On("RevokeAllBefore", mock.Anything, username, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration")).Return(nil)
+	m.sessions.On("RevokeUserSessions", mock.Anything, username).Return(nil)
+}
+
+func TestBanUser_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name          string
+		actor         string
+		target        string
+		setupMocks    func(adminMocks)
+		expectedError error
+	}{
+		{
+			name:   "Moderator bans a user",
+			actor:  "moderator",
+			target: "spammer",
+			setupMocks: func(m adminMocks) {
+				m.repository.On("GetUserByName", mock.Anything, "moderator").Return(&models.User{Username: "moderator", Role: models.RoleModerator}, nil)
+				m.repository.On("GetUserByName", mock.Anything, "spammer").Return(&models.User{Username: "spammer", Role: models.RoleUser}, nil)
+				m.repository.On("BanUser", mock.Anything, "spammer", "spam", mock.AnythingOfType("time.Time")).Return(nil)
+				m.expectSessionsRevoked("spammer")
+			},
+		},
+		{
+			name:   "Moderator cannot ban another moderator",
+			actor:  "moderator",
+			target: "other",
+			setupMocks: func(m adminMocks) {
+				m.repository.On("GetUserByName", mock.Anything, "moderator").Return(&models.User{Username: "moderator", Role: models.RoleModerator}, nil)
+				m.repository.On("GetUserByName", mock.Anything, "other").Return(&models.User{Username: "other", Role: models.RoleModerator}, nil)
+			},
+			expectedError: services.ErrInsufficientRole,
+		},
+		{
+			name:   "Role is read from the database, not the token",
+			actor:  "demoted",
+			target: "spammer",
+			setupMocks: func(m adminMocks) {
+				m.repository.On("GetUserByName", mock.Anything, "demoted").Return(&models.User{Username: "demoted", Role: models.RoleUser}, nil)
+			},
+			expectedError: services.ErrInsufficientRole,
+		},
+		{
+			name:          "Own account",
+			actor:         "moderator",
+			target:        "moderator",
+			setupMocks:    func(m adminMocks) {},
+			expectedError: services.ErrInsufficientRole,
+		},
+		{
+			name:   "Unknown user",
+			actor:  "moderator",
+			target: "ghost",
+			setupMocks: func(m adminMocks) {
+				m.repository.On("GetUserByName", mock.Anything, "moderator").Return(&models.User{Username: "moderator", Role: models.RoleModerator}, nil)
+				m.repository.On("GetUserByName", mock.Anything, "ghost").Return((*models.User)(nil), nil)
+			},
+			expectedError: services.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mocks := newAdminAuthService()
+			tt.setupMocks(mocks)
+
+			err := authService.BanUser(context.Background(), tt.actor, tt.target, " spam ")
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mocks.repository.AssertNotCalled(t, "BanUser", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			mocks.repository.AssertExpectations(t)
+			mocks.tokens.AssertExpectations(t)
+			mocks.sessions.AssertExpectations(t)
+		})
+	}
+}
+
+func TestSetUserRole_TableDrive(t *testing.T) {
+	admin := &models.User{Username: "admin", Role: models.RoleAdmin}
+
+	var ts = []struct {
+		name          string
+		target        *models.User
+		role          models.Role
+		expectRevoke  bool
+		expectedError error
+	}{
+		{
+			name:   "Promotion keeps sessions",
+			target: &models.User{Username: "validuser", Role: models.RoleUser},
+			role:   models.RoleModerator,
+		},
+		{
+			name:         "Demotion ends sessions",
+			target:       &models.User{Username: "validuser", Role: models.RoleModerator},
+			role:         models.RoleUser,
+			expectRevoke: true,
+		},
+		{
+			name:          "Unknown role",
+			target:        &models.User{Username: "validuser", Role: models.RoleUser},
+			role:          "owner",
+			expectedError: services.ErrInvalidRole,
+		},
+		{
+			name:          "Bots cannot hold a role",
+			target:        &models.User{Username: "deploybot", Role: models.RoleUser, IsBot: true},
+			role:          models.RoleModerator,
+			expectedError: services.ErrInvalidRole,
+		},
+		{
+			name:          "Another admin",
+			target:        &models.User{Username: "otheradmin", Role: models.RoleAdmin},
+			role:          models.RoleUser,
+			expectedError: services.ErrInsufficientRole,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mocks := newAdminAuthService()
+			mocks.repository.On("GetUserByName", mock.Anything, "admin").Return(admin, nil)
+			mocks.repository.On("GetUserByName", mock.Anything, tt.target.Username).Return(tt.target, nil)
+			mocks.repository.On("SetRole", mock.Anything, tt.target.Username, tt.role).Return(nil)
+			if tt.expectRevoke {
+				mocks.expectSessionsRevoked(tt.target.Username)
+			}
+
+			err := authService.SetUserRole(context.Background(), "admin", tt.target.Username, tt.role)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mocks.repository.AssertNotCalled(t, "SetRole", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			mocks.repository.AssertExpectations(t)
+			mocks.tokens.AssertExpectations(t)
+			mocks.sessions.AssertExpectations(t)
+			if !tt.expectRevoke {
+				mocks.sessions.AssertNotCalled(t, "RevokeUserSessions", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestAdminResetPassword(t *testing.T) {
+	authService, mocks := newAdminAuthService()
+
+	mocks.repository.On("GetUserByName", mock.Anything, "admin").Return(&models.User{Username: "admin", Role: models.RoleAdmin}, nil)
+	mocks.repository.On("GetUserByName", mock.Anything, "validuser").Return(&models.User{Username: "validuser", Email: "valid@gmail.com"}, nil)
+	mocks.repository.On("UpdatePassword", mock.Anything, "validuser", "!").Return(nil)
+	mocks.repository.On("CreatePasswordResetToken", mock.Anything, "validuser", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+	mocks.email.On("SendPasswordResetEmail", "valid@gmail.com", mock.AnythingOfType("string")).Return(nil)
+	mocks.expectSessionsRevoked("validuser")
+
+	err := authService.AdminResetPassword(context.Background(), "admin", "validuser")
+
+	require.NoError(t, err)
+	mocks.repository.AssertExpectations(t)
+	mocks.email.AssertExpectations(t)
+	mocks.sessions.AssertExpectations(t)
+}
+
+func TestUnlockUserHandler_ErrorStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var ts = []struct {
+		name         string
+		user         *models.User
+		repoErr      error
+		expectedCode int
+	}{
+		{name: "Known user", user: &models.User{Username: "validuser"}, expectedCode: http.StatusOK},
+		{name: "Unknown user", user: nil, expectedCode: http.StatusNotFound},
+		{name: "Repository failure", user: nil, repoErr: errors.New("connection refused"), expectedCode: http.StatusInternalServerError},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			authService, mocks := newAdminAuthService()
+			mocks.repository.On("GetUserByName", mock.Anything, "validuser").Return(tt.user, tt.repoErr)
+
+			handler := handlers.NewAdminHandler(authService, slog.Default(), tests.NoopTracer())
+			router := gin.New()
+			router.POST("/admin/users/:username/unlock", handler.UnlockUser)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/users/validuser/unlock", nil))
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
+
+func TestListUsers_Pagination(t *testing.T) {
+	authService, mocks := newAdminAuthService()
+
+	mocks.repository.On("ListUsers", mock.Anything, "valid", 3, 0).Return([]models.UserSummary{
+		{Username: "valid1"}, {Username: "valid2"}, {Username: "valid3"},
+	}, nil)
+
+	users, hasMore, err := authService.ListUsers(context.Background(), " valid ", 2, -1)
+
+	require.NoError(t, err)
+	assert.True(t, hasMore)
+	assert.Len(t, users, 2)
+}
+
+func TestBootstrapAdmins(t *testing.T) {
+	authService, mocks := newAdminAuthService()
+
+	mocks.repository.On("GetUserByName", mock.Anything, "founder").Return(&models.User{Username: "founder", Role: models.RoleUser}, nil)
+	mocks.repository.On("GetUserByName", mock.Anything, "already").Return(&models.User{Username: "already", Role: models.RoleAdmin}, nil)
+	mocks.repository.On("GetUserByName", mock.Anything, "missing").Return((*models.User)(nil), nil)
+	mocks.repository.On("SetRole", mock.Anything, "founder", models.RoleAdmin).Return(nil)
+
+	authService.BootstrapAdmins(context.Background(), []string{"founder", "already", "missing"})
+
+	mocks.repository.AssertExpectations(t)
+	mocks.repository.AssertNumberOfCalls(t, "SetRole", 1)
+}
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	handler := handlers.NewAuthHandler(nil, slog.Default(), tests.NoopTracer())
+
+	var ts = []struct {
+		role         string
+		required     models.Role
+		expectedCode int
+	}{
+		{string(models.RoleModerator), models.RoleModerator, http.StatusOK},
+		{string(models.RoleAdmin), models.RoleModerator, http.StatusOK},
+		{string(models.RoleModerator), models.RoleAdmin, http.StatusForbidden},
+		{string(models.RoleUser), models.RoleModerator, http.StatusForbidden},
+		// API keys carry no role.
+		{"", models.RoleModerator, http.StatusForbidden},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.role+" needs "+string(tt.required), func(t *testing.T) {
+			router := gin.New()
+			router.GET("/admin",
+				func(c *gin.Context) { c.Set("role", tt.role) },
+				handler.RequireRole(tt.required),
+				func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+		})
+	}
+}
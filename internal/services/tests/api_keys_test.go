@@ -0,0 +1,324 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"massager/app/tests"
+	"massager/internal/handlers"
+	"massager/internal/models"
+	"massager/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newAPIKeyAuthService(mockRepository *tests.MockRepository, apiKeyRepository *tests.MockAPIKeyRepository) *services.AuthService {
+	authService := services.NewAuthService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+		&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	authService.SetAPIKeys(apiKeyRepository)
+	return authService
+}
+
+func TestCreateAPIKey_StoresOnlyTheHash(t *testing.T) {
+	mockRepository := &tests.MockRepository{}
+	apiKeyRepository := &tests.MockAPIKeyRepository{}
+	authService := newAPIKeyAuthService(mockRepository, apiKeyRepository)
+
+	var stored models.APIKey
+	apiKeyRepository.On("CreateAPIKey", mock.Anything, mock.AnythingOfType("models.APIKey")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(models.APIKey)
+	}).Return(7, nil)
+
+	rawKey, key, err := authService.CreateAPIKey(context.Background(), "validuser", models.NewAPIKey{
+		Name:   " CI ",
+		Scopes: []string{models.ScopeMessagesWrite, models.ScopeChatsRead, models.ScopeMessagesWrite},
+	})
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(rawKey, services.APIKeyPrefix))
+	assert.Equal(t, 7, key.ID)
+	assert.Equal(t, "validuser", stored.Username)
+	assert.Equal(t, "CI", stored.Name)
+	assert.Equal(t, []string{models.ScopeChatsRead, models.ScopeMessagesWrite}, stored.Scopes)
+	assert.True(t, strings.HasPrefix(rawKey, stored.Prefix))
+
+	sum := sha256.Sum256([]byte(rawKey))
+	assert.Equal(t, hex.EncodeToString(sum[:]), stored.KeyHash)
+}
+
+func TestCreateAPIKey_TableDrive(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+
+	var ts = []struct {
+		name          string
+		request       models.NewAPIKey
+		setupMocks    func(*tests.MockRepository)
+		expectedError error
+	}{
+		{
+			name:          "Missing name",
+			request:       models.NewAPIKey{Scopes: []string{models.ScopeChatsRead}},
+			expectedError: services.ErrInvalidAPIKeyRequest,
+		},
+		{
+			name:          "No scopes",
+			request:       models.NewAPIKey{Name: "ci"},
+			expectedError: services.ErrInvalidAPIKeyRequest,
+		},
+		{
+			name:          "Unknown scope",
+			request:       models.NewAPIKey{Name: "ci", Scopes: []string{"admin"}},
+			expectedError: services.ErrInvalidAPIKeyRequest,
+		},
+		{
+			name:          "Expiry in the past",
+			request:       models.NewAPIKey{Name: "ci", Scopes: []string{models.ScopeChatsRead}, ExpiresAt: &past},
+			expectedError: services.ErrInvalidAPIKeyRequest,
+		},
+		{
+			name:    "Bot of another user",
+			request: models.NewAPIKey{Name: "ci", Scopes: []string{models.ScopeChatsRead}, Bot: "otherbot"},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByName", mock.Anything, "otherbot").Return(&models.User{Username: "otherbot", IsBot: true, BotOwner: "otheruser"}, nil)
+			},
+			expectedError: services.ErrBotNotFound,
+		},
+		{
+			name:    "Human is not a bot",
+			request: models.NewAPIKey{Name: "ci", Scopes: []string{models.ScopeChatsRead}, Bot: "otheruser"},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByName", mock.Anything, "otheruser").Return(&models.User{Username: "otheruser"}, nil)
+			},
+			expectedError: services.ErrBotNotFound,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := &tests.MockRepository{}
+			apiKeyRepository := &tests.MockAPIKeyRepository{}
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockRepository)
+			}
+			authService := newAPIKeyAuthService(mockRepository, apiKeyRepository)
+
+			_, _, err := authService.CreateAPIKey(context.Background(), "validuser", tt.request)
+
+			assert.ErrorIs(t, err, tt.expectedError)
+			apiKeyRepository.AssertNotCalled(t, "CreateAPIKey", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestCreateAPIKey_ForOwnBot(t *testing.T) {
+	mockRepository := &tests.MockRepository{}
+	apiKeyRepository := &tests.MockAPIKeyRepository{}
+	authService := newAPIKeyAuthService(mockRepository, apiKeyRepository)
+
+	mockRepository.On("GetUserByName", mock.Anything, "deploybot").Return(&models.User{Username: "deploybot", IsBot: true, BotOwner: "validuser"}, nil)
+	apiKeyRepository.On("CreateAPIKey", mock.Anything, mock.MatchedBy(func(key models.APIKey) bool {
+		return key.Username == "deploybot"
+	})).Return(1, nil)
+
+	_, key, err := authService.CreateAPIKey(context.Background(), "validuser", models.NewAPIKey{
+		Name: "deploys", Scopes: []string{models.ScopeMessagesWrite}, Bot: "deploybot",
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, "deploybot", key.Username)
+	apiKeyRepository.AssertExpectations(t)
+}
+
+func TestValidateAPIKey_TableDrive(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	recentlyUsed := time.Now()
+
+	var ts = []struct {
+		name          string
+		key           *models.APIKey
+		expectTouch   bool
+		expectedError error
+	}{
+		{
+			name:        "Valid key",
+			key:         &models.APIKey{ID: 3, Username: "deploybot", Scopes: []string{models.ScopeChatsRead}, ExpiresAt: &future},
+			expectTouch: true,
+		},
+		{
+			name: "Recently used key is not touched again",
+			key:  &models.APIKey{ID: 3, Username: "deploybot", Scopes: []string{models.ScopeChatsRead}, LastUsedAt: &recentlyUsed},
+		},
+		{
+			name:          "Unknown key",
+			expectedError: services.ErrInvalidAPIKey,
+		},
+		{
+			name:          "Revoked key",
+			key:           &models.APIKey{ID: 3, Username: "deploybot", RevokedAt: &past},
+			expectedError: services.ErrInvalidAPIKey,
+		},
+		{
+			name:          "Expired key",
+			key:           &models.APIKey{ID: 3, Username: "deploybot", ExpiresAt: &past},
+			expectedError: services.ErrInvalidAPIKey,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			apiKeyRepository := &tests.MockAPIKeyRepository{}
+			authService := newAPIKeyAuthService(&tests.MockRepository{}, apiKeyRepository)
+
+			rawKey := services.APIKeyPrefix + "secret"
+			sum := sha256.Sum256([]byte(rawKey))
+			apiKeyRepository.On("GetAPIKeyByHash", mock.Anything, hex.EncodeToString(sum[:])).Return(tt.key, nil)
+			if tt.expectTouch {
+				apiKeyRepository.On("TouchAPIKey", mock.Anything, tt.key.ID, mock.AnythingOfType("time.Time")).Return(nil)
+			}
+
+			claims, err := authService.Authenticate(context.Background(), rawKey)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "deploybot", claims.Username)
+			assert.True(t, claims.HasScope(models.ScopeChatsRead))
+			assert.False(t, claims.HasScope(models.ScopeMessagesWrite))
+			apiKeyRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAuthMiddleware_APIKeyScopes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	apiKeyRepository := &tests.MockAPIKeyRepository{}
+	authService := newAPIKeyAuthService(&tests.MockRepository{}, apiKeyRepository)
+	handler := handlers.NewAuthHandler(authService, slog.Default(), tests.NoopTracer())
+
+	recentlyUsed := time.Now()
+	apiKeyRepository.On("GetAPIKeyByHash", mock.Anything, mock.Anything).Return(&models.APIKey{
+		ID: 3, Username: "deploybot", Scopes: []string{models.ScopeChatsRead}, LastUsedAt: &recentlyUsed,
+	}, nil)
+
+	router := gin.New()
+	ok := func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"username": c.GetString("username")}) }
+	router.GET("/chats", handler.AuthMiddleware(models.ScopeChatsRead), ok)
+	router.POST("/chats", handler.AuthMiddleware(models.ScopeChatsWrite), ok)
+	router.GET("/sessions", handler.AuthMiddleware(), ok)
+
+	var ts = []struct {
+		method       string
+		path         string
+		expectedCode int
+	}{
+		{http.MethodGet, "/chats", http.StatusOK},
+		{http.MethodPost, "/chats", http.StatusForbidden},
+		{http.MethodGet, "/sessions", http.StatusForbidden},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("Authorization", "Bearer "+services.APIKeyPrefix+"secret")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), "deploybot")
+			}
+		})
+	}
+}
+
+func TestCreateBot_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name          string
+		username      string
+		setupMocks    func(*tests.MockRepository)
+		expectedError error
+	}{
+		{
+			name:     "Valid bot",
+			username: "deploy-bot",
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByName", mock.Anything, "deploy-bot").Return((*models.User)(nil), nil)
+				mr.On("GetBots", mock.Anything, "validuser").Return([]models.Profile{}, nil)
+				mr.On("CreateBot", mock.Anything, "validuser", "deploy-bot", "Deploy Bot").Return(nil)
+			},
+		},
+		{
+			name:          "Invalid characters",
+			username:      "Deploy Bot",
+			expectedError: services.ErrInvalidBot,
+		},
+		{
+			name:          "Reserved prefix",
+			username:      "deleted-1",
+			expectedError: services.ErrUsernameTaken,
+		},
+		{
+			name:     "Username taken",
+			username: "validuser",
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByName", mock.Anything, "validuser").Return(&models.User{Username: "validuser"}, nil)
+			},
+			expectedError: services.ErrUsernameTaken,
+		},
+		{
+			name:     "Too many bots",
+			username: "deploy-bot",
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByName", mock.Anything, "deploy-bot").Return((*models.User)(nil), nil)
+				mr.On("GetBots", mock.Anything, "validuser").Return(make([]models.Profile, 10), nil)
+			},
+			expectedError: services.ErrBotLimitReached,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := &tests.MockRepository{}
+			if tt.setupMocks != nil {
+				tt.setupMocks(mockRepository)
+			}
+			authService := newAPIKeyAuthService(mockRepository, &tests.MockAPIKeyRepository{})
+
+			bot, err := authService.CreateBot(context.Background(), "validuser", tt.username, " Deploy Bot ")
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mockRepository.AssertNotCalled(t, "CreateBot", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, bot.IsBot)
+			mockRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeAPIKey_NotOwned(t *testing.T) {
+	apiKeyRepository := &tests.MockAPIKeyRepository{}
+	authService := newAPIKeyAuthService(&tests.MockRepository{}, apiKeyRepository)
+
+	apiKeyRepository.On("RevokeAPIKey", mock.Anything, 9, "validuser").Return(false, nil)
+
+	err := authService.RevokeAPIKey(context.Background(), "validuser", 9)
+
+	assert.ErrorIs(t, err, services.ErrAPIKeyNotFound)
+}
@@ -0,0 +1,248 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"massager/app/tests"
+	"massager/internal/models"
+	"massager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newInviteChatService(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, invites *tests.MockChatInviteRepository) *services.ChatService {
+	service := services.NewChatService(chatRepo, &tests.MockMessageRepository{}, userRepo, slog.Default(), tests.NoopTracer())
+	service.SetInvites(invites)
+	return service
+}
+
+func inviteTokenHash(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestCreateInvite_StoresOnlyTheHash(t *testing.T) {
+	chatRepo := &tests.MockChatRepository{}
+	invites := &tests.MockChatInviteRepository{}
+	service := newInviteChatService(chatRepo, &tests.MockRepository{}, invites)
+
+	chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil)
+
+	var stored models.ChatInvite
+	invites.On("CreateInvite", mock.Anything, mock.AnythingOfType("models.ChatInvite")).Run(func(args mock.Arguments) {
+		stored = args.Get(1).(models.ChatInvite)
+	}).Return(5, nil)
+
+	expiresAt := time.Now().Add(time.Hour)
+	token, invite, err := service.CreateInvite(context.Background(), 42, "admin", models.NewChatInvite{ExpiresAt: &expiresAt, MaxUses: 3})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, invite.ID)
+	assert.Equal(t, inviteTokenHash(token), stored.TokenHash)
+	assert.Equal(t, token[:len(stored.Prefix)], stored.Prefix)
+	assert.Equal(t, "admin", stored.CreatedBy)
+	assert.Equal(t, 3, stored.MaxUses)
+	assert.Equal(t, &expiresAt, stored.ExpiresAt)
+}
+
+func TestCreateInvite_Rejected(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	ts := []struct {
+		name          string
+		actor         string
+		request       models.NewChatInvite
+		expectedError error
+	}{
+		{
+			name:          "Member may not create invites",
+			actor:         "member",
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "Negative max uses",
+			actor:         "owner",
+			request:       models.NewChatInvite{MaxUses: -1},
+			expectedError: services.ErrInvalidInviteRequest,
+		},
+		{
+			name:          "Expiry in the past",
+			actor:         "owner",
+			request:       models.NewChatInvite{ExpiresAt: &past},
+			expectedError: services.ErrInvalidInviteRequest,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			invites := &tests.MockChatInviteRepository{}
+			service := newInviteChatService(chatRepo, &tests.MockRepository{}, invites)
+
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil).Maybe()
+
+			_, _, err := service.CreateInvite(context.Background(), 42, tt.actor, tt.request)
+			assert.ErrorIs(t, err, tt.expectedError)
+			invites.AssertNotCalled(t, "CreateInvite", mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestJoinByInvite(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	revokedAt := time.Now().Add(-time.Hour)
+
+	ts := []struct {
+		name          string
+		username      string
+		invite        *models.ChatInvite
+		used          bool
+		expectAdd     bool
+		expectedError error
+	}{
+		{
+			name:      "Joins through the shared membership path",
+			username:  "newbie",
+			invite:    &models.ChatInvite{ID: 5, ChatID: 42, MaxUses: 2, Uses: 1},
+			used:      true,
+			expectAdd: true,
+		},
+		{
+			name:          "Unknown token",
+			username:      "newbie",
+			expectedError: services.ErrInvalidInvite,
+		},
+		{
+			name:          "Expired invite",
+			username:      "newbie",
+			invite:        &models.ChatInvite{ID: 5, ChatID: 42, ExpiresAt: &past},
+			expectedError: services.ErrInvalidInvite,
+		},
+		{
+			name:          "Revoked invite",
+			username:      "newbie",
+			invite:        &models.ChatInvite{ID: 5, ChatID: 42, RevokedAt: &revokedAt},
+			expectedError: services.ErrInvalidInvite,
+		},
+		{
+			name:          "Used up invite",
+			username:      "newbie",
+			invite:        &models.ChatInvite{ID: 5, ChatID: 42, MaxUses: 1, Uses: 1},
+			expectedError: services.ErrInvalidInvite,
+		},
+		{
+			name:          "Last use taken by a concurrent join",
+			username:      "newbie",
+			invite:        &models.ChatInvite{ID: 5, ChatID: 42, MaxUses: 1},
+			used:          false,
+			expectedError: services.ErrInvalidInvite,
+		},
+		{
+			name:          "Already a member",
+			username:      "member",
+			invite:        &models.ChatInvite{ID: 5, ChatID: 42},
+			expectedError: services.ErrAlreadyChatMember,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			userRepo := &tests.MockRepository{}
+			invites := &tests.MockChatInviteRepository{}
+			service := newInviteChatService(chatRepo, userRepo, invites)
+
+			invites.On("GetInviteByHash", mock.Anything, inviteTokenHash("secret")).Return(tt.invite, nil)
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil).Maybe()
+			userRepo.On("GetUserByName", mock.Anything, tt.username).Return(&models.User{Username: tt.username}, nil).Maybe()
+			invites.On("UseInvite", mock.Anything, 5, tt.username, mock.AnythingOfType("time.Time")).Return(tt.used, nil).Maybe()
+
+			chat, err := service.JoinByInvite(context.Background(), "secret", tt.username)
+
+			assert.Equal(t, tt.expectedError, err)
+			if tt.expectAdd {
+				require.NotNil(t, chat)
+				assert.Contains(t, chat.Members, tt.username)
+				assert.Equal(t, models.ChatRoleMember, chat.Role(tt.username))
+				invites.AssertCalled(t, "UseInvite", mock.Anything, 5, tt.username, mock.AnythingOfType("time.Time"))
+			} else {
+				assert.Nil(t, chat)
+			}
+			// The membership is stored by the invite repository together with the use.
+			chatRepo.AssertNotCalled(t, "AddMembers", mock.Anything, mock.Anything, mock.Anything)
+			chatRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestJoinByInvite_UnknownUserDoesNotUseInvite(t *testing.T) {
+	chatRepo := &tests.MockChatRepository{}
+	userRepo := &tests.MockRepository{}
+	invites := &tests.MockChatInviteRepository{}
+	service := newInviteChatService(chatRepo, userRepo, invites)
+
+	invites.On("GetInviteByHash", mock.Anything, inviteTokenHash("secret")).Return(&models.ChatInvite{ID: 5, ChatID: 42, MaxUses: 1}, nil)
+	chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil)
+	userRepo.On("GetUserByName", mock.Anything, "ghost").Return((*models.User)(nil), nil)
+
+	_, err := service.JoinByInvite(context.Background(), "secret", "ghost")
+
+	assert.ErrorIs(t, err, services.ErrUserNotFound)
+	invites.AssertNotCalled(t, "UseInvite", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestJoinByInvite_ConcurrentJoinIsAConflict(t *testing.T) {
+	chatRepo := &tests.MockChatRepository{}
+	userRepo := &tests.MockRepository{}
+	invites := &tests.MockChatInviteRepository{}
+	service := newInviteChatService(chatRepo, userRepo, invites)
+
+	joined := testChatWithRoles()
+	joined.Members = append(joined.Members, "newbie")
+
+	invites.On("GetInviteByHash", mock.Anything, inviteTokenHash("secret")).Return(&models.ChatInvite{ID: 5, ChatID: 42}, nil)
+	chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil).Once()
+	userRepo.On("GetUserByName", mock.Anything, "newbie").Return(&models.User{Username: "newbie"}, nil)
+	// Another request added the user between the membership check and the use.
+	invites.On("UseInvite", mock.Anything, 5, "newbie", mock.AnythingOfType("time.Time")).Return(false, nil)
+	chatRepo.On("GetChatByID", mock.Anything, 42).Return(joined, nil)
+
+	_, err := service.JoinByInvite(context.Background(), "secret", "newbie")
+
+	assert.Equal(t, services.ErrAlreadyChatMember, err)
+}
+
+func TestPreviewInvite(t *testing.T) {
+	chatRepo := &tests.MockChatRepository{}
+	invites := &tests.MockChatInviteRepository{}
+	service := newInviteChatService(chatRepo, &tests.MockRepository{}, invites)
+
+	invites.On("GetInviteByHash", mock.Anything, inviteTokenHash("secret")).Return(&models.ChatInvite{ID: 5, ChatID: 42}, nil)
+	chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil)
+
+	preview, err := service.PreviewInvite(context.Background(), "secret")
+	require.NoError(t, err)
+	assert.Equal(t, "Team", preview.Name)
+	assert.Equal(t, 3, preview.MemberCount)
+	invites.AssertNotCalled(t, "UseInvite", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRevokeInvite(t *testing.T) {
+	chatRepo := &tests.MockChatRepository{}
+	invites := &tests.MockChatInviteRepository{}
+	service := newInviteChatService(chatRepo, &tests.MockRepository{}, invites)
+
+	chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil)
+	invites.On("RevokeInvite", mock.Anything, 42, 5).Return(true, nil)
+	invites.On("RevokeInvite", mock.Anything, 42, 6).Return(false, nil)
+
+	assert.NoError(t, service.RevokeInvite(context.Background(), 42, "admin", 5))
+	assert.Equal(t, services.ErrInviteNotFound, service.RevokeInvite(context.Background(), 42, "admin", 6))
+	assert.Equal(t, services.ErrChatPermission, service.RevokeInvite(context.Background(), 42, "member", 5))
+}
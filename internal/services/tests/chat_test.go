@@ -10,6 +10,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestChat_CreateChat(t *testing.T) {
@@ -19,6 +20,7 @@ func TestChat_CreateChat(t *testing.T) {
 	ts := []struct {
 		name          string
 		chatName      string
+		createdBy     string
 		memberIDs     []string
 		setupMocks    func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository)
 		expectedID    int
@@ -27,20 +29,36 @@ func TestChat_CreateChat(t *testing.T) {
 		{
 			name:      "Successful chat creation",
 			chatName:  "Test Chat",
+			createdBy: "user1",
 			memberIDs: []string{"user1", "user2", "user3"},
 			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
-				userRepo.On("GetUserByName", ctx, "user1").Return(&models.User{Username: "user1"}, nil)
-				userRepo.On("GetUserByName", ctx, "user2").Return(&models.User{Username: "user2"}, nil)
-				userRepo.On("GetUserByName", ctx, "user3").Return(&models.User{Username: "user3"}, nil)
+				userRepo.On("GetUserByName", mock.Anything, "user1").Return(&models.User{Username: "user1"}, nil)
+				userRepo.On("GetUserByName", mock.Anything, "user2").Return(&models.User{Username: "user2"}, nil)
+				userRepo.On("GetUserByName", mock.Anything, "user3").Return(&models.User{Username: "user3"}, nil)
 
-				chatRepo.On("CreateChat", ctx, "Test Chat", []string{"user1", "user2", "user3"}).Return(123, nil)
+				chatRepo.On("CreateChat", mock.Anything, "Test Chat", "user1", []string{"user1", "user2", "user3"}).Return(123, nil)
 			},
 			expectedID:    123,
 			expectedError: nil,
 		},
+		{
+			name:      "Creator is added to the members",
+			chatName:  "Test Chat",
+			createdBy: "user1",
+			memberIDs: []string{"user2"},
+			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
+				userRepo.On("GetUserByName", mock.Anything, "user1").Return(&models.User{Username: "user1"}, nil)
+				userRepo.On("GetUserByName", mock.Anything, "user2").Return(&models.User{Username: "user2"}, nil)
+
+				chatRepo.On("CreateChat", mock.Anything, "Test Chat", "user1", []string{"user1", "user2"}).Return(7, nil)
+			},
+			expectedID:    7,
+			expectedError: nil,
+		},
 		{
 			name:      "Empty chat name",
 			chatName:  "",
+			createdBy: "user1",
 			memberIDs: []string{"user1", "user2"},
 			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
 			},
@@ -50,6 +68,7 @@ func TestChat_CreateChat(t *testing.T) {
 		{
 			name:      "Not enough participants",
 			chatName:  "Test Chat",
+			createdBy: "user1",
 			memberIDs: []string{"user1"},
 			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
 			},
@@ -59,10 +78,11 @@ func TestChat_CreateChat(t *testing.T) {
 		{
 			name:      "User not found",
 			chatName:  "Test Chat",
+			createdBy: "user1",
 			memberIDs: []string{"user1", "user2"},
 			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
-				userRepo.On("GetUserByName", ctx, "user1").Return(&models.User{Username: "user1"}, nil)
-				userRepo.On("GetUserByName", ctx, "user2").Return((*models.User)(nil), nil)
+				userRepo.On("GetUserByName", mock.Anything, "user1").Return(&models.User{Username: "user1"}, nil)
+				userRepo.On("GetUserByName", mock.Anything, "user2").Return((*models.User)(nil), nil)
 			},
 			expectedID:    0,
 			expectedError: services.ErrUserNotFound,
@@ -70,11 +90,12 @@ func TestChat_CreateChat(t *testing.T) {
 		{
 			name:      "Error creating chat in repository",
 			chatName:  "Test Chat",
+			createdBy: "user1",
 			memberIDs: []string{"user1", "user2"},
 			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
-				userRepo.On("GetUserByName", ctx, "user1").Return(&models.User{Username: "user1"}, nil)
-				userRepo.On("GetUserByName", ctx, "user2").Return(&models.User{Username: "user2"}, nil)
-				chatRepo.On("CreateChat", ctx, "Test Chat", []string{"user1", "user2"}).Return(0, errors.New("db error"))
+				userRepo.On("GetUserByName", mock.Anything, "user1").Return(&models.User{Username: "user1"}, nil)
+				userRepo.On("GetUserByName", mock.Anything, "user2").Return(&models.User{Username: "user2"}, nil)
+				chatRepo.On("CreateChat", mock.Anything, "Test Chat", "user1", []string{"user1", "user2"}).Return(0, errors.New("db error"))
 			},
 			expectedID:    0,
 			expectedError: errors.New("db error"),
@@ -92,7 +113,7 @@ func TestChat_CreateChat(t *testing.T) {
 			tt.setupMocks(chatRepo, userRepo, messageRepo)
 
 			service := services.NewChatService(chatRepo, messageRepo, userRepo, logger, tests.NoopTracer())
-			chatID, err := service.CreateChat(ctx, tt.chatName, tt.memberIDs)
+			chatID, err := service.CreateChat(ctx, tt.chatName, tt.createdBy, tt.memberIDs)
 
 			assert.Equal(t, tt.expectedID, chatID)
 			assert.Equal(t, tt.expectedError, err)
@@ -119,17 +140,19 @@ func TestChatService_GetUserChats(t *testing.T) {
 			name:   "Successfully retrieved user chats",
 			userID: "user1",
 			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
-				userRepo.On("GetUserByName", ctx, "user1").Return(&models.User{Username: "user1"}, nil)
+				userRepo.On("GetUserByName", mock.Anything, "user1").Return(&models.User{Username: "user1"}, nil)
 
 				expectedChats := &[]models.Chat{
 					{ID: 1, Name: "Chat 1", Members: []string{"user1", "user2"}},
 					{ID: 2, Name: "Chat 2", Members: []string{"user1", "user3"}},
+					{ID: 3, Kind: models.ChatKindDirect, Members: []string{"user1", "user4"}, DisplayNames: map[string]string{"user1": "User One", "user4": "User Four"}},
 				}
-				chatRepo.On("GetUserChats", ctx, "user1").Return(expectedChats, nil)
+				chatRepo.On("GetUserChats", mock.Anything, "user1").Return(expectedChats, nil)
 			},
 			expectedChats: []models.Chat{
-				{ID: 1, Name: "Chat 1", Members: []string{"user1", "user2"}},
-				{ID: 2, Name: "Chat 2", Members: []string{"user1", "user3"}},
+				{ID: 1, Name: "Chat 1", Title: "Chat 1", Members: []string{"user1", "user2"}},
+				{ID: 2, Name: "Chat 2", Title: "Chat 2", Members: []string{"user1", "user3"}},
+				{ID: 3, Kind: models.ChatKindDirect, Title: "User Four", Members: []string{"user1", "user4"}, DisplayNames: map[string]string{"user1": "User One", "user4": "User Four"}},
 			},
 			expectedError: nil,
 		},
@@ -145,7 +168,7 @@ func TestChatService_GetUserChats(t *testing.T) {
 			name:   "User not found",
 			userID: "unknown",
 			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
-				userRepo.On("GetUserByName", ctx, "unknown").Return((*models.User)(nil), nil)
+				userRepo.On("GetUserByName", mock.Anything, "unknown").Return((*models.User)(nil), nil)
 			},
 			expectedChats: nil,
 			expectedError: services.ErrUserNotFound,
@@ -154,8 +177,8 @@ func TestChatService_GetUserChats(t *testing.T) {
 			name:   "Repository error when retrieving chats",
 			userID: "user1",
 			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository, messageRepo *tests.MockMessageRepository) {
-				userRepo.On("GetUserByName", ctx, "user1").Return(&models.User{Username: "user1"}, nil)
-				chatRepo.On("GetUserChats", ctx, "user1").Return(&[]models.Chat{}, errors.New("db error"))
+				userRepo.On("GetUserByName", mock.Anything, "user1").Return(&models.User{Username: "user1"}, nil)
+				chatRepo.On("GetUserChats", mock.Anything, "user1").Return(&[]models.Chat{}, errors.New("db error"))
 			},
 			expectedChats: nil,
 			expectedError: errors.New("db error"),
@@ -182,3 +205,757 @@ func TestChatService_GetUserChats(t *testing.T) {
 		})
 	}
 }
+
+func testChatWithRoles() *models.Chat {
+	return &models.Chat{
+		ID:      42,
+		Name:    "Team",
+		Members: []string{"owner", "admin", "member"},
+		Roles: map[string]models.ChatRole{
+			"owner":  models.ChatRoleOwner,
+			"admin":  models.ChatRoleAdmin,
+			"member": models.ChatRoleMember,
+		},
+	}
+}
+
+func TestChatService_DeleteChat(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	ts := []struct {
+		name          string
+		username      string
+		setupMocks    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository)
+		expectedError error
+	}{
+		{
+			name:     "Owner deletes the chat",
+			username: "owner",
+			setupMocks: func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {
+				chatRepo.On("DeleteChat", mock.Anything, 42).Return(nil)
+				messageRepo.On("DeleteMessagesByChatID", mock.Anything, 42).Return(nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:          "Admin may not delete the chat",
+			username:      "admin",
+			setupMocks:    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "Member may not delete the chat",
+			username:      "member",
+			setupMocks:    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "Outsider may not delete the chat",
+			username:      "stranger",
+			setupMocks:    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrNotChatMember,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			userRepo := &tests.MockRepository{}
+			messageRepo := &tests.MockMessageRepository{}
+
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil)
+			tt.setupMocks(chatRepo, messageRepo)
+
+			service := services.NewChatService(chatRepo, messageRepo, userRepo, logger, tests.NoopTracer())
+			err := service.DeleteChat(ctx, 42, tt.username)
+
+			assert.Equal(t, tt.expectedError, err)
+			chatRepo.AssertExpectations(t)
+			messageRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChatService_SetMemberRole(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	ts := []struct {
+		name          string
+		actor         string
+		target        string
+		role          models.ChatRole
+		expectUpdate  bool
+		expectedError error
+	}{
+		{
+			name:         "Owner promotes a member",
+			actor:        "owner",
+			target:       "member",
+			role:         models.ChatRoleAdmin,
+			expectUpdate: true,
+		},
+		{
+			name:         "Owner demotes an admin",
+			actor:        "owner",
+			target:       "admin",
+			role:         models.ChatRoleMember,
+			expectUpdate: true,
+		},
+		{
+			name:   "Unchanged role is a no-op",
+			actor:  "owner",
+			target: "admin",
+			role:   models.ChatRoleAdmin,
+		},
+		{
+			name:          "Admin may not manage roles",
+			actor:         "admin",
+			target:        "member",
+			role:          models.ChatRoleAdmin,
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "Owner role cannot be assigned",
+			actor:         "owner",
+			target:        "member",
+			role:          models.ChatRoleOwner,
+			expectedError: services.ErrInvalidChatRole,
+		},
+		{
+			name:          "Owner cannot demote themselves",
+			actor:         "owner",
+			target:        "owner",
+			role:          models.ChatRoleMember,
+			expectedError: services.ErrInvalidChatRole,
+		},
+		{
+			name:          "Target must be a member",
+			actor:         "owner",
+			target:        "stranger",
+			role:          models.ChatRoleAdmin,
+			expectedError: services.ErrNotChatMember,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			userRepo := &tests.MockRepository{}
+			messageRepo := &tests.MockMessageRepository{}
+
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil).Maybe()
+			if tt.expectUpdate {
+				chatRepo.On("SetMemberRole", mock.Anything, 42, tt.target, tt.role).Return(nil)
+			}
+
+			service := services.NewChatService(chatRepo, messageRepo, userRepo, logger, tests.NoopTracer())
+			err := service.SetMemberRole(ctx, 42, tt.actor, tt.target, tt.role)
+
+			assert.Equal(t, tt.expectedError, err)
+			chatRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChatService_TransferOwnership(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	ts := []struct {
+		name           string
+		actor          string
+		newOwner       string
+		expectTransfer bool
+		expectedError  error
+	}{
+		{
+			name:           "Owner hands the chat to a member",
+			actor:          "owner",
+			newOwner:       "member",
+			expectTransfer: true,
+		},
+		{
+			name:          "Admin may not transfer ownership",
+			actor:         "admin",
+			newOwner:      "member",
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "New owner must be a member",
+			actor:         "owner",
+			newOwner:      "stranger",
+			expectedError: services.ErrNotChatMember,
+		},
+		{
+			name:          "Owner cannot transfer to themselves",
+			actor:         "owner",
+			newOwner:      "owner",
+			expectedError: services.ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			userRepo := &tests.MockRepository{}
+			messageRepo := &tests.MockMessageRepository{}
+
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil).Maybe()
+			if tt.expectTransfer {
+				chatRepo.On("TransferOwnership", mock.Anything, 42, tt.newOwner).Return(nil)
+			}
+
+			service := services.NewChatService(chatRepo, messageRepo, userRepo, logger, tests.NoopTracer())
+			err := service.TransferOwnership(ctx, 42, tt.actor, tt.newOwner)
+
+			assert.Equal(t, tt.expectedError, err)
+			chatRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChatService_AddMembers(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	ts := []struct {
+		name          string
+		actor         string
+		usernames     []string
+		setupMocks    func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository)
+		expectedAdded []string
+		expectedError error
+	}{
+		{
+			name:      "Admin adds new users and skips existing members",
+			actor:     "admin",
+			usernames: []string{"newbie", "member", "newbie"},
+			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {
+				userRepo.On("GetUserByName", mock.Anything, "newbie").Return(&models.User{Username: "newbie"}, nil)
+				chatRepo.On("AddMembers", mock.Anything, 42, []string{"newbie"}).Return(nil)
+			},
+			expectedAdded: []string{"newbie"},
+		},
+		{
+			name:          "Member may not add users",
+			actor:         "member",
+			usernames:     []string{"newbie"},
+			setupMocks:    func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {},
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:      "Unknown user",
+			actor:     "owner",
+			usernames: []string{"ghost"},
+			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {
+				userRepo.On("GetUserByName", mock.Anything, "ghost").Return((*models.User)(nil), nil)
+			},
+			expectedError: services.ErrUserNotFound,
+		},
+		{
+			name:          "Everyone is already a member",
+			actor:         "owner",
+			usernames:     []string{"admin", "member"},
+			setupMocks:    func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {},
+			expectedError: services.ErrAlreadyChatMember,
+		},
+		{
+			name:          "No usernames",
+			actor:         "owner",
+			setupMocks:    func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {},
+			expectedError: services.ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			userRepo := &tests.MockRepository{}
+			messageRepo := &tests.MockMessageRepository{}
+
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil).Maybe()
+			tt.setupMocks(chatRepo, userRepo)
+
+			service := services.NewChatService(chatRepo, messageRepo, userRepo, logger, tests.NoopTracer())
+			added, err := service.AddMembers(ctx, 42, tt.actor, tt.usernames)
+
+			assert.Equal(t, tt.expectedAdded, added)
+			assert.Equal(t, tt.expectedError, err)
+			chatRepo.AssertExpectations(t)
+			userRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChatService_RemoveMember(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	ts := []struct {
+		name          string
+		actor         string
+		target        string
+		expectRemove  bool
+		expectedError error
+	}{
+		{
+			name:         "Owner removes an admin",
+			actor:        "owner",
+			target:       "admin",
+			expectRemove: true,
+		},
+		{
+			name:         "Admin removes a member",
+			actor:        "admin",
+			target:       "member",
+			expectRemove: true,
+		},
+		{
+			name:          "Admin may not remove the owner",
+			actor:         "admin",
+			target:        "owner",
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "Member may not remove anyone",
+			actor:         "member",
+			target:        "admin",
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "Target must be a member",
+			actor:         "owner",
+			target:        "stranger",
+			expectedError: services.ErrNotChatMember,
+		},
+		{
+			name:         "Removing yourself leaves the chat",
+			actor:        "member",
+			target:       "member",
+			expectRemove: true,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			userRepo := &tests.MockRepository{}
+			messageRepo := &tests.MockMessageRepository{}
+
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil)
+			if tt.expectRemove {
+				chatRepo.On("RemoveMember", mock.Anything, 42, tt.target).Return("", nil)
+			}
+
+			service := services.NewChatService(chatRepo, messageRepo, userRepo, logger, tests.NoopTracer())
+			err := service.RemoveMember(ctx, 42, tt.actor, tt.target)
+
+			assert.Equal(t, tt.expectedError, err)
+			chatRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChatService_LeaveChat(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	t.Run("Owner leaves and the chat gets a new owner", func(t *testing.T) {
+		chatRepo := &tests.MockChatRepository{}
+		messageRepo := &tests.MockMessageRepository{}
+
+		chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil)
+		chatRepo.On("RemoveMember", mock.Anything, 42, "owner").Return("admin", nil)
+
+		service := services.NewChatService(chatRepo, messageRepo, &tests.MockRepository{}, logger, tests.NoopTracer())
+		assert.NoError(t, service.LeaveChat(ctx, 42, "owner"))
+		chatRepo.AssertExpectations(t)
+	})
+
+	t.Run("Last member leaving deletes the chat", func(t *testing.T) {
+		chatRepo := &tests.MockChatRepository{}
+		messageRepo := &tests.MockMessageRepository{}
+
+		chat := &models.Chat{
+			ID:      42,
+			Members: []string{"owner"},
+			Roles:   map[string]models.ChatRole{"owner": models.ChatRoleOwner},
+		}
+		chatRepo.On("GetChatByID", mock.Anything, 42).Return(chat, nil)
+		chatRepo.On("DeleteChat", mock.Anything, 42).Return(nil)
+		messageRepo.On("DeleteMessagesByChatID", mock.Anything, 42).Return(nil)
+
+		service := services.NewChatService(chatRepo, messageRepo, &tests.MockRepository{}, logger, tests.NoopTracer())
+		assert.NoError(t, service.LeaveChat(ctx, 42, "owner"))
+		chatRepo.AssertExpectations(t)
+		messageRepo.AssertExpectations(t)
+	})
+
+	t.Run("Outsider cannot leave", func(t *testing.T) {
+		chatRepo := &tests.MockChatRepository{}
+
+		chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil)
+
+		service := services.NewChatService(chatRepo, &tests.MockMessageRepository{}, &tests.MockRepository{}, logger, tests.NoopTracer())
+		assert.Equal(t, services.ErrNotChatMember, service.LeaveChat(ctx, 42, "stranger"))
+	})
+}
+
+func testDirectChat() *models.Chat {
+	return &models.Chat{
+		ID:           7,
+		Kind:         models.ChatKindDirect,
+		Members:      []string{"alice", "bob"},
+		DisplayNames: map[string]string{"alice": "Alice", "bob": "Bob"},
+	}
+}
+
+func TestChatService_OpenDirectChat(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	ts := []struct {
+		name            string
+		username        string
+		other           string
+		setupMocks      func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository)
+		expectedTitle   string
+		expectedCreated bool
+		expectedError   error
+	}{
+		{
+			name:     "Creates the chat on first use",
+			username: "alice",
+			other:    "bob",
+			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {
+				userRepo.On("GetUserByName", mock.Anything, "bob").Return(&models.User{Username: "bob"}, nil)
+				chatRepo.On("GetOrCreateDirectChat", mock.Anything, "alice", "bob").Return(7, true, nil)
+				chatRepo.On("GetChatByID", mock.Anything, 7).Return(testDirectChat(), nil)
+			},
+			expectedTitle:   "Bob",
+			expectedCreated: true,
+		},
+		{
+			name:     "Returns the existing chat",
+			username: "bob",
+			other:    "alice",
+			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {
+				userRepo.On("GetUserByName", mock.Anything, "alice").Return(&models.User{Username: "alice"}, nil)
+				chatRepo.On("GetOrCreateDirectChat", mock.Anything, "bob", "alice").Return(7, false, nil)
+				chatRepo.On("GetChatByID", mock.Anything, 7).Return(testDirectChat(), nil)
+			},
+			expectedTitle: "Alice",
+		},
+		{
+			name:          "Cannot message yourself",
+			username:      "alice",
+			other:         "alice",
+			setupMocks:    func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {},
+			expectedError: services.ErrInvalidInput,
+		},
+		{
+			name:     "Unknown user",
+			username: "alice",
+			other:    "ghost",
+			setupMocks: func(chatRepo *tests.MockChatRepository, userRepo *tests.MockRepository) {
+				userRepo.On("GetUserByName", mock.Anything, "ghost").Return((*models.User)(nil), nil)
+			},
+			expectedError: services.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			userRepo := &tests.MockRepository{}
+
+			tt.setupMocks(chatRepo, userRepo)
+
+			service := services.NewChatService(chatRepo, &tests.MockMessageRepository{}, userRepo, logger, tests.NoopTracer())
+			chat, created, err := service.OpenDirectChat(ctx, tt.username, tt.other)
+
+			assert.Equal(t, tt.expectedError, err)
+			assert.Equal(t, tt.expectedCreated, created)
+			if tt.expectedError == nil {
+				assert.Equal(t, tt.expectedTitle, chat.Title)
+			}
+			chatRepo.AssertExpectations(t)
+			userRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChatService_DirectChatIsFixed(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	chatRepo := &tests.MockChatRepository{}
+	messageRepo := &tests.MockMessageRepository{}
+	chatRepo.On("GetChatByID", mock.Anything, 7).Return(testDirectChat(), nil)
+
+	service := services.NewChatService(chatRepo, messageRepo, &tests.MockRepository{}, logger, tests.NoopTracer())
+
+	_, err := service.AddMembers(ctx, 7, "alice", []string{"carol"})
+	assert.Equal(t, services.ErrDirectChat, err)
+	assert.Equal(t, services.ErrDirectChat, service.RemoveMember(ctx, 7, "alice", "bob"))
+	assert.Equal(t, services.ErrDirectChat, service.LeaveChat(ctx, 7, "bob"))
+	assert.Equal(t, services.ErrDirectChat, service.SetMemberRole(ctx, 7, "alice", "bob", models.ChatRoleAdmin))
+	assert.Equal(t, services.ErrDirectChat, service.TransferOwnership(ctx, 7, "alice", "bob"))
+	assert.Equal(t, services.ErrNotChatMember, service.DeleteChat(ctx, 7, "carol"))
+
+	chatRepo.On("DeleteChat", mock.Anything, 7).Return(nil)
+	messageRepo.On("DeleteMessagesByChatID", mock.Anything, 7).Return(nil)
+	assert.NoError(t, service.DeleteChat(ctx, 7, "bob"))
+}
+
+func TestChatService_UpdateChat(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	text := func(v string) *string { return &v }
+
+	ts := []struct {
+		name          string
+		actor         string
+		update        models.ChatUpdate
+		setupMocks    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository)
+		expectedName  string
+		expectedError error
+	}{
+		{
+			name:   "Admin renames the chat and sets a description",
+			actor:  "admin",
+			update: models.ChatUpdate{Name: text("  Crew "), Description: text("Weekly sync")},
+			setupMocks: func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {
+				chatRepo.On("UpdateChat", mock.Anything, 42, models.ChatUpdate{Name: text("Crew"), Description: text("Weekly sync")}).Return(nil)
+				messageRepo.On("CreateSystemMessage", mock.Anything, "admin", `admin renamed the chat to "Crew", changed the description`, 42).Return(nil)
+			},
+			expectedName: "Crew",
+		},
+		{
+			name:   "Only changed fields are written",
+			actor:  "owner",
+			update: models.ChatUpdate{Name: text("Team"), AvatarURL: text("https://cdn.example.com/team.png")},
+			setupMocks: func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {
+				chatRepo.On("UpdateChat", mock.Anything, 42, models.ChatUpdate{AvatarURL: text("https://cdn.example.com/team.png")}).Return(nil)
+				messageRepo.On("CreateSystemMessage", mock.Anything, "owner", "owner changed the avatar", 42).Return(nil)
+			},
+			expectedName: "Team",
+		},
+		{
+			name:         "Nothing changed",
+			actor:        "owner",
+			update:       models.ChatUpdate{Name: text("Team")},
+			setupMocks:   func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {},
+			expectedName: "Team",
+		},
+		{
+			name:          "Member may not edit the chat",
+			actor:         "member",
+			update:        models.ChatUpdate{Name: text("Mine")},
+			setupMocks:    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "Empty name",
+			actor:         "owner",
+			update:        models.ChatUpdate{Name: text("  ")},
+			setupMocks:    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrInvalidInput,
+		},
+		{
+			name:          "Avatar must be a web url",
+			actor:         "owner",
+			update:        models.ChatUpdate{AvatarURL: text("javascript:alert(1)")},
+			setupMocks:    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrInvalidInput,
+		},
+		{
+			name:          "Empty update",
+			actor:         "owner",
+			setupMocks:    func(chatRepo *tests.MockChatRepository, messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			messageRepo := &tests.MockMessageRepository{}
+
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil).Maybe()
+			tt.setupMocks(chatRepo, messageRepo)
+
+			service := services.NewChatService(chatRepo, messageRepo, &tests.MockRepository{}, logger, tests.NoopTracer())
+			chat, err := service.UpdateChat(ctx, 42, tt.actor, tt.update)
+
+			assert.ErrorIs(t, err, tt.expectedError)
+			if tt.expectedError == nil {
+				assert.Equal(t, tt.expectedName, chat.Name)
+				assert.Equal(t, tt.expectedName, chat.Title)
+			}
+			chatRepo.AssertExpectations(t)
+			messageRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChatService_PinMessage(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	ts := []struct {
+		name          string
+		actor         string
+		messageID     int
+		pinned        bool
+		setupMocks    func(messageRepo *tests.MockMessageRepository)
+		expectedError error
+	}{
+		{
+			name:      "Owner pins a message",
+			actor:     "owner",
+			messageID: 5,
+			pinned:    true,
+			setupMocks: func(messageRepo *tests.MockMessageRepository) {
+				messageRepo.On("SetMessagePinned", mock.Anything, 42, 5, "owner", true).Return(true, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:      "Admin unpins a message",
+			actor:     "admin",
+			messageID: 5,
+			pinned:    false,
+			setupMocks: func(messageRepo *tests.MockMessageRepository) {
+				messageRepo.On("SetMessagePinned", mock.Anything, 42, 5, "admin", false).Return(true, nil)
+			},
+			expectedError: nil,
+		},
+		{
+			name:          "Member may not pin a message",
+			actor:         "member",
+			messageID:     5,
+			pinned:        true,
+			setupMocks:    func(messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrChatPermission,
+		},
+		{
+			name:          "Outsider may not pin a message",
+			actor:         "stranger",
+			messageID:     5,
+			pinned:        true,
+			setupMocks:    func(messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrNotChatMember,
+		},
+		{
+			name:      "Message from another chat is not found",
+			actor:     "admin",
+			messageID: 9,
+			pinned:    true,
+			setupMocks: func(messageRepo *tests.MockMessageRepository) {
+				messageRepo.On("SetMessagePinned", mock.Anything, 42, 9, "admin", true).Return(false, nil)
+			},
+			expectedError: services.ErrMessageNotFound,
+		},
+		{
+			name:          "Invalid message ID",
+			actor:         "admin",
+			messageID:     0,
+			pinned:        true,
+			setupMocks:    func(messageRepo *tests.MockMessageRepository) {},
+			expectedError: services.ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			messageRepo := &tests.MockMessageRepository{}
+
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(testChatWithRoles(), nil).Maybe()
+			tt.setupMocks(messageRepo)
+
+			service := services.NewChatService(chatRepo, messageRepo, &tests.MockRepository{}, logger, tests.NoopTracer())
+			err := service.PinMessage(ctx, 42, tt.messageID, tt.actor, tt.pinned)
+
+			assert.Equal(t, tt.expectedError, err)
+			chatRepo.AssertExpectations(t)
+			messageRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChat_GetChatMessages(t *testing.T) {
+	logger := slog.Default()
+	ctx := context.Background()
+
+	ts := []struct {
+		name          string
+		username      string
+		removed       string
+		expectedError error
+	}{
+		{
+			name:     "Member reads the history",
+			username: "member",
+		},
+		{
+			name:          "Removed member",
+			username:      "member",
+			removed:       "member",
+			expectedError: services.ErrNotChatMember,
+		},
+		{
+			name:          "Never a member",
+			username:      "stranger",
+			expectedError: services.ErrNotChatMember,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			chatRepo := &tests.MockChatRepository{}
+			messageRepo := &tests.MockMessageRepository{}
+
+			chat := testChatWithRoles()
+			if tt.removed != "" {
+				chatRepo.On("RemoveMember", mock.Anything, 42, tt.removed).Return("", nil)
+			}
+			chatRepo.On("GetChatByID", mock.Anything, 42).Return(chat, nil)
+			if tt.expectedError == nil {
+				messageRepo.On("GetMessages", mock.Anything, 42, 50, 0).Return([]models.Message{{ChatID: 42, Content: "hello"}}, nil)
+			}
+
+			service := services.NewChatService(chatRepo, messageRepo, &tests.MockRepository{}, logger, tests.NoopTracer())
+			if tt.removed != "" {
+				assert.NoError(t, service.RemoveMember(ctx, 42, "owner", tt.removed))
+			}
+
+			messages, err := service.GetChatMessages(ctx, 42, tt.username, 0, 0)
+
+			assert.Equal(t, tt.expectedError, err)
+			if tt.expectedError == nil {
+				assert.Len(t, messages, 1)
+			} else {
+				messageRepo.AssertNotCalled(t, "GetMessages", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			chatRepo.AssertExpectations(t)
+			messageRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChat_GetChatMessagesOfUnknownChat(t *testing.T) {
+	chatRepo := &tests.MockChatRepository{}
+	messageRepo := &tests.MockMessageRepository{}
+	chatRepo.On("GetChatByID", mock.Anything, 404).Return((*models.Chat)(nil), nil)
+
+	service := services.NewChatService(chatRepo, messageRepo, &tests.MockRepository{}, slog.Default(), tests.NoopTracer())
+	_, err := service.GetChatMessages(context.Background(), 404, "member", 0, 0)
+
+	assert.Equal(t, services.ErrChatNotFound, err)
+	messageRepo.AssertNotCalled(t, "GetMessages", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,139 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"massager/app/config"
+	"massager/app/tests"
+	"massager/internal/adapters"
+	"massager/internal/models"
+	"massager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEmailService(outbox *tests.MockEmailOutboxRepository, transport *adapters.MemoryMailTransport) *services.EmailService {
+	return services.NewEmailService(config.EmailConfig{
+		Locale: "en",
+		Outbox: config.EmailOutboxConfig{MaxAttempts: 5, RetryBase: time.Minute, RetryMax: 3 * time.Minute},
+	}, "https://chat.example.com/", outbox, transport, slog.Default())
+}
+
+func TestEmailService_QueuesRenderedEmail(t *testing.T) {
+	outbox := &tests.MockEmailOutboxRepository{}
+	outbox.On("EnqueueEmail", mock.Anything, mock.MatchedBy(func(email models.OutboundEmail) bool {
+		return email.Recipient == "user@gmail.com" &&
+			email.Subject == "Verify Your Email Address" &&
+			assert.Contains(t, email.TextBody, "https://chat.example.com/api/auth/verify-email?token=abc") &&
+			assert.Contains(t, email.HTMLBody, "https://chat.example.com/api/auth/verify-email?token=abc")
+	})).Return(1, nil)
+
+	transport := adapters.NewMemoryMailTransport()
+	emailService := newTestEmailService(outbox, transport)
+
+	require.NoError(t, emailService.SendVerificationEmail("user@gmail.com", "abc"))
+	outbox.AssertExpectations(t)
+	// Nothing is delivered until the outbox is processed.
+	assert.Empty(t, transport.Sent())
+}
+
+func TestEmailService_QueueFailure(t *testing.T) {
+	outbox := &tests.MockEmailOutboxRepository{}
+	outbox.On("EnqueueEmail", mock.Anything, mock.Anything).Return(0, errors.New("db error"))
+
+	emailService := newTestEmailService(outbox, adapters.NewMemoryMailTransport())
+
+	assert.Error(t, emailService.SendPasswordResetEmail("user@gmail.com", "abc"))
+}
+
+func TestEmailService_ProcessOutbox(t *testing.T) {
+	ts := []struct {
+		name         string
+		attempts     int
+		transportErr error
+		setupMocks   func(outbox *tests.MockEmailOutboxRepository)
+		expectedSent int
+	}{
+		{
+			name:     "Delivered",
+			attempts: 1,
+			setupMocks: func(outbox *tests.MockEmailOutboxRepository) {
+				outbox.On("MarkEmailSent", mock.Anything, 7, mock.AnythingOfType("time.Time")).Return(nil)
+			},
+			expectedSent: 1,
+		},
+		{
+			name:         "Retried with backoff",
+			attempts:     2,
+			transportErr: errors.New("connection refused"),
+			setupMocks: func(outbox *tests.MockEmailOutboxRepository) {
+				outbox.On("RetryEmail", mock.Anything, 7, "connection refused", mock.MatchedBy(func(retryAt time.Time) bool {
+					delay := time.Until(retryAt)
+					return delay > 110*time.Second && delay <= 2*time.Minute
+				})).Return(nil)
+			},
+		},
+		{
+			name:         "Backoff is capped",
+			attempts:     4,
+			transportErr: errors.New("connection refused"),
+			setupMocks: func(outbox *tests.MockEmailOutboxRepository) {
+				outbox.On("RetryEmail", mock.Anything, 7, "connection refused", mock.MatchedBy(func(retryAt time.Time) bool {
+					delay := time.Until(retryAt)
+					return delay > 170*time.Second && delay <= 3*time.Minute
+				})).Return(nil)
+			},
+		},
+		{
+			name:         "Dead after the last attempt",
+			attempts:     5,
+			transportErr: errors.New("mailbox unavailable"),
+			setupMocks: func(outbox *tests.MockEmailOutboxRepository) {
+				outbox.On("MarkEmailDead", mock.Anything, 7, "mailbox unavailable").Return(nil)
+			},
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			email := models.OutboundEmail{ID: 7, Recipient: "user@gmail.com", Subject: "Hello", TextBody: "Hi", Attempts: tt.attempts}
+
+			outbox := &tests.MockEmailOutboxRepository{}
+			outbox.On("ClaimDueEmails", mock.Anything, mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Time"), 20).
+				Return([]models.OutboundEmail{email}, nil)
+			tt.setupMocks(outbox)
+
+			transport := adapters.NewMemoryMailTransport()
+			transport.SetError(tt.transportErr)
+
+			sent, err := newTestEmailService(outbox, transport).ProcessOutbox(context.Background())
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.expectedSent, sent)
+			assert.Len(t, transport.Sent(), tt.expectedSent)
+			outbox.AssertExpectations(t)
+		})
+	}
+}
+
+func TestEmailService_PurgeOutbox(t *testing.T) {
+	outbox := &tests.MockEmailOutboxRepository{}
+	outbox.On("DeleteFinishedEmails", mock.Anything, mock.MatchedBy(func(before time.Time) bool {
+		age := time.Since(before)
+		return age > 7*24*time.Hour-time.Minute && age <= 7*24*time.Hour+time.Minute
+	})).Return(int64(3), nil)
+
+	emailService := newTestEmailService(outbox, adapters.NewMemoryMailTransport())
+
+	purged, err := emailService.PurgeOutbox(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	outbox.AssertExpectations(t)
+}
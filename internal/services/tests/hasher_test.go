@@ -0,0 +1,63 @@
+package services_test
+
+import (
+	"massager/app/config"
+	"massager/internal/services"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Small parameters keep the tests fast; they are not meant for production.
+var testArgon2 = config.Argon2Config{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestArgon2idHasher(t *testing.T) {
+	hasher := services.NewArgon2idHasher(testArgon2)
+
+	hash, err := hasher.GenerateFromPassword([]byte("correctpassword"), hasher.DefaultCost())
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(hash), "$argon2id$v=19$m=1024,t=1,p=1$"))
+
+	assert.NoError(t, hasher.CompareHashAndPassword(hash, []byte("correctpassword")))
+	assert.ErrorIs(t, hasher.CompareHashAndPassword(hash, []byte("wrongpassword")), bcrypt.ErrMismatchedHashAndPassword)
+	assert.False(t, hasher.NeedsRehash(hash))
+
+	stronger := testArgon2
+	stronger.Iterations = 2
+	assert.True(t, services.NewArgon2idHasher(stronger).NeedsRehash(hash))
+
+	assert.ErrorIs(t, hasher.CompareHashAndPassword([]byte("$argon2id$broken"), []byte("correctpassword")), services.ErrUnknownHashFormat)
+}
+
+func TestPasswordHasher_MixedFormats(t *testing.T) {
+	hasher := services.NewPasswordHasher(config.PasswordHashingConfig{Algorithm: "argon2id", Argon2: testArgon2})
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte("correctpassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	assert.NoError(t, hasher.CompareHashAndPassword(legacy, []byte("correctpassword")))
+	assert.Error(t, hasher.CompareHashAndPassword(legacy, []byte("wrongpassword")))
+	assert.True(t, hasher.NeedsRehash(legacy))
+
+	current, err := hasher.GenerateFromPassword([]byte("correctpassword"), hasher.DefaultCost())
+	require.NoError(t, err)
+	assert.NoError(t, hasher.CompareHashAndPassword(current, []byte("correctpassword")))
+	assert.False(t, hasher.NeedsRehash(current))
+
+	assert.ErrorIs(t, hasher.CompareHashAndPassword([]byte("plaintext"), []byte("plaintext")), services.ErrUnknownHashFormat)
+}
+
+func TestPasswordHasher_BcryptCost(t *testing.T) {
+	hasher := services.NewPasswordHasher(config.PasswordHashingConfig{Algorithm: "bcrypt", BcryptCost: bcrypt.MinCost + 1})
+
+	weak, err := bcrypt.GenerateFromPassword([]byte("correctpassword"), bcrypt.MinCost)
+	require.NoError(t, err)
+	assert.True(t, hasher.NeedsRehash(weak))
+
+	current, err := hasher.GenerateFromPassword([]byte("correctpassword"), hasher.DefaultCost())
+	require.NoError(t, err)
+	assert.False(t, hasher.NeedsRehash(current))
+}
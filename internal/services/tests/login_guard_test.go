@@ -0,0 +1,207 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"massager/app/config"
+	"massager/app/tests"
+	"massager/internal/handlers"
+	"massager/internal/models"
+	"massager/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testLoginProtection = config.LoginProtectionConfig{
+	FailureWindow:   15 * time.Minute,
+	BackoffAfter:    3,
+	IPBackoffAfter:  10,
+	BackoffBase:     time.Second,
+	BackoffMax:      time.Minute,
+	LockoutAfter:    5,
+	LockoutDuration: 15 * time.Minute,
+}
+
+func TestLoginGuard_Check(t *testing.T) {
+	var ts = []struct {
+		name          string
+		lockedUntil   time.Time
+		backoffUntil  time.Time
+		expectedError error
+	}{
+		{
+			name: "No failures",
+		},
+		{
+			name:          "Backing off",
+			backoffUntil:  time.Now().Add(4 * time.Second),
+			expectedError: services.ErrTooManyAttempts,
+		},
+		{
+			name:          "Locked",
+			lockedUntil:   time.Now().Add(10 * time.Minute),
+			expectedError: services.ErrAccountLocked,
+		},
+		{
+			name:        "Expired lock",
+			lockedUntil: time.Now().Add(-time.Minute),
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			attempts := &tests.MockAttemptRepository{}
+			attempts.On("BlockedUntil", mock.Anything, "login_lock:user:validuser").Return(tt.lockedUntil, nil)
+			attempts.On("BlockedUntil", mock.Anything, "login_backoff:user:validuser").Return(tt.backoffUntil, nil).Maybe()
+			attempts.On("BlockedUntil", mock.Anything, "login_backoff:ip:10.0.0.1").Return(time.Time{}, nil).Maybe()
+
+			guard := services.NewLoginGuard(attempts, testLoginProtection, slog.Default())
+			err := guard.Check(context.Background(), "validuser", "10.0.0.1")
+
+			if tt.expectedError == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorIs(t, err, tt.expectedError)
+			var throttleErr *services.ThrottleError
+			assert.True(t, errors.As(err, &throttleErr))
+			assert.Greater(t, throttleErr.RetryAfter, time.Duration(0))
+		})
+	}
+}
+
+func TestLoginGuard_RecordFailure(t *testing.T) {
+	var ts = []struct {
+		name          string
+		failures      int64
+		expectBackoff time.Duration
+		expectLock    bool
+	}{
+		{
+			name:     "Below backoff threshold",
+			failures: 2,
+		},
+		{
+			name:          "First backoff",
+			failures:      3,
+			expectBackoff: time.Second,
+		},
+		{
+			name:          "Backoff doubles",
+			failures:      4,
+			expectBackoff: 2 * time.Second,
+		},
+		{
+			name:       "Lockout",
+			failures:   5,
+			expectLock: true,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			attempts := &tests.MockAttemptRepository{}
+			attempts.On("Increment", mock.Anything, "login:user:validuser", 15*time.Minute).Return(tt.failures, nil)
+			// The IP is counted on every failure, including the one that locks the account.
+			attempts.On("Increment", mock.Anything, "login:ip:10.0.0.1", 15*time.Minute).Return(int64(1), nil)
+
+			if tt.expectLock {
+				attempts.On("Block", mock.Anything, "login_lock:user:validuser", mock.MatchedBy(func(until time.Time) bool {
+					return time.Until(until) > 14*time.Minute
+				})).Return(nil)
+				attempts.On("Reset", mock.Anything, "login:user:validuser").Return(nil)
+			}
+
+			if tt.expectBackoff > 0 {
+				attempts.On("Block", mock.Anything, "login_backoff:user:validuser", mock.MatchedBy(func(until time.Time) bool {
+					wait := time.Until(until)
+					return wait > tt.expectBackoff-time.Second/2 && wait <= tt.expectBackoff
+				})).Return(nil)
+			}
+
+			guard := services.NewLoginGuard(attempts, testLoginProtection, slog.Default())
+			lockedUntil, err := guard.RecordFailure(context.Background(), "validuser", "10.0.0.1")
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectLock, !lockedUntil.IsZero())
+			attempts.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogin_Lockout(t *testing.T) {
+	hashedPassword, _ := bcrypt.GenerateFromPassword([]byte("correctpassword"), bcrypt.MinCost)
+	user := &models.User{Username: "validuser", Password: string(hashedPassword), Email: "valid@gmail.com", IsVerefied: true}
+
+	t.Run("Last failure locks the account and notifies the owner", func(t *testing.T) {
+		t.Parallel()
+
+		mockRepository := &tests.MockRepository{}
+		mockHasher := &tests.MockHasher{}
+		emailService := &tests.MockEmailService{}
+		attempts := &tests.MockAttemptRepository{}
+
+		attempts.On("BlockedUntil", mock.Anything, mock.AnythingOfType("string")).Return(time.Time{}, nil)
+		attempts.On("Increment", mock.Anything, "login:user:validuser", mock.AnythingOfType("time.Duration")).Return(int64(5), nil)
+		attempts.On("Increment", mock.Anything, "login:ip:10.0.0.1", mock.AnythingOfType("time.Duration")).Return(int64(1), nil)
+		attempts.On("Block", mock.Anything, "login_lock:user:validuser", mock.AnythingOfType("time.Time")).Return(nil)
+		attempts.On("Reset", mock.Anything, "login:user:validuser").Return(nil)
+		mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
+		mockHasher.On("CompareHashAndPassword", []byte(user.Password), []byte("wrongpassword")).Return(bcrypt.ErrMismatchedHashAndPassword)
+		emailService.On("SendAccountLockedEmail", "valid@gmail.com", mock.AnythingOfType("time.Time")).Return(nil)
+
+		var authService = services.NewAuthService(
+			mockRepository, emailService, mockHasher,
+			&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+			[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+		authService.SetLoginGuard(services.NewLoginGuard(attempts, testLoginProtection, slog.Default()))
+
+		_, err := authService.Login(context.Background(), "validuser", "wrongpassword", models.ClientInfo{IPAddress: "10.0.0.1"})
+		assert.EqualError(t, err, "invalid credentials")
+
+		attempts.AssertExpectations(t)
+		emailService.AssertExpectations(t)
+	})
+
+	t.Run("Locked account is rejected with 423", func(t *testing.T) {
+		t.Parallel()
+
+		mockRepository := &tests.MockRepository{}
+		attempts := &tests.MockAttemptRepository{}
+		attempts.On("BlockedUntil", mock.Anything, "login_lock:user:validuser").Return(time.Now().Add(10*time.Minute), nil)
+
+		var authService = services.NewAuthService(
+			mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+			&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+			[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+		authService.SetLoginGuard(services.NewLoginGuard(attempts, testLoginProtection, slog.Default()))
+
+		var handler = handlers.NewAuthHandler(authService, slog.Default(), tests.NoopTracer())
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = tests.CreateTestRequest("/login", http.MethodPost, map[string]interface{}{
+			"username": "validuser",
+			"password": "correctpassword",
+		})
+
+		handler.Login(c)
+
+		assert.Equal(t, http.StatusLocked, w.Code)
+		assert.NotEmpty(t, w.Header().Get("Retry-After"))
+		mockRepository.AssertNotCalled(t, "GetUserByName", mock.Anything, mock.Anything)
+	})
+}
@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -49,6 +50,30 @@ func TestLogin_TableDrive(t *testing.T) {
 				mur.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
 
 				mph.On("CompareHashAndPassword", []byte(user.Password), []byte("correctpassword")).Return(nil)
+				mph.On("NeedsRehash", []byte(user.Password)).Return(false)
+			},
+			expectedCode: http.StatusOK,
+			checkToken:   true,
+		},
+		{
+			name: "Outdated hash is upgraded",
+			requestBody: map[string]interface{}{
+				"username": "validuser",
+				"password": "correctpassword",
+			},
+			setupMocks: func(mur *tests.MockRepository, mph *tests.MockHasher) {
+				user := &models.User{
+					Username:   "validuser",
+					Password:   "$2a$04$legacyhash",
+					IsVerefied: true,
+				}
+				mur.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
+				mur.On("UpdatePassword", mock.Anything, "validuser", "$argon2id$new").Return(nil)
+
+				mph.On("CompareHashAndPassword", []byte(user.Password), []byte("correctpassword")).Return(nil)
+				mph.On("NeedsRehash", []byte(user.Password)).Return(true)
+				mph.On("DefaultCost").Return(3)
+				mph.On("GenerateFromPassword", []byte("correctpassword"), 3).Return([]byte("$argon2id$new"), nil)
 			},
 			expectedCode: http.StatusOK,
 			checkToken:   true,
@@ -86,6 +111,49 @@ func TestLogin_TableDrive(t *testing.T) {
 			expectedBody: "invalid credentials",
 			checkToken:   false,
 		},
+		{
+			name: "Two-factor authentication enabled",
+			requestBody: map[string]interface{}{
+				"username": "mfauser",
+				"password": "correctpassword",
+			},
+			setupMocks: func(mur *tests.MockRepository, mph *tests.MockHasher) {
+				user := &models.User{
+					Username:    "mfauser",
+					Password:    "hashed_password",
+					IsVerefied:  true,
+					TOTPSecret:  "JBSWY3DPEHPK3PXP",
+					TOTPEnabled: true,
+				}
+				mur.On("GetUserByName", mock.Anything, "mfauser").Return(user, nil)
+				mph.On("CompareHashAndPassword", []byte(user.Password), []byte("correctpassword")).Return(nil)
+				mph.On("NeedsRehash", []byte(user.Password)).Return(false)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"mfa_required":true`,
+			checkToken:   false,
+		},
+		{
+			name: "Banned user",
+			requestBody: map[string]interface{}{
+				"username": "banneduser",
+				"password": "correctpassword",
+			},
+			setupMocks: func(mur *tests.MockRepository, mph *tests.MockHasher) {
+				user := &models.User{
+					Username:   "banneduser",
+					Password:   "hashed_password",
+					IsVerefied: true,
+					BannedAt:   time.Now(),
+				}
+				mur.On("GetUserByName", mock.Anything, "banneduser").Return(user, nil)
+				mph.On("CompareHashAndPassword", []byte(user.Password), []byte("correctpassword")).Return(nil)
+				mph.On("NeedsRehash", []byte(user.Password)).Return(false)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: services.ErrAccountBanned.Error(),
+			checkToken:   false,
+		},
 		{
 			name: "User not verified",
 			requestBody: map[string]interface{}{
@@ -114,15 +182,23 @@ func TestLogin_TableDrive(t *testing.T) {
 			mockRepository := &tests.MockRepository{}
 			mockHasher := &tests.MockHasher{}
 			var tokenRepository ports.TokenRepository
+			refreshRepository := &tests.MockRefreshTokenRepository{}
+			sessionRepository := &tests.MockSessionRepository{}
 			emailService := &tests.MockEmailService{}
 			jwtKey := []byte(JwtKey)
 			logger := slog.Default()
 
 			tt.setupMocks(mockRepository, mockHasher)
+			if tt.checkToken {
+				sessionRepository.On("CreateSession", mock.Anything, mock.MatchedBy(func(session models.Session) bool {
+					return session.Username == "validuser" && session.ID != ""
+				})).Return(nil)
+				refreshRepository.On("SaveRefreshToken", mock.Anything, mock.AnythingOfType("models.RefreshToken")).Return(nil)
+			}
 
 			var authService = services.NewAuthService(
 				mockRepository, emailService, mockHasher,
-				tokenRepository, jwtKey, logger, tests.NoopTracer())
+				tokenRepository, refreshRepository, sessionRepository, jwtKey, logger, tests.NoopTracer())
 
 			var handler = handlers.NewAuthHandler(authService,
 				logger, tests.NoopTracer())
@@ -148,6 +224,7 @@ func TestLogin_TableDrive(t *testing.T) {
 				tokenString, exists := response["token"]
 				assert.True(t, exists)
 				assert.NotEmpty(t, tokenString)
+				assert.NotEmpty(t, response["refresh_token"])
 
 				token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
 					return jwtKey, nil
@@ -159,11 +236,15 @@ func TestLogin_TableDrive(t *testing.T) {
 				if claims, ok := token.Claims.(jwt.MapClaims); ok {
 					assert.Equal(t, "validuser", claims["username"])
 					assert.NotEmpty(t, claims["exp"])
+					assert.NotEmpty(t, claims["sid"])
+					assert.Equal(t, string(models.RoleUser), claims["role"])
 				}
 			}
 
 			mockRepository.AssertExpectations(t)
 			mockHasher.AssertExpectations(t)
+			refreshRepository.AssertExpectations(t)
+			sessionRepository.AssertExpectations(t)
 		})
 	}
 }
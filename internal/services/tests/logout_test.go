@@ -0,0 +1,151 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"massager/app/tests"
+	"massager/internal/models"
+	"massager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func signTestToken(t *testing.T, username string, issuedAt time.Time) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": username,
+		"iat":      float64(issuedAt.UnixMilli()) / 1000,
+		"exp":      issuedAt.Add(time.Hour).Unix(),
+	})
+
+	tokenString, err := token.SignedString([]byte(JwtKey))
+	assert.NoError(t, err)
+	return tokenString
+}
+
+func TestValidateToken_RevokedBefore(t *testing.T) {
+	sameSecond := time.Now().Truncate(time.Second)
+
+	var ts = []struct {
+		name          string
+		issuedAt      time.Time
+		revokedBefore time.Time
+		expectError   bool
+	}{
+		{
+			name:          "No cutoff",
+			issuedAt:      time.Now(),
+			revokedBefore: time.Time{},
+			expectError:   false,
+		},
+		{
+			name:          "Issued after cutoff",
+			issuedAt:      time.Now(),
+			revokedBefore: time.Now().Add(-time.Minute),
+			expectError:   false,
+		},
+		{
+			name:          "Issued before cutoff",
+			issuedAt:      time.Now().Add(-time.Minute),
+			revokedBefore: time.Now(),
+			expectError:   true,
+		},
+		{
+			name:          "Issued earlier in the same second as the cutoff",
+			issuedAt:      sameSecond.Add(100 * time.Millisecond),
+			revokedBefore: sameSecond.Add(900 * time.Millisecond),
+			expectError:   true,
+		},
+		{
+			name:          "Issued later in the same second as the cutoff",
+			issuedAt:      sameSecond.Add(900 * time.Millisecond),
+			revokedBefore: sameSecond.Add(100 * time.Millisecond),
+			expectError:   false,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tokenRepository := &tests.MockTokenRepository{}
+			tokenRepository.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+			tokenRepository.On("GetRevokedBefore", mock.Anything, "validuser").Return(tt.revokedBefore, nil)
+
+			var authService = services.NewAuthService(
+				&tests.MockRepository{}, &tests.MockEmailService{}, &tests.MockHasher{},
+				tokenRepository, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{}, []byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			claims, err := authService.ValidateToken(context.Background(), signTestToken(t, "validuser", tt.issuedAt))
+
+			if tt.expectError {
+				assert.EqualError(t, err, "token revoked")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "validuser", claims.Username)
+			}
+
+			tokenRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestLogout_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name       string
+		refresh    string
+		setupMocks func(*tests.MockTokenRepository, *tests.MockRefreshTokenRepository)
+	}{
+		{
+			name:    "Access token only",
+			refresh: "",
+			setupMocks: func(mtr *tests.MockTokenRepository, mrt *tests.MockRefreshTokenRepository) {
+				mtr.On("Revoke", mock.Anything, mock.AnythingOfType("string"), mock.MatchedBy(func(expiration time.Duration) bool {
+					return expiration > 0 && expiration <= time.Hour
+				})).Return(nil)
+			},
+		},
+		{
+			name:    "Refresh token family is revoked",
+			refresh: "refresh-token",
+			setupMocks: func(mtr *tests.MockTokenRepository, mrt *tests.MockRefreshTokenRepository) {
+				mtr.On("Revoke", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(nil)
+				mrt.On("GetRefreshToken", mock.Anything, mock.AnythingOfType("string")).
+					Return(&models.RefreshToken{FamilyID: "family-1", Username: "validuser"}, nil)
+				mrt.On("RevokeFamily", mock.Anything, "family-1", mock.AnythingOfType("time.Duration")).Return(nil)
+			},
+		},
+		{
+			name:    "Foreign refresh token is ignored",
+			refresh: "refresh-token",
+			setupMocks: func(mtr *tests.MockTokenRepository, mrt *tests.MockRefreshTokenRepository) {
+				mtr.On("Revoke", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(nil)
+				mrt.On("GetRefreshToken", mock.Anything, mock.AnythingOfType("string")).
+					Return(&models.RefreshToken{FamilyID: "family-2", Username: "otheruser"}, nil)
+			},
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tokenRepository := &tests.MockTokenRepository{}
+			refreshRepository := &tests.MockRefreshTokenRepository{}
+			tt.setupMocks(tokenRepository, refreshRepository)
+
+			var authService = services.NewAuthService(
+				&tests.MockRepository{}, &tests.MockEmailService{}, &tests.MockHasher{},
+				tokenRepository, refreshRepository, &tests.MockSessionRepository{}, []byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			err := authService.Logout(context.Background(), signTestToken(t, "validuser", time.Now()), tt.refresh)
+			assert.NoError(t, err)
+
+			tokenRepository.AssertExpectations(t)
+			refreshRepository.AssertExpectations(t)
+		})
+	}
+}
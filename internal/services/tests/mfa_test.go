@@ -0,0 +1,393 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"massager/app/config"
+	"massager/app/tests"
+	"massager/internal/adapters"
+	"massager/internal/models"
+	"massager/internal/services"
+	"massager/internal/services/totp"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+const totpSecret = "JBSWY3DPEHPK3PXP"
+
+func signMFAToken(t *testing.T, username, typ string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": username,
+		"typ":      typ,
+		"iat":      time.Now().Unix(),
+		"exp":      time.Now().Add(5 * time.Minute).Unix(),
+	})
+
+	tokenString, err := token.SignedString([]byte(JwtKey))
+	assert.NoError(t, err)
+	return tokenString
+}
+
+func currentCode(t *testing.T) string {
+	code, err := totp.GenerateCode(totpSecret, time.Now())
+	assert.NoError(t, err)
+	return code
+}
+
+func TestCompleteMFALogin_TableDrive(t *testing.T) {
+	mfaUser := &models.User{Username: "mfauser", IsVerefied: true, TOTPSecret: totpSecret, TOTPEnabled: true}
+
+	var ts = []struct {
+		name          string
+		tokenType     string
+		code          func(*testing.T) string
+		setupMocks    func(*tests.MockRepository, *tests.MockTokenRepository, *tests.MockSessionRepository, *tests.MockRefreshTokenRepository)
+		expectedError error
+	}{
+		{
+			name:      "Valid TOTP code",
+			tokenType: "mfa_pending",
+			code:      currentCode,
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository, mrt *tests.MockRefreshTokenRepository) {
+				mtr.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+				mr.On("GetUserByName", mock.Anything, "mfauser").Return(mfaUser, nil)
+				mr.On("AcceptTOTPStep", mock.Anything, "mfauser", mock.AnythingOfType("int64")).Return(true, nil)
+				mtr.On("Revoke", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(nil)
+				msr.On("CreateSession", mock.Anything, mock.AnythingOfType("models.Session")).Return(nil)
+				mrt.On("SaveRefreshToken", mock.Anything, mock.AnythingOfType("models.RefreshToken")).Return(nil)
+			},
+		},
+		{
+			name:      "Replayed TOTP code",
+			tokenType: "mfa_pending",
+			code:      currentCode,
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository, mrt *tests.MockRefreshTokenRepository) {
+				mtr.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+				mr.On("GetUserByName", mock.Anything, "mfauser").Return(mfaUser, nil)
+				mr.On("AcceptTOTPStep", mock.Anything, "mfauser", mock.AnythingOfType("int64")).Return(false, nil)
+				mr.On("ConsumeRecoveryCode", mock.Anything, "mfauser", mock.AnythingOfType("string")).Return(false, nil)
+			},
+			expectedError: services.ErrInvalidMFACode,
+		},
+		{
+			name:      "Recovery code",
+			tokenType: "mfa_pending",
+			code:      func(*testing.T) string { return "ABCDE-FGHIJ" },
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository, mrt *tests.MockRefreshTokenRepository) {
+				mtr.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+				mr.On("GetUserByName", mock.Anything, "mfauser").Return(mfaUser, nil)
+				mr.On("ConsumeRecoveryCode", mock.Anything, "mfauser", mock.AnythingOfType("string")).Return(true, nil)
+				mtr.On("Revoke", mock.Anything, mock.AnythingOfType("string"), mock.AnythingOfType("time.Duration")).Return(nil)
+				msr.On("CreateSession", mock.Anything, mock.AnythingOfType("models.Session")).Return(nil)
+				mrt.On("SaveRefreshToken", mock.Anything, mock.AnythingOfType("models.RefreshToken")).Return(nil)
+			},
+		},
+		{
+			name:      "Wrong code",
+			tokenType: "mfa_pending",
+			code:      func(*testing.T) string { return "000000" },
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository, mrt *tests.MockRefreshTokenRepository) {
+				mtr.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+				mr.On("GetUserByName", mock.Anything, "mfauser").Return(mfaUser, nil)
+				mr.On("ConsumeRecoveryCode", mock.Anything, "mfauser", mock.AnythingOfType("string")).Return(false, nil)
+			},
+			expectedError: services.ErrInvalidMFACode,
+		},
+		{
+			name:      "Already exchanged token",
+			tokenType: "mfa_pending",
+			code:      currentCode,
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository, mrt *tests.MockRefreshTokenRepository) {
+				mtr.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(true, nil)
+			},
+			expectedError: services.ErrInvalidMFAToken,
+		},
+		{
+			name:      "Access token instead of mfa token",
+			tokenType: "access",
+			code:      currentCode,
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository, mrt *tests.MockRefreshTokenRepository) {
+			},
+			expectedError: services.ErrInvalidMFAToken,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			tokenRepository := &tests.MockTokenRepository{}
+			sessionRepository := &tests.MockSessionRepository{}
+			refreshRepository := &tests.MockRefreshTokenRepository{}
+			tt.setupMocks(mockRepository, tokenRepository, sessionRepository, refreshRepository)
+
+			var authService = services.NewAuthService(
+				mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+				tokenRepository, refreshRepository, sessionRepository, []byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			tokens, err := authService.CompleteMFALogin(context.Background(),
+				signMFAToken(t, "mfauser", tt.tokenType), tt.code(t), models.ClientInfo{})
+
+			assert.Equal(t, tt.expectedError, err)
+			if tt.expectedError == nil {
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.NotEmpty(t, tokens.RefreshToken)
+			}
+
+			mockRepository.AssertExpectations(t)
+			tokenRepository.AssertExpectations(t)
+			sessionRepository.AssertExpectations(t)
+			refreshRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestValidateToken_RejectsMFAToken(t *testing.T) {
+	tokenRepository := &tests.MockTokenRepository{}
+	tokenRepository.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+
+	var authService = services.NewAuthService(
+		&tests.MockRepository{}, &tests.MockEmailService{}, &tests.MockHasher{},
+		tokenRepository, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{}, []byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+	_, err := authService.ValidateToken(context.Background(), signMFAToken(t, "mfauser", "mfa_pending"))
+	assert.EqualError(t, err, "invalid token type")
+}
+
+func TestConfirmTOTP_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name          string
+		user          *models.User
+		code          func(*testing.T) string
+		expectEnable  bool
+		expectedError error
+	}{
+		{
+			name:         "Valid code enables TOTP",
+			user:         &models.User{Username: "validuser", TOTPSecret: totpSecret},
+			code:         currentCode,
+			expectEnable: true,
+		},
+		{
+			name:          "Invalid code",
+			user:          &models.User{Username: "validuser", TOTPSecret: totpSecret},
+			code:          func(*testing.T) string { return "000000" },
+			expectedError: services.ErrInvalidMFACode,
+		},
+		{
+			name:          "Enrollment not started",
+			user:          &models.User{Username: "validuser"},
+			code:          currentCode,
+			expectedError: services.ErrMFANotEnrolled,
+		},
+		{
+			name:          "Already enabled",
+			user:          &models.User{Username: "validuser", TOTPSecret: totpSecret, TOTPEnabled: true},
+			code:          currentCode,
+			expectedError: services.ErrMFAAlreadyEnabled,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(tt.user, nil)
+			if tt.expectEnable {
+				mockRepository.On("AcceptTOTPStep", mock.Anything, "validuser", mock.AnythingOfType("int64")).Return(true, nil)
+				mockRepository.On("EnableTOTP", mock.Anything, "validuser", mock.MatchedBy(func(hashes []string) bool {
+					return len(hashes) == 10
+				})).Return(nil)
+			}
+
+			var authService = services.NewAuthService(
+				mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+				&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+				[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			recoveryCodes, err := authService.ConfirmTOTP(context.Background(), "validuser", tt.code(t))
+
+			assert.Equal(t, tt.expectedError, err)
+			if tt.expectedError == nil {
+				assert.Len(t, recoveryCodes, 10)
+			}
+
+			mockRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestDisableTOTP_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name          string
+		code          func(*testing.T) string
+		expectDisable bool
+		expectedError error
+	}{
+		{
+			name:          "Current TOTP code",
+			code:          currentCode,
+			expectDisable: true,
+		},
+		{
+			name:          "Missing code",
+			code:          func(*testing.T) string { return "" },
+			expectedError: services.ErrInvalidMFACode,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			mockRepository.On("GetUserByName", mock.Anything, "validuser").
+				Return(&models.User{Username: "validuser", TOTPSecret: totpSecret, TOTPEnabled: true}, nil)
+			if tt.expectDisable {
+				mockRepository.On("AcceptTOTPStep", mock.Anything, "validuser", mock.AnythingOfType("int64")).Return(true, nil)
+				mockRepository.On("DisableTOTP", mock.Anything, "validuser").Return(nil)
+			}
+
+			var authService = services.NewAuthService(
+				mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+				&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+				[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			err := authService.DisableTOTP(context.Background(), "validuser", tt.code(t))
+			assert.Equal(t, tt.expectedError, err)
+
+			mockRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestVerifySecondFactor_CodeIsAcceptedOnce(t *testing.T) {
+	mfaUser := &models.User{Username: "mfauser", TOTPSecret: totpSecret, TOTPEnabled: true}
+
+	mockRepository := &tests.MockRepository{}
+	mockRepository.On("GetUserByName", mock.Anything, "mfauser").Return(mfaUser, nil)
+	mockRepository.On("AcceptTOTPStep", mock.Anything, "mfauser", mock.AnythingOfType("int64")).Return(true, nil).Once()
+	mockRepository.On("AcceptTOTPStep", mock.Anything, "mfauser", mock.AnythingOfType("int64")).Return(false, nil)
+	mockRepository.On("ConsumeRecoveryCode", mock.Anything, "mfauser", mock.AnythingOfType("string")).Return(false, nil)
+	mockRepository.On("DisableTOTP", mock.Anything, "mfauser").Return(nil)
+
+	var authService = services.NewAuthService(
+		mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+		&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+	code := currentCode(t)
+	assert.NoError(t, authService.DisableTOTP(context.Background(), "mfauser", code))
+	assert.Equal(t, services.ErrInvalidMFACode, authService.DisableTOTP(context.Background(), "mfauser", code))
+
+	mockRepository.AssertNumberOfCalls(t, "DisableTOTP", 1)
+}
+
+func TestDisableTOTP_WrongCodesLockTheAccount(t *testing.T) {
+	user := &models.User{Username: "mfauser", Email: "mfa@gmail.com", TOTPSecret: totpSecret, TOTPEnabled: true}
+
+	mockRepository := &tests.MockRepository{}
+	mockEmailService := &tests.MockEmailService{}
+	mockRepository.On("GetUserByName", mock.Anything, "mfauser").Return(user, nil)
+	mockRepository.On("ConsumeRecoveryCode", mock.Anything, "mfauser", mock.AnythingOfType("string")).Return(false, nil)
+	mockEmailService.On("SendAccountLockedEmail", "mfa@gmail.com", mock.AnythingOfType("time.Time")).Return(nil)
+
+	var authService = services.NewAuthService(
+		mockRepository, mockEmailService, &tests.MockHasher{},
+		&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	authService.SetLoginGuard(services.NewLoginGuard(adapters.NewMemoryAttemptRepository(), config.LoginProtectionConfig{
+		LockoutAfter:    3,
+		LockoutDuration: 15 * time.Minute,
+	}, slog.Default()))
+
+	for i := 0; i < 3; i++ {
+		err := authService.DisableTOTP(context.Background(), "mfauser", "abcde-fghij")
+		assert.Equal(t, services.ErrInvalidMFACode, err)
+	}
+
+	// The next attempt is refused before the code is looked at.
+	err := authService.DisableTOTP(context.Background(), "mfauser", "abcde-fghij")
+	assert.ErrorIs(t, err, services.ErrAccountLocked)
+
+	mockRepository.AssertNumberOfCalls(t, "ConsumeRecoveryCode", 3)
+	mockRepository.AssertNotCalled(t, "DisableTOTP", mock.Anything, mock.Anything)
+	mockEmailService.AssertExpectations(t)
+}
+
+func TestLogin_MFATokenUsesMillisecondDates(t *testing.T) {
+	mfaUser := &models.User{Username: "mfauser", Password: "hashed_password", IsVerefied: true, TOTPSecret: totpSecret, TOTPEnabled: true}
+
+	mockRepository := &tests.MockRepository{}
+	mockHasher := &tests.MockHasher{}
+	tokenRepository := &tests.MockTokenRepository{}
+	sessionRepository := &tests.MockSessionRepository{}
+	refreshRepository := &tests.MockRefreshTokenRepository{}
+
+	mockRepository.On("GetUserByName", mock.Anything, "mfauser").Return(mfaUser, nil)
+	mockRepository.On("AcceptTOTPStep", mock.Anything, "mfauser", mock.AnythingOfType("int64")).Return(true, nil)
+	mockHasher.On("CompareHashAndPassword", []byte("hashed_password"), []byte("correctpassword")).Return(nil)
+	mockHasher.On("NeedsRehash", []byte("hashed_password")).Return(false)
+	tokenRepository.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+	tokenRepository.On("Revoke", mock.Anything, mock.AnythingOfType("string"), mock.MatchedBy(func(ttl time.Duration) bool {
+		return ttl > 4*time.Minute && ttl <= 5*time.Minute
+	})).Return(nil)
+	sessionRepository.On("CreateSession", mock.Anything, mock.AnythingOfType("models.Session")).Return(nil)
+	refreshRepository.On("SaveRefreshToken", mock.Anything, mock.AnythingOfType("models.RefreshToken")).Return(nil)
+
+	var authService = services.NewAuthService(
+		mockRepository, &tests.MockEmailService{}, mockHasher,
+		tokenRepository, refreshRepository, sessionRepository, []byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+	result, err := authService.Login(context.Background(), "mfauser", "correctpassword", models.ClientInfo{})
+	assert.NoError(t, err)
+
+	claims := jwt.MapClaims{}
+	_, _, err = jwt.NewParser().ParseUnverified(result.MFAToken, claims)
+	assert.NoError(t, err)
+	iat, _ := claims["iat"].(float64)
+	exp, _ := claims["exp"].(float64)
+	assert.InDelta(t, float64(time.Now().UnixMilli())/1000, iat, 1)
+	assert.InDelta(t, (5 * time.Minute).Seconds(), exp-iat, 0.001)
+
+	_, err = authService.CompleteMFALogin(context.Background(), result.MFAToken, currentCode(t), models.ClientInfo{})
+	assert.NoError(t, err)
+	tokenRepository.AssertExpectations(t)
+}
+
+func TestConfirmTOTP_WrongCodesLockTheAccount(t *testing.T) {
+	user := &models.User{Username: "validuser", Email: "valid@gmail.com", TOTPSecret: totpSecret}
+
+	mockRepository := &tests.MockRepository{}
+	mockEmailService := &tests.MockEmailService{}
+	mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
+	mockEmailService.On("SendAccountLockedEmail", "valid@gmail.com", mock.AnythingOfType("time.Time")).Return(nil)
+
+	var authService = services.NewAuthService(
+		mockRepository, mockEmailService, &tests.MockHasher{},
+		&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	authService.SetLoginGuard(services.NewLoginGuard(adapters.NewMemoryAttemptRepository(), config.LoginProtectionConfig{
+		LockoutAfter:    3,
+		LockoutDuration: 15 * time.Minute,
+	}, slog.Default()))
+
+	for i := 0; i < 3; i++ {
+		_, err := authService.ConfirmTOTP(context.Background(), "validuser", "000000")
+		assert.Equal(t, services.ErrInvalidMFACode, err)
+	}
+
+	// Even the right code is refused once the account is locked.
+	_, err := authService.ConfirmTOTP(context.Background(), "validuser", currentCode(t))
+	assert.ErrorIs(t, err, services.ErrAccountLocked)
+
+	mockRepository.AssertNumberOfCalls(t, "GetUserByName", 3)
+	mockRepository.AssertNotCalled(t, "EnableTOTP", mock.Anything, mock.Anything, mock.Anything)
+	mockEmailService.AssertExpectations(t)
+}
@@ -0,0 +1,226 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"massager/app/config"
+	"massager/app/tests"
+	"massager/internal/handlers"
+	"massager/internal/models"
+	"massager/internal/services"
+	"massager/internal/services/oidc"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// oidcStateStore lets MockOIDCStateRepository hand the state saved by the
+// login redirect back exactly once.
+func oidcStateStore(m *tests.MockOIDCStateRepository) {
+	saved := &models.OIDCState{}
+	var savedHash string
+	m.On("SaveOIDCState", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		savedHash = args.String(1)
+		*saved = args.Get(2).(models.OIDCState)
+	}).Return(nil)
+
+	matchesSaved := mock.MatchedBy(func(stateHash string) bool { return stateHash == savedHash })
+	m.On("ConsumeOIDCState", mock.Anything, matchesSaved).Return(saved, nil).Once()
+	m.On("ConsumeOIDCState", mock.Anything, mock.Anything).Return((*models.OIDCState)(nil), nil)
+}
+
+func TestOIDCLogin_TableDrive(t *testing.T) {
+	issuer := tests.NewFakeOIDCIssuer()
+	defer issuer.Close()
+
+	linkedUser := &models.User{Username: "validuser", Email: "valid@corp.example", IsVerefied: true}
+
+	var ts = []struct {
+		name          string
+		provider      config.OIDCProviderConfig
+		identity      tests.FakeOIDCUser
+		setupMocks    func(*tests.MockRepository)
+		expectedCode  int
+		expectedBody  string
+		expectSession bool
+	}{
+		{
+			name:     "Linked identity logs in",
+			identity: tests.FakeOIDCUser{Subject: "sub-1", Email: "valid@corp.example", EmailVerified: true},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByIdentity", mock.Anything, "corp", "sub-1").Return(linkedUser, nil)
+			},
+			expectedCode:  http.StatusOK,
+			expectSession: true,
+		},
+		{
+			name:     "Verified email links an existing account",
+			provider: config.OIDCProviderConfig{LinkByEmail: true},
+			identity: tests.FakeOIDCUser{Subject: "sub-2", Email: "valid@corp.example", EmailVerified: true},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByIdentity", mock.Anything, "corp", "sub-2").Return((*models.User)(nil), nil)
+				mr.On("GetUserByEmail", mock.Anything, "valid@corp.example").Return(linkedUser, nil)
+				mr.On("LinkIdentity", mock.Anything, "validuser", "corp", "sub-2", "valid@corp.example").Return(nil)
+			},
+			expectedCode:  http.StatusOK,
+			expectSession: true,
+		},
+		{
+			name:     "Unknown subject is provisioned",
+			provider: config.OIDCProviderConfig{AutoProvision: true},
+			identity: tests.FakeOIDCUser{Subject: "sub-3", Email: "Valid.User@corp.example", EmailVerified: true, Name: "Valid User"},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByIdentity", mock.Anything, "corp", "sub-3").Return((*models.User)(nil), nil)
+				mr.On("GetUserByEmail", mock.Anything, "valid.user@corp.example").Return((*models.User)(nil), nil)
+				mr.On("GetUserByName", mock.Anything, "valid.user").Return(&models.User{Username: "valid.user"}, nil).Once()
+				mr.On("GetUserByName", mock.Anything, "valid.user2").Return((*models.User)(nil), nil).Once()
+				mr.On("CreateUserWithIdentity", mock.Anything, "valid.user2", "valid.user@corp.example", "corp", "sub-3").Return(nil)
+				mr.On("UpdateProfile", mock.Anything, "valid.user2", mock.MatchedBy(func(update models.ProfileUpdate) bool {
+					return update.DisplayName != nil && *update.DisplayName == "Valid User"
+				})).Return(nil)
+				mr.On("GetUserByName", mock.Anything, "valid.user2").Return(&models.User{Username: "valid.user2", IsVerefied: true}, nil)
+			},
+			expectedCode:  http.StatusOK,
+			expectSession: true,
+		},
+		{
+			name:     "Reserved preferred username falls back to user",
+			provider: config.OIDCProviderConfig{AutoProvision: true},
+			identity: tests.FakeOIDCUser{Subject: "sub-7", Email: "boss@corp.example", EmailVerified: true, PreferredUsername: "Admin"},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByIdentity", mock.Anything, "corp", "sub-7").Return((*models.User)(nil), nil)
+				mr.On("GetUserByEmail", mock.Anything, "boss@corp.example").Return((*models.User)(nil), nil)
+				mr.On("GetUserByName", mock.Anything, "user").Return((*models.User)(nil), nil).Once()
+				mr.On("CreateUserWithIdentity", mock.Anything, "user", "boss@corp.example", "corp", "sub-7").Return(nil)
+				mr.On("GetUserByName", mock.Anything, "user").Return(&models.User{Username: "user", IsVerefied: true}, nil)
+			},
+			expectedCode:  http.StatusOK,
+			expectSession: true,
+		},
+		{
+			name:     "Numbered username stays within the maximum length",
+			provider: config.OIDCProviderConfig{AutoProvision: true},
+			identity: tests.FakeOIDCUser{Subject: "sub-8", Email: "long@corp.example", EmailVerified: true, PreferredUsername: strings.Repeat("a", 40)},
+			setupMocks: func(mr *tests.MockRepository) {
+				taken, numbered := strings.Repeat("a", 32), strings.Repeat("a", 31)+"2"
+				mr.On("GetUserByIdentity", mock.Anything, "corp", "sub-8").Return((*models.User)(nil), nil)
+				mr.On("GetUserByEmail", mock.Anything, "long@corp.example").Return((*models.User)(nil), nil)
+				mr.On("GetUserByName", mock.Anything, taken).Return(&models.User{Username: taken}, nil).Once()
+				mr.On("GetUserByName", mock.Anything, numbered).Return((*models.User)(nil), nil).Once()
+				mr.On("CreateUserWithIdentity", mock.Anything, numbered, "long@corp.example", "corp", "sub-8").Return(nil)
+				mr.On("GetUserByName", mock.Anything, numbered).Return(&models.User{Username: numbered, IsVerefied: true}, nil)
+			},
+			expectedCode:  http.StatusOK,
+			expectSession: true,
+		},
+		{
+			name:     "Email taken by an account that may not be linked",
+			provider: config.OIDCProviderConfig{AutoProvision: true},
+			identity: tests.FakeOIDCUser{Subject: "sub-4", Email: "valid@corp.example", EmailVerified: true},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByIdentity", mock.Anything, "corp", "sub-4").Return((*models.User)(nil), nil)
+				mr.On("GetUserByEmail", mock.Anything, "valid@corp.example").Return(linkedUser, nil)
+			},
+			expectedCode: http.StatusConflict,
+			expectedBody: services.ErrOIDCEmailTaken.Error(),
+		},
+		{
+			name:     "Unverified email is neither linked nor provisioned",
+			provider: config.OIDCProviderConfig{AutoProvision: true, LinkByEmail: true},
+			identity: tests.FakeOIDCUser{Subject: "sub-5", Email: "valid@corp.example"},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByIdentity", mock.Anything, "corp", "sub-5").Return((*models.User)(nil), nil)
+			},
+			expectedCode: http.StatusForbidden,
+			expectedBody: services.ErrOIDCAccountNotFound.Error(),
+		},
+		{
+			name:     "Linked account with two-factor authentication",
+			identity: tests.FakeOIDCUser{Subject: "sub-6"},
+			setupMocks: func(mr *tests.MockRepository) {
+				mr.On("GetUserByIdentity", mock.Anything, "corp", "sub-6").Return(&models.User{Username: "mfauser", TOTPEnabled: true}, nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"mfa_required":true`,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepository := &tests.MockRepository{}
+			refreshRepository := &tests.MockRefreshTokenRepository{}
+			sessionRepository := &tests.MockSessionRepository{}
+			stateRepository := &tests.MockOIDCStateRepository{}
+			oidcStateStore(stateRepository)
+			tt.setupMocks(mockRepository)
+
+			if tt.expectSession {
+				sessionRepository.On("CreateSession", mock.Anything, mock.MatchedBy(func(session models.Session) bool {
+					return session.DeviceLabel == "corp"
+				})).Return(nil)
+				refreshRepository.On("SaveRefreshToken", mock.Anything, mock.AnythingOfType("models.RefreshToken")).Return(nil)
+			}
+
+			providerConfig := tt.provider
+			providerConfig.Name = "corp"
+			providerConfig.Issuer = issuer.URL
+			providerConfig.ClientID = tests.FakeOIDCClientID
+			providerConfig.ClientSecret = tests.FakeOIDCClientSecret
+			providerConfig.RedirectURL = "http://horizon.test/api/auth/oidc/corp/callback"
+
+			authService := services.NewAuthService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+				&tests.MockTokenRepository{}, refreshRepository, sessionRepository,
+				[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+			authService.SetOIDC([]*oidc.Provider{oidc.NewProvider(providerConfig, issuer.Client())}, stateRepository)
+			handler := handlers.NewAuthHandler(authService, slog.Default(), tests.NoopTracer())
+
+			// The login endpoint redirects to the issuer, which redirects back with a code.
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/corp/login", nil)
+			c.Params = gin.Params{{Key: "provider", Value: "corp"}}
+			handler.OIDCLogin(c)
+			require.Equal(t, http.StatusFound, w.Code)
+
+			issuer.SetUser(tt.identity)
+			callback, err := issuer.Authorize(w.Header().Get("Location"))
+			require.NoError(t, err)
+
+			w = httptest.NewRecorder()
+			c, _ = gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+			c.Params = gin.Params{{Key: "provider", Value: "corp"}}
+			handler.OIDCCallback(c)
+
+			assert.Equal(t, tt.expectedCode, w.Code)
+			if tt.expectedBody != "" {
+				assert.Contains(t, w.Body.String(), tt.expectedBody)
+			}
+			if tt.expectSession {
+				assert.Contains(t, w.Body.String(), `"refresh_token"`)
+			}
+			mockRepository.AssertExpectations(t)
+			sessionRepository.AssertExpectations(t)
+
+			// The state is spent, so replaying the callback fails.
+			_, err = authService.CompleteOIDCLogin(context.Background(), "corp", callback.Query().Get("state"), callback.Query().Get("code"), models.ClientInfo{})
+			assert.ErrorIs(t, err, services.ErrInvalidOIDCState)
+		})
+	}
+}
+
+func TestOIDCLogin_UnknownProvider(t *testing.T) {
+	authService := services.NewAuthService(&tests.MockRepository{}, &tests.MockEmailService{}, &tests.MockHasher{},
+		&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	authService.SetOIDC(nil, &tests.MockOIDCStateRepository{})
+
+	_, err := authService.StartOIDCLogin(context.Background(), "corp")
+	assert.ErrorIs(t, err, services.ErrUnknownOIDCProvider)
+}
@@ -0,0 +1,165 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"massager/app/tests"
+	"massager/internal/models"
+	"massager/internal/services"
+	"massager/internal/services/validation"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestRequestPasswordReset_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name       string
+		email      string
+		setupMocks func(*tests.MockRepository, *tests.MockEmailService)
+	}{
+		{
+			name:  "Registered email",
+			email: "valid@gmail.com",
+			setupMocks: func(mr *tests.MockRepository, mes *tests.MockEmailService) {
+				mr.On("GetUserByEmail", mock.Anything, "valid@gmail.com").Return(&models.User{Username: "validuser"}, nil)
+
+				var storedHash string
+				mr.On("CreatePasswordResetToken", mock.Anything, "validuser", mock.AnythingOfType("string"), mock.MatchedBy(func(expiresAt time.Time) bool {
+					return expiresAt.After(time.Now())
+				})).Run(func(args mock.Arguments) { storedHash = args.String(2) }).Return(nil)
+
+				// Only the hash of the emailed token may be stored.
+				mes.On("SendPasswordResetEmail", "valid@gmail.com", mock.MatchedBy(func(token string) bool {
+					hash := sha256.Sum256([]byte(token))
+					return hex.EncodeToString(hash[:]) == storedHash
+				})).Return(nil)
+			},
+		},
+		{
+			name:  "Unknown email",
+			email: "unknown@gmail.com",
+			setupMocks: func(mr *tests.MockRepository, mes *tests.MockEmailService) {
+				mr.On("GetUserByEmail", mock.Anything, "unknown@gmail.com").Return((*models.User)(nil), nil)
+			},
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			mockEmailService := &tests.MockEmailService{}
+			tt.setupMocks(mockRepository, mockEmailService)
+
+			var authService = services.NewAuthService(
+				mockRepository, mockEmailService, &tests.MockHasher{},
+				&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+				[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			err := authService.RequestPasswordReset(context.Background(), tt.email)
+			assert.NoError(t, err)
+
+			mockRepository.AssertExpectations(t)
+			mockEmailService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResetPassword_TableDrive(t *testing.T) {
+	const resetToken = "reset-token"
+	hash := sha256.Sum256([]byte(resetToken))
+	tokenHash := hex.EncodeToString(hash[:])
+
+	var ts = []struct {
+		name          string
+		newPassword   string
+		setupMocks    func(*tests.MockRepository, *tests.MockTokenRepository, *tests.MockSessionRepository)
+		expectedError error
+	}{
+		{
+			name:        "Successful reset revokes sessions",
+			newPassword: "newpassword",
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository) {
+				mr.On("GetPasswordResetTokenUser", mock.Anything, tokenHash).Return("validuser", nil)
+				mr.On("ConsumePasswordResetToken", mock.Anything, tokenHash).Return("validuser", nil)
+				mr.On("UpdatePassword", mock.Anything, "validuser", "hashed_password").Return(nil)
+				mtr.On("RevokeAllBefore", mock.Anything, "validuser", mock.AnythingOfType("time.Time"), mock.AnythingOfType("time.Duration")).Return(nil)
+				msr.On("RevokeUserSessions", mock.Anything, "validuser").Return(nil)
+			},
+		},
+		{
+			name:        "Invalid, expired or used token",
+			newPassword: "newpassword",
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository) {
+				mr.On("GetPasswordResetTokenUser", mock.Anything, tokenHash).Return("", nil)
+			},
+			expectedError: services.ErrInvalidResetToken,
+		},
+		{
+			name:        "Token spent by a concurrent reset",
+			newPassword: "newpassword",
+			setupMocks: func(mr *tests.MockRepository, mtr *tests.MockTokenRepository, msr *tests.MockSessionRepository) {
+				mr.On("GetPasswordResetTokenUser", mock.Anything, tokenHash).Return("validuser", nil)
+				mr.On("ConsumePasswordResetToken", mock.Anything, tokenHash).Return("", nil)
+			},
+			expectedError: services.ErrInvalidResetToken,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			mockHasher := &tests.MockHasher{}
+			tokenRepository := &tests.MockTokenRepository{}
+			sessionRepository := &tests.MockSessionRepository{}
+
+			mockHasher.On("DefaultCost").Return(bcrypt.DefaultCost)
+			mockHasher.On("GenerateFromPassword", []byte(tt.newPassword), bcrypt.DefaultCost).Return([]byte("hashed_password"), nil)
+			tt.setupMocks(mockRepository, tokenRepository, sessionRepository)
+
+			var authService = services.NewAuthService(
+				mockRepository, &tests.MockEmailService{}, mockHasher,
+				tokenRepository, &tests.MockRefreshTokenRepository{}, sessionRepository,
+				[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			err := authService.ResetPassword(context.Background(), resetToken, tt.newPassword)
+			assert.Equal(t, tt.expectedError, err)
+
+			mockRepository.AssertExpectations(t)
+			tokenRepository.AssertExpectations(t)
+			sessionRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResetPassword_RejectsPasswordMatchingUsername(t *testing.T) {
+	const resetToken = "reset-token"
+	hash := sha256.Sum256([]byte(resetToken))
+	tokenHash := hex.EncodeToString(hash[:])
+
+	mockRepository := &tests.MockRepository{}
+	mockRepository.On("GetPasswordResetTokenUser", mock.Anything, tokenHash).Return("validuser", nil)
+
+	var authService = services.NewAuthService(
+		mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+		&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+	err := authService.ResetPassword(context.Background(), resetToken, "validuser")
+
+	var validationErrs validation.Errors
+	assert.ErrorAs(t, err, &validationErrs)
+	assert.Contains(t, validationErrs, "password")
+	// The rejected password must not spend the token.
+	mockRepository.AssertNotCalled(t, "ConsumePasswordResetToken", mock.Anything, mock.Anything)
+	mockRepository.AssertExpectations(t)
+}
@@ -0,0 +1,178 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"massager/app/tests"
+	"massager/internal/models"
+	"massager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRefreshTokens_TableDrive(t *testing.T) {
+	const refreshToken = "refresh-token"
+	hash := sha256.Sum256([]byte(refreshToken))
+	tokenHash := hex.EncodeToString(hash[:])
+
+	validToken := func() *models.RefreshToken {
+		return &models.RefreshToken{
+			TokenHash: tokenHash,
+			FamilyID:  "family-1",
+			Username:  "validuser",
+			IssuedAt:  time.Now().Add(-time.Minute),
+			ExpiresAt: time.Now().Add(time.Hour),
+		}
+	}
+
+	var ts = []struct {
+		name          string
+		refreshToken  string
+		setupMocks    func(*tests.MockRefreshTokenRepository, *tests.MockTokenRepository)
+		user          *models.User
+		expectedError error
+	}{
+		{
+			name:         "Successful rotation",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return(validToken(), nil)
+				mrt.On("IsFamilyRevoked", mock.Anything, "family-1").Return(false, nil)
+				mtr.On("GetRevokedBefore", mock.Anything, "validuser").Return(time.Time{}, nil)
+				mrt.On("MarkRefreshTokenUsed", mock.Anything, tokenHash).Return(true, nil)
+				mrt.On("SaveRefreshToken", mock.Anything, mock.MatchedBy(func(token models.RefreshToken) bool {
+					return token.FamilyID == "family-1" && token.Username == "validuser" && token.TokenHash != tokenHash
+				})).Return(nil)
+			},
+		},
+		{
+			name:         "Reused token revokes family",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				used := validToken()
+				used.Used = true
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return(used, nil)
+				mrt.On("IsFamilyRevoked", mock.Anything, "family-1").Return(false, nil)
+				mtr.On("GetRevokedBefore", mock.Anything, "validuser").Return(time.Time{}, nil)
+				mrt.On("RevokeFamily", mock.Anything, "family-1", mock.AnythingOfType("time.Duration")).Return(nil)
+			},
+			expectedError: services.ErrRefreshTokenReused,
+		},
+		{
+			name:         "Concurrent reuse revokes family",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return(validToken(), nil)
+				mrt.On("IsFamilyRevoked", mock.Anything, "family-1").Return(false, nil)
+				mtr.On("GetRevokedBefore", mock.Anything, "validuser").Return(time.Time{}, nil)
+				mrt.On("MarkRefreshTokenUsed", mock.Anything, tokenHash).Return(false, nil)
+				mrt.On("RevokeFamily", mock.Anything, "family-1", mock.AnythingOfType("time.Duration")).Return(nil)
+			},
+			expectedError: services.ErrRefreshTokenReused,
+		},
+		{
+			name:         "Banned user",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return(validToken(), nil)
+				mrt.On("IsFamilyRevoked", mock.Anything, "family-1").Return(false, nil)
+				mtr.On("GetRevokedBefore", mock.Anything, "validuser").Return(time.Time{}, nil)
+				mrt.On("MarkRefreshTokenUsed", mock.Anything, tokenHash).Return(true, nil)
+			},
+			user:          &models.User{Username: "validuser", BannedAt: time.Now()},
+			expectedError: services.ErrAccountBanned,
+		},
+		{
+			name:         "Revoked family",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return(validToken(), nil)
+				mrt.On("IsFamilyRevoked", mock.Anything, "family-1").Return(true, nil)
+			},
+			expectedError: services.ErrInvalidRefreshToken,
+		},
+		{
+			name:         "Issued before revoke-all cutoff",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return(validToken(), nil)
+				mrt.On("IsFamilyRevoked", mock.Anything, "family-1").Return(false, nil)
+				mtr.On("GetRevokedBefore", mock.Anything, "validuser").Return(time.Now(), nil)
+			},
+			expectedError: services.ErrInvalidRefreshToken,
+		},
+		{
+			name:         "Expired token",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				expired := validToken()
+				expired.ExpiresAt = time.Now().Add(-time.Minute)
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return(expired, nil)
+				mrt.On("IsFamilyRevoked", mock.Anything, "family-1").Return(false, nil)
+			},
+			expectedError: services.ErrInvalidRefreshToken,
+		},
+		{
+			name:         "Unknown token",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return((*models.RefreshToken)(nil), nil)
+			},
+			expectedError: services.ErrInvalidRefreshToken,
+		},
+		{
+			name:         "Empty token",
+			refreshToken: "",
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+			},
+			expectedError: services.ErrInvalidRefreshToken,
+		},
+		{
+			name:         "Repository error",
+			refreshToken: refreshToken,
+			setupMocks: func(mrt *tests.MockRefreshTokenRepository, mtr *tests.MockTokenRepository) {
+				mrt.On("GetRefreshToken", mock.Anything, tokenHash).Return((*models.RefreshToken)(nil), errors.New("redis down"))
+			},
+			expectedError: errors.New("redis down"),
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			refreshRepository := &tests.MockRefreshTokenRepository{}
+			tokenRepository := &tests.MockTokenRepository{}
+			tt.setupMocks(refreshRepository, tokenRepository)
+
+			user := tt.user
+			if user == nil {
+				user = &models.User{Username: "validuser", Role: models.RoleUser}
+			}
+			mockRepository := &tests.MockRepository{}
+			mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(user, nil).Maybe()
+
+			var authService = services.NewAuthService(
+				mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+				tokenRepository, refreshRepository, &tests.MockSessionRepository{}, []byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			tokens, err := authService.RefreshTokens(context.Background(), tt.refreshToken)
+
+			assert.Equal(t, tt.expectedError, err)
+			if tt.expectedError == nil {
+				assert.NotEmpty(t, tokens.AccessToken)
+				assert.NotEmpty(t, tokens.RefreshToken)
+				assert.NotEqual(t, tt.refreshToken, tokens.RefreshToken)
+			}
+
+			refreshRepository.AssertExpectations(t)
+			tokenRepository.AssertExpectations(t)
+		})
+	}
+}
@@ -42,7 +42,7 @@ func TestRegister_TableDrive(t *testing.T) {
 				mh.On("DefaultCost").Return(bcrypt.DefaultCost)
 
 				// Mock: User creation
-				mr.On("CreateUser", mock.Anything, "validuser", "hashed_password", "validemail@gmail.com", mock.AnythingOfType("string")).Return(nil)
+				mr.On("CreateUser", mock.Anything, "validuser", "hashed_password", "validemail@gmail.com", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
 
 				// Mock: Email sending
 				mes.On("SendVerificationEmail", "validemail@gmail.com", mock.AnythingOfType("string")).Return(nil)
@@ -54,7 +54,7 @@ func TestRegister_TableDrive(t *testing.T) {
 			name: "empty username",
 			requestBody: map[string]interface{}{
 				"username": "",
-				"password": "password123",
+				"password": "s3cure-passphrase",
 				"email":    "test@gmail.com",
 			},
 			setupMocks: func(mur *tests.MockRepository, mph *tests.MockHasher, mes *tests.MockEmailService) {
@@ -80,7 +80,7 @@ func TestRegister_TableDrive(t *testing.T) {
 			name: "empty email",
 			requestBody: map[string]interface{}{
 				"username": "testuser",
-				"password": "password123",
+				"password": "s3cure-passphrase",
 				"email":    "",
 			},
 			setupMocks: func(mockRepo *tests.MockRepository, mockHasher *tests.MockHasher, mes *tests.MockEmailService) {
@@ -89,11 +89,37 @@ func TestRegister_TableDrive(t *testing.T) {
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "username, password and email are required", // FIX: Updated expected error message
 		},
+		{
+			name: "invalid fields",
+			requestBody: map[string]interface{}{
+				"username": "a,b",
+				"password": "password",
+				"email":    "Test User <test@gmail.com>",
+			},
+			setupMocks: func(mockRepo *tests.MockRepository, mockHasher *tests.MockHasher, mes *tests.MockEmailService) {
+				// No mocks needed since validation fails early
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"fields":{"email":"is not a valid address","password":"is too common","username":"may only contain the characters [a-zA-Z0-9._-]"}`,
+		},
+		{
+			name: "reserved username",
+			requestBody: map[string]interface{}{
+				"username": "Admin",
+				"password": "s3cure-passphrase",
+				"email":    "test@gmail.com",
+			},
+			setupMocks: func(mockRepo *tests.MockRepository, mockHasher *tests.MockHasher, mes *tests.MockEmailService) {
+				// No mocks needed since validation fails early
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "username is reserved",
+		},
 		{
 			name: "username already exists",
 			requestBody: map[string]interface{}{
 				"username": "existinguser",
-				"password": "password123",
+				"password": "s3cure-passphrase",
 				"email":    "test@gmail.com",
 			},
 			setupMocks: func(mockRepo *tests.MockRepository, mockHasher *tests.MockHasher, mes *tests.MockEmailService) {
@@ -108,7 +134,7 @@ func TestRegister_TableDrive(t *testing.T) {
 			name: "password hashing fails",
 			requestBody: map[string]interface{}{
 				"username": "testuser",
-				"password": "password123",
+				"password": "s3cure-passphrase",
 				"email":    "test@gmail.com",
 			},
 			setupMocks: func(mockRepo *tests.MockRepository, mockHasher *tests.MockHasher, mes *tests.MockEmailService) {
@@ -116,7 +142,7 @@ func TestRegister_TableDrive(t *testing.T) {
 				mockRepo.On("GetUserByName", mock.Anything, "testuser").Return((*models.User)(nil), nil)
 
 				// Mock: Password hashing fails
-				mockHasher.On("GenerateFromPassword", []byte("password123"), bcrypt.DefaultCost).Return([]byte(""), errors.New("hashing failed"))
+				mockHasher.On("GenerateFromPassword", []byte("s3cure-passphrase"), bcrypt.DefaultCost).Return([]byte(""), errors.New("hashing failed"))
 				mockHasher.On("DefaultCost").Return(bcrypt.DefaultCost)
 			},
 			expectedCode: http.StatusBadRequest,
@@ -126,7 +152,7 @@ func TestRegister_TableDrive(t *testing.T) {
 			name: "user creation fails",
 			requestBody: map[string]interface{}{
 				"username": "testuser",
-				"password": "password123",
+				"password": "s3cure-passphrase",
 				"email":    "test@gmail.com",
 			},
 			setupMocks: func(mockRepo *tests.MockRepository, mockHasher *tests.MockHasher, mes *tests.MockEmailService) {
@@ -134,11 +160,11 @@ func TestRegister_TableDrive(t *testing.T) {
 				mockRepo.On("GetUserByName", mock.Anything, "testuser").Return((*models.User)(nil), nil)
 
 				// Mock: Password hashing succeeds
-				mockHasher.On("GenerateFromPassword", []byte("password123"), bcrypt.DefaultCost).Return([]byte("hashed_password"), nil)
+				mockHasher.On("GenerateFromPassword", []byte("s3cure-passphrase"), bcrypt.DefaultCost).Return([]byte("hashed_password"), nil)
 				mockHasher.On("DefaultCost").Return(bcrypt.DefaultCost)
 
 				// Mock: User creation fails
-				mockRepo.On("CreateUser", mock.Anything, "testuser", "hashed_password", "test@gmail.com", mock.AnythingOfType("string")).Return(errors.New("database error"))
+				mockRepo.On("CreateUser", mock.Anything, "testuser", "hashed_password", "test@gmail.com", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(errors.New("database error"))
 			},
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "registration failed",
@@ -147,7 +173,7 @@ func TestRegister_TableDrive(t *testing.T) {
 			name: "email sending fails",
 			requestBody: map[string]interface{}{
 				"username": "testuser",
-				"password": "password123",
+				"password": "s3cure-passphrase",
 				"email":    "test@gmail.com",
 			},
 			setupMocks: func(mockRepo *tests.MockRepository, mockHasher *tests.MockHasher, mes *tests.MockEmailService) {
@@ -155,11 +181,11 @@ func TestRegister_TableDrive(t *testing.T) {
 				mockRepo.On("GetUserByName", mock.Anything, "testuser").Return((*models.User)(nil), nil)
 
 				// Mock: Password hashing
-				mockHasher.On("GenerateFromPassword", []byte("password123"), bcrypt.DefaultCost).Return([]byte("hashed_password"), nil)
+				mockHasher.On("GenerateFromPassword", []byte("s3cure-passphrase"), bcrypt.DefaultCost).Return([]byte("hashed_password"), nil)
 				mockHasher.On("DefaultCost").Return(bcrypt.DefaultCost)
 
 				// Mock: User creation succeeds
-				mockRepo.On("CreateUser", mock.Anything, "testuser", "hashed_password", "test@gmail.com", mock.AnythingOfType("string")).Return(nil)
+				mockRepo.On("CreateUser", mock.Anything, "testuser", "hashed_password", "test@gmail.com", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
 
 				// Mock: Email sending fails
 				mes.On("SendVerificationEmail", "test@gmail.com", mock.AnythingOfType("string")).Return(errors.New("email error"))
@@ -167,6 +193,23 @@ func TestRegister_TableDrive(t *testing.T) {
 			expectedCode: http.StatusOK, // Registration should still succeed even if email fails
 			expectedBody: "User registered successfully",
 		},
+		{
+			name: "Email is stored lower-case",
+			requestBody: map[string]interface{}{
+				"username": "testuser",
+				"password": "s3cure-passphrase",
+				"email":    "Test.User@Gmail.com",
+			},
+			setupMocks: func(mockRepo *tests.MockRepository, mockHasher *tests.MockHasher, mes *tests.MockEmailService) {
+				mockRepo.On("GetUserByName", mock.Anything, "testuser").Return((*models.User)(nil), nil)
+				mockHasher.On("GenerateFromPassword", []byte("s3cure-passphrase"), bcrypt.DefaultCost).Return([]byte("hashed_password"), nil)
+				mockHasher.On("DefaultCost").Return(bcrypt.DefaultCost)
+				mockRepo.On("CreateUser", mock.Anything, "testuser", "hashed_password", "test.user@gmail.com", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+				mes.On("SendVerificationEmail", "test.user@gmail.com", mock.AnythingOfType("string")).Return(nil)
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: "User registered successfully",
+		},
 	}
 
 	for _, tt := range ts {
@@ -184,7 +227,7 @@ func TestRegister_TableDrive(t *testing.T) {
 
 			var authService = services.NewAuthService(
 				mockRepository, mockEmailService, mockHasher,
-				tokenRepository, jwtKey, logger, tests.NoopTracer())
+				tokenRepository, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{}, jwtKey, logger, tests.NoopTracer())
 
 			var handler = handlers.NewAuthHandler(authService, logger, tests.NoopTracer())
 
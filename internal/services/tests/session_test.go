@@ -0,0 +1,134 @@
+package services_test
+
+import (
+	"context"
+	"log/slog"
+	"massager/app/tests"
+	"massager/internal/models"
+	"massager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestValidateToken_Session(t *testing.T) {
+	revokedAt := time.Now().Add(-time.Minute)
+
+	var ts = []struct {
+		name        string
+		session     *models.Session
+		expectTouch bool
+		expectError bool
+	}{
+		{
+			name:    "Active session",
+			session: &models.Session{ID: "session-1", Username: "validuser", LastSeenAt: time.Now()},
+		},
+		{
+			name:        "Stale last seen is touched",
+			session:     &models.Session{ID: "session-1", Username: "validuser", LastSeenAt: time.Now().Add(-time.Hour)},
+			expectTouch: true,
+		},
+		{
+			name:        "Revoked session",
+			session:     &models.Session{ID: "session-1", Username: "validuser", RevokedAt: &revokedAt},
+			expectError: true,
+		},
+		{
+			name:        "Unknown session",
+			session:     nil,
+			expectError: true,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tokenRepository := &tests.MockTokenRepository{}
+			tokenRepository.On("IsRevoked", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+			tokenRepository.On("GetRevokedBefore", mock.Anything, "validuser").Return(time.Time{}, nil)
+
+			sessionRepository := &tests.MockSessionRepository{}
+			sessionRepository.On("GetSession", mock.Anything, "session-1").Return(tt.session, nil)
+			if tt.expectTouch {
+				sessionRepository.On("TouchSession", mock.Anything, "session-1", mock.AnythingOfType("time.Time")).Return(nil)
+			}
+
+			var authService = services.NewAuthService(
+				&tests.MockRepository{}, &tests.MockEmailService{}, &tests.MockHasher{},
+				tokenRepository, &tests.MockRefreshTokenRepository{}, sessionRepository, []byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+				"username": "validuser",
+				"sid":      "session-1",
+				"iat":      time.Now().Unix(),
+				"exp":      time.Now().Add(time.Hour).Unix(),
+			})
+			tokenString, _ := token.SignedString([]byte(JwtKey))
+
+			claims, err := authService.ValidateToken(context.Background(), tokenString)
+
+			if tt.expectError {
+				assert.EqualError(t, err, "session revoked")
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, "session-1", claims.SessionID)
+			}
+
+			sessionRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRevokeSession_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name          string
+		session       *models.Session
+		expectRevoke  bool
+		expectedError error
+	}{
+		{
+			name:         "Own session",
+			session:      &models.Session{ID: "session-1", Username: "validuser"},
+			expectRevoke: true,
+		},
+		{
+			name:          "Foreign session",
+			session:       &models.Session{ID: "session-1", Username: "otheruser"},
+			expectedError: services.ErrSessionNotFound,
+		},
+		{
+			name:          "Unknown session",
+			session:       nil,
+			expectedError: services.ErrSessionNotFound,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			sessionRepository := &tests.MockSessionRepository{}
+			refreshRepository := &tests.MockRefreshTokenRepository{}
+			sessionRepository.On("GetSession", mock.Anything, "session-1").Return(tt.session, nil)
+			if tt.expectRevoke {
+				sessionRepository.On("RevokeSession", mock.Anything, "session-1").Return(nil)
+				refreshRepository.On("RevokeFamily", mock.Anything, "session-1", mock.AnythingOfType("time.Duration")).Return(nil)
+			}
+
+			var authService = services.NewAuthService(
+				&tests.MockRepository{}, &tests.MockEmailService{}, &tests.MockHasher{},
+				&tests.MockTokenRepository{}, refreshRepository, sessionRepository, []byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			err := authService.RevokeSession(context.Background(), "validuser", "session-1")
+			assert.Equal(t, tt.expectedError, err)
+
+			sessionRepository.AssertExpectations(t)
+			refreshRepository.AssertExpectations(t)
+		})
+	}
+}
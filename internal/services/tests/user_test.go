@@ -0,0 +1,396 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"massager/app/config"
+	"massager/app/tests"
+	"massager/internal/adapters"
+	"massager/internal/models"
+	"massager/internal/services"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newTestUserService(repo *tests.MockRepository, emailService *tests.MockEmailService, hasher *tests.MockHasher,
+	refreshRepo *tests.MockRefreshTokenRepository, sessionRepo *tests.MockSessionRepository) *services.UserService {
+	var authService = services.NewAuthService(
+		repo, emailService, hasher,
+		&tests.MockTokenRepository{}, refreshRepo, sessionRepo,
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+	return services.NewUserService(repo, &tests.MockChatRepository{}, &tests.MockMessageRepository{}, emailService, hasher, authService,
+		slog.Default(), tests.NoopTracer())
+}
+
+func TestChangePassword_TableDrive(t *testing.T) {
+	user := &models.User{Username: "validuser", Password: "stored_hash", Email: "valid@gmail.com"}
+
+	var ts = []struct {
+		name            string
+		currentPassword string
+		newPassword     string
+		setupMocks      func(*tests.MockRepository, *tests.MockHasher, *tests.MockRefreshTokenRepository, *tests.MockSessionRepository)
+		expectedError   error
+	}{
+		{
+			name:            "Other sessions are revoked",
+			currentPassword: "correctpassword",
+			newPassword:     "newpassword",
+			setupMocks: func(mr *tests.MockRepository, mh *tests.MockHasher, mrr *tests.MockRefreshTokenRepository, msr *tests.MockSessionRepository) {
+				mr.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
+				mh.On("CompareHashAndPassword", []byte("stored_hash"), []byte("correctpassword")).Return(nil)
+				mh.On("DefaultCost").Return(bcrypt.DefaultCost)
+				mh.On("GenerateFromPassword", []byte("newpassword"), bcrypt.DefaultCost).Return([]byte("new_hash"), nil)
+				mr.On("UpdatePassword", mock.Anything, "validuser", "new_hash").Return(nil)
+
+				msr.On("GetUserSessions", mock.Anything, "validuser").Return([]models.Session{{ID: "current"}, {ID: "laptop"}}, nil)
+				msr.On("RevokeSession", mock.Anything, "laptop").Return(nil)
+				mrr.On("RevokeFamily", mock.Anything, "laptop", mock.AnythingOfType("time.Duration")).Return(nil)
+			},
+		},
+		{
+			name:            "Wrong current password",
+			currentPassword: "wrongpassword",
+			newPassword:     "newpassword",
+			setupMocks: func(mr *tests.MockRepository, mh *tests.MockHasher, mrr *tests.MockRefreshTokenRepository, msr *tests.MockSessionRepository) {
+				mr.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
+				mh.On("CompareHashAndPassword", []byte("stored_hash"), []byte("wrongpassword")).Return(bcrypt.ErrMismatchedHashAndPassword)
+			},
+			expectedError: services.ErrWrongPassword,
+		},
+		{
+			name:            "Account without a password",
+			currentPassword: "anypassword",
+			newPassword:     "newpassword",
+			setupMocks: func(mr *tests.MockRepository, mh *tests.MockHasher, mrr *tests.MockRefreshTokenRepository, msr *tests.MockSessionRepository) {
+				mr.On("GetUserByName", mock.Anything, "validuser").Return(&models.User{Username: "validuser", Password: "!"}, nil)
+				mh.On("CompareHashAndPassword", []byte("!"), []byte("anypassword")).Return(services.ErrUnknownHashFormat)
+			},
+			expectedError: services.ErrWrongPassword,
+		},
+		{
+			name:            "Same password",
+			currentPassword: "correctpassword",
+			newPassword:     "correctpassword",
+			setupMocks: func(mr *tests.MockRepository, mh *tests.MockHasher, mrr *tests.MockRefreshTokenRepository, msr *tests.MockSessionRepository) {
+			},
+			expectedError: services.ErrPasswordUnchanged,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			mockHasher := &tests.MockHasher{}
+			refreshRepository := &tests.MockRefreshTokenRepository{}
+			sessionRepository := &tests.MockSessionRepository{}
+			tt.setupMocks(mockRepository, mockHasher, refreshRepository, sessionRepository)
+
+			userService := newTestUserService(mockRepository, &tests.MockEmailService{}, mockHasher, refreshRepository, sessionRepository)
+			err := userService.ChangePassword(context.Background(), "validuser", "current", tt.currentPassword, tt.newPassword)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mockRepository.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+				sessionRepository.AssertNotCalled(t, "RevokeSession", mock.Anything, "current")
+			}
+			mockRepository.AssertExpectations(t)
+			sessionRepository.AssertExpectations(t)
+			refreshRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestChangePassword_WrongPasswordsLockTheAccount(t *testing.T) {
+	user := &models.User{Username: "validuser", Password: "stored_hash", Email: "valid@gmail.com"}
+
+	mockRepository := &tests.MockRepository{}
+	mockHasher := &tests.MockHasher{}
+	mockEmailService := &tests.MockEmailService{}
+	mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
+	mockHasher.On("CompareHashAndPassword", []byte("stored_hash"), []byte("wrongpassword")).Return(bcrypt.ErrMismatchedHashAndPassword)
+	mockEmailService.On("SendAccountLockedEmail", "valid@gmail.com", mock.AnythingOfType("time.Time")).Return(nil)
+
+	authService := services.NewAuthService(mockRepository, mockEmailService, mockHasher,
+		&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	authService.SetLoginGuard(services.NewLoginGuard(adapters.NewMemoryAttemptRepository(), config.LoginProtectionConfig{
+		LockoutAfter:    3,
+		LockoutDuration: 15 * time.Minute,
+	}, slog.Default()))
+	userService := services.NewUserService(mockRepository, &tests.MockChatRepository{}, &tests.MockMessageRepository{},
+		mockEmailService, mockHasher, authService, slog.Default(), tests.NoopTracer())
+
+	for i := 0; i < 3; i++ {
+		err := userService.ChangePassword(context.Background(), "validuser", "current", "wrongpassword", "newpassword")
+		assert.ErrorIs(t, err, services.ErrWrongPassword)
+	}
+
+	// The next attempt is refused before the password is looked at.
+	err := userService.ChangePassword(context.Background(), "validuser", "current", "wrongpassword", "newpassword")
+	assert.ErrorIs(t, err, services.ErrAccountLocked)
+
+	mockHasher.AssertNumberOfCalls(t, "CompareHashAndPassword", 3)
+	mockEmailService.AssertExpectations(t)
+	mockRepository.AssertNotCalled(t, "UpdatePassword", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRequestEmailChange_TableDrive(t *testing.T) {
+	user := &models.User{Username: "validuser", Password: "stored_hash", Email: "old@gmail.com"}
+
+	var ts = []struct {
+		name          string
+		newEmail      string
+		setupMocks    func(*tests.MockRepository, *tests.MockEmailService)
+		expectedError error
+	}{
+		{
+			name:     "New address is parked and old one notified",
+			newEmail: "new@gmail.com",
+			setupMocks: func(mr *tests.MockRepository, mes *tests.MockEmailService) {
+				mr.On("GetUserByEmail", mock.Anything, "new@gmail.com").Return((*models.User)(nil), nil)
+
+				var storedHash string
+				mr.On("SetPendingEmail", mock.Anything, "validuser", "new@gmail.com", mock.AnythingOfType("string"), mock.MatchedBy(func(expiresAt time.Time) bool {
+					return expiresAt.After(time.Now())
+				})).Run(func(args mock.Arguments) { storedHash = args.String(3) }).Return(nil)
+
+				// Only the hash of the emailed token may be stored.
+				mes.On("SendEmailChangeVerification", "new@gmail.com", mock.MatchedBy(func(token string) bool {
+					hash := sha256.Sum256([]byte(token))
+					return hex.EncodeToString(hash[:]) == storedHash
+				})).Return(nil)
+				mes.On("SendEmailChangeNotice", "old@gmail.com", "new@gmail.com").Return(nil)
+			},
+		},
+		{
+			name:     "Address is stored lower-case",
+			newEmail: " New.User@Gmail.com ",
+			setupMocks: func(mr *tests.MockRepository, mes *tests.MockEmailService) {
+				mr.On("GetUserByEmail", mock.Anything, "new.user@gmail.com").Return((*models.User)(nil), nil)
+				mr.On("SetPendingEmail", mock.Anything, "validuser", "new.user@gmail.com", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+				mes.On("SendEmailChangeVerification", "new.user@gmail.com", mock.AnythingOfType("string")).Return(nil)
+				mes.On("SendEmailChangeNotice", "old@gmail.com", "new.user@gmail.com").Return(nil)
+			},
+		},
+		{
+			name:     "Address in use with different case",
+			newEmail: "Taken@Gmail.com",
+			setupMocks: func(mr *tests.MockRepository, mes *tests.MockEmailService) {
+				mr.On("GetUserByEmail", mock.Anything, "taken@gmail.com").Return(&models.User{Username: "someone"}, nil)
+			},
+			expectedError: services.ErrEmailTaken,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			mockEmailService := &tests.MockEmailService{}
+			mockHasher := &tests.MockHasher{}
+			mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
+			mockHasher.On("CompareHashAndPassword", []byte("stored_hash"), []byte("correctpassword")).Return(nil)
+			tt.setupMocks(mockRepository, mockEmailService)
+
+			userService := newTestUserService(mockRepository, mockEmailService, mockHasher,
+				&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+			err := userService.RequestEmailChange(context.Background(), "validuser", "correctpassword", tt.newEmail)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mockRepository.AssertNotCalled(t, "SetPendingEmail", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepository.AssertExpectations(t)
+			mockEmailService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConfirmEmailChange(t *testing.T) {
+	const confirmToken = "confirm-token"
+	hash := sha256.Sum256([]byte(confirmToken))
+	tokenHash := hex.EncodeToString(hash[:])
+
+	t.Run("Valid token", func(t *testing.T) {
+		t.Parallel()
+
+		mockRepository := &tests.MockRepository{}
+		mockRepository.On("ConfirmPendingEmail", mock.Anything, tokenHash).Return("validuser", "new@gmail.com", nil)
+
+		userService := newTestUserService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+			&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+		assert.NoError(t, userService.ConfirmEmailChange(context.Background(), confirmToken))
+		mockRepository.AssertExpectations(t)
+	})
+
+	t.Run("Unknown or expired token", func(t *testing.T) {
+		t.Parallel()
+
+		mockRepository := &tests.MockRepository{}
+		mockRepository.On("ConfirmPendingEmail", mock.Anything, tokenHash).Return("", "", nil)
+
+		userService := newTestUserService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+			&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+		assert.ErrorIs(t, userService.ConfirmEmailChange(context.Background(), confirmToken), services.ErrInvalidEmailChangeToken)
+	})
+}
+
+func TestUpdateProfile_TableDrive(t *testing.T) {
+	text := func(s string) *string { return &s }
+
+	var ts = []struct {
+		name          string
+		update        models.ProfileUpdate
+		expectedError error
+	}{
+		{
+			name:   "Valid update",
+			update: models.ProfileUpdate{DisplayName: text("  John Doe "), AvatarURL: text("https://example.com/a.png"), Timezone: text("Europe/Berlin")},
+		},
+		{
+			name:   "Clearing fields",
+			update: models.ProfileUpdate{Bio: text(""), AvatarURL: text("")},
+		},
+		{
+			name:          "Display name too long",
+			update:        models.ProfileUpdate{DisplayName: text(strings.Repeat("a", 65))},
+			expectedError: services.ErrInvalidProfile,
+		},
+		{
+			name:          "Avatar is not a web url",
+			update:        models.ProfileUpdate{AvatarURL: text("javascript:alert(1)")},
+			expectedError: services.ErrInvalidProfile,
+		},
+		{
+			name:          "Unknown timezone",
+			update:        models.ProfileUpdate{Timezone: text("Mars/Olympus")},
+			expectedError: services.ErrInvalidProfile,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			if tt.expectedError == nil {
+				mockRepository.On("UpdateProfile", mock.Anything, "validuser", mock.MatchedBy(func(update models.ProfileUpdate) bool {
+					return update.DisplayName == nil || *update.DisplayName == "John Doe"
+				})).Return(nil)
+				mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(&models.User{Username: "validuser", DisplayName: "John Doe"}, nil)
+			}
+
+			userService := newTestUserService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+				&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+			profile, err := userService.UpdateProfile(context.Background(), "validuser", tt.update)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mockRepository.AssertNotCalled(t, "UpdateProfile", mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "John Doe", profile.DisplayName)
+			mockRepository.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetProfile_HidesAccountDetails(t *testing.T) {
+	mockRepository := &tests.MockRepository{}
+	mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(&models.User{
+		Username: "validuser", Password: "stored_hash", Email: "valid@gmail.com", DisplayName: "John Doe",
+	}, nil)
+
+	userService := newTestUserService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+		&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+	profile, err := userService.GetProfile(context.Background(), "validuser")
+
+	assert.NoError(t, err)
+	data, _ := json.Marshal(profile)
+	assert.NotContains(t, string(data), "valid@gmail.com")
+	assert.NotContains(t, string(data), "stored_hash")
+	assert.Contains(t, string(data), `"display_name":"John Doe"`)
+}
+
+func TestSearchUsers_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name          string
+		query         string
+		limit         int
+		found         int
+		expectedLimit int
+		expectedMore  bool
+		expectedError error
+	}{
+		{
+			name:          "Default page size",
+			query:         "jo",
+			found:         3,
+			expectedLimit: 20,
+		},
+		{
+			name:          "Next page exists",
+			query:         "john",
+			limit:         2,
+			found:         3,
+			expectedLimit: 2,
+			expectedMore:  true,
+		},
+		{
+			name:          "Page size is capped",
+			query:         "john",
+			limit:         1000,
+			expectedLimit: 50,
+		},
+		{
+			name:          "Query too short",
+			query:         " j ",
+			expectedError: services.ErrInvalidInput,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			found := make([]models.Profile, tt.found)
+			mockRepository := &tests.MockRepository{}
+			if tt.expectedError == nil {
+				// The caller is excluded and one extra row is fetched to detect the next page.
+				mockRepository.On("SearchUsers", mock.Anything, strings.TrimSpace(tt.query), "validuser", tt.expectedLimit+1, 0).Return(found, nil)
+			}
+
+			userService := newTestUserService(mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+				&tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{})
+			users, hasMore, err := userService.SearchUsers(context.Background(), "validuser", tt.query, tt.limit, 0)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				mockRepository.AssertNotCalled(t, "SearchUsers", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedMore, hasMore)
+			assert.LessOrEqual(t, len(users), tt.expectedLimit)
+			mockRepository.AssertExpectations(t)
+		})
+	}
+}
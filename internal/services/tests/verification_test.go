@@ -0,0 +1,195 @@
+package services_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"massager/app/config"
+	"massager/app/tests"
+	"massager/internal/adapters"
+	"massager/internal/models"
+	"massager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+var testVerification = config.VerificationConfig{
+	TokenTTL:     24 * time.Hour,
+	ResendLimit:  3,
+	ResendWindow: time.Hour,
+}
+
+func TestVerifyEmail_TableDrive(t *testing.T) {
+	const verifyToken = "verify-token"
+	hash := sha256.Sum256([]byte(verifyToken))
+	tokenHash := hex.EncodeToString(hash[:])
+
+	var ts = []struct {
+		name          string
+		user          *models.User
+		expectVerify  bool
+		expectedError error
+	}{
+		{
+			name:         "Valid token",
+			user:         &models.User{Username: "validuser", VerifyTokenExpiresAt: time.Now().Add(time.Hour)},
+			expectVerify: true,
+		},
+		{
+			name:          "Expired token",
+			user:          &models.User{Username: "validuser", VerifyTokenExpiresAt: time.Now().Add(-time.Minute)},
+			expectedError: services.ErrVerificationTokenExpired,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			// Tokens are looked up by hash, never by their raw value.
+			mockRepository.On("GetUserByVerifyToken", mock.Anything, tokenHash).Return(tt.user, nil)
+			if tt.expectVerify {
+				mockRepository.On("MarkUserAsVerified", mock.Anything, "validuser").Return(nil)
+			}
+
+			var authService = services.NewAuthService(
+				mockRepository, &tests.MockEmailService{}, &tests.MockHasher{},
+				&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+				[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+
+			err := authService.VerifyEmail(context.Background(), verifyToken)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepository.AssertExpectations(t)
+			if !tt.expectVerify {
+				mockRepository.AssertNotCalled(t, "MarkUserAsVerified", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestResendVerification_TableDrive(t *testing.T) {
+	var ts = []struct {
+		name          string
+		email         string
+		username      string
+		previousSends int64
+		setupMocks    func(*tests.MockRepository, *tests.MockEmailService)
+		expectedError error
+	}{
+		{
+			name:  "Unverified account",
+			email: "Valid@gmail.com",
+			setupMocks: func(mr *tests.MockRepository, mes *tests.MockEmailService) {
+				mr.On("GetUserByEmail", mock.Anything, "valid@gmail.com").Return(&models.User{Username: "validuser", Email: "valid@gmail.com"}, nil)
+
+				var storedHash string
+				mr.On("SetVerifyToken", mock.Anything, "validuser", mock.AnythingOfType("string"), mock.MatchedBy(func(expiresAt time.Time) bool {
+					return time.Until(expiresAt) > 23*time.Hour
+				})).Run(func(args mock.Arguments) { storedHash = args.String(2) }).Return(nil)
+
+				mes.On("SendVerificationEmail", "valid@gmail.com", mock.MatchedBy(func(token string) bool {
+					hash := sha256.Sum256([]byte(token))
+					return hex.EncodeToString(hash[:]) == storedHash
+				})).Return(nil)
+			},
+		},
+		{
+			name:     "Unknown account gets the same answer",
+			username: "unknown",
+			setupMocks: func(mr *tests.MockRepository, mes *tests.MockEmailService) {
+				mr.On("GetUserByName", mock.Anything, "unknown").Return((*models.User)(nil), nil)
+			},
+		},
+		{
+			name:  "Already verified account gets the same answer",
+			email: "valid@gmail.com",
+			setupMocks: func(mr *tests.MockRepository, mes *tests.MockEmailService) {
+				mr.On("GetUserByEmail", mock.Anything, "valid@gmail.com").Return(&models.User{Username: "validuser", IsVerefied: true}, nil)
+			},
+		},
+		{
+			name:          "Too many requests",
+			email:         "valid@gmail.com",
+			previousSends: 3,
+			setupMocks:    func(mr *tests.MockRepository, mes *tests.MockEmailService) {},
+			expectedError: services.ErrTooManyAttempts,
+		},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			mockRepository := &tests.MockRepository{}
+			mockEmailService := &tests.MockEmailService{}
+			attempts := &tests.MockAttemptRepository{}
+			tt.setupMocks(mockRepository, mockEmailService)
+
+			blockedUntil := time.Time{}
+			if tt.previousSends >= int64(testVerification.ResendLimit) {
+				blockedUntil = time.Now().Add(30 * time.Minute)
+			}
+			attempts.On("BlockedUntil", mock.Anything, mock.AnythingOfType("string")).Return(blockedUntil, nil)
+			attempts.On("Increment", mock.Anything, mock.AnythingOfType("string"), time.Hour).Return(tt.previousSends+1, nil).Maybe()
+
+			var authService = services.NewAuthService(
+				mockRepository, mockEmailService, &tests.MockHasher{},
+				&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+				[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+			authService.SetVerification(attempts, testVerification)
+
+			err := authService.ResendVerification(context.Background(), tt.email, tt.username)
+
+			if tt.expectedError != nil {
+				assert.ErrorIs(t, err, tt.expectedError)
+				var throttleErr *services.ThrottleError
+				assert.True(t, errors.As(err, &throttleErr))
+				mockRepository.AssertNotCalled(t, "GetUserByEmail", mock.Anything, mock.Anything)
+			} else {
+				assert.NoError(t, err)
+			}
+			mockRepository.AssertExpectations(t)
+			mockEmailService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestResendVerification_AlternatingIdentifiersShareTheLimit(t *testing.T) {
+	user := &models.User{Username: "validuser", Email: "valid@gmail.com"}
+
+	mockRepository := &tests.MockRepository{}
+	mockEmailService := &tests.MockEmailService{}
+	mockRepository.On("GetUserByEmail", mock.Anything, "valid@gmail.com").Return(user, nil)
+	mockRepository.On("GetUserByName", mock.Anything, "validuser").Return(user, nil)
+	mockRepository.On("SetVerifyToken", mock.Anything, "validuser", mock.AnythingOfType("string"), mock.AnythingOfType("time.Time")).Return(nil)
+	mockEmailService.On("SendVerificationEmail", "valid@gmail.com", mock.AnythingOfType("string")).Return(nil)
+
+	var authService = services.NewAuthService(
+		mockRepository, mockEmailService, &tests.MockHasher{},
+		&tests.MockTokenRepository{}, &tests.MockRefreshTokenRepository{}, &tests.MockSessionRepository{},
+		[]byte(JwtKey), slog.Default(), tests.NoopTracer())
+	authService.SetVerification(adapters.NewMemoryAttemptRepository(), testVerification)
+
+	var throttled error
+	for i := 0; i < 2*testVerification.ResendLimit && throttled == nil; i++ {
+		if i%2 == 0 {
+			throttled = authService.ResendVerification(context.Background(), "valid@gmail.com", "")
+		} else {
+			throttled = authService.ResendVerification(context.Background(), "", "validuser")
+		}
+	}
+
+	assert.ErrorIs(t, throttled, services.ErrTooManyAttempts)
+	mockEmailService.AssertNumberOfCalls(t, "SendVerificationEmail", testVerification.ResendLimit)
+}
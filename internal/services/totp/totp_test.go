@@ -0,0 +1,83 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Test vectors from RFC 6238 appendix B (SHA1), truncated to six digits.
+func TestTOTP_RFC6238Vectors(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{unix: 59, code: "287082"},
+		{unix: 1111111109, code: "081804"},
+		{unix: 1111111111, code: "050471"},
+		{unix: 1234567890, code: "005924"},
+		{unix: 2000000000, code: "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := GenerateCode(secret, time.Unix(tt.unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.code, code)
+		assert.True(t, Validate(secret, tt.code, time.Unix(tt.unix, 0)))
+	}
+}
+
+func TestTOTP_Validate(t *testing.T) {
+	secret, err := GenerateSecret()
+	assert.NoError(t, err)
+
+	now := time.Now()
+	code, _ := GenerateCode(secret, now)
+
+	tests := []struct {
+		name   string
+		code   string
+		at     time.Time
+		result bool
+	}{
+		{name: "Current step", code: code, at: now, result: true},
+		{name: "Previous step within skew", code: code, at: now.Add(Period), result: true},
+		{name: "Outside skew", code: code, at: now.Add(3 * Period), result: false},
+		{name: "Wrong length", code: code[:5], at: now, result: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.result, Validate(secret, tt.code, tt.at))
+		})
+	}
+}
+
+func TestTOTP_Match(t *testing.T) {
+	secret := encoding.EncodeToString([]byte("12345678901234567890"))
+	at := time.Unix(1111111109, 0)
+
+	step, ok := Match(secret, "081804", at)
+	assert.True(t, ok)
+	assert.Equal(t, counterAt(at), step)
+
+	// A code from the previous step reports that step, not the current one.
+	step, ok = Match(secret, "081804", at.Add(Period))
+	assert.True(t, ok)
+	assert.Equal(t, counterAt(at), step)
+
+	_, ok = Match(secret, "000000", at)
+	assert.False(t, ok)
+}
+
+func TestTOTP_URI(t *testing.T) {
+	uri := URI("Horizon Messenger", "alice", "JBSWY3DPEHPK3PXP")
+
+	assert.True(t, strings.HasPrefix(uri, "otpauth://totp/Horizon%20Messenger:alice?"))
+	assert.Contains(t, uri, "secret=JBSWY3DPEHPK3PXP")
+	assert.Contains(t, uri, "issuer=Horizon+Messenger")
+}
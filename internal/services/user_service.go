@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"massager/internal/models"
+	"massager/internal/ports"
+	"massager/internal/services/validation"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	ErrWrongPassword             = errors.New("current password is incorrect")
+	ErrEmailTaken                = errors.New("email already in use")
+	ErrInvalidEmailChangeToken   = errors.New("invalid or expired email change token")
+	ErrPasswordUnchanged         = errors.New("new password must differ from the current one")
+	ErrEmailUnchanged            = errors.New("new email must differ from the current one")
+	ErrAccountSettingsIncomplete = errors.New("current password and new value are required")
+)
+
+const emailChangeTokenTTL = 24 * time.Hour
+
+// UserService manages an account's own settings. Session handling stays in
+// AuthService, which UserService calls when credentials change.
+type UserService struct {
+	userRepo     ports.IUserRepository
+	chatRepo     ports.IChatRepository
+	messageRepo  ports.IMessageRepository
+	emailService ports.IEmailService
+	hasher       ports.IHasher
+	authService  *AuthService
+	chatService  *ChatService
+	policy       *validation.Policy
+	logger       *slog.Logger
+	tracer       trace.Tracer
+
+	deletionGracePeriod time.Duration
+}
+
+func NewUserService(userRepo ports.IUserRepository, chatRepo ports.IChatRepository, messageRepo ports.IMessageRepository,
+	emailService ports.IEmailService, hasher ports.IHasher, authService *AuthService, logger *slog.Logger, tracer trace.Tracer) *UserService {
+	return &UserService{
+		userRepo:            userRepo,
+		chatRepo:            chatRepo,
+		messageRepo:         messageRepo,
+		emailService:        emailService,
+		hasher:              hasher,
+		authService:         authService,
+		chatService:         NewChatService(chatRepo, messageRepo, userRepo, logger, tracer),
+		policy:              validation.Default(),
+		logger:              logger,
+		tracer:              tracer,
+		deletionGracePeriod: defaultDeletionGracePeriod,
+	}
+}
+
+// SetChatService replaces the chat service used when a deleted account leaves
+// its groups, so members are notified through its hub.
+func (s *UserService) SetChatService(chatService *ChatService) {
+	s.chatService = chatService
+}
+
+// SetValidationPolicy replaces the default rules for new passwords and emails.
+func (s *UserService) SetValidationPolicy(policy *validation.Policy) {
+	s.policy = policy
+}
+
+// ChangePassword replaces the password after checking the current one and logs
+// out every other session. The session the request came from stays valid.
+func (s *UserService) ChangePassword(ctx context.Context, username, sessionID, currentPassword, newPassword string) error {
+	ctx, span := s.tracer.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	span.SetAttributes(attribute.String("user.username", username))
+
+	if currentPassword == "" || newPassword == "" {
+		span.RecordError(ErrAccountSettingsIncomplete)
+		return ErrAccountSettingsIncomplete
+	}
+	if currentPassword == newPassword {
+		span.RecordError(ErrPasswordUnchanged)
+		return ErrPasswordUnchanged
+	}
+	if err := s.policy.ValidatePassword(newPassword, username); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	if _, err := s.checkPassword(ctx, username, currentPassword); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	hashedPassword, err := s.hasher.GenerateFromPassword([]byte(newPassword), s.hasher.DefaultCost())
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("password hashing failed", "error", err)
+		return errors.New("failed to change password")
+	}
+
+	if err := s.userRepo.UpdatePassword(ctx, username, string(hashedPassword)); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to update password", "username", username, "error", err)
+		return errors.New("failed to change password")
+	}
+
+	if err := s.authService.RevokeOtherSessions(ctx, username, sessionID); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to revoke sessions after password change", "username", username, "error", err)
+		return err
+	}
+
+	span.SetStatus(codes.Ok, "password changed")
+	s.logger.Info("password changed", "username", username)
+	return nil
+}
+
+// RequestEmailChange parks the new address as pending and sends a confirmation
+// link to it. The current address keeps working until the link is opened and
+// is told about the request, so the owner notices a hijacked session.
+func (s *UserService) RequestEmailChange(ctx context.Context, username, currentPassword, newEmail string) error {
+	ctx, span := s.tracer.Start(ctx, "UserService.RequestEmailChange")
+	defer span.End()
+
+	newEmail = normalizeEmail(newEmail)
+	span.SetAttributes(
+		attribute.String("user.username", username),
+		attribute.String("user.new_email", newEmail),
+	)
+
+	if currentPassword == "" || newEmail == "" {
+		span.RecordError(ErrAccountSettingsIncomplete)
+		return ErrAccountSettingsIncomplete
+	}
+	if err := s.policy.ValidateEmail(newEmail); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	user, err := s.checkPassword(ctx, username, currentPassword)
+	if err != nil {
+		span.RecordError(err)
+		return err
+	}
+	if strings.EqualFold(user.Email, newEmail) {
+		span.RecordError(ErrEmailUnchanged)
+		return ErrEmailUnchanged
+	}
+
+	owner, err := s.userRepo.GetUserByEmail(ctx, newEmail)
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to look up user by email", "error", err)
+		return errors.New("failed to change email")
+	}
+	if owner != nil {
+		span.RecordError(ErrEmailTaken)
+		return ErrEmailTaken
+	}
+
+	confirmToken, err := generateSecureToken()
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to generate email change token", "error", err)
+		return errors.New("failed to change email")
+	}
+
+	err = s.userRepo.SetPendingEmail(ctx, username, newEmail, hashToken(confirmToken), time.Now().Add(emailChangeTokenTTL))
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to store pending email", "username", username, "error", err)
+		return errors.New("failed to change email")
+	}
+
+	if err := s.emailService.SendEmailChangeVerification(newEmail, confirmToken); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to send email change verification", "username", username, "error", err)
+		return errors.New("failed to send confirmation email")
+	}
+
+	if err := s.emailService.SendEmailChangeNotice(user.Email, newEmail); err != nil {
+		span.RecordError(err)
+		s.logger.Warn("failed to notify old address about email change", "username", username, "error", err)
+	}
+
+	span.SetStatus(codes.Ok, "email change requested")
+	s.logger.Info("email change requested", "username", username)
+	return nil
+}
+
+// ConfirmEmailChange switches the account to the pending address the token was
+// sent to.
+func (s *UserService) ConfirmEmailChange(ctx context.Context, token string) error {
+	ctx, span := s.tracer.Start(ctx, "UserService.ConfirmEmailChange")
+	defer span.End()
+
+	if token == "" {
+		span.RecordError(ErrInvalidEmailChangeToken)
+		return ErrInvalidEmailChangeToken
+	}
+
+	username, email, err := s.userRepo.ConfirmPendingEmail(ctx, hashToken(token))
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to confirm pending email", "error", err)
+		return errors.New("failed to change email")
+	}
+	if username == "" {
+		span.RecordError(ErrInvalidEmailChangeToken)
+		s.logger.Warn("invalid email change token presented")
+		return ErrInvalidEmailChangeToken
+	}
+
+	span.SetAttributes(attribute.String("user.username", username))
+	span.SetStatus(codes.Ok, "email changed")
+	s.logger.Info("email changed", "username", username, "email", email)
+	return nil
+}
+
+// checkPassword loads the user and confirms the password is theirs.
+func (s *UserService) checkPassword(ctx context.Context, username, password string) (*models.User, error) {
+	// Re-entering the password goes through the same per-user counters as
+	// login, so a stolen session cannot be used to guess it.
+	if err := s.authService.checkLoginAllowed(ctx, username, ""); err != nil {
+		s.logger.Warn("password check rejected", "username", username, "error", err)
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByName(ctx, username)
+	if err != nil {
+		s.logger.Error("failed to get user", "username", username, "error", err)
+		return nil, errors.New("failed to verify password")
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	// Like login, any failed comparison is a wrong password: accounts created
+	// through OIDC, reset by an admin or owned by bots carry a disabled hash
+	// that no hasher accepts.
+	if err := s.hasher.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		s.logger.Warn("wrong current password", "username", username, "error", err)
+		s.authService.recordLoginFailure(ctx, username, "", user)
+		return nil, ErrWrongPassword
+	}
+
+	s.authService.recordLoginSuccess(ctx, username)
+	return user, nil
+}
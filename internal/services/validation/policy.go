@@ -0,0 +1,280 @@
+package validation
+
+import (
+	"bufio"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"massager/app/config"
+	"net/mail"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInvalid matches every Errors value, so callers can use errors.Is without
+// caring which fields failed.
+var ErrInvalid = errors.New("validation failed")
+
+//go:embed banned_passwords.txt
+var builtinBannedPasswords string
+
+const (
+	defaultUsernameMinLength = 3
+	defaultUsernameMaxLength = 32
+	defaultUsernameCharset   = "a-zA-Z0-9._-"
+	defaultEmailMaxLength    = 254
+	defaultPasswordMinLength = 8
+	defaultPasswordMaxLength = 128
+)
+
+var defaultReservedUsernames = []string{"admin", "administrator", "root", "system", "support", "horizon", "me", "api"}
+
+// Errors maps a field name to what is wrong with it.
+type Errors map[string]string
+
+func (e Errors) Error() string {
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	messages := make([]string, len(fields))
+	for i, field := range fields {
+		messages[i] = fmt.Sprintf("%s %s", field, e[field])
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e Errors) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// orNil keeps a nil error from turning into a non-nil interface holding an
+// empty map.
+func (e Errors) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Policy checks usernames, emails and passwords against the configured rules.
+type Policy struct {
+	username config.UsernamePolicyConfig
+	charset  *regexp.Regexp
+	reserved map[string]bool
+	email    config.EmailPolicyConfig
+	password config.PasswordPolicyConfig
+	banned   map[string]bool
+}
+
+// New builds a policy from the config, filling in defaults for zero values.
+func New(cfg config.ValidationConfig) (*Policy, error) {
+	if cfg.Username.MinLength <= 0 {
+		cfg.Username.MinLength = defaultUsernameMinLength
+	}
+	if cfg.Username.MaxLength <= 0 {
+		cfg.Username.MaxLength = defaultUsernameMaxLength
+	}
+	if cfg.Username.Charset == "" {
+		cfg.Username.Charset = defaultUsernameCharset
+	}
+	if cfg.Username.Reserved == nil {
+		cfg.Username.Reserved = defaultReservedUsernames
+	}
+	if cfg.Email.MaxLength <= 0 {
+		cfg.Email.MaxLength = defaultEmailMaxLength
+	}
+	if cfg.Password.MinLength <= 0 {
+		cfg.Password.MinLength = defaultPasswordMinLength
+	}
+	if cfg.Password.MaxLength <= 0 {
+		cfg.Password.MaxLength = defaultPasswordMaxLength
+	}
+
+	charset, err := regexp.Compile("^[" + cfg.Username.Charset + "]+$")
+	if err != nil {
+		return nil, fmt.Errorf("username charset: %w", err)
+	}
+	// Chat members are aggregated into comma-separated lists by the chat queries.
+	if charset.MatchString(",") {
+		return nil, errors.New("username charset must not allow commas")
+	}
+
+	reserved := make(map[string]bool, len(cfg.Username.Reserved))
+	for _, name := range cfg.Username.Reserved {
+		reserved[strings.ToLower(name)] = true
+	}
+
+	banned := make(map[string]bool)
+	if err := readBannedPasswords(strings.NewReader(builtinBannedPasswords), banned); err != nil {
+		return nil, err
+	}
+	if cfg.Password.BannedListFile != "" {
+		file, err := os.Open(cfg.Password.BannedListFile)
+		if err != nil {
+			return nil, fmt.Errorf("banned password list: %w", err)
+		}
+		defer file.Close()
+
+		if err := readBannedPasswords(file, banned); err != nil {
+			return nil, fmt.Errorf("banned password list: %w", err)
+		}
+	}
+
+	return &Policy{
+		username: cfg.Username,
+		charset:  charset,
+		reserved: reserved,
+		email:    cfg.Email,
+		password: cfg.Password,
+		banned:   banned,
+	}, nil
+}
+
+// Default is the policy used when none is configured.
+func Default() *Policy {
+	policy, err := New(config.ValidationConfig{})
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+// ValidateRegistration checks every field of a new account and reports all
+// failures at once.
+func (p *Policy) ValidateRegistration(username, email, password string) error {
+	errs := Errors{}
+	if msg := p.usernameProblem(username); msg != "" {
+		errs["username"] = msg
+	}
+	if msg := p.emailProblem(email); msg != "" {
+		errs["email"] = msg
+	}
+	if msg := p.passwordProblem(password, username); msg != "" {
+		errs["password"] = msg
+	}
+	return errs.orNil()
+}
+
+func (p *Policy) ValidateUsername(username string) error {
+	if msg := p.usernameProblem(username); msg != "" {
+		return Errors{"username": msg}
+	}
+	return nil
+}
+
+// UsernameMaxLength is the longest username the policy accepts.
+func (p *Policy) UsernameMaxLength() int {
+	return p.username.MaxLength
+}
+
+func (p *Policy) ValidateEmail(email string) error {
+	if msg := p.emailProblem(email); msg != "" {
+		return Errors{"email": msg}
+	}
+	return nil
+}
+
+// ValidatePassword checks a password for the given account; username may be
+// empty when it is not known yet.
+func (p *Policy) ValidatePassword(password, username string) error {
+	if msg := p.passwordProblem(password, username); msg != "" {
+		return Errors{"password": msg}
+	}
+	return nil
+}
+
+func (p *Policy) usernameProblem(username string) string {
+	length := utf8.RuneCountInString(username)
+	switch {
+	case length < p.username.MinLength || length > p.username.MaxLength:
+		return fmt.Sprintf("must be between %d and %d characters", p.username.MinLength, p.username.MaxLength)
+	case !p.charset.MatchString(username):
+		return fmt.Sprintf("may only contain the characters [%s]", p.username.Charset)
+	case p.reserved[strings.ToLower(username)]:
+		return "is reserved"
+	}
+	return ""
+}
+
+// emailProblem accepts the bare addr-spec form of RFC 5322. Display names,
+// comments and quoted local parts are rejected.
+func (p *Policy) emailProblem(email string) string {
+	if len(email) > p.email.MaxLength {
+		return fmt.Sprintf("must be at most %d characters", p.email.MaxLength)
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return "is not a valid address"
+	}
+	return ""
+}
+
+func (p *Policy) passwordProblem(password, username string) string {
+	length := utf8.RuneCountInString(password)
+	if length < p.password.MinLength || length > p.password.MaxLength {
+		return fmt.Sprintf("must be between %d and %d characters", p.password.MinLength, p.password.MaxLength)
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			hasSymbol = true
+		}
+	}
+
+	var missing []string
+	if p.password.RequireUpper && !hasUpper {
+		missing = append(missing, "an uppercase letter")
+	}
+	if p.password.RequireLower && !hasLower {
+		missing = append(missing, "a lowercase letter")
+	}
+	if p.password.RequireDigit && !hasDigit {
+		missing = append(missing, "a digit")
+	}
+	if p.password.RequireSymbol && !hasSymbol {
+		missing = append(missing, "a symbol")
+	}
+	if len(missing) > 0 {
+		return "must contain " + strings.Join(missing, ", ")
+	}
+
+	lowered := strings.ToLower(password)
+	if username != "" && lowered == strings.ToLower(username) {
+		return "must not match the username"
+	}
+	if p.banned[lowered] {
+		return "is too common"
+	}
+	return ""
+}
+
+// readBannedPasswords adds one password per line; blank lines and lines
+// starting with # are skipped.
+func readBannedPasswords(r io.Reader, banned map[string]bool) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		banned[strings.ToLower(line)] = true
+	}
+	return scanner.Err()
+}
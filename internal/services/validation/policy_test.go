@@ -0,0 +1,138 @@
+package validation
+
+import (
+	"errors"
+	"massager/app/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicy_Username(t *testing.T) {
+	policy := Default()
+
+	ts := []struct {
+		name     string
+		username string
+		expected string
+	}{
+		{name: "Valid", username: "valid.user-1"},
+		{name: "Too short", username: "ab", expected: "must be between 3 and 32 characters"},
+		{name: "Too long", username: strings.Repeat("a", 33), expected: "must be between 3 and 32 characters"},
+		{name: "Comma", username: "alice,bob", expected: "may only contain the characters [a-zA-Z0-9._-]"},
+		{name: "Space", username: "alice bob", expected: "may only contain the characters [a-zA-Z0-9._-]"},
+		{name: "Reserved in any case", username: "ROOT", expected: "is reserved"},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.ValidateUsername(tt.username)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var fieldErrs Errors
+			require.True(t, errors.As(err, &fieldErrs))
+			assert.Equal(t, Errors{"username": tt.expected}, fieldErrs)
+			assert.ErrorIs(t, err, ErrInvalid)
+		})
+	}
+}
+
+func TestPolicy_Email(t *testing.T) {
+	policy := Default()
+
+	ts := []struct {
+		name  string
+		email string
+		valid bool
+	}{
+		{name: "Plain address", email: "user@example.com", valid: true},
+		{name: "Plus and dots", email: "first.last+tag@mail.example.org", valid: true},
+		{name: "Missing domain", email: "user@", valid: false},
+		{name: "Missing at", email: "user.example.com", valid: false},
+		{name: "Display name", email: "User <user@example.com>", valid: false},
+		{name: "Two ats", email: "user@@example.com", valid: false},
+		{name: "Too long", email: strings.Repeat("a", 250) + "@example.com", valid: false},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.ValidateEmail(tt.email)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalid)
+			}
+		})
+	}
+}
+
+func TestPolicy_Password(t *testing.T) {
+	policy, err := New(config.ValidationConfig{Password: config.PasswordPolicyConfig{
+		MinLength:     10,
+		RequireUpper:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}})
+	require.NoError(t, err)
+
+	ts := []struct {
+		name     string
+		password string
+		username string
+		expected string
+	}{
+		{name: "Valid", password: "Correct-Horse-7"},
+		{name: "Too short", password: "Ab-1", expected: "must be between 10 and 128 characters"},
+		{name: "Missing classes", password: "correcthorsebattery", expected: "must contain an uppercase letter, a digit, a symbol"},
+		{name: "Matches username", password: "Validuser-1", username: "validuser-1", expected: "must not match the username"},
+	}
+
+	for _, tt := range ts {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.ValidatePassword(tt.password, tt.username)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Equal(t, Errors{"password": tt.expected}, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_BannedPasswords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "banned.txt")
+	require.NoError(t, os.WriteFile(path, []byte("# local list\nHorizonRocks\n\n"), 0o600))
+
+	policy, err := New(config.ValidationConfig{Password: config.PasswordPolicyConfig{BannedListFile: path}})
+	require.NoError(t, err)
+
+	assert.Equal(t, Errors{"password": "is too common"}, policy.ValidatePassword("Password1", ""))
+	assert.Equal(t, Errors{"password": "is too common"}, policy.ValidatePassword("horizonrocks", ""))
+	assert.NoError(t, policy.ValidatePassword("horizon-rocks-2", ""))
+}
+
+func TestPolicy_RegistrationReportsEveryField(t *testing.T) {
+	err := Default().ValidateRegistration("a", "nope", "short")
+
+	assert.Equal(t, Errors{
+		"username": "must be between 3 and 32 characters",
+		"email":    "is not a valid address",
+		"password": "must be between 8 and 128 characters",
+	}, err)
+	assert.Equal(t, "email is not a valid address; password must be between 8 and 128 characters; username must be between 3 and 32 characters", err.Error())
+}
+
+func TestNew_RejectsCommasInCharset(t *testing.T) {
+	_, err := New(config.ValidationConfig{Username: config.UsernamePolicyConfig{Charset: "a-z,"}})
+	assert.Error(t, err)
+
+	_, err = New(config.ValidationConfig{Username: config.UsernamePolicyConfig{Charset: "z-a"}})
+	assert.Error(t, err)
+}
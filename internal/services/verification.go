@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"massager/app/config"
+	"massager/internal/models"
+	"massager/internal/ports"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var ErrVerificationTokenExpired = errors.New("verification token expired, please request a new one")
+
+const defaultVerificationTokenTTL = 24 * time.Hour
+
+// SetVerification configures verification token lifetime and resend throttling.
+func (s *AuthService) SetVerification(attempts ports.AttemptRepository, cfg config.VerificationConfig) {
+	s.attempts = attempts
+	if cfg.TokenTTL <= 0 {
+		cfg.TokenTTL = defaultVerificationTokenTTL
+	}
+	s.verification = cfg
+}
+
+// ResendVerification emails a fresh verification link to an unverified account,
+// found by email or, if no email is given, by username. The result does not
+// depend on whether the account exists, so callers learn nothing about it.
+func (s *AuthService) ResendVerification(ctx context.Context, email, username string) error {
+	ctx, span := s.tracer.Start(ctx, "AuthService.ResendVerification")
+	defer span.End()
+
+	email = normalizeEmail(email)
+	span.SetAttributes(
+		attribute.String("user.email", email),
+		attribute.String("user.username", username),
+	)
+
+	if email == "" && username == "" {
+		err := errors.New("email or username is required")
+		span.RecordError(err)
+		return err
+	}
+
+	throttleKey := "verification_resend:user:" + username
+	if email != "" {
+		throttleKey = "verification_resend:email:" + email
+	}
+	if err := s.throttleResend(ctx, throttleKey); err != nil {
+		span.RecordError(err)
+		return err
+	}
+
+	var user *models.User
+	var err error
+	if email != "" {
+		user, err = s.userRepo.GetUserByEmail(ctx, email)
+	} else {
+		user, err = s.userRepo.GetUserByName(ctx, username)
+	}
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to look up user for verification resend", "error", err)
+		return errors.New("failed to resend verification email")
+	}
+
+	if user == nil || user.IsVerefied {
+		span.SetStatus(codes.Ok, "nothing to resend")
+		s.logger.Info("verification resend requested for unknown or verified account")
+		return nil
+	}
+
+	// A request by username is also counted against the account's address, so
+	// alternating email and username does not get past the per-address limit.
+	if addressKey := "verification_resend:email:" + normalizeEmail(user.Email); addressKey != throttleKey {
+		if err := s.throttleResend(ctx, addressKey); err != nil {
+			span.RecordError(err)
+			return err
+		}
+	}
+
+	verifyToken, err := generateSecureToken()
+	if err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to generate verification token", "error", err)
+		return errors.New("failed to resend verification email")
+	}
+
+	expiresAt := time.Now().Add(s.verification.TokenTTL)
+	if err := s.userRepo.SetVerifyToken(ctx, user.Username, hashToken(verifyToken), expiresAt); err != nil {
+		span.RecordError(err)
+		s.logger.Error("failed to store verification token", "username", user.Username, "error", err)
+		return errors.New("failed to resend verification email")
+	}
+
+	if err := s.emailService.SendVerificationEmail(user.Email, verifyToken); err != nil {
+		span.RecordError(err)
+		s.logger.Warn("failed to send verification email", "username", user.Username, "error", err)
+	}
+
+	span.SetStatus(codes.Ok, "verification email resent")
+	s.logger.Info("verification email resent", "username", user.Username)
+	return nil
+}
+
+// throttleResend counts resend requests per address, whether or not an account
+// uses it, and returns a *ThrottleError once the limit is reached.
+func (s *AuthService) throttleResend(ctx context.Context, key string) error {
+	if s.attempts == nil || s.verification.ResendLimit <= 0 {
+		return nil
+	}
+
+	blockedUntil, err := s.attempts.BlockedUntil(ctx, key)
+	if err != nil {
+		s.logger.Error("resend throttle check failed", "error", err)
+		return errors.New("failed to resend verification email")
+	}
+	if wait := time.Until(blockedUntil); wait > 0 {
+		return &ThrottleError{Err: ErrTooManyAttempts, RetryAfter: wait}
+	}
+
+	count, err := s.attempts.Increment(ctx, key, s.verification.ResendWindow)
+	if err != nil {
+		s.logger.Error("resend throttle update failed", "error", err)
+		return errors.New("failed to resend verification email")
+	}
+
+	if count >= int64(s.verification.ResendLimit) {
+		if err := s.attempts.Block(ctx, key, time.Now().Add(s.verification.ResendWindow)); err != nil {
+			s.logger.Error("resend throttle update failed", "error", err)
+		}
+		if err := s.attempts.Reset(ctx, key); err != nil {
+			s.logger.Error("resend throttle update failed", "error", err)
+		}
+	}
+
+	return nil
+}
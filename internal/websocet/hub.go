@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"strconv"
 	"sync"
+	"time"
 
 	"massager/internal/models"
 	"massager/internal/ports"
@@ -36,6 +37,8 @@ type Client struct {
 	Send    chan []byte
 	UserID  string
 	ChatIDs map[int]bool
+	// ReadOnly clients (API keys without messages:write) may not send messages.
+	ReadOnly bool
 }
 
 type Hub struct {
@@ -198,10 +201,21 @@ func (c *Client) ReadPump() {
 			"chatID", chatID,
 			"sender", c.UserID)
 
+		if msgType == "message" && c.ReadOnly {
+			errorMsg := map[string]interface{}{
+				"type":    "error",
+				"error":   "api key lacks the messages:write scope",
+				"chat_id": chatID,
+			}
+			errorData, _ := json.Marshal(errorMsg)
+			c.Send <- errorData
+			continue
+		}
+
 		if msgType == "message" {
 			content, _ := rawMsg["content"].(string)
 
-			err := c.Hub.ChatService.SendMessage(context.Background(), c.UserID, content, chatID)
+			sent, err := c.Hub.ChatService.SendMessage(context.Background(), c.UserID, content, chatID)
 			if err != nil {
 				c.Hub.Logger.Error("Failed to send message",
 					"error", err,
@@ -223,15 +237,29 @@ func (c *Client) ReadPump() {
 			encryptedContent, _ := keying.Encrypt(key, content)
 
 			msg := models.Message{
-				Type:    "message",
-				ChatID:  chatID,
-				Sender:  c.UserID,
-				Content: encryptedContent,
-				Key:     key,
+				Type:              "message",
+				ChatID:            chatID,
+				Sender:            c.UserID,
+				SenderDisplayName: sent.SenderDisplayName,
+				Content:           encryptedContent,
+				Timestamp:         sent.Timestamp,
+				Key:               key,
 			}
 
 			c.Hub.Broadcast <- msg
 		} else if msgType == "join_chat" {
+			isMember, err := c.Hub.ChatService.IsChatMember(context.Background(), chatID, c.UserID)
+			if err != nil || !isMember {
+				errorMsg := map[string]interface{}{
+					"type":    "error",
+					"error":   "You are not a member of this chat or chat doesn't exist",
+					"chat_id": chatID,
+				}
+				errorData, _ := json.Marshal(errorMsg)
+				c.Send <- errorData
+				continue
+			}
+
 			msg := models.Message{
 				Type:   "join_chat",
 				ChatID: chatID,
@@ -266,6 +294,60 @@ func (h *Hub) BroadcastToUser(userID string, message map[string]interface{}) {
 	}
 }
 
+// JoinChatRoom subscribes a connected user to the chat's messages. Users who
+// are offline join when they next send join_chat.
+func (h *Hub) JoinChatRoom(chatID int, userID string) {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	client, exists := h.Clients[userID]
+	if !exists {
+		return
+	}
+
+	if h.ChatRooms[chatID] == nil {
+		h.ChatRooms[chatID] = make(map[string]bool)
+	}
+	h.ChatRooms[chatID][userID] = true
+	client.ChatIDs[chatID] = true
+	h.Logger.Info("User joined chat", "userID", userID, "chatID", chatID)
+}
+
+// LeaveChatRoom stops delivering the chat's messages to the user.
+func (h *Hub) LeaveChatRoom(chatID int, userID string) {
+	h.Mutex.Lock()
+	defer h.Mutex.Unlock()
+
+	if users, ok := h.ChatRooms[chatID]; ok {
+		delete(users, userID)
+		if len(users) == 0 {
+			delete(h.ChatRooms, chatID)
+		}
+	}
+	if client, exists := h.Clients[userID]; exists {
+		delete(client.ChatIDs, chatID)
+	}
+	h.Logger.Info("User left chat", "userID", userID, "chatID", chatID)
+}
+
+// DisconnectUser closes the user's connection. The read pump then fails and
+// unregisters the client through the usual path.
+func (h *Hub) DisconnectUser(userID string) {
+	h.Mutex.RLock()
+	defer h.Mutex.RUnlock()
+
+	client, exists := h.Clients[userID]
+	if !exists {
+		h.Logger.Debug("User not connected", "userID", userID)
+		return
+	}
+
+	closeMessage := websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "session revoked")
+	client.Conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(time.Second))
+	client.Conn.Close()
+	h.Logger.Info("Client disconnected", "userID", userID)
+}
+
 func (c *Client) WritePump() {
 	defer func() {
 		c.Conn.Close()